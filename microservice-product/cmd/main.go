@@ -1,11 +1,16 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"projet/internal/config"
 	"projet/internal/db"
+	"projet/internal/monnaie"
+	"projet/internal/repository"
+	"projet/internal/service"
 	"projet/routes"
+	"time"
 )
 
 func main() {
@@ -14,6 +19,10 @@ func main() {
 		log.Fatalf("Failed to load configuration: %v", err)
 	}
 
+	if err := monnaie.DefinirFormat(cfg.FormatMontants); err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
 	database, err := db.Connect(cfg)
 	if err != nil {
 		log.Fatalf("Failed to connect to database: %v", err)
@@ -23,7 +32,21 @@ func main() {
 	sqlDB, _ := database.DB()
 	defer sqlDB.Close()
 
-	app := routes.NewRouter(database)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// un seul service webhooks partagé par tous ceux qui publient des événements ;
+	// son worker reprend les réessais et les envois interrompus par un arrêt
+	webhookService := service.NewWebhookService(repository.NewWebhookRepo(database), nil, service.BackoffExponentiel(cfg.DelaiWebhook))
+	go webhookService.Demarrer(ctx, 15*time.Second)
+
+	// publication / dépublication programmées et purge de la corbeille
+	auditService := service.NewAuditService(repository.NewAuditRepo(database))
+	revisionService := service.NewRevisionService(repository.NewRevisionRepo(database))
+	produitService := service.NewProduitService(repository.NewRepo(database), webhookService, auditService, revisionService)
+	go service.NewPlanificateur(produitService, cfg.IntervallePlanificateur, cfg.RetentionCorbeille).Demarrer(ctx)
+
+	app := routes.NewRouter(database, webhookService, cfg.ExigerIfMatch, cfg.SecretJWT)
 	addr := fmt.Sprintf(":%s", cfg.ServerPort)
 	if err := app.Listen(addr); err != nil {
 		log.Fatalf("Failed to start server: %v", err)
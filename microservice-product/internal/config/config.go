@@ -3,6 +3,8 @@ package config
 import (
 	"fmt"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -19,6 +21,19 @@ type Config struct {
 
 	// Server: 8000 par exemple
 	ServerPort string
+
+	// Planificateur de publication: 1m par défaut
+	IntervallePlanificateur time.Duration
+	// Durée de conservation de la corbeille avant purge: 30 jours par défaut, 0 = jamais
+	RetentionCorbeille time.Duration
+	// If-Match obligatoire sur PUT/PATCH/DELETE (428 sinon): false par défaut
+	ExigerIfMatch bool
+	// Prix dans les réponses: "chaine" ("19.99", par défaut) ou "unites_mineures" (1999)
+	FormatMontants string
+	// Secret HS256 des jetons (claim "groupe"): vide = Authorization ignoré
+	SecretJWT string
+	// Délai avant le premier réessai d'un webhook, doublé à chaque essai: 2s par défaut
+	DelaiWebhook time.Duration
 }
 
 func Load() (Config, error) {
@@ -66,6 +81,56 @@ func Load() (Config, error) {
 		return Config{}, err
 	}
 
+	// optionnel, format time.ParseDuration (30s, 5m...)
+	intervalle := time.Minute
+	if val := os.Getenv("PLANIFICATEUR_INTERVALLE"); val != "" {
+		intervalle, err = time.ParseDuration(val)
+		if err != nil || intervalle <= 0 {
+			return Config{}, fmt.Errorf("invalid PLANIFICATEUR_INTERVALLE %q", val)
+		}
+	}
+
+	// optionnel, en jours
+	retentionJours := 30
+	if val := os.Getenv("CORBEILLE_RETENTION_JOURS"); val != "" {
+		retentionJours, err = strconv.Atoi(val)
+		if err != nil || retentionJours < 0 {
+			return Config{}, fmt.Errorf("invalid CORBEILLE_RETENTION_JOURS %q", val)
+		}
+	}
+
+	// optionnel, true/false
+	exigerIfMatch := false
+	if val := os.Getenv("EXIGER_IF_MATCH"); val != "" {
+		exigerIfMatch, err = strconv.ParseBool(val)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid EXIGER_IF_MATCH %q", val)
+		}
+	}
+
+	// optionnel, chaine / unites_mineures (en unites_mineures, les montants
+	// des requêtes restent des chaînes décimales : voir handler.verifierMontants)
+	formatMontants := os.Getenv("FORMAT_MONTANTS")
+	if formatMontants == "" {
+		formatMontants = "chaine"
+	}
+	if formatMontants != "chaine" && formatMontants != "unites_mineures" {
+		return Config{}, fmt.Errorf("invalid FORMAT_MONTANTS %q", formatMontants)
+	}
+
+	// optionnel, sans secret le groupe de clients vient de X-Groupe-Client ;
+	// avec secret, seulement du jeton Bearer vérifié
+	secretJWT := os.Getenv("JWT_SECRET")
+
+	// optionnel, format time.ParseDuration
+	delaiWebhook := 2 * time.Second
+	if val := os.Getenv("WEBHOOK_DELAI_INITIAL"); val != "" {
+		delaiWebhook, err = time.ParseDuration(val)
+		if err != nil || delaiWebhook <= 0 {
+			return Config{}, fmt.Errorf("invalid WEBHOOK_DELAI_INITIAL %q", val)
+		}
+	}
+
 	return Config{
 		DBHost:     dbHost,
 		DBPort:     dbPort,
@@ -74,6 +139,13 @@ func Load() (Config, error) {
 		DBName:     dbName,
 		DBSSLMode:  dbSSLMode,
 		ServerPort: serverPort,
+
+		IntervallePlanificateur: intervalle,
+		RetentionCorbeille:      time.Duration(retentionJours) * 24 * time.Hour,
+		ExigerIfMatch:           exigerIfMatch,
+		FormatMontants:          formatMontants,
+		SecretJWT:               secretJWT,
+		DelaiWebhook:            delaiWebhook,
 	}, nil
 }
 
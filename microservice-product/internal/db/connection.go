@@ -6,6 +6,8 @@ import (
 	"log"
 	"projet/internal/config"
 	"projet/internal/models"
+	"projet/internal/repository"
+	"strings"
 	"time"
 
 	"gorm.io/driver/postgres"
@@ -34,7 +36,22 @@ func Connect(cfg config.Config) (*gorm.DB, error) {
 	}
 
 	//l Auto migration ti creati table si n'xiste pas. Automatiquement.
-	db.AutoMigrate(&models.Produit{}, &models.OptionProduit{}, &models.ValeurOption{}, &models.Variante{})
+	db.AutoMigrate(&models.Produit{}, &models.OptionProduit{}, &models.ValeurOption{}, &models.Variante{},
+		&models.AbonnementWebhook{}, &models.LivraisonWebhook{}, &models.JournalAudit{},
+		&models.RevisionProduit{}, &models.ModeleOption{}, &models.ValeurModeleOption{},
+		&models.ConfigurationSKU{}, &models.ListePrix{}, &models.PrixListe{}, &models.TauxChange{},
+		&models.PalierQuantite{}, &models.GroupeClient{}, &models.RegleGroupe{},
+		&models.ClasseTaxe{}, &models.TauxTaxe{}, &models.ParametresTaxe{}, &models.AjustementPrix{})
+
+	if err := migrerUniciteSKU(db); err != nil {
+		return nil, err
+	}
+	if err := migrerCodesBarres(db); err != nil {
+		return nil, err
+	}
+	if err := migrerTarifsVariantes(db); err != nil {
+		return nil, err
+	}
 
 	//récupération de la connexion behind the scenes.
 	sqlDB, err := db.DB()
@@ -52,6 +69,98 @@ func Connect(cfg config.Config) (*gorm.DB, error) {
 	return db, nil
 }
 
+// migrerUniciteSKU : le SKU était unique sur toute la table des variantes ;
+// il l'est désormais par boutique, produits et variantes confondus.
+// L'index des produits est posé à part : des doublons déjà en base le
+// feraient échouer, et un échec dans AutoMigrate bloquerait les modèles suivants.
+// Les doublons (produit/produit ou produit/variante) bloquent le démarrage et
+// sont listés pour être corrigés à la main.
+func migrerUniciteSKU(db *gorm.DB) error {
+	etapes := []string{
+		`DROP INDEX IF EXISTS idx_variantes_sku`,
+		`UPDATE variantes v SET boutique_id = p.boutique_id FROM produits p
+			WHERE p.id = v.produit_id AND v.boutique_id IS NULL`,
+	}
+	for _, sql := range etapes {
+		if err := db.Exec(sql).Error; err != nil {
+			return fmt.Errorf("migration unicité SKU: %w", err)
+		}
+	}
+
+	var doublons []string
+	if err := db.Raw(`SELECT boutique_id || ' ' || sku FROM (
+			SELECT boutique_id, sku FROM produits WHERE sku IS NOT NULL
+			UNION ALL
+			SELECT boutique_id, sku FROM variantes
+		) skus
+		GROUP BY boutique_id, sku HAVING COUNT(*) > 1`).Scan(&doublons).Error; err != nil {
+		return fmt.Errorf("migration unicité SKU: %w", err)
+	}
+	if len(doublons) > 0 {
+		return fmt.Errorf("migration unicité SKU: %d SKU en double (boutique sku) : %s",
+			len(doublons), strings.Join(doublons, ", "))
+	}
+
+	if err := db.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_sku_produit_boutique
+		ON produits (boutique_id, sku) WHERE sku IS NOT NULL`).Error; err != nil {
+		return fmt.Errorf("migration unicité SKU: %w", err)
+	}
+	return nil
+}
+
+// migrerCodesBarres rend le code-barres unique par boutique, comparé sur le
+// GTIN normalisé à 14 chiffres comme repository.codesBarresPris. Les codes
+// vides deviennent NULL ; des doublons déjà en base bloquent le démarrage et
+// sont listés pour être corrigés à la main.
+func migrerCodesBarres(db *gorm.DB) error {
+	if err := db.Exec(`UPDATE variantes SET code_barres = NULL WHERE code_barres = ''`).Error; err != nil {
+		return fmt.Errorf("migration codes-barres: %w", err)
+	}
+
+	var doublons []string
+	if err := db.Raw(`SELECT boutique_id || ' ' || LPAD(code_barres, 14, '0') FROM variantes
+		WHERE code_barres IS NOT NULL
+		GROUP BY boutique_id, LPAD(code_barres, 14, '0') HAVING COUNT(*) > 1`).Scan(&doublons).Error; err != nil {
+		return fmt.Errorf("migration codes-barres: %w", err)
+	}
+	if len(doublons) > 0 {
+		return fmt.Errorf("migration codes-barres: %d code(s) en double (boutique gtin) : %s",
+			len(doublons), strings.Join(doublons, ", "))
+	}
+
+	if err := db.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS ` + repository.IndexCodeBarresVariante + `
+		ON variantes (boutique_id, LPAD(code_barres, 14, '0')) WHERE code_barres IS NOT NULL`).Error; err != nil {
+		return fmt.Errorf("migration codes-barres: %w", err)
+	}
+	return nil
+}
+
+// migrerTarifsVariantes pose les clés étrangères prix_listes / palier_quantites
+// -> variantes, après avoir retiré les lignes déjà orphelines. Elles sont
+// différées à la fin de la transaction : la restauration d'une révision
+// supprime puis recrée les variantes avec les mêmes identifiants.
+func migrerTarifsVariantes(db *gorm.DB) error {
+	for _, table := range []string{"prix_listes", "palier_quantites"} {
+		contrainte := "fk_" + table + "_variante"
+		etapes := []string{
+			`DELETE FROM ` + table + ` t WHERE t.variante_id IS NOT NULL
+				AND NOT EXISTS (SELECT 1 FROM variantes v WHERE v.id = t.variante_id)`,
+			`DO $$ BEGIN
+				IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = '` + contrainte + `') THEN
+					ALTER TABLE ` + table + ` ADD CONSTRAINT ` + contrainte + `
+						FOREIGN KEY (variante_id) REFERENCES variantes (id) DEFERRABLE INITIALLY DEFERRED;
+				END IF;
+			END $$`,
+		}
+		for _, sql := range etapes {
+			if err := db.Exec(sql).Error; err != nil {
+				return fmt.Errorf("migration clé étrangère %s: %w", contrainte, err)
+			}
+		}
+	}
+	return nil
+}
+
 func Disconnect(db *gorm.DB) error {
 	//idhekeni nil ma3andik matskkr
 	if db == nil {
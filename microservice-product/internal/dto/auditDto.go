@@ -0,0 +1,25 @@
+package dto
+
+import (
+	"encoding/json"
+	"projet/internal/models"
+	"time"
+)
+
+type JournalAuditResponse struct {
+	ID         string             `json:"id"`
+	BoutiqueID string             `json:"boutique_id"`
+	ProduitID  *string            `json:"produit_id,omitempty"`
+	Acteur     string             `json:"acteur"`
+	TypeEntite string             `json:"type_entite"`
+	EntiteID   string             `json:"entite_id"`
+	Action     models.ActionAudit `json:"action"`
+	Diff       json.RawMessage    `json:"diff"`
+	CreeLe     time.Time          `json:"cree_le"`
+}
+
+// un champ modifié dans le diff d'audit
+type ChangementChamp struct {
+	Avant interface{} `json:"avant"`
+	Apres interface{} `json:"apres"`
+}
@@ -0,0 +1,49 @@
+package dto
+
+import (
+	"projet/internal/monnaie"
+	"time"
+)
+
+// RequeteCreationGroupe : code en minuscules, sans espace (grossiste, vip...)
+type RequeteCreationGroupe struct {
+	Code string `json:"code" validate:"required,min=1,max=50,excludesall= ,"`
+	Nom  string `json:"nom"  validate:"required,min=1,max=100"`
+}
+
+type RequeteUpdateGroupe struct {
+	Nom *string `json:"nom" validate:"omitempty,min=1,max=100"`
+}
+
+// RequeteRegleGroupe : une cible au plus (variante_id avec son produit_id),
+// aucune = tout le catalogue ; montant (prix imposé, dans la devise du
+// produit) ou pourcentage (-15 = 15 % de remise)
+type RequeteRegleGroupe struct {
+	ProduitID   *string          `json:"produit_id"  validate:"omitempty,uuid"`
+	VarianteID  *string          `json:"variante_id" validate:"omitempty,uuid"`
+	Marque      *string          `json:"marque"      validate:"omitempty,min=1,max=255"`
+	Montant     *monnaie.Montant `json:"montant"     validate:"omitempty,min=0"`
+	Pourcentage *float64         `json:"pourcentage" validate:"omitempty,gt=-100,lte=1000"`
+}
+
+// RequeteReglesGroupe remplace toutes les règles du groupe
+type RequeteReglesGroupe struct {
+	Regles []RequeteRegleGroupe `json:"regles" validate:"max=500,dive"`
+}
+
+type RegleGroupeResponse struct {
+	ProduitID   *string          `json:"produit_id,omitempty"`
+	VarianteID  *string          `json:"variante_id,omitempty"`
+	Marque      *string          `json:"marque,omitempty"`
+	Montant     *monnaie.Montant `json:"montant,omitempty"`
+	Pourcentage *float64         `json:"pourcentage,omitempty"`
+}
+
+type GroupeClientResponse struct {
+	ID         string                `json:"id"`
+	Code       string                `json:"code"`
+	Nom        string                `json:"nom"`
+	CreeLe     time.Time             `json:"cree_le"`
+	MisAJourLe time.Time             `json:"mis_a_jour_le"`
+	Regles     []RegleGroupeResponse `json:"regles,omitempty"`
+}
@@ -0,0 +1,57 @@
+package dto
+
+import "projet/internal/models"
+
+const (
+	CibleProduit  = "produit"
+	CibleVariante = "variante"
+
+	ActionMasseModifier    = "modifier"
+	ActionMasseStatut      = "statut"
+	ActionMasseAjusterPrix = "ajuster_prix"
+	ActionMasseSupprimer   = "supprimer"
+	ActionMasseStock       = "stock"
+)
+
+// OperationMasse : une action sur un produit ou une variante.
+// Seul le champ correspondant à l'action est lu (produit/variante pour
+// modifier, statut, pourcentage, quantite_stock).
+type OperationMasse struct {
+	Cible       string                 `json:"cible"          validate:"required,oneof=produit variante"`
+	ID          string                 `json:"id"             validate:"required,uuid"`
+	Action      string                 `json:"action"         validate:"required,oneof=modifier statut ajuster_prix supprimer stock"`
+	Produit     *RequeteUpdateProduit  `json:"produit"`
+	Variante    *RequeteUpdateVariante `json:"variante"`
+	Statut      *models.StatutProduit  `json:"statut"         validate:"omitempty,oneof=brouillon publie archive"`
+	Pourcentage *float64               `json:"pourcentage"    validate:"omitempty,gt=-100,lte=1000"`
+	Stock       *int                   `json:"quantite_stock" validate:"omitempty,min=0"`
+	// verrouillage optimiste par élément, facultatif
+	Version *int `json:"version" validate:"omitempty,min=1"`
+}
+
+type RequeteMasse struct {
+	Operations []OperationMasse `json:"operations"  validate:"required,min=1,max=1000,dive"`
+	// tout_ou_rien: une seule transaction, le moindre échec annule tout
+	ToutOuRien bool `json:"tout_ou_rien"`
+	// nombre d'opérations par transaction hors mode tout_ou_rien (100 par défaut)
+	TailleLot int `json:"taille_lot" validate:"omitempty,min=1,max=500"`
+}
+
+type ResultatOperationMasse struct {
+	Index   int    `json:"index"`
+	Cible   string `json:"cible"`
+	ID      string `json:"id"`
+	Action  string `json:"action"`
+	Succes  bool   `json:"succes"`
+	Erreur  string `json:"erreur,omitempty"`
+	Version int    `json:"version,omitempty"`
+}
+
+type ReponseMasse struct {
+	Total      int                      `json:"total"`
+	Reussies   int                      `json:"reussies"`
+	Echouees   int                      `json:"echouees"`
+	ToutOuRien bool                     `json:"tout_ou_rien"`
+	Annulee    bool                     `json:"annulee"`
+	Resultats  []ResultatOperationMasse `json:"resultats"`
+}
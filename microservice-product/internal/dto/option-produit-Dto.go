@@ -17,24 +17,103 @@ type OptionProduitResponse struct {
 	ProduitID  string                 `json:"produit_id"`
 	Nom        string                 `json:"nom"`
 	Position   int                    `json:"position"`
+	Version    int                    `json:"version"`
+	ModeleID   *string                `json:"modele_id,omitempty"`
 	CreeLe     time.Time              `json:"cree_le"`
 	MisAJourLe time.Time              `json:"mis_a_jour_le"`
 	ValeurOpts []ValeurOptionResponse `json:"valeur_opts,omitempty"`
 }
 
+// Libelles : libellé traduit par code de langue BCP 47 ("fr", "en-GB"...)
 type RequeteCreationValeurOption struct {
-	Valeur   string `json:"valeur"    validate:"required,min=1,max=100"`
-	Position int    `json:"position"  validate:"min=0"`
+	Valeur      string            `json:"valeur"       validate:"required,min=1,max=100"`
+	Position    int               `json:"position"     validate:"min=0"`
+	CouleurHex  *string           `json:"couleur_hex"  validate:"omitempty,hexcolor"`
+	ImageSwatch *string           `json:"image_swatch" validate:"omitempty,url,max=2048"`
+	Code        *string           `json:"code"         validate:"omitempty,min=1,max=50,printascii,excludesall= "`
+	Libelles    map[string]string `json:"libelles"     validate:"omitempty,dive,keys,bcp47_language_tag,endkeys,required,max=100"`
 }
 
+// Les métadonnées se retirent avec une chaîne vide (libelles : objet vide)
 type RequeteUpdateValeurOption struct {
-	Valeur   *string `json:"valeur"    validate:"omitempty,min=1,max=100"`
-	Position *int    `json:"position"  validate:"omitempty,min=0"`
+	Valeur      *string           `json:"valeur"       validate:"omitempty,min=1,max=100"`
+	Position    *int              `json:"position"     validate:"omitempty,min=0"`
+	CouleurHex  *string           `json:"couleur_hex"  validate:"omitzero,hexcolor"`
+	ImageSwatch *string           `json:"image_swatch" validate:"omitzero,url,max=2048"`
+	Code        *string           `json:"code"         validate:"omitzero,max=50,printascii,excludesall= "`
+	Libelles    map[string]string `json:"libelles"     validate:"omitempty,dive,keys,bcp47_language_tag,endkeys,required,max=100"`
 }
 
 type ValeurOptionResponse struct {
+	ID             string            `json:"id"`
+	OptionID       string            `json:"option_id"`
+	Valeur         string            `json:"valeur"`
+	Position       int               `json:"position"`
+	Version        int               `json:"version"`
+	ValeurModeleID *string           `json:"valeur_modele_id,omitempty"`
+	CouleurHex     *string           `json:"couleur_hex,omitempty"`
+	ImageSwatch    *string           `json:"image_swatch,omitempty"`
+	Code           *string           `json:"code,omitempty"`
+	Libelles       map[string]string `json:"libelles,omitempty"`
+}
+
+// RequeteOrdre : IDs dans le nouvel ordre, chaque élément exactement une fois
+type RequeteOrdre struct {
+	IDs []string `json:"ids" validate:"required,min=1,dive,uuid"`
+}
+
+// ------------------------------------------------------------
+// Modèles d'options de la boutique
+// ------------------------------------------------------------
+
+type RequeteValeurModele struct {
+	ID       *string `json:"id"       validate:"omitempty,uuid"`
+	Valeur   string  `json:"valeur"   validate:"required,min=1,max=100"`
+	Position int     `json:"position" validate:"min=0"`
+}
+
+type RequeteCreationModeleOption struct {
+	Nom     string                `json:"nom"     validate:"required,min=1,max=100"`
+	Valeurs []RequeteValeurModele `json:"valeurs" validate:"required,min=1,dive"`
+}
+
+// RequeteUpdateModeleOption : Valeurs, si fournie, remplace la liste complète
+// (avec id = mise à jour, sans id = ajout, absente = suppression). Propager
+// répercute les changements sur les options de produits liées.
+type RequeteUpdateModeleOption struct {
+	Nom      *string               `json:"nom"      validate:"omitempty,min=1,max=100"`
+	Valeurs  []RequeteValeurModele `json:"valeurs"  validate:"omitempty,min=1,dive"`
+	Propager bool                  `json:"propager"`
+}
+
+type ValeurModeleResponse struct {
 	ID       string `json:"id"`
-	OptionID string `json:"option_id"`
 	Valeur   string `json:"valeur"`
 	Position int    `json:"position"`
 }
+
+type ModeleOptionResponse struct {
+	ID           string                 `json:"id"`
+	BoutiqueID   string                 `json:"boutique_id"`
+	Nom          string                 `json:"nom"`
+	Version      int                    `json:"version"`
+	CreeLe       time.Time              `json:"cree_le"`
+	MisAJourLe   time.Time              `json:"mis_a_jour_le"`
+	Valeurs      []ValeurModeleResponse `json:"valeurs"`
+	OptionsLiees int64                  `json:"options_liees"`
+}
+
+// ReponseMajModele : le modèle modifié et le nombre d'options de produits
+// mises à jour par propagation
+type ReponseMajModele struct {
+	Modele           ModeleOptionResponse `json:"modele"`
+	OptionsPropagees int64                `json:"options_propagees"`
+}
+
+// RequeteApplicationModele : Lier garde le lien avec le modèle (les mises à
+// jour propagées s'appliquent), sinon l'option est une simple copie
+type RequeteApplicationModele struct {
+	ModeleID string `json:"modele_id" validate:"required,uuid"`
+	Lier     bool   `json:"lier"`
+	Position int    `json:"position"  validate:"min=0"`
+}
@@ -0,0 +1,58 @@
+package dto
+
+import "projet/internal/monnaie"
+
+// RequetePalier : prix unitaire à partir de quantite_min articles
+type RequetePalier struct {
+	QuantiteMin int             `json:"quantite_min" validate:"min=1"`
+	Prix        monnaie.Montant `json:"prix"         validate:"min=0"`
+}
+
+// RequetePaliers remplace les paliers du produit, ou de la variante si
+// variante_id est renseigné ; une liste vide les retire
+type RequetePaliers struct {
+	VarianteID *string         `json:"variante_id" validate:"omitempty,uuid"`
+	Paliers    []RequetePalier `json:"paliers"     validate:"max=20,dive"`
+}
+
+// PalierResponse : QuantiteMax absente pour le dernier palier
+type PalierResponse struct {
+	QuantiteMin  int          `json:"quantite_min"`
+	QuantiteMax  *int         `json:"quantite_max,omitempty"`
+	PrixUnitaire monnaie.Prix `json:"prix_unitaire"`
+}
+
+type PaliersProduitResponse struct {
+	ProduitID string                      `json:"produit_id"`
+	Paliers   []PalierResponse            `json:"paliers"`
+	Variantes map[string][]PalierResponse `json:"variantes"`
+}
+
+// LigneCalculPrix : une variante, ou un produit sans variantes
+type LigneCalculPrix struct {
+	VarianteID *string `json:"variante_id" validate:"required_without=ProduitID,omitempty,uuid"`
+	ProduitID  *string `json:"produit_id"  validate:"omitempty,uuid"`
+	Quantite   int     `json:"quantite"    validate:"min=1,max=1000000"`
+}
+
+type RequeteCalculPrix struct {
+	Lignes []LigneCalculPrix `json:"lignes" validate:"required,min=1,max=200,dive"`
+}
+
+// LigneCalculResponse : PrixBase est le prix effectif hors palier
+// (promotion comprise), PalierMin le palier appliqué
+type LigneCalculResponse struct {
+	ProduitID    string       `json:"produit_id"`
+	VarianteID   *string      `json:"variante_id,omitempty"`
+	Quantite     int          `json:"quantite"`
+	PrixBase     monnaie.Prix `json:"prix_base"`
+	PrixUnitaire monnaie.Prix `json:"prix_unitaire"`
+	TotalLigne   monnaie.Prix `json:"total_ligne"`
+	PalierMin    *int         `json:"palier_min,omitempty"`
+}
+
+// ReponseCalculPrix : Total absent si les lignes mêlent plusieurs devises
+type ReponseCalculPrix struct {
+	Lignes []LigneCalculResponse `json:"lignes"`
+	Total  *monnaie.Prix         `json:"total,omitempty"`
+}
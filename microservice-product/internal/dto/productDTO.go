@@ -2,75 +2,190 @@ package dto
 
 import (
 	"projet/internal/models"
+	"projet/internal/monnaie"
 	"time"
 )
 
 type RequeteCreationProduit struct {
-	Titre           string                   `json:"titre"            validate:"required,min=1,max=255"`
-	Description     *string                  `json:"description"`
-	Slug            *string                  `json:"slug"`
-	Statut          models.StatutProduit     `json:"statut"           validate:"required,oneof=brouillon publie archive"`
-	PrixDefaut      float64                  `json:"prix_defaut"      validate:"min=0"`
-	Devise          string                   `json:"devise"           validate:"required,len=3"`
-	SKU             *string                  `json:"sku"`
-	SuiviStock      bool                     `json:"suivi_stock"`
-	QuantiteStock   int                      `json:"quantite_stock"   validate:"min=0"`
-	Poids           *float64                 `json:"poids"            validate:"omitempty,min=0"`
-	Dimensions      *string                  `json:"dimensions"`
-	Marque          *string                  `json:"marque"`
-	ClasseTaxe      *string                  `json:"classe_taxe"`
-	Visibilite      models.VisibiliteProduit `json:"visibilite"       validate:"required,oneof=publique privee"`
-	DatePublication *time.Time               `json:"date_publication"`
+	Titre             string                   `json:"titre"            validate:"required,min=1,max=255"`
+	Description       *string                  `json:"description"`
+	Slug              *string                  `json:"slug"`
+	Statut            models.StatutProduit     `json:"statut"           validate:"required,oneof=brouillon publie archive"`
+	PrixDefaut        monnaie.Montant          `json:"prix_defaut"      validate:"min=0"`
+	Devise            string                   `json:"devise"           validate:"required,len=3"`
+	SKU               *string                  `json:"sku"`
+	SuiviStock        bool                     `json:"suivi_stock"`
+	QuantiteStock     int                      `json:"quantite_stock"   validate:"min=0"`
+	Poids             *float64                 `json:"poids"            validate:"omitempty,min=0"`
+	Dimensions        *string                  `json:"dimensions"`
+	Marque            *string                  `json:"marque"`
+	ClasseTaxe        *string                  `json:"classe_taxe"`
+	Visibilite        models.VisibiliteProduit `json:"visibilite"       validate:"required,oneof=publique privee"`
+	Images            []string                 `json:"images"           validate:"omitempty,max=50,dive,required,max=2048"`
+	DatePublication   *time.Time               `json:"date_publication"`
+	DateDepublication *time.Time               `json:"date_depublication"`
+	PrixBarre         *monnaie.Montant         `json:"prix_barre"       validate:"omitempty,min=0"`
+	PrixPromo         *monnaie.Montant         `json:"prix_promo"       validate:"omitempty,min=0"`
+	DebutPromo        *time.Time               `json:"debut_promo"`
+	FinPromo          *time.Time               `json:"fin_promo"`
+
+	// agrégat complet, facultatif : tout est créé dans une seule transaction
+	Options   []RequeteCreationOptionImbriquee   `json:"options"   validate:"omitempty,dive"`
+	Variantes []RequeteCreationVarianteImbriquee `json:"variantes" validate:"omitempty,dive"`
+}
+
+// RequeteCreationOptionImbriquee : option créée avec ses valeurs dans POST /produits
+type RequeteCreationOptionImbriquee struct {
+	Nom      string                        `json:"nom"      validate:"required,min=1,max=100"`
+	Position int                           `json:"position" validate:"min=0"`
+	Valeurs  []RequeteCreationValeurOption `json:"valeurs"  validate:"required,min=1,dive"`
+}
+
+// RequeteCreationVarianteImbriquee : les valeurs sont désignées par nom,
+// {"Taille": "M", "Couleur": "Rouge"}, puisqu'elles n'ont pas encore d'ID
+type RequeteCreationVarianteImbriquee struct {
+	SKU           string            `json:"sku"            validate:"required,min=1,max=100"`
+	Prix          *monnaie.Montant  `json:"prix"           validate:"omitempty,min=0"`
+	QuantiteStock int               `json:"quantite_stock" validate:"min=0"`
+	CodeBarres    *string           `json:"code_barres"`
+	Poids         *float64          `json:"poids"          validate:"omitempty,min=0"`
+	Images        []string          `json:"images"`
+	Valeurs       map[string]string `json:"valeurs"        validate:"required,min=1"`
 }
 
 type RequeteUpdateProduit struct {
-	Titre           *string                   `json:"titre"            validate:"omitempty,min=1,max=255"`
-	Description     *string                   `json:"description"`
-	Slug            *string                   `json:"slug"`
-	Statut          *models.StatutProduit     `json:"statut"           validate:"omitempty,oneof=brouillon publie archive"`
-	PrixDefaut      *float64                  `json:"prix_defaut"      validate:"omitempty,min=0"`
-	Devise          *string                   `json:"devise"           validate:"omitempty,len=3"`
-	SKU             *string                   `json:"sku"`
-	SuiviStock      *bool                     `json:"suivi_stock"`
-	QuantiteStock   *int                      `json:"quantite_stock"   validate:"omitempty,min=0"`
-	Poids           *float64                  `json:"poids"            validate:"omitempty,min=0"`
-	Dimensions      *string                   `json:"dimensions"`
-	Marque          *string                   `json:"marque"`
-	ClasseTaxe      *string                   `json:"classe_taxe"`
-	Visibilite      *models.VisibiliteProduit `json:"visibilite"       validate:"omitempty,oneof=publique privee"`
-	DatePublication *time.Time                `json:"date_publication"`
+	Titre         *string                   `json:"titre"            validate:"omitempty,min=1,max=255"`
+	Description   *string                   `json:"description"`
+	Slug          *string                   `json:"slug"`
+	Statut        *models.StatutProduit     `json:"statut"           validate:"omitempty,oneof=brouillon publie archive"`
+	PrixDefaut    *monnaie.Montant          `json:"prix_defaut"      validate:"omitempty,min=0"`
+	Devise        *string                   `json:"devise"           validate:"omitempty,len=3"`
+	SKU           *string                   `json:"sku"`
+	SuiviStock    *bool                     `json:"suivi_stock"`
+	QuantiteStock *int                      `json:"quantite_stock"   validate:"omitempty,min=0"`
+	Poids         *float64                  `json:"poids"            validate:"omitempty,min=0"`
+	Dimensions    *string                   `json:"dimensions"`
+	Marque        *string                   `json:"marque"`
+	ClasseTaxe    *string                   `json:"classe_taxe"`
+	Visibilite    *models.VisibiliteProduit `json:"visibilite"       validate:"omitempty,oneof=publique privee"`
+	// nil = inchangé, [] = efface les images
+	Images            []string         `json:"images"           validate:"omitempty,max=50,dive,required,max=2048"`
+	DatePublication   *time.Time       `json:"date_publication"`
+	DateDepublication *time.Time       `json:"date_depublication"`
+	PrixBarre         *monnaie.Montant `json:"prix_barre"       validate:"omitempty,min=0"`
+	PrixPromo         *monnaie.Montant `json:"prix_promo"       validate:"omitempty,min=0"`
+	DebutPromo        *time.Time       `json:"debut_promo"`
+	FinPromo          *time.Time       `json:"fin_promo"`
 }
 
 type ProduitResponse struct {
-	ID              string                   `json:"id"`
-	BoutiqueID      string                   `json:"boutique_id"`
-	Titre           string                   `json:"titre"`
-	Description     *string                  `json:"description,omitempty"`
-	Slug            string                   `json:"slug"`
-	Statut          models.StatutProduit     `json:"statut"`
-	PrixDefaut      float64                  `json:"prix_defaut"`
-	Devise          string                   `json:"devise"`
-	SKU             *string                  `json:"sku,omitempty"`
-	SuiviStock      bool                     `json:"suivi_stock"`
-	QuantiteStock   int                      `json:"quantite_stock"`
-	Poids           *float64                 `json:"poids,omitempty"`
-	Dimensions      *string                  `json:"dimensions,omitempty"`
-	Marque          *string                  `json:"marque,omitempty"`
-	ClasseTaxe      *string                  `json:"classe_taxe,omitempty"`
-	Visibilite      models.VisibiliteProduit `json:"visibilite"`
-	DatePublication *time.Time               `json:"date_publication,omitempty"`
-	CreeLe          time.Time                `json:"cree_le"`
-	MisAJourLe      time.Time                `json:"mis_a_jour_le"`
-	Options         []OptionProduitResponse  `json:"options,omitempty"`
-	Variantes       []VarianteResponse       `json:"variantes,omitempty"`
+	ID                string                   `json:"id"`
+	BoutiqueID        string                   `json:"boutique_id"`
+	Titre             string                   `json:"titre"`
+	Description       *string                  `json:"description,omitempty"`
+	Slug              string                   `json:"slug"`
+	Statut            models.StatutProduit     `json:"statut"`
+	PrixDefaut        monnaie.Prix             `json:"prix_defaut"`
+	Devise            string                   `json:"devise"`
+	SKU               *string                  `json:"sku,omitempty"`
+	SuiviStock        bool                     `json:"suivi_stock"`
+	QuantiteStock     int                      `json:"quantite_stock"`
+	Poids             *float64                 `json:"poids,omitempty"`
+	Dimensions        *string                  `json:"dimensions,omitempty"`
+	Marque            *string                  `json:"marque,omitempty"`
+	ClasseTaxe        *string                  `json:"classe_taxe,omitempty"`
+	Visibilite        models.VisibiliteProduit `json:"visibilite"`
+	Images            []string                 `json:"images,omitempty"`
+	DatePublication   *time.Time               `json:"date_publication,omitempty"`
+	DateDepublication *time.Time               `json:"date_depublication,omitempty"`
+	CreeLe            time.Time                `json:"cree_le"`
+	MisAJourLe        time.Time                `json:"mis_a_jour_le"`
+	SupprimeLe        *time.Time               `json:"supprime_le,omitempty"`
+	Version           int                      `json:"version"`
+	Options           []OptionProduitResponse  `json:"options,omitempty"`
+	Variantes         []VarianteResponse       `json:"variantes,omitempty"`
+	PrixBarre         *monnaie.Prix            `json:"prix_barre,omitempty"`
+	PrixPromo         *monnaie.Prix            `json:"prix_promo,omitempty"`
+	DebutPromo        *time.Time               `json:"debut_promo,omitempty"`
+	FinPromo          *time.Time               `json:"fin_promo,omitempty"`
+	PromoActive       bool                     `json:"promo_active"`
+	// prix payé : promotion active comprise ; avec ?devise=, dans la devise
+	// demandée et SourcePrix vaut liste, conversion ou base
+	PrixEffectif *monnaie.Prix `json:"prix_effectif,omitempty"`
+	SourcePrix   string        `json:"source_prix,omitempty"`
+	// code du groupe de clients dont les prix sont appliqués ; avec ?devise=,
+	// le prix du groupe est converti et prime sur la liste du marché
+	GroupeClient string `json:"groupe_client,omitempty"`
+	// prix d'origine affiché barré et remise, quand il dépasse le prix effectif
+	PrixOrigine       *monnaie.Prix `json:"prix_origine,omitempty"`
+	RemisePourcentage *float64      `json:"remise_pourcentage,omitempty"`
+	// prix effectif hors taxes et taxes comprises au taux de la classe de
+	// taxe pour ?pays= (&region=), à défaut le pays de la boutique
+	PrixHT   *monnaie.Prix `json:"prix_ht,omitempty"`
+	PrixTTC  *monnaie.Prix `json:"prix_ttc,omitempty"`
+	TauxTaxe *float64      `json:"taux_taxe,omitempty"`
+	// prix dégressifs par quantité, hérités par les variantes sans paliers ;
+	// omis avec ?devise= quand aucun taux ne permet de les convertir
+	Paliers []PalierResponse `json:"paliers,omitempty"`
 }
 
+// FiltreProduit : critères de GET /produits/search, repris en JSON par les
+// règles de prix (sans pagination ni corbeille)
 type FiltreProduit struct {
-	Statut          *models.StatutProduit     `query:"statut"`
-	Visibilite      *models.VisibiliteProduit `query:"visibilite"`
-	Marque          *string                   `query:"marque"`
-	Recherche       *string                   `query:"search"`
-	Page            int                       `query:"page"`
-	Limite          int                       `query:"limit"`
-	InclureSupprime bool                      `query:"inclure_supprime"`
+	Statut          *models.StatutProduit     `query:"statut"           json:"statut"     validate:"omitempty,oneof=brouillon publie archive"`
+	Visibilite      *models.VisibiliteProduit `query:"visibilite"       json:"visibilite" validate:"omitempty,oneof=publique privee"`
+	Marque          *string                   `query:"marque"           json:"marque"`
+	Recherche       *string                   `query:"search"           json:"search"`
+	Page            int                       `query:"page"             json:"-"`
+	Limite          int                       `query:"limit"            json:"-"`
+	InclureSupprime bool                      `query:"inclure_supprime" json:"-"`
+}
+
+// DocumentProduit est la représentation modifiable d'un produit, cible des
+// requêtes PATCH : le patch est appliqué sur ce document puis le résultat
+// complet est validé. Un champ null ou absent vaut effacement.
+type DocumentProduit struct {
+	Titre             string                   `json:"titre"              validate:"required,min=1,max=255"`
+	Description       *string                  `json:"description"`
+	Slug              string                   `json:"slug"               validate:"required,max=255"`
+	Statut            models.StatutProduit     `json:"statut"             validate:"required,oneof=brouillon publie archive"`
+	PrixDefaut        monnaie.Montant          `json:"prix_defaut"        validate:"min=0"`
+	Devise            string                   `json:"devise"             validate:"required,len=3"`
+	SKU               *string                  `json:"sku"                validate:"omitempty,max=100"`
+	SuiviStock        bool                     `json:"suivi_stock"`
+	QuantiteStock     int                      `json:"quantite_stock"     validate:"min=0"`
+	Poids             *float64                 `json:"poids"              validate:"omitempty,min=0"`
+	Dimensions        *string                  `json:"dimensions"         validate:"omitempty,max=100"`
+	Marque            *string                  `json:"marque"             validate:"omitempty,max=255"`
+	ClasseTaxe        *string                  `json:"classe_taxe"        validate:"omitempty,max=100"`
+	Visibilite        models.VisibiliteProduit `json:"visibilite"         validate:"required,oneof=publique privee"`
+	Images            []string                 `json:"images"             validate:"omitempty,max=50,dive,required,max=2048"`
+	DatePublication   *time.Time               `json:"date_publication"`
+	DateDepublication *time.Time               `json:"date_depublication"`
+	PrixBarre         *monnaie.Montant         `json:"prix_barre"         validate:"omitempty,min=0"`
+	PrixPromo         *monnaie.Montant         `json:"prix_promo"         validate:"omitempty,min=0"`
+	DebutPromo        *time.Time               `json:"debut_promo"`
+	FinPromo          *time.Time               `json:"fin_promo"`
+}
+
+// RequeteDuplication : tous les champs sont facultatifs
+type RequeteDuplication struct {
+	// boutique de destination (propriétaire multi-boutiques), la boutique courante par défaut
+	BoutiqueID *string `json:"boutique_id"  validate:"omitempty,uuid"`
+	Titre      *string `json:"titre"        validate:"omitempty,min=1,max=255"`
+	// ajouté aux SKU copiés, "-COPIE" par défaut
+	SuffixeSKU *string `json:"suffixe_sku"  validate:"omitempty,min=1,max=20"`
+}
+
+// CorrespondanceCatalogue : Champ vaut produit.sku, variante.sku ou
+// variante.code_barres ; Variante est renseignée pour les deux derniers
+type CorrespondanceCatalogue struct {
+	Champ    string            `json:"champ"`
+	Produit  ProduitResponse   `json:"produit"`
+	Variante *VarianteResponse `json:"variante,omitempty"`
+}
+
+type ReponseRechercheCode struct {
+	Code      string                    `json:"code"`
+	Resultats []CorrespondanceCatalogue `json:"resultats"`
 }
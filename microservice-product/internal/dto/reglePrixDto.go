@@ -0,0 +1,72 @@
+package dto
+
+import (
+	"encoding/json"
+	"projet/internal/monnaie"
+	"time"
+)
+
+const (
+	TransformationPourcentage = "pourcentage"
+	TransformationDelta       = "delta"
+	TransformationFixer       = "fixer"
+	TransformationArrondir    = "arrondir"
+
+	PorteeProduits  = "produits"
+	PorteeVariantes = "variantes"
+	PorteeTout      = "tout"
+)
+
+// TransformationPrix : pourcentage (+5 = hausse de 5 %), delta (montant
+// ajouté, négatif pour baisser), fixer (nouveau prix) ou arrondir.
+// Terminaison (0.99, 9.99...) s'applique après n'importe quel type.
+type TransformationPrix struct {
+	Type        string           `json:"type"        validate:"required,oneof=pourcentage delta fixer arrondir"`
+	Pourcentage *float64         `json:"pourcentage" validate:"omitempty,gt=-100,lte=1000"`
+	Montant     *monnaie.Montant `json:"montant"`
+	Terminaison *monnaie.Montant `json:"terminaison" validate:"omitempty,min=0"`
+}
+
+// RequeteReglePrix : les articles visés (mêmes critères que
+// GET /produits/search) et la transformation à leur appliquer. Devise est
+// requise pour delta et fixer et restreint alors la sélection aux produits
+// de cette devise.
+type RequeteReglePrix struct {
+	Filtre         FiltreProduit      `json:"filtre"`
+	Devise         string             `json:"devise"         validate:"omitempty,iso4217"`
+	Portee         string             `json:"portee"         validate:"omitempty,oneof=produits variantes tout"`
+	Transformation TransformationPrix `json:"transformation"`
+}
+
+// LigneReglePrix : un article modifié par la règle. Erreur empêche
+// l'application de toute la règle.
+type LigneReglePrix struct {
+	Cible      string       `json:"cible"`
+	ProduitID  string       `json:"produit_id"`
+	VarianteID *string      `json:"variante_id,omitempty"`
+	Titre      string       `json:"titre"`
+	Avant      monnaie.Prix `json:"avant"`
+	Apres      monnaie.Prix `json:"apres"`
+	Erreur     string       `json:"erreur,omitempty"`
+}
+
+type ReponseReglePrix struct {
+	Simulation   bool             `json:"simulation"`
+	AjustementID string           `json:"ajustement_id,omitempty"`
+	Produits     int              `json:"produits"`
+	Variantes    int              `json:"variantes"`
+	Inchanges    int              `json:"inchanges"`
+	Erreurs      int              `json:"erreurs"`
+	Lignes       []LigneReglePrix `json:"lignes"`
+}
+
+type AjustementPrixResponse struct {
+	ID          string          `json:"id"`
+	BoutiqueID  string          `json:"boutique_id"`
+	Acteur      string          `json:"acteur"`
+	Regle       json.RawMessage `json:"regle"`
+	NbProduits  int             `json:"nb_produits"`
+	NbVariantes int             `json:"nb_variantes"`
+	Lignes      json.RawMessage `json:"lignes,omitempty"`
+	CreeLe      time.Time       `json:"cree_le"`
+}
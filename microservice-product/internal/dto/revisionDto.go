@@ -0,0 +1,31 @@
+package dto
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type RevisionResponse struct {
+	ID        string          `json:"id"`
+	ProduitID string          `json:"produit_id"`
+	Numero    int             `json:"numero"`
+	Acteur    string          `json:"acteur"`
+	CreeLe    time.Time       `json:"cree_le"`
+	Snapshot  json.RawMessage `json:"snapshot,omitempty"`
+}
+
+// différences d'une collection d'entités (options, valeurs ou variantes) entre deux révisions
+type DiffCollection struct {
+	Ajoutees   []string                              `json:"ajoutees"`
+	Supprimees []string                              `json:"supprimees"`
+	Modifiees  map[string]map[string]ChangementChamp `json:"modifiees"`
+}
+
+type DiffRevisionsResponse struct {
+	De        int                        `json:"de"`
+	Vers      int                        `json:"vers"`
+	Produit   map[string]ChangementChamp `json:"produit"`
+	Options   DiffCollection             `json:"options"`
+	Valeurs   DiffCollection             `json:"valeurs"`
+	Variantes DiffCollection             `json:"variantes"`
+}
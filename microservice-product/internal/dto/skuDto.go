@@ -0,0 +1,37 @@
+package dto
+
+import "time"
+
+// RequeteConfigurationSKU : nil = inchangé. Compteur repositionne le dernier
+// numéro attribué (le prochain SKU prendra Compteur+1).
+type RequeteConfigurationSKU struct {
+	Prefixe  *string `json:"prefixe"  validate:"omitempty,max=20,printascii,excludesall= "`
+	Modele   *string `json:"modele"   validate:"omitempty,min=1,max=100"`
+	Compteur *int64  `json:"compteur" validate:"omitempty,min=0"`
+}
+
+type ConfigurationSKUResponse struct {
+	Prefixe    string     `json:"prefixe"`
+	Modele     string     `json:"modele"`
+	Compteur   int64      `json:"compteur"`
+	MisAJourLe *time.Time `json:"mis_a_jour_le,omitempty"`
+}
+
+// RequeteGenerationSKU : Apercu calcule sans rien écrire ni consommer le
+// compteur ; Modele remplace celui de la boutique pour cet appel
+type RequeteGenerationSKU struct {
+	Apercu bool    `json:"apercu"`
+	Modele *string `json:"modele" validate:"omitempty,min=1,max=100"`
+}
+
+type PropositionSKU struct {
+	VarianteID string `json:"variante_id"`
+	Actuel     string `json:"actuel"`
+	Propose    string `json:"propose"`
+}
+
+type ReponseGenerationSKU struct {
+	Apercu    bool             `json:"apercu"`
+	Modele    string           `json:"modele"`
+	Variantes []PropositionSKU `json:"variantes"`
+}
@@ -0,0 +1,59 @@
+package dto
+
+import (
+	"projet/internal/monnaie"
+	"time"
+)
+
+type RequeteCreationListePrix struct {
+	Nom    string  `json:"nom"    validate:"required,min=1,max=100"`
+	Devise string  `json:"devise" validate:"required,iso4217"`
+	Pays   *string `json:"pays"   validate:"omitempty,iso3166_1_alpha2"`
+}
+
+// RequeteUpdateListePrix : la devise ne change pas, les prix y sont
+// exprimés ; pays "" rend la liste valable pour tous les marchés
+type RequeteUpdateListePrix struct {
+	Nom  *string `json:"nom"  validate:"omitempty,min=1,max=100"`
+	Pays *string `json:"pays" validate:"omitzero,iso3166_1_alpha2"`
+}
+
+type ListePrixResponse struct {
+	ID         string    `json:"id"`
+	Nom        string    `json:"nom"`
+	Devise     string    `json:"devise"`
+	Pays       *string   `json:"pays,omitempty"`
+	CreeLe     time.Time `json:"cree_le"`
+	MisAJourLe time.Time `json:"mis_a_jour_le"`
+}
+
+// LignePrixListe : sans variante, prix du produit hérité par ses variantes
+// sans prix propre ; montant null retire le prix explicite
+type LignePrixListe struct {
+	ProduitID  string           `json:"produit_id"  validate:"required,uuid"`
+	VarianteID *string          `json:"variante_id" validate:"omitempty,uuid"`
+	Montant    *monnaie.Montant `json:"montant"     validate:"omitempty,min=0"`
+}
+
+type RequetePrixListe struct {
+	Prix []LignePrixListe `json:"prix" validate:"required,min=1,max=500,dive"`
+}
+
+type PrixListeResponse struct {
+	ProduitID  string       `json:"produit_id"`
+	VarianteID *string      `json:"variante_id,omitempty"`
+	Montant    monnaie.Prix `json:"montant"`
+}
+
+type RequeteTauxChange struct {
+	DeviseSource string       `json:"devise_source" validate:"required,iso4217"`
+	DeviseCible  string       `json:"devise_cible"  validate:"required,iso4217,nefield=DeviseSource"`
+	Taux         monnaie.Taux `json:"taux"          validate:"required,gt=0"`
+}
+
+type TauxChangeResponse struct {
+	DeviseSource string       `json:"devise_source"`
+	DeviseCible  string       `json:"devise_cible"`
+	Taux         monnaie.Taux `json:"taux"`
+	MisAJourLe   time.Time    `json:"mis_a_jour_le"`
+}
@@ -0,0 +1,54 @@
+package dto
+
+import "time"
+
+// RequeteCreationClasseTaxe : code repris tel quel dans classe_taxe des produits
+type RequeteCreationClasseTaxe struct {
+	Code string `json:"code" validate:"required,min=1,max=100,excludesall= ,"`
+	Nom  string `json:"nom"  validate:"required,min=1,max=100"`
+}
+
+type RequeteUpdateClasseTaxe struct {
+	Nom *string `json:"nom" validate:"omitempty,min=1,max=100"`
+}
+
+// RequeteTauxTaxe : taux en pourcentage pour un pays, ou une de ses régions
+type RequeteTauxTaxe struct {
+	Pays   string  `json:"pays"   validate:"required,iso3166_1_alpha2"`
+	Region string  `json:"region" validate:"omitempty,max=10,alphanum"`
+	Taux   float64 `json:"taux"   validate:"gte=0,lte=100"`
+}
+
+// RequeteTauxClasse remplace tous les taux de la classe
+type RequeteTauxClasse struct {
+	Taux []RequeteTauxTaxe `json:"taux" validate:"max=500,dive"`
+}
+
+type TauxTaxeResponse struct {
+	Pays   string  `json:"pays"`
+	Region string  `json:"region,omitempty"`
+	Taux   float64 `json:"taux"`
+}
+
+type ClasseTaxeResponse struct {
+	ID         string             `json:"id"`
+	Code       string             `json:"code"`
+	Nom        string             `json:"nom"`
+	CreeLe     time.Time          `json:"cree_le"`
+	MisAJourLe time.Time          `json:"mis_a_jour_le"`
+	Taux       []TauxTaxeResponse `json:"taux"`
+}
+
+// RequeteParametresTaxe : nil = inchangé, "" efface pays ou classe par défaut
+type RequeteParametresTaxe struct {
+	PrixTTC      *bool   `json:"prix_ttc"`
+	PaysDefaut   *string `json:"pays_defaut"   validate:"omitempty,iso3166_1_alpha2"`
+	ClasseDefaut *string `json:"classe_defaut" validate:"omitempty,max=100"`
+}
+
+type ParametresTaxeResponse struct {
+	PrixTTC      bool       `json:"prix_ttc"`
+	PaysDefaut   *string    `json:"pays_defaut,omitempty"`
+	ClasseDefaut *string    `json:"classe_defaut,omitempty"`
+	MisAJourLe   *time.Time `json:"mis_a_jour_le,omitempty"`
+}
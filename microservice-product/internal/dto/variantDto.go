@@ -1,40 +1,125 @@
 package dto
 
-import "time"
+import (
+	"projet/internal/monnaie"
+	"time"
+)
 
+// RequeteCreationVariante : sans SKU, il est généré selon le modèle de la boutique
 type RequeteCreationVariante struct {
-	SKU           string   `json:"sku"             validate:"required,min=1,max=100"`
-	Prix          *float64 `json:"prix"            validate:"omitempty,min=0"`
-	QuantiteStock int      `json:"quantite_stock"  validate:"min=0"`
-	CodeBarres    *string  `json:"code_barres"`
-	Poids         *float64 `json:"poids"           validate:"omitempty,min=0"`
-	Images        []string `json:"images"`
+	SKU           string           `json:"sku"             validate:"omitempty,min=1,max=100"`
+	Prix          *monnaie.Montant `json:"prix"            validate:"omitempty,min=0"`
+	QuantiteStock int              `json:"quantite_stock"  validate:"min=0"`
+	CodeBarres    *string          `json:"code_barres"`
+	Poids         *float64         `json:"poids"           validate:"omitempty,min=0"`
+	Images        []string         `json:"images"`
+	PrixBarre     *monnaie.Montant `json:"prix_barre"      validate:"omitempty,min=0"`
+	PrixPromo     *monnaie.Montant `json:"prix_promo"      validate:"omitempty,min=0"`
+	DebutPromo    *time.Time       `json:"debut_promo"`
+	FinPromo      *time.Time       `json:"fin_promo"`
 
 	ValeurOptionIDs []string `json:"valeur_option_ids" validate:"required,min=1"`
 }
 
 type RequeteUpdateVariante struct {
-	SKU             *string  `json:"sku"             validate:"omitempty,min=1,max=100"`
-	Prix            *float64 `json:"prix"            validate:"omitempty,min=0"`
-	QuantiteStock   *int     `json:"quantite_stock"  validate:"omitempty,min=0"`
-	CodeBarres      *string  `json:"code_barres"`
-	Poids           *float64 `json:"poids"           validate:"omitempty,min=0"`
-	Images          []string `json:"images"`
-	ValeurOptionIDs []string `json:"valeur_option_ids" validate:"omitempty,min=1"`
+	SKU             *string          `json:"sku"             validate:"omitempty,min=1,max=100"`
+	Prix            *monnaie.Montant `json:"prix"            validate:"omitempty,min=0"`
+	QuantiteStock   *int             `json:"quantite_stock"  validate:"omitempty,min=0"`
+	CodeBarres      *string          `json:"code_barres"`
+	Poids           *float64         `json:"poids"           validate:"omitempty,min=0"`
+	Images          []string         `json:"images"`
+	PrixBarre       *monnaie.Montant `json:"prix_barre"      validate:"omitempty,min=0"`
+	PrixPromo       *monnaie.Montant `json:"prix_promo"      validate:"omitempty,min=0"`
+	DebutPromo      *time.Time       `json:"debut_promo"`
+	FinPromo        *time.Time       `json:"fin_promo"`
+	ValeurOptionIDs []string         `json:"valeur_option_ids" validate:"omitempty,min=1"`
 }
 
 type VarianteResponse struct {
 	ID            string                 `json:"id"`
 	ProduitID     string                 `json:"produit_id"`
 	SKU           string                 `json:"sku"`
-	Prix          *float64               `json:"prix,omitempty"`
+	Prix          *monnaie.Prix          `json:"prix,omitempty"`
 	QuantiteStock int                    `json:"quantite_stock"`
 	CodeBarres    *string                `json:"code_barres,omitempty"`
 	Poids         *float64               `json:"poids,omitempty"`
 	Images        []string               `json:"images,omitempty"`
+	Version       int                    `json:"version"`
 	CreeLe        time.Time              `json:"cree_le"`
 	MisAJourLe    time.Time              `json:"mis_a_jour_le"`
 	ValeurOptions []ValeurOptionResponse `json:"valeur_options,omitempty"`
-	// Prix effectif = Prix si présent, sinon PrixDefaut du produit
-	PrixEffectif float64 `json:"prix_effectif"`
+	PrixBarre     *monnaie.Prix          `json:"prix_barre,omitempty"`
+	PrixPromo     *monnaie.Prix          `json:"prix_promo,omitempty"`
+	DebutPromo    *time.Time             `json:"debut_promo,omitempty"`
+	FinPromo      *time.Time             `json:"fin_promo,omitempty"`
+	PromoActive   bool                   `json:"promo_active"`
+	// Prix effectif = Prix si présent, sinon PrixDefaut du produit, remplacé
+	// par le prix promotionnel actif ; avec ?devise=, converti et SourcePrix
+	// vaut liste, conversion ou base
+	PrixEffectif monnaie.Prix `json:"prix_effectif"`
+	SourcePrix   string       `json:"source_prix,omitempty"`
+	// code du groupe de clients dont les prix sont appliqués (prime sur la liste du marché)
+	GroupeClient string `json:"groupe_client,omitempty"`
+	// prix d'origine affiché barré et remise, quand il dépasse le prix effectif
+	PrixOrigine       *monnaie.Prix `json:"prix_origine,omitempty"`
+	RemisePourcentage *float64      `json:"remise_pourcentage,omitempty"`
+	// prix effectif hors taxes et taxes comprises au taux de la classe de
+	// taxe pour ?pays= (&region=), à défaut le pays de la boutique
+	PrixHT   *monnaie.Prix `json:"prix_ht,omitempty"`
+	PrixTTC  *monnaie.Prix `json:"prix_ttc,omitempty"`
+	TauxTaxe *float64      `json:"taux_taxe,omitempty"`
+	// paliers propres, sinon ceux du produit
+	Paliers []PalierResponse `json:"paliers,omitempty"`
+}
+
+// DocumentVariante : représentation modifiable d'une variante pour PATCH
+// (les liens vers les valeurs d'option restent gérés par PUT)
+type DocumentVariante struct {
+	SKU           string           `json:"sku"            validate:"required,min=1,max=100"`
+	Prix          *monnaie.Montant `json:"prix"           validate:"omitempty,min=0"`
+	QuantiteStock int              `json:"quantite_stock" validate:"min=0"`
+	CodeBarres    *string          `json:"code_barres"    validate:"omitempty,max=100"`
+	Poids         *float64         `json:"poids"          validate:"omitempty,min=0"`
+	Images        []string         `json:"images"         validate:"dive,required"`
+	PrixBarre     *monnaie.Montant `json:"prix_barre"     validate:"omitempty,min=0"`
+	PrixPromo     *monnaie.Montant `json:"prix_promo"     validate:"omitempty,min=0"`
+	DebutPromo    *time.Time       `json:"debut_promo"`
+	FinPromo      *time.Time       `json:"fin_promo"`
+}
+
+// SelectionVariante : choix du client, par IDs de valeurs et/ou par nom
+// d'option -> libellé (ou code) de valeur, insensibles à la casse
+type SelectionVariante struct {
+	ValeurIDs []string
+	ParOption map[string]string
+}
+
+type ReponseResolutionVariante struct {
+	Variante   VarianteResponse `json:"variante"`
+	Disponible bool             `json:"disponible"`
+}
+
+// DisponibiliteValeur : Existe si une variante combine cette valeur avec le
+// reste de la sélection, Disponible si l'une d'elles est en stock
+type DisponibiliteValeur struct {
+	ID           string  `json:"id"`
+	Valeur       string  `json:"valeur"`
+	Code         *string `json:"code,omitempty"`
+	Selectionnee bool    `json:"selectionnee"`
+	Existe       bool    `json:"existe"`
+	Disponible   bool    `json:"disponible"`
+	Stock        int     `json:"stock"`
+}
+
+type DisponibiliteOption struct {
+	ID      string                `json:"id"`
+	Nom     string                `json:"nom"`
+	Valeurs []DisponibiliteValeur `json:"valeurs"`
+}
+
+// MatriceDisponibilite : Variante est renseignée quand la sélection est complète
+type MatriceDisponibilite struct {
+	Selection []string              `json:"selection"`
+	Options   []DisponibiliteOption `json:"options"`
+	Variante  *VarianteResponse     `json:"variante,omitempty"`
 }
@@ -0,0 +1,54 @@
+package dto
+
+import (
+	"projet/internal/models"
+	"time"
+)
+
+type RequeteCreationWebhook struct {
+	URL        string   `json:"url"        validate:"required,url,max=2048"`
+	Secret     *string  `json:"secret"     validate:"omitempty,min=16,max=255"`
+	Evenements []string `json:"evenements" validate:"required,min=1,dive,oneof=produit.publie produit.archive produit.supprime variante.rupture"`
+	Actif      *bool    `json:"actif"`
+}
+
+type RequeteUpdateWebhook struct {
+	URL        *string  `json:"url"        validate:"omitempty,url,max=2048"`
+	Secret     *string  `json:"secret"     validate:"omitempty,min=16,max=255"`
+	Evenements []string `json:"evenements" validate:"omitempty,min=1,dive,oneof=produit.publie produit.archive produit.supprime variante.rupture"`
+	Actif      *bool    `json:"actif"`
+}
+
+type WebhookResponse struct {
+	ID         string    `json:"id"`
+	BoutiqueID string    `json:"boutique_id"`
+	URL        string    `json:"url"`
+	Evenements []string  `json:"evenements"`
+	Actif      bool      `json:"actif"`
+	CreeLe     time.Time `json:"cree_le"`
+	MisAJourLe time.Time `json:"mis_a_jour_le"`
+	// Secret n'est renvoyé qu'a la création (ou quand il est régénéré)
+	Secret string `json:"secret,omitempty"`
+}
+
+type LivraisonWebhookResponse struct {
+	ID           string                 `json:"id"`
+	AbonnementID string                 `json:"abonnement_id"`
+	Evenement    string                 `json:"evenement"`
+	Payload      string                 `json:"payload"`
+	Statut       models.StatutLivraison `json:"statut"`
+	Tentatives   int                    `json:"tentatives"`
+	CodeReponse  *int                   `json:"code_reponse,omitempty"`
+	Erreur       *string                `json:"erreur,omitempty"`
+	CreeLe       time.Time              `json:"cree_le"`
+	MisAJourLe   time.Time              `json:"mis_a_jour_le"`
+}
+
+// corps envoyé aux URLs des marchands
+type EvenementWebhook struct {
+	ID         string      `json:"id"`
+	Evenement  string      `json:"evenement"`
+	BoutiqueID string      `json:"boutique_id"`
+	CreeLe     time.Time   `json:"cree_le"`
+	Donnees    interface{} `json:"donnees"`
+}
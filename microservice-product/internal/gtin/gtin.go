@@ -0,0 +1,55 @@
+// Package gtin valide les codes-barres GTIN : UPC-A (12 chiffres), EAN-13
+// et GTIN-14, tous protégés par la même clé de contrôle modulo 10.
+package gtin
+
+import "errors"
+
+var (
+	ErrFormat      = errors.New("12, 13 ou 14 chiffres attendus (UPC-A, EAN-13, GTIN-14)")
+	ErrCleControle = errors.New("clé de contrôle incorrecte")
+)
+
+// Valider vérifie la longueur et la clé de contrôle
+func Valider(code string) error {
+	if !numerique(code) || len(code) < 12 || len(code) > 14 {
+		return ErrFormat
+	}
+	// en partant de la droite (clé exclue), poids 3 puis 1 en alternance
+	somme := 0
+	for i := len(code) - 2; i >= 0; i-- {
+		chiffre := int(code[i] - '0')
+		if (len(code)-2-i)%2 == 0 {
+			chiffre *= 3
+		}
+		somme += chiffre
+	}
+	if (10-somme%10)%10 != int(code[len(code)-1]-'0') {
+		return ErrCleControle
+	}
+	return nil
+}
+
+// Normaliser ramène un code numérique sur 14 chiffres (zéros à gauche) :
+// l'UPC-A 036000291452 et l'EAN-13 0036000291452 désignent le même article.
+// Renvoie "" si code n'est pas un GTIN possible.
+func Normaliser(code string) string {
+	if !numerique(code) || len(code) < 12 || len(code) > 14 {
+		return ""
+	}
+	for len(code) < 14 {
+		code = "0" + code
+	}
+	return code
+}
+
+func numerique(code string) bool {
+	if code == "" {
+		return false
+	}
+	for i := 0; i < len(code); i++ {
+		if code[i] < '0' || code[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,46 @@
+package gtin
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValider(t *testing.T) {
+	cas := []struct {
+		code    string
+		attendu error
+	}{
+		{"036000291452", nil},   // UPC-A
+		{"4006381333931", nil},  // EAN-13
+		{"0036000291452", nil},  // UPC-A écrit en EAN-13
+		{"10036000291459", nil}, // GTIN-14
+		{"036000291453", ErrCleControle},
+		{"4006381333932", ErrCleControle},
+		{"10036000291450", ErrCleControle},
+		{"", ErrFormat},
+		{"12345678901", ErrFormat},     // 11 chiffres
+		{"123456789012345", ErrFormat}, // 15 chiffres
+		{"03600029145A", ErrFormat},
+		{" 036000291452", ErrFormat},
+	}
+	for _, c := range cas {
+		if err := Valider(c.code); !errors.Is(err, c.attendu) {
+			t.Errorf("Valider(%q) = %v, attendu %v", c.code, err, c.attendu)
+		}
+	}
+}
+
+func TestNormaliser(t *testing.T) {
+	cas := map[string]string{
+		"036000291452":   "00036000291452",
+		"0036000291452":  "00036000291452",
+		"10036000291459": "10036000291459",
+		"ABC":            "",
+		"12345":          "",
+	}
+	for code, attendu := range cas {
+		if obtenu := Normaliser(code); obtenu != attendu {
+			t.Errorf("Normaliser(%q) = %q, attendu %q", code, obtenu, attendu)
+		}
+	}
+}
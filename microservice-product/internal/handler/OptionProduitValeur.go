@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"errors"
 	"projet/internal/dto"
 	"projet/internal/service"
 
@@ -15,12 +16,28 @@ func NewOptionProduitHandler(service *service.OptionProduitService) *OptionProdu
 	return &OptionProduitHandler{service: service}
 }
 
-func (h *OptionProduitHandler) getBoutiqueID(c *fiber.Ctx) (string, error) {
-	boutiqueID := c.Get("X-Boutique-ID")
-	if boutiqueID == "" {
-		return "", fiber.NewError(fiber.StatusUnauthorized, "Missing store context")
+// modeCascade lit ?cascade= : absent, l'opération est refusée si des
+// variantes en dépendent ; "variantes" supprime ces variantes
+func modeCascade(c *fiber.Ctx) (bool, error) {
+	switch c.Query("cascade") {
+	case "":
+		return false, nil
+	case "variantes":
+		return true, nil
 	}
-	return boutiqueID, nil
+	return false, fiber.NewError(fiber.StatusBadRequest, "cascade invalide (valeur acceptée : variantes)")
+}
+
+// reponseVariantesLiees : 409 avec la liste des variantes concernées
+func reponseVariantesLiees(c *fiber.Ctx, err *service.ErreurVariantesLiees) error {
+	variantes := make([]fiber.Map, len(err.Variantes))
+	for i, v := range err.Variantes {
+		variantes[i] = fiber.Map{"id": v.ID, "sku": v.SKU}
+	}
+	return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+		"error":     "Des variantes dépendent de cette opération, relancez avec ?cascade=variantes pour les supprimer",
+		"variantes": variantes,
+	})
 }
 
 // ============================================================
@@ -39,12 +56,25 @@ func (h *OptionProduitHandler) CreateOption(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "Format JSON invalide"})
 	}
 
-	if _, err := h.getBoutiqueID(c); err != nil {
+	boutiqueID, err := getBoutiqueID(c)
+	if err != nil {
 		return err
 	}
 
-	option, err := h.service.CreationOptionProduit(c.Context(), produitID, req)
+	cascade, err := modeCascade(c)
 	if err != nil {
+		return err
+	}
+
+	option, err := h.service.CreationOptionProduit(contexteRequete(c), produitID, boutiqueID, req, cascade)
+	if err != nil {
+		if errors.Is(err, service.ErrProduitIntrouvable) {
+			return c.Status(404).JSON(fiber.Map{"error": "Produit non trouvé"})
+		}
+		var liees *service.ErreurVariantesLiees
+		if errors.As(err, &liees) {
+			return reponseVariantesLiees(c, liees)
+		}
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
 
@@ -58,12 +88,16 @@ func (h *OptionProduitHandler) ListOptions(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "ID produit requis"})
 	}
 
-	if _, err := h.getBoutiqueID(c); err != nil {
+	boutiqueID, err := getBoutiqueID(c)
+	if err != nil {
 		return err
 	}
 
-	options, err := h.service.ListOptionProduit(c.Context(), produitID)
+	options, err := h.service.ListOptionProduit(contexteRequete(c), produitID, boutiqueID)
 	if err != nil {
+		if errors.Is(err, service.ErrProduitIntrouvable) {
+			return c.Status(404).JSON(fiber.Map{"error": "Produit non trouvé"})
+		}
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
 
@@ -77,18 +111,20 @@ func (h *OptionProduitHandler) GetOptionByID(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "ID option requis"})
 	}
 
-	if _, err := h.getBoutiqueID(c); err != nil {
+	boutiqueID, err := getBoutiqueID(c)
+	if err != nil {
 		return err
 	}
 
-	option, err := h.service.GetByIDOptionProduit(c.Context(), optionID)
+	option, err := h.service.GetByIDOptionProduit(contexteRequete(c), optionID, boutiqueID)
 	if err != nil {
-		if err.Error() == "option non trouvée" {
+		if err.Error() == "option non trouvée" || errors.Is(err, service.ErrProduitIntrouvable) {
 			return c.Status(404).JSON(fiber.Map{"error": "Option non trouvée"})
 		}
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
 
+	definirETag(c, option.Version)
 	return c.Status(200).JSON(option)
 }
 
@@ -104,18 +140,28 @@ func (h *OptionProduitHandler) UpdateOption(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "Format JSON invalide"})
 	}
 
-	if _, err := h.getBoutiqueID(c); err != nil {
+	boutiqueID, err := getBoutiqueID(c)
+	if err != nil {
 		return err
 	}
 
-	option, err := h.service.Update(c.Context(), optionID, req)
+	version, err := versionIfMatch(c)
 	if err != nil {
-		if err.Error() == "option non trouvée" {
+		return err
+	}
+
+	option, err := h.service.Update(contexteRequete(c), optionID, boutiqueID, req, version)
+	if err != nil {
+		if err.Error() == "option non trouvée" || errors.Is(err, service.ErrProduitIntrouvable) {
 			return c.Status(404).JSON(fiber.Map{"error": "Option non trouvée"})
 		}
+		if versionObsolete(err) {
+			return reponseVersionObsolete(c)
+		}
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
 
+	definirETag(c, option.Version)
 	return c.Status(200).JSON(option)
 }
 
@@ -126,21 +172,73 @@ func (h *OptionProduitHandler) DeleteOption(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "ID option requis"})
 	}
 
-	if _, err := h.getBoutiqueID(c); err != nil {
+	boutiqueID, err := getBoutiqueID(c)
+	if err != nil {
+		return err
+	}
+
+	version, err := versionIfMatch(c)
+	if err != nil {
+		return err
+	}
+
+	cascade, err := modeCascade(c)
+	if err != nil {
 		return err
 	}
 
-	err := h.service.Delete(c.Context(), optionID)
+	err = h.service.Delete(contexteRequete(c), optionID, boutiqueID, version, cascade)
 	if err != nil {
-		if err.Error() == "option non trouvée" {
+		var liees *service.ErreurVariantesLiees
+		if errors.As(err, &liees) {
+			return reponseVariantesLiees(c, liees)
+		}
+		if err.Error() == "option non trouvée" || errors.Is(err, service.ErrProduitIntrouvable) {
 			return c.Status(404).JSON(fiber.Map{"error": "Option non trouvée"})
 		}
+		if versionObsolete(err) {
+			return reponseVersionObsolete(c)
+		}
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
 
 	return c.Status(200).JSON(fiber.Map{"ok": true})
 }
 
+// PUT /api/produits/:produitId/options/ordre
+func (h *OptionProduitHandler) ReordonnerOptions(c *fiber.Ctx) error {
+	produitID := c.Params("produitId")
+	if produitID == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "ID produit requis"})
+	}
+
+	var req dto.RequeteOrdre
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Format JSON invalide"})
+	}
+	if err := validate.Struct(req); err != nil {
+		return c.Status(422).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	boutiqueID, err := getBoutiqueID(c)
+	if err != nil {
+		return err
+	}
+
+	options, err := h.service.ReordonnerOptions(contexteRequete(c), produitID, boutiqueID, req.IDs)
+	if err != nil {
+		if errors.Is(err, service.ErrProduitIntrouvable) {
+			return c.Status(404).JSON(fiber.Map{"error": "Produit non trouvé"})
+		}
+		if errors.Is(err, service.ErrOrdreIncomplet) {
+			return c.Status(422).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.Status(200).JSON(fiber.Map{"options": options})
+}
+
 // ============================================================
 // VALEURS D'OPTIONS
 // ============================================================
@@ -156,13 +254,23 @@ func (h *OptionProduitHandler) CreateValeur(c *fiber.Ctx) error {
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": "Format JSON invalide"})
 	}
+	if err := validate.Struct(req); err != nil {
+		return c.Status(422).JSON(fiber.Map{"error": err.Error()})
+	}
 
-	if _, err := h.getBoutiqueID(c); err != nil {
+	boutiqueID, err := getBoutiqueID(c)
+	if err != nil {
 		return err
 	}
 
-	valeur, err := h.service.CreationValeurOption(c.Context(), optionID, req)
+	valeur, err := h.service.CreationValeurOption(contexteRequete(c), optionID, boutiqueID, req)
 	if err != nil {
+		if err.Error() == "option non trouvée" || errors.Is(err, service.ErrProduitIntrouvable) {
+			return c.Status(404).JSON(fiber.Map{"error": "Option non trouvée"})
+		}
+		if errors.Is(err, service.ErrCodeValeurPris) {
+			return c.Status(409).JSON(fiber.Map{"error": err.Error()})
+		}
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
 
@@ -176,12 +284,50 @@ func (h *OptionProduitHandler) ListValeurs(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "ID option requis"})
 	}
 
-	if _, err := h.getBoutiqueID(c); err != nil {
+	boutiqueID, err := getBoutiqueID(c)
+	if err != nil {
+		return err
+	}
+
+	valeurs, err := h.service.ListValeursByOption(contexteRequete(c), optionID, boutiqueID)
+	if err != nil {
+		if err.Error() == "option non trouvée" || errors.Is(err, service.ErrProduitIntrouvable) {
+			return c.Status(404).JSON(fiber.Map{"error": "Option non trouvée"})
+		}
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.Status(200).JSON(fiber.Map{"valeurs": valeurs})
+}
+
+// PUT /api/options/:optionId/valeurs/ordre
+func (h *OptionProduitHandler) ReordonnerValeurs(c *fiber.Ctx) error {
+	optionID := c.Params("optionId")
+	if optionID == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "ID option requis"})
+	}
+
+	var req dto.RequeteOrdre
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Format JSON invalide"})
+	}
+	if err := validate.Struct(req); err != nil {
+		return c.Status(422).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	boutiqueID, err := getBoutiqueID(c)
+	if err != nil {
 		return err
 	}
 
-	valeurs, err := h.service.ListValeursByOption(c.Context(), optionID)
+	valeurs, err := h.service.ReordonnerValeurs(contexteRequete(c), optionID, boutiqueID, req.IDs)
 	if err != nil {
+		if err.Error() == "option non trouvée" || errors.Is(err, service.ErrProduitIntrouvable) {
+			return c.Status(404).JSON(fiber.Map{"error": "Option non trouvée"})
+		}
+		if errors.Is(err, service.ErrOrdreIncomplet) {
+			return c.Status(422).JSON(fiber.Map{"error": err.Error()})
+		}
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
 
@@ -195,7 +341,7 @@ func (h *OptionProduitHandler) GetValeurByID(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "ID valeur requis"})
 	}
 
-	if _, err := h.getBoutiqueID(c); err != nil {
+	if _, err := getBoutiqueID(c); err != nil {
 		return err
 	}
 
@@ -215,19 +361,35 @@ func (h *OptionProduitHandler) UpdateValeur(c *fiber.Ctx) error {
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": "Format JSON invalide"})
 	}
+	if err := validate.Struct(req); err != nil {
+		return c.Status(422).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	boutiqueID, err := getBoutiqueID(c)
+	if err != nil {
+		return err
+	}
 
-	if _, err := h.getBoutiqueID(c); err != nil {
+	version, err := versionIfMatch(c)
+	if err != nil {
 		return err
 	}
 
-	valeur, err := h.service.UpdateValeur(c.Context(), valeurID, req)
+	valeur, err := h.service.UpdateValeur(contexteRequete(c), valeurID, boutiqueID, req, version)
 	if err != nil {
-		if err.Error() == "valeur non trouvée" {
+		if errors.Is(err, service.ErrCodeValeurPris) {
+			return c.Status(409).JSON(fiber.Map{"error": err.Error()})
+		}
+		if err.Error() == "valeur non trouvée" || errors.Is(err, service.ErrProduitIntrouvable) {
 			return c.Status(404).JSON(fiber.Map{"error": "Valeur non trouvée"})
 		}
+		if versionObsolete(err) {
+			return reponseVersionObsolete(c)
+		}
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
 
+	definirETag(c, valeur.Version)
 	return c.Status(200).JSON(valeur)
 }
 
@@ -238,15 +400,33 @@ func (h *OptionProduitHandler) DeleteValeur(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "ID valeur requis"})
 	}
 
-	if _, err := h.getBoutiqueID(c); err != nil {
+	boutiqueID, err := getBoutiqueID(c)
+	if err != nil {
+		return err
+	}
+
+	version, err := versionIfMatch(c)
+	if err != nil {
 		return err
 	}
 
-	err := h.service.DeleteValeur(c.Context(), valeurID)
+	cascade, err := modeCascade(c)
 	if err != nil {
-		if err.Error() == "valeur non trouvée" {
+		return err
+	}
+
+	err = h.service.DeleteValeur(contexteRequete(c), valeurID, boutiqueID, version, cascade)
+	if err != nil {
+		var liees *service.ErreurVariantesLiees
+		if errors.As(err, &liees) {
+			return reponseVariantesLiees(c, liees)
+		}
+		if err.Error() == "valeur non trouvée" || errors.Is(err, service.ErrProduitIntrouvable) {
 			return c.Status(404).JSON(fiber.Map{"error": "Valeur non trouvée"})
 		}
+		if versionObsolete(err) {
+			return reponseVersionObsolete(c)
+		}
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
 
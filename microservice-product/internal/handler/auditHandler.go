@@ -0,0 +1,35 @@
+package handler
+
+import (
+	"projet/internal/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type AuditHandler struct {
+	service *service.AuditService
+}
+
+func NewAuditHandler(service *service.AuditService) *AuditHandler {
+	return &AuditHandler{service: service}
+}
+
+// GET /produits/:id/historique?page=&limit=
+func (h *AuditHandler) HistoriqueProduit(c *fiber.Ctx) error {
+	id := c.Params("id")
+	boutiqueID, err := getBoutiqueID(c)
+	if err != nil {
+		return err
+	}
+
+	entrees, total, page, limite, err := h.service.HistoriqueProduit(c.Context(), id, boutiqueID, c.QueryInt("page", 1), c.QueryInt("limit", 20))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch history"})
+	}
+	return c.JSON(fiber.Map{
+		"historique": entrees,
+		"total":      total,
+		"page":       page,
+		"limite":     limite,
+	})
+}
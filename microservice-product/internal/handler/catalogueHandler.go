@@ -0,0 +1,38 @@
+package handler
+
+import (
+	"errors"
+	"projet/internal/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type CatalogueHandler struct {
+	produits *service.ProduitService
+}
+
+func NewCatalogueHandler(produits *service.ProduitService) *CatalogueHandler {
+	return &CatalogueHandler{produits: produits}
+}
+
+// GET /catalogue/lookup?code=
+func (h *CatalogueHandler) Lookup(c *fiber.Ctx) error {
+	boutiqueID, err := getBoutiqueID(c)
+	if err != nil {
+		return err
+	}
+
+	code := c.Query("code")
+	if code == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Paramètre code requis"})
+	}
+
+	resultat, err := h.produits.RechercherCode(contexteRequete(c), boutiqueID, code)
+	if err != nil {
+		if errors.Is(err, service.ErrCodeIntrouvable) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusOK).JSON(resultat)
+}
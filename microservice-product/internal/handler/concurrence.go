@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"projet/internal/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ============================================================
+// Verrouillage optimiste : ETag = version de la ligne
+// ============================================================
+
+// definirETag expose la version courante ; le client la renvoie dans If-Match
+func definirETag(c *fiber.Ctx, version int) {
+	c.Set(fiber.HeaderETag, `"`+strconv.Itoa(version)+`"`)
+}
+
+// versionIfMatch lit l'en-tête If-Match ("3", W/"3" ou *).
+// nil signifie "pas de condition" : en-tête absent ou "*".
+func versionIfMatch(c *fiber.Ctx) (*int, error) {
+	valeur := strings.TrimSpace(c.Get(fiber.HeaderIfMatch))
+	if valeur == "" || valeur == "*" {
+		return nil, nil
+	}
+	valeur = strings.TrimPrefix(valeur, "W/")
+	valeur = strings.Trim(valeur, `"`)
+	version, err := strconv.Atoi(valeur)
+	if err != nil || version < 1 {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "If-Match invalide")
+	}
+	return &version, nil
+}
+
+// versionObsolete vaut true si err signale un If-Match dépassé
+func versionObsolete(err error) bool {
+	return errors.Is(err, service.ErrVersionObsolete)
+}
+
+func reponseVersionObsolete(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusPreconditionFailed).JSON(fiber.Map{
+		"error": "La ressource a été modifiée entre-temps, rechargez-la puis réessayez",
+	})
+}
+
+// ExigerIfMatch refuse (428) les écritures sans If-Match quand exiger vaut true.
+// Sans exigence, l'en-tête reste honoré s'il est présent.
+func ExigerIfMatch(exiger bool) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !exiger {
+			return c.Next()
+		}
+		// un réordonnancement ou un jeu de paliers porte sur une liste, sans version propre
+		chemin := strings.TrimSuffix(c.Path(), "/")
+		if strings.HasSuffix(chemin, "/ordre") || strings.HasSuffix(chemin, "/paliers") {
+			return c.Next()
+		}
+		switch c.Method() {
+		case fiber.MethodPut, fiber.MethodPatch, fiber.MethodDelete:
+			if c.Get(fiber.HeaderIfMatch) == "" {
+				return c.Status(fiber.StatusPreconditionRequired).JSON(fiber.Map{"error": "En-tête If-Match requis"})
+			}
+		}
+		return c.Next()
+	}
+}
@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"context"
+	"projet/internal/jeton"
+	"projet/internal/service"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// getBoutiqueID lit la boutique de la requête (X-Boutique-ID), 401 si absente
+func getBoutiqueID(c *fiber.Ctx) (string, error) {
+	boutiqueID := c.Get("X-Boutique-ID")
+	if boutiqueID == "" {
+		return "", fiber.NewError(fiber.StatusUnauthorized, "Missing store context")
+	}
+	return boutiqueID, nil
+}
+
+// contexteRequete enrichit le contexte de la requête avec l'acteur (X-User-ID)
+// et la boutique, repris par le journal d'audit des services
+func contexteRequete(c *fiber.Ctx) context.Context {
+	return service.AvecActeur(c.Context(), c.Get("X-User-ID"), c.Get("X-Boutique-ID"))
+}
+
+// boutiqueAutorisee vérifie que boutiqueID figure dans X-Boutiques-Autorisees
+func boutiqueAutorisee(c *fiber.Ctx, boutiqueID string) bool {
+	for _, id := range strings.Split(c.Get("X-Boutiques-Autorisees"), ",") {
+		if strings.TrimSpace(id) == boutiqueID {
+			return true
+		}
+	}
+	return false
+}
+
+// cleGroupeClient : code du groupe de clients posé par GroupeClient
+const cleGroupeClient = "groupeClient"
+
+// cleJeton : revendications du jeton vérifié par GroupeClient (absentes sans jeton)
+const cleJeton = "jeton"
+
+// GroupeClient retient le groupe de clients de la requête. Avec un secret
+// configuré, seul le claim "groupe" d'un jeton Bearer vérifié compte (401 si
+// le jeton est invalide) ; sans jeton, ce sont les prix publics, l'en-tête
+// X-Groupe-Client étant falsifiable. Sans secret, la passerelle est de
+// confiance et pose X-Groupe-Client.
+func GroupeClient(secret string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if secret == "" {
+			c.Locals(cleGroupeClient, strings.TrimSpace(c.Get("X-Groupe-Client")))
+			return c.Next()
+		}
+		autorisation := c.Get(fiber.HeaderAuthorization)
+		if !strings.HasPrefix(autorisation, "Bearer ") {
+			c.Locals(cleGroupeClient, "")
+			return c.Next()
+		}
+		claims, err := jeton.Verifier(strings.TrimPrefix(autorisation, "Bearer "), secret, time.Now())
+		if err != nil {
+			return fiber.NewError(fiber.StatusUnauthorized, err.Error())
+		}
+		c.Locals(cleGroupeClient, claims.Groupe)
+		c.Locals(cleJeton, claims)
+		return c.Next()
+	}
+}
+
+// groupeRequete : groupe retenu par GroupeClient ; ?groupe= permet au
+// marchand de prévisualiser les prix d'un groupe de sa boutique. L'aperçu
+// exige un jeton vérifié qui liste la boutique (claim "boutiques") : sans
+// secret configuré, ?groupe= est ignoré.
+func groupeRequete(c *fiber.Ctx) string {
+	if apercu := strings.TrimSpace(c.Query("groupe")); apercu != "" {
+		if claims, ok := c.Locals(cleJeton).(*jeton.Claims); ok && claims.AdministreBoutique(c.Get("X-Boutique-ID")) {
+			return apercu
+		}
+	}
+	groupe, _ := c.Locals(cleGroupeClient).(string)
+	return groupe
+}
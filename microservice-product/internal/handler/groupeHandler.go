@@ -0,0 +1,134 @@
+package handler
+
+import (
+	"projet/internal/dto"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// POST /groupes-clients
+func (h *TarifHandler) CreateGroupe(c *fiber.Ctx) error {
+	var req dto.RequeteCreationGroupe
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Format JSON invalide"})
+	}
+	req.Code = strings.ToLower(strings.TrimSpace(req.Code))
+	if err := validate.Struct(req); err != nil {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	boutiqueID, err := getBoutiqueID(c)
+	if err != nil {
+		return err
+	}
+
+	groupe, err := h.service.CreateGroupe(contexteRequete(c), boutiqueID, req)
+	if err != nil {
+		if traite, reponse := reponseTarif(c, err); traite {
+			return reponse
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusCreated).JSON(groupe)
+}
+
+// GET /groupes-clients
+func (h *TarifHandler) ListGroupes(c *fiber.Ctx) error {
+	boutiqueID, err := getBoutiqueID(c)
+	if err != nil {
+		return err
+	}
+
+	groupes, err := h.service.ListGroupes(contexteRequete(c), boutiqueID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"groupes": groupes})
+}
+
+// GET /groupes-clients/:id
+func (h *TarifHandler) GetGroupe(c *fiber.Ctx) error {
+	boutiqueID, err := getBoutiqueID(c)
+	if err != nil {
+		return err
+	}
+
+	groupe, err := h.service.GetGroupe(contexteRequete(c), c.Params("id"), boutiqueID)
+	if err != nil {
+		if traite, reponse := reponseTarif(c, err); traite {
+			return reponse
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusOK).JSON(groupe)
+}
+
+// PATCH /groupes-clients/:id
+func (h *TarifHandler) UpdateGroupe(c *fiber.Ctx) error {
+	var req dto.RequeteUpdateGroupe
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Format JSON invalide"})
+	}
+	if err := validate.Struct(req); err != nil {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	boutiqueID, err := getBoutiqueID(c)
+	if err != nil {
+		return err
+	}
+
+	groupe, err := h.service.UpdateGroupe(contexteRequete(c), c.Params("id"), boutiqueID, req)
+	if err != nil {
+		if traite, reponse := reponseTarif(c, err); traite {
+			return reponse
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusOK).JSON(groupe)
+}
+
+// DELETE /groupes-clients/:id
+func (h *TarifHandler) DeleteGroupe(c *fiber.Ctx) error {
+	boutiqueID, err := getBoutiqueID(c)
+	if err != nil {
+		return err
+	}
+
+	if err := h.service.DeleteGroupe(contexteRequete(c), c.Params("id"), boutiqueID); err != nil {
+		if traite, reponse := reponseTarif(c, err); traite {
+			return reponse
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"ok": true})
+}
+
+// PUT /groupes-clients/:id/regles
+func (h *TarifHandler) DefinirRegles(c *fiber.Ctx) error {
+	var req dto.RequeteReglesGroupe
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Format JSON invalide"})
+	}
+	if err := verifierMontants(c.Body(), &req); err != nil {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err := validate.Struct(req); err != nil {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	boutiqueID, err := getBoutiqueID(c)
+	if err != nil {
+		return err
+	}
+
+	groupe, err := h.service.DefinirRegles(contexteRequete(c), c.Params("id"), boutiqueID, req)
+	if err != nil {
+		if traite, reponse := reponseTarif(c, err); traite {
+			return reponse
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusOK).JSON(groupe)
+}
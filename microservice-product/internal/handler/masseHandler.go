@@ -0,0 +1,160 @@
+package handler
+
+import (
+	"errors"
+	"projet/internal/dto"
+	"projet/internal/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type MasseHandler struct {
+	service *service.MasseService
+}
+
+func NewMasseHandler(service *service.MasseService) *MasseHandler {
+	return &MasseHandler{service: service}
+}
+
+// POST /produits/bulk
+// 200 si tout est passé, 207 si certaines opérations ont échoué (détail par
+// opération dans resultats), 409 si le mode tout_ou_rien a tout annulé.
+func (h *MasseHandler) ExecuterMasse(c *fiber.Ctx) error {
+	var req dto.RequeteMasse
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid JSON"})
+	}
+	if err := verifierMontants(c.Body(), &req); err != nil {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	if err := validate.Struct(req); err != nil {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	boutiqueID, err := getBoutiqueID(c)
+	if err != nil {
+		return err
+	}
+
+	reponse, err := h.service.Executer(contexteRequete(c), boutiqueID, req)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	switch {
+	case reponse.Annulee:
+		return c.Status(fiber.StatusConflict).JSON(reponse)
+	case reponse.Echouees > 0:
+		return c.Status(fiber.StatusMultiStatus).JSON(reponse)
+	}
+	return c.Status(fiber.StatusOK).JSON(reponse)
+}
+
+// ------------------------------------------------------------
+// Règles de prix
+// ------------------------------------------------------------
+
+// reponseReglePrix : 422 pour une règle invalide ou une sélection trop large,
+// 412 si un article a été modifié pendant l'application
+func reponseReglePrix(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, service.ErrReglePrix), errors.Is(err, service.ErrSelectionTropLarge):
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+	case versionObsolete(err):
+		return reponseVersionObsolete(c)
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+}
+
+func (h *MasseHandler) lireReglePrix(c *fiber.Ctx) (*dto.RequeteReglePrix, error) {
+	var req dto.RequeteReglePrix
+	if err := c.BodyParser(&req); err != nil {
+		return nil, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid JSON"})
+	}
+	if err := verifierMontants(c.Body(), &req); err != nil {
+		return nil, c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err := validate.Struct(req); err != nil {
+		return nil, c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+	}
+	return &req, nil
+}
+
+// POST /produits/regles-prix/apercu
+// prix avant / après de chaque article visé, sans rien écrire
+func (h *MasseHandler) ApercuReglePrix(c *fiber.Ctx) error {
+	boutiqueID, err := getBoutiqueID(c)
+	if err != nil {
+		return err
+	}
+	req, err := h.lireReglePrix(c)
+	if req == nil {
+		return err
+	}
+
+	reponse, err := h.service.ApercuReglePrix(contexteRequete(c), boutiqueID, *req)
+	if err != nil {
+		return reponseReglePrix(c, err)
+	}
+	return c.JSON(reponse)
+}
+
+// POST /produits/regles-prix/appliquer
+// 200 avec l'ID de l'ajustement enregistré ; 409 avec les lignes en erreur
+// si un seul article ne peut pas recevoir son nouveau prix (rien n'est écrit)
+func (h *MasseHandler) AppliquerReglePrix(c *fiber.Ctx) error {
+	boutiqueID, err := getBoutiqueID(c)
+	if err != nil {
+		return err
+	}
+	req, err := h.lireReglePrix(c)
+	if req == nil {
+		return err
+	}
+
+	reponse, err := h.service.AppliquerReglePrix(contexteRequete(c), boutiqueID, *req)
+	if errors.Is(err, service.ErrReglePrixLignes) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error(), "detail": reponse})
+	}
+	if err != nil {
+		return reponseReglePrix(c, err)
+	}
+	return c.JSON(reponse)
+}
+
+// GET /produits/regles-prix/ajustements?page=&limit=
+func (h *MasseHandler) ListAjustements(c *fiber.Ctx) error {
+	boutiqueID, err := getBoutiqueID(c)
+	if err != nil {
+		return err
+	}
+
+	ajustements, total, page, limite, err := h.service.ListAjustements(c.Context(), boutiqueID, c.QueryInt("page", 1), c.QueryInt("limit", 20))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch price adjustments"})
+	}
+	return c.JSON(fiber.Map{
+		"ajustements": ajustements,
+		"total":       total,
+		"page":        page,
+		"limite":      limite,
+	})
+}
+
+// GET /produits/regles-prix/ajustements/:id
+func (h *MasseHandler) GetAjustement(c *fiber.Ctx) error {
+	boutiqueID, err := getBoutiqueID(c)
+	if err != nil {
+		return err
+	}
+
+	ajustement, err := h.service.GetAjustement(c.Context(), c.Params("id"), boutiqueID)
+	if errors.Is(err, service.ErrAjustementIntrouvable) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch price adjustment"})
+	}
+	return c.JSON(ajustement)
+}
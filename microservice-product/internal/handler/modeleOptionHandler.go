@@ -0,0 +1,170 @@
+package handler
+
+import (
+	"errors"
+	"projet/internal/dto"
+	"projet/internal/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type ModeleOptionHandler struct {
+	service *service.ModeleOptionService
+}
+
+func NewModeleOptionHandler(service *service.ModeleOptionService) *ModeleOptionHandler {
+	return &ModeleOptionHandler{service: service}
+}
+
+// reponseErreurModele traduit les erreurs communes du service
+func reponseErreurModele(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, service.ErrModeleIntrouvable):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Modèle non trouvé"})
+	case errors.Is(err, service.ErrValeursModele):
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+	case versionObsolete(err):
+		return reponseVersionObsolete(c)
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+}
+
+// POST /modeles-options
+func (h *ModeleOptionHandler) CreateModele(c *fiber.Ctx) error {
+	var req dto.RequeteCreationModeleOption
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Format JSON invalide"})
+	}
+	if err := validate.Struct(req); err != nil {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	boutiqueID, err := getBoutiqueID(c)
+	if err != nil {
+		return err
+	}
+
+	modele, err := h.service.Create(contexteRequete(c), boutiqueID, req)
+	if err != nil {
+		return reponseErreurModele(c, err)
+	}
+
+	definirETag(c, modele.Version)
+	return c.Status(fiber.StatusCreated).JSON(modele)
+}
+
+// GET /modeles-options
+func (h *ModeleOptionHandler) ListModeles(c *fiber.Ctx) error {
+	boutiqueID, err := getBoutiqueID(c)
+	if err != nil {
+		return err
+	}
+
+	modeles, err := h.service.List(contexteRequete(c), boutiqueID)
+	if err != nil {
+		return reponseErreurModele(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"modeles": modeles})
+}
+
+// GET /modeles-options/:id
+func (h *ModeleOptionHandler) GetModele(c *fiber.Ctx) error {
+	boutiqueID, err := getBoutiqueID(c)
+	if err != nil {
+		return err
+	}
+
+	modele, err := h.service.GetByID(contexteRequete(c), c.Params("id"), boutiqueID)
+	if err != nil {
+		return reponseErreurModele(c, err)
+	}
+
+	definirETag(c, modele.Version)
+	return c.Status(fiber.StatusOK).JSON(modele)
+}
+
+// PUT /modeles-options/:id
+func (h *ModeleOptionHandler) UpdateModele(c *fiber.Ctx) error {
+	var req dto.RequeteUpdateModeleOption
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Format JSON invalide"})
+	}
+	if err := validate.Struct(req); err != nil {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	boutiqueID, err := getBoutiqueID(c)
+	if err != nil {
+		return err
+	}
+	version, err := versionIfMatch(c)
+	if err != nil {
+		return err
+	}
+
+	resultat, err := h.service.Update(contexteRequete(c), c.Params("id"), boutiqueID, req, version)
+	if err != nil {
+		return reponseErreurModele(c, err)
+	}
+
+	definirETag(c, resultat.Modele.Version)
+	return c.Status(fiber.StatusOK).JSON(resultat)
+}
+
+// DELETE /modeles-options/:id
+func (h *ModeleOptionHandler) DeleteModele(c *fiber.Ctx) error {
+	boutiqueID, err := getBoutiqueID(c)
+	if err != nil {
+		return err
+	}
+	version, err := versionIfMatch(c)
+	if err != nil {
+		return err
+	}
+
+	if err := h.service.Delete(contexteRequete(c), c.Params("id"), boutiqueID, version); err != nil {
+		return reponseErreurModele(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"ok": true})
+}
+
+// POST /produits/:produitId/options/depuis-modele
+func (h *ModeleOptionHandler) AppliquerModele(c *fiber.Ctx) error {
+	produitID := c.Params("produitId")
+	if produitID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ID produit requis"})
+	}
+
+	var req dto.RequeteApplicationModele
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Format JSON invalide"})
+	}
+	if err := validate.Struct(req); err != nil {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	boutiqueID, err := getBoutiqueID(c)
+	if err != nil {
+		return err
+	}
+
+	cascade, err := modeCascade(c)
+	if err != nil {
+		return err
+	}
+
+	option, err := h.service.Appliquer(contexteRequete(c), produitID, boutiqueID, req, cascade)
+	if err != nil {
+		var liees *service.ErreurVariantesLiees
+		if errors.As(err, &liees) {
+			return reponseVariantesLiees(c, liees)
+		}
+		if err.Error() == "product not found" {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Produit non trouvé"})
+		}
+		return reponseErreurModele(c, err)
+	}
+
+	definirETag(c, option.Version)
+	return c.Status(fiber.StatusCreated).JSON(option)
+}
@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"projet/internal/monnaie"
+)
+
+// errMontantNu : en FORMAT_MONTANTS=unites_mineures les réponses écrivent
+// 1999 pour 19.99 EUR. Un nombre nu dans une requête serait ambigu (1999
+// unités ou 1999 centimes ?) et la devise n'est pas toujours connue au
+// décodage : les montants des requêtes s'écrivent alors en chaîne décimale.
+var errMontantNu = errors.New(`en unités mineures, les montants des requêtes s'écrivent en chaîne décimale ("19.99")`)
+
+var typeMontant = reflect.TypeOf(monnaie.Montant(0))
+
+// verifierMontants refuse, en mode unités mineures, un champ monnaie.Montant
+// de cible écrit en nombre nu dans corps. Les erreurs de syntaxe sont
+// laissées au décodage de l'appelant.
+func verifierMontants(corps []byte, cible interface{}) error {
+	if monnaie.FormatActuel() != monnaie.FormatUnitesMineures {
+		return nil
+	}
+	var brut interface{}
+	dec := json.NewDecoder(bytes.NewReader(corps))
+	dec.UseNumber()
+	if err := dec.Decode(&brut); err != nil {
+		return nil
+	}
+	return montantsNus(brut, reflect.TypeOf(cible), "")
+}
+
+func montantsNus(valeur interface{}, t reflect.Type, chemin string) error {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == typeMontant {
+		if _, nu := valeur.(json.Number); nu {
+			return fmt.Errorf("%w: %s", errMontantNu, chemin)
+		}
+		return nil
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		objet, ok := valeur.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		for i := 0; i < t.NumField(); i++ {
+			champ := t.Field(i)
+			nom := strings.Split(champ.Tag.Get("json"), ",")[0]
+			if champ.Anonymous && nom == "" {
+				// struct embarquée : ses champs sont au même niveau
+				if err := montantsNus(objet, champ.Type, chemin); err != nil {
+					return err
+				}
+				continue
+			}
+			if nom == "" || nom == "-" || !champ.IsExported() {
+				continue
+			}
+			if sous, ok := objet[nom]; ok {
+				if err := montantsNus(sous, champ.Type, joindreChemin(chemin, nom)); err != nil {
+					return err
+				}
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		liste, ok := valeur.([]interface{})
+		if !ok {
+			return nil
+		}
+		for i, element := range liste {
+			if err := montantsNus(element, t.Elem(), fmt.Sprintf("%s[%d]", chemin, i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		objet, ok := valeur.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		for cle, element := range objet {
+			if err := montantsNus(element, t.Elem(), joindreChemin(chemin, cle)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func joindreChemin(chemin, nom string) string {
+	if chemin == "" {
+		return nom
+	}
+	return chemin + "." + nom
+}
@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+
+	"projet/internal/patch"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// appliquerPatch applique le corps de la requête PATCH sur document selon le
+// Content-Type (merge patch RFC 7396 ou JSON Patch RFC 6902) et décode le
+// résultat dans cible. En cas d'échec, renvoie le code HTTP à répondre.
+// La validation du résultat reste à la charge de l'appelant.
+func appliquerPatch(c *fiber.Ctx, document, cible interface{}) (int, error) {
+	original, err := json.Marshal(document)
+	if err != nil {
+		return fiber.StatusInternalServerError, err
+	}
+
+	typeContenu := strings.TrimSpace(strings.Split(c.Get(fiber.HeaderContentType), ";")[0])
+	var resultat []byte
+	switch typeContenu {
+	// application/json accepté comme merge patch : c'est ce que la plupart des clients envoient
+	case patch.TypeMergePatch, fiber.MIMEApplicationJSON:
+		resultat, err = patch.Fusionner(original, c.Body())
+	case patch.TypeJSONPatch:
+		resultat, err = patch.Appliquer(original, c.Body())
+	default:
+		return fiber.StatusUnsupportedMediaType, errors.New("Content-Type non supporté, utilisez " +
+			patch.TypeMergePatch + " ou " + patch.TypeJSONPatch)
+	}
+	if err != nil {
+		if errors.Is(err, patch.ErrTestEchoue) {
+			return fiber.StatusConflict, err
+		}
+		return fiber.StatusBadRequest, err
+	}
+
+	// null (ou un champ retiré) décoderait la valeur zéro : refusé pour les
+	// champs qui ne peuvent pas être vides (prix_defaut, quantite_stock...)
+	var champs map[string]json.RawMessage
+	if err := json.Unmarshal(resultat, &champs); err != nil {
+		return fiber.StatusUnprocessableEntity, errors.New("document patché invalide: " + err.Error())
+	}
+	for _, nom := range champsNonNullables(cible) {
+		if valeur, ok := champs[nom]; !ok || string(valeur) == "null" {
+			return fiber.StatusUnprocessableEntity, errors.New("document patché invalide: " + nom + " ne peut pas être null")
+		}
+	}
+
+	if err := verifierMontants(resultat, cible); err != nil {
+		return fiber.StatusUnprocessableEntity, err
+	}
+
+	// un champ inconnu dans le résultat est une erreur de patch, pas un champ ignoré
+	dec := json.NewDecoder(bytes.NewReader(resultat))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(cible); err != nil {
+		return fiber.StatusUnprocessableEntity, errors.New("document patché invalide: " + err.Error())
+	}
+	return 0, nil
+}
+
+// champsNonNullables liste les noms JSON des champs de cible (pointeur sur
+// struct) dont le type n'admet pas nil
+func champsNonNullables(cible interface{}) []string {
+	t := reflect.TypeOf(cible)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	var noms []string
+	for i := 0; i < t.NumField(); i++ {
+		champ := t.Field(i)
+		switch champ.Type.Kind() {
+		case reflect.Ptr, reflect.Slice, reflect.Map, reflect.Interface:
+			continue
+		}
+		nom := strings.Split(champ.Tag.Get("json"), ",")[0]
+		if nom == "" || nom == "-" {
+			continue
+		}
+		noms = append(noms, nom)
+	}
+	return noms
+}
@@ -1,7 +1,9 @@
 package handler
 
 import (
+	"errors"
 	"projet/internal/dto"
+	"projet/internal/models"
 	services "projet/internal/service"
 
 	"github.com/go-playground/validator/v10"
@@ -14,21 +16,13 @@ var validate = validator.New()
 // hedhi injection de dépendance
 type ProduitHandler struct {
 	service *services.ProduitService
+	tarifs  *services.TarifService
 }
 
 // Hedha constructeur
 // we want a pointer to modify the original instance
-func NewProduitHandler(service *services.ProduitService) *ProduitHandler {
-	return &ProduitHandler{service: service}
-}
-
-func (h *ProduitHandler) getBoutiqueID(c *fiber.Ctx) (string, error) {
-	//bech t9ra l headers mt3 http
-	boutiqueID := c.Get("X-Boutique-ID")
-	if boutiqueID == "" {
-		return "", fiber.NewError(fiber.StatusUnauthorized, "Missing store context")
-	}
-	return boutiqueID, nil
+func NewProduitHandler(service *services.ProduitService, tarifs *services.TarifService) *ProduitHandler {
+	return &ProduitHandler{service: service, tarifs: tarifs}
 }
 
 func (h *ProduitHandler) CreateProduit(c *fiber.Ctx) error {
@@ -37,51 +31,91 @@ func (h *ProduitHandler) CreateProduit(c *fiber.Ctx) error {
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid JSON"})
 	}
+	if err := verifierMontants(c.Body(), &req); err != nil {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+	}
 
 	if err := validate.Struct(req); err != nil {
 		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
 	}
 
-	boutiqueID, err := h.getBoutiqueID(c)
+	boutiqueID, err := getBoutiqueID(c)
 	if err != nil {
 		return err
 	}
 
-	produit, err := h.service.Create(c.Context(), boutiqueID, req)
+	produit, err := h.service.Create(contexteRequete(c), boutiqueID, req)
 	if err != nil {
+		var errTransition *services.ErreurTransition
+		if errors.As(err, &errTransition) {
+			return reponseTransition(c, errTransition)
+		}
+		if errors.Is(err, services.ErrCodeBarresPris) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+		}
+		if traite, reponse := reponsePromotion(c, err); traite {
+			return reponse
+		}
+		if traite, reponse := reponseSKU(c, err); traite {
+			return reponse
+		}
+		var errAgregat *services.ErreurAgregat
+		if errors.As(err, &errAgregat) {
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+				"error":     "Produit invalide, rien n'a été créé",
+				"problemes": errAgregat.Problemes,
+			})
+		}
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
 	}
 	return c.Status(fiber.StatusCreated).JSON(produit)
 }
 
 func (h *ProduitHandler) ListProduits(c *fiber.Ctx) error {
-	boutiqueID, err := h.getBoutiqueID(c)
+	boutiqueID, err := getBoutiqueID(c)
 	if err != nil {
 		return err
 	}
 
-	produits, err := h.service.List(c.Context(), boutiqueID)
+	contexte, err := contexteTarif(c)
+	if err != nil {
+		return err
+	}
+
+	produits, err := h.service.List(contexteRequete(c), boutiqueID)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch products"})
 	}
+	if err := h.presenter(c, boutiqueID, contexte, produits); err != nil {
+		return err
+	}
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{"produits": produits})
 }
 
 func (h *ProduitHandler) GetProduitByID(c *fiber.Ctx) error {
 	//for path parameters kima produits/1<-
 	id := c.Params("id")
-	boutiqueID, err := h.getBoutiqueID(c)
+	boutiqueID, err := getBoutiqueID(c)
 	if err != nil {
 		return err
 	}
 
-	produit, err := h.service.GetByID(c.Context(), id, boutiqueID)
+	contexte, err := contexteTarif(c)
+	if err != nil {
+		return err
+	}
+
+	produit, err := h.service.GetByID(contexteRequete(c), id, boutiqueID)
 	if err != nil {
 		if err.Error() == "product not found" {
 			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Product not found"})
 		}
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch product"})
 	}
+	if err := h.tarifs.PresenterProduits(contexteRequete(c), boutiqueID, contexte, produit); err != nil {
+		return reponsePresentation(c, err)
+	}
+	definirETag(c, produit.Version)
 	return c.Status(fiber.StatusOK).JSON(produit)
 }
 
@@ -94,17 +128,172 @@ func (h *ProduitHandler) UpdateProduit(c *fiber.Ctx) error {
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid JSON"})
 	}
+	if err := verifierMontants(c.Body(), &req); err != nil {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	if err := validate.Struct(req); err != nil {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	boutiqueID, err := getBoutiqueID(c)
+	if err != nil {
+		return err
+	}
+
+	version, err := versionIfMatch(c)
+	if err != nil {
+		return err
+	}
+
+	produit, err := h.service.Update(contexteRequete(c), id, boutiqueID, req, version)
+	if err != nil {
+		if err.Error() == "product not found" {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Product not found"})
+		}
+		if versionObsolete(err) {
+			return reponseVersionObsolete(c)
+		}
+		var errTransition *services.ErreurTransition
+		if errors.As(err, &errTransition) {
+			return reponseTransition(c, errTransition)
+		}
+		if traite, reponse := reponsePromotion(c, err); traite {
+			return reponse
+		}
+		if traite, reponse := reponseSKU(c, err); traite {
+			return reponse
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	definirETag(c, produit.Version)
+	return c.Status(fiber.StatusOK).JSON(produit)
+}
+
+// PATCH /produits/:id
+// Content-Type application/merge-patch+json (null efface le champ) ou
+// application/json-patch+json ; le produit résultant est validé en entier.
+func (h *ProduitHandler) PatchProduit(c *fiber.Ctx) error {
+	id := c.Params("id")
+	boutiqueID, err := getBoutiqueID(c)
+	if err != nil {
+		return err
+	}
+
+	ifMatch, err := versionIfMatch(c)
+	if err != nil {
+		return err
+	}
+
+	document, version, err := h.service.Document(contexteRequete(c), id, boutiqueID)
+	if err != nil {
+		if err.Error() == "product not found" {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Product not found"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	// le patch est calculé sur cette version : sans If-Match on la verrouille quand même
+	if ifMatch != nil && *ifMatch != version {
+		return reponseVersionObsolete(c)
+	}
+
+	var modifie dto.DocumentProduit
+	if statut, err := appliquerPatch(c, document, &modifie); err != nil {
+		return c.Status(statut).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err := validate.Struct(modifie); err != nil {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	produit, err := h.service.Remplacer(contexteRequete(c), id, boutiqueID, modifie, version)
+	if err != nil {
+		if err.Error() == "product not found" {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Product not found"})
+		}
+		if versionObsolete(err) {
+			return reponseVersionObsolete(c)
+		}
+		var errTransition *services.ErreurTransition
+		if errors.As(err, &errTransition) {
+			return reponseTransition(c, errTransition)
+		}
+		if traite, reponse := reponsePromotion(c, err); traite {
+			return reponse
+		}
+		if traite, reponse := reponseSKU(c, err); traite {
+			return reponse
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	definirETag(c, produit.Version)
+	return c.Status(fiber.StatusOK).JSON(produit)
+}
+
+// POST /produits/:id/dupliquer
+// Copier vers une autre boutique exige qu'elle figure dans X-Boutiques-Autorisees
+// (liste séparée par des virgules, posée par la passerelle pour les propriétaires multi-boutiques).
+func (h *ProduitHandler) DupliquerProduit(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	var req dto.RequeteDuplication
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid JSON"})
+		}
+	}
 
 	if err := validate.Struct(req); err != nil {
 		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
 	}
 
-	boutiqueID, err := h.getBoutiqueID(c)
+	boutiqueID, err := getBoutiqueID(c)
+	if err != nil {
+		return err
+	}
+
+	if req.BoutiqueID != nil && *req.BoutiqueID != boutiqueID && !boutiqueAutorisee(c, *req.BoutiqueID) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Store not allowed"})
+	}
+
+	produit, err := h.service.Dupliquer(contexteRequete(c), id, boutiqueID, req)
+	if err != nil {
+		if err.Error() == "product not found" {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Product not found"})
+		}
+		if traite, reponse := reponseSKU(c, err); traite {
+			return reponse
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusCreated).JSON(produit)
+}
+
+// POST /produits/:id/publier
+func (h *ProduitHandler) PublierProduit(c *fiber.Ctx) error {
+	return h.changerStatut(c, models.StatutPublie)
+}
+
+// POST /produits/:id/archiver
+func (h *ProduitHandler) ArchiverProduit(c *fiber.Ctx) error {
+	return h.changerStatut(c, models.StatutArchive)
+}
+
+// POST /produits/:id/restaurer
+// repasse un produit archivé en brouillon (cycle de vie)
+func (h *ProduitHandler) RestaurerProduit(c *fiber.Ctx) error {
+	return h.changerStatut(c, models.StatutBrouillon)
+}
+
+// POST /produits/corbeille/:id/restaurer
+// sort le produit de la corbeille, son statut est conservé ; 404 s'il n'y est pas
+func (h *ProduitHandler) RestaurerCorbeille(c *fiber.Ctx) error {
+	id := c.Params("id")
+	boutiqueID, err := getBoutiqueID(c)
 	if err != nil {
 		return err
 	}
 
-	produit, err := h.service.Update(c.Context(), id, boutiqueID, req)
+	produit, err := h.service.RestaurerCorbeille(contexteRequete(c), id, boutiqueID)
 	if err != nil {
 		if err.Error() == "product not found" {
 			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Product not found"})
@@ -114,19 +303,107 @@ func (h *ProduitHandler) UpdateProduit(c *fiber.Ctx) error {
 	return c.Status(fiber.StatusOK).JSON(produit)
 }
 
+// GET /produits/corbeille?page=&limit=
+func (h *ProduitHandler) ListCorbeille(c *fiber.Ctx) error {
+	boutiqueID, err := getBoutiqueID(c)
+	if err != nil {
+		return err
+	}
+
+	produits, page, limite, err := h.service.ListCorbeille(contexteRequete(c), boutiqueID, c.QueryInt("page", 1), c.QueryInt("limit", 20))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch deleted products"})
+	}
+	return c.JSON(fiber.Map{
+		"produits": produits,
+		"page":     page,
+		"limite":   limite,
+	})
+}
+
+func (h *ProduitHandler) changerStatut(c *fiber.Ctx, vers models.StatutProduit) error {
+	id := c.Params("id")
+	boutiqueID, err := getBoutiqueID(c)
+	if err != nil {
+		return err
+	}
+
+	version, err := versionIfMatch(c)
+	if err != nil {
+		return err
+	}
+
+	produit, err := h.service.ChangerStatut(contexteRequete(c), id, boutiqueID, vers, version)
+	if err != nil {
+		if err.Error() == "product not found" {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Product not found"})
+		}
+		if versionObsolete(err) {
+			return reponseVersionObsolete(c)
+		}
+		var errTransition *services.ErreurTransition
+		if errors.As(err, &errTransition) {
+			return reponseTransition(c, errTransition)
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	definirETag(c, produit.Version)
+	return c.Status(fiber.StatusOK).JSON(produit)
+}
+
+// reponsePromotion : 422 si la période promotionnelle est incohérente ou
+// si classe_taxe ne désigne aucune classe de la boutique
+func reponsePromotion(c *fiber.Ctx, err error) (bool, error) {
+	if errors.Is(err, services.ErrPromotion) || errors.Is(err, services.ErrClasseTaxe) ||
+		errors.Is(err, services.ErrDatesPublication) {
+		return true, c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+	}
+	return false, nil
+}
+
+// reponseTransition : exigences non remplies -> 422 avec la liste, transition interdite -> 409
+func reponseTransition(c *fiber.Ctx, err *services.ErreurTransition) error {
+	if len(err.ExigencesManquantes) > 0 {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+			"error":                err.Error(),
+			"statut_actuel":        err.De,
+			"statut_cible":         err.Vers,
+			"exigences_manquantes": err.ExigencesManquantes,
+		})
+	}
+	return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+		"error":         err.Error(),
+		"statut_actuel": err.De,
+		"statut_cible":  err.Vers,
+	})
+}
+
 /*ylwj par id tore ou id produit ou faama tests pour les erreurs simple pas besoin de more explanations*/
 func (h *ProduitHandler) DeleteProduit(c *fiber.Ctx) error {
 	id := c.Params("id")
-	boutiqueID, err := h.getBoutiqueID(c)
+	boutiqueID, err := getBoutiqueID(c)
+	if err != nil {
+		return err
+	}
+
+	version, err := versionIfMatch(c)
 	if err != nil {
 		return err
 	}
 
-	err = h.service.Delete(c.Context(), id, boutiqueID)
+	//?definitif=true: suppression physique au lieu de la corbeille
+	if c.QueryBool("definitif") {
+		err = h.service.Purger(contexteRequete(c), id, boutiqueID, version)
+	} else {
+		err = h.service.Delete(contexteRequete(c), id, boutiqueID, version)
+	}
 	if err != nil {
 		if err.Error() == "product not found" {
 			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Product not found"})
 		}
+		if versionObsolete(err) {
+			return reponseVersionObsolete(c)
+		}
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
 	}
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{"ok": true})
@@ -144,16 +421,24 @@ func (h *ProduitHandler) SearchProduits(c *fiber.Ctx) error {
 	}
 
 	//tcherchi par store id
-	boutiqueID, err := h.getBoutiqueID(c)
+	boutiqueID, err := getBoutiqueID(c)
+	if err != nil {
+		return err
+	}
+
+	contexte, err := contexteTarif(c)
 	if err != nil {
 		return err
 	}
 
 	//t3yt ll func illi fi service
-	produits, page, limite, err := h.service.Search(c.Context(), boutiqueID, filter)
+	produits, page, limite, err := h.service.Search(contexteRequete(c), boutiqueID, filter)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to search products"})
 	}
+	if err := h.presenter(c, boutiqueID, contexte, produits); err != nil {
+		return err
+	}
 
 	//houni trj3 nil khtr fmch erreur, ou howwa yriturni erorr ou zeda yiktb response
 	return c.JSON(fiber.Map{
@@ -162,3 +447,15 @@ func (h *ProduitHandler) SearchProduits(c *fiber.Ctx) error {
 		"limite":   limite,
 	})
 }
+
+// presenter : paliers et prix effectifs d'une page de produits, dans la devise demandée
+func (h *ProduitHandler) presenter(c *fiber.Ctx, boutiqueID string, contexte services.ContexteTarif, produits []dto.ProduitResponse) error {
+	ptrs := make([]*dto.ProduitResponse, len(produits))
+	for i := range produits {
+		ptrs[i] = &produits[i]
+	}
+	if err := h.tarifs.PresenterProduits(contexteRequete(c), boutiqueID, contexte, ptrs...); err != nil {
+		return reponsePresentation(c, err)
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"projet/internal/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type RevisionHandler struct {
+	service        *service.RevisionService
+	produitService *service.ProduitService
+}
+
+func NewRevisionHandler(service *service.RevisionService, produitService *service.ProduitService) *RevisionHandler {
+	return &RevisionHandler{service: service, produitService: produitService}
+}
+
+// GET /produits/:id/revisions
+func (h *RevisionHandler) ListRevisions(c *fiber.Ctx) error {
+	id := c.Params("id")
+	boutiqueID, err := getBoutiqueID(c)
+	if err != nil {
+		return err
+	}
+
+	revisions, err := h.service.List(c.Context(), id, boutiqueID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch revisions"})
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"revisions": revisions})
+}
+
+// GET /produits/:id/revisions/:n
+func (h *RevisionHandler) GetRevision(c *fiber.Ctx) error {
+	id := c.Params("id")
+	numero, err := c.ParamsInt("n")
+	if err != nil || numero <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid revision number"})
+	}
+	boutiqueID, err := getBoutiqueID(c)
+	if err != nil {
+		return err
+	}
+
+	revision, err := h.service.GetByNumero(c.Context(), id, boutiqueID, numero)
+	if err != nil {
+		if err.Error() == "revision not found" {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Revision not found"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch revision"})
+	}
+	return c.Status(fiber.StatusOK).JSON(revision)
+}
+
+// GET /produits/:id/revisions/:n/diff?avec=m (par défaut la révision précédente)
+func (h *RevisionHandler) DiffRevisions(c *fiber.Ctx) error {
+	id := c.Params("id")
+	numero, err := c.ParamsInt("n")
+	if err != nil || numero <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid revision number"})
+	}
+	avec := c.QueryInt("avec", numero-1)
+	if avec <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Nothing to compare with"})
+	}
+	boutiqueID, err := getBoutiqueID(c)
+	if err != nil {
+		return err
+	}
+
+	diff, err := h.service.Diff(c.Context(), id, boutiqueID, avec, numero)
+	if err != nil {
+		if err.Error() == "revision not found" {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Revision not found"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusOK).JSON(diff)
+}
+
+// POST /produits/:id/revisions/:n/restaurer
+func (h *RevisionHandler) RestaurerRevision(c *fiber.Ctx) error {
+	id := c.Params("id")
+	numero, err := c.ParamsInt("n")
+	if err != nil || numero <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid revision number"})
+	}
+	boutiqueID, err := getBoutiqueID(c)
+	if err != nil {
+		return err
+	}
+
+	produit, err := h.produitService.RestaurerRevision(contexteRequete(c), id, boutiqueID, numero)
+	if err != nil {
+		switch err.Error() {
+		case "product not found":
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Product not found"})
+		case "revision not found":
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Revision not found"})
+		}
+		// le SKU ou un code-barres a pu être repris ailleurs depuis la révision
+		if traite, reponse := reponseCodeBarres(c, err); traite {
+			return reponse
+		}
+		if traite, reponse := reponseSKU(c, err); traite {
+			return reponse
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusOK).JSON(produit)
+}
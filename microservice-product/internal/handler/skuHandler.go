@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"errors"
+	"projet/internal/dto"
+	"projet/internal/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type SKUHandler struct {
+	produits *service.ProduitService
+}
+
+func NewSKUHandler(produits *service.ProduitService) *SKUHandler {
+	return &SKUHandler{produits: produits}
+}
+
+// reponseSKU : 409 si le SKU (ou une autre valeur unique) est déjà pris,
+// 422 si le modèle de génération est invalide
+func reponseSKU(c *fiber.Ctx, err error) (bool, error) {
+	switch {
+	case errors.Is(err, service.ErrSKUPris), errors.Is(err, service.ErrDoublon):
+		return true, c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, service.ErrModeleSKU):
+		return true, c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+	}
+	return false, nil
+}
+
+// GET /sku/configuration
+func (h *SKUHandler) GetConfiguration(c *fiber.Ctx) error {
+	boutiqueID, err := getBoutiqueID(c)
+	if err != nil {
+		return err
+	}
+
+	config, err := h.produits.ConfigurationSKU(contexteRequete(c), boutiqueID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusOK).JSON(config)
+}
+
+// PUT /sku/configuration
+func (h *SKUHandler) UpdateConfiguration(c *fiber.Ctx) error {
+	var req dto.RequeteConfigurationSKU
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Format JSON invalide"})
+	}
+	if err := validate.Struct(req); err != nil {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	boutiqueID, err := getBoutiqueID(c)
+	if err != nil {
+		return err
+	}
+
+	config, err := h.produits.ModifierConfigurationSKU(contexteRequete(c), boutiqueID, req)
+	if err != nil {
+		if traite, reponse := reponseSKU(c, err); traite {
+			return reponse
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusOK).JSON(config)
+}
+
+// POST /produits/:produitId/variantes/generer-skus
+func (h *SKUHandler) GenererSKUs(c *fiber.Ctx) error {
+	produitID := c.Params("produitId")
+	if produitID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ID produit requis"})
+	}
+
+	var req dto.RequeteGenerationSKU
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Format JSON invalide"})
+		}
+	}
+	if err := validate.Struct(req); err != nil {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	boutiqueID, err := getBoutiqueID(c)
+	if err != nil {
+		return err
+	}
+
+	resultat, err := h.produits.GenererSKUs(contexteRequete(c), produitID, boutiqueID, req)
+	if err != nil {
+		if err.Error() == "product not found" {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Produit non trouvé"})
+		}
+		if traite, reponse := reponseSKU(c, err); traite {
+			return reponse
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusOK).JSON(resultat)
+}
@@ -0,0 +1,336 @@
+package handler
+
+import (
+	"errors"
+	"projet/internal/dto"
+	"projet/internal/service"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type TarifHandler struct {
+	service *service.TarifService
+}
+
+func NewTarifHandler(service *service.TarifService) *TarifHandler {
+	return &TarifHandler{service: service}
+}
+
+// contexteTarif lit ?devise=EUR&pays=FR&region= et le groupe de clients ;
+// sans devise, la réponse reste dans la devise du produit
+func contexteTarif(c *fiber.Ctx) (service.ContexteTarif, error) {
+	contexte := service.ContexteTarif{
+		Devise: strings.ToUpper(strings.TrimSpace(c.Query("devise"))),
+		Pays:   strings.ToUpper(strings.TrimSpace(c.Query("pays"))),
+		Region: strings.ToUpper(strings.TrimSpace(c.Query("region"))),
+		Groupe: groupeRequete(c),
+	}
+	if contexte.Devise != "" && validate.Var(contexte.Devise, "iso4217") != nil {
+		return contexte, fiber.NewError(fiber.StatusBadRequest, "devise invalide (code ISO 4217 attendu)")
+	}
+	if contexte.Pays != "" && validate.Var(contexte.Pays, "iso3166_1_alpha2") != nil {
+		return contexte, fiber.NewError(fiber.StatusBadRequest, "pays invalide (code ISO 3166-1 alpha-2 attendu)")
+	}
+	if contexte.Region != "" && (contexte.Pays == "" || validate.Var(contexte.Region, "max=10,alphanum") != nil) {
+		return contexte, fiber.NewError(fiber.StatusBadRequest, "region invalide (code alphanumérique avec ?pays=)")
+	}
+	return contexte, nil
+}
+
+// reponseTarif : 404 liste, groupe ou classe de taxe inconnus, 409 marché
+// déjà couvert, code pris ou classe utilisée, 422 prix, règle, taux ou
+// conversion impossibles
+func reponseTarif(c *fiber.Ctx, err error) (bool, error) {
+	switch {
+	case errors.Is(err, service.ErrListeIntrouvable), errors.Is(err, service.ErrGroupeIntrouvable),
+		errors.Is(err, service.ErrClasseTaxeIntrouvable):
+		return true, c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, service.ErrMarcheOccupe), errors.Is(err, service.ErrDoublon),
+		errors.Is(err, service.ErrClasseTaxeUtilisee), errors.Is(err, service.ErrClasseTaxeDefaut):
+		return true, c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, service.ErrPrixListe), errors.Is(err, service.ErrTauxIntrouvable), errors.Is(err, service.ErrRegleGroupe),
+		errors.Is(err, service.ErrTauxTaxe), errors.Is(err, service.ErrClasseTaxe):
+		return true, c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+	}
+	return false, nil
+}
+
+// POST /listes-prix
+func (h *TarifHandler) CreateListe(c *fiber.Ctx) error {
+	var req dto.RequeteCreationListePrix
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Format JSON invalide"})
+	}
+	req.Devise = strings.ToUpper(req.Devise)
+	if err := validate.Struct(req); err != nil {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	boutiqueID, err := getBoutiqueID(c)
+	if err != nil {
+		return err
+	}
+
+	liste, err := h.service.CreateListe(contexteRequete(c), boutiqueID, req)
+	if err != nil {
+		if traite, reponse := reponseTarif(c, err); traite {
+			return reponse
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusCreated).JSON(liste)
+}
+
+// GET /listes-prix
+func (h *TarifHandler) ListListes(c *fiber.Ctx) error {
+	boutiqueID, err := getBoutiqueID(c)
+	if err != nil {
+		return err
+	}
+
+	listes, err := h.service.ListListes(contexteRequete(c), boutiqueID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"listes": listes})
+}
+
+// GET /listes-prix/:id
+func (h *TarifHandler) GetListe(c *fiber.Ctx) error {
+	boutiqueID, err := getBoutiqueID(c)
+	if err != nil {
+		return err
+	}
+
+	liste, err := h.service.GetListe(contexteRequete(c), c.Params("id"), boutiqueID)
+	if err != nil {
+		if traite, reponse := reponseTarif(c, err); traite {
+			return reponse
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusOK).JSON(liste)
+}
+
+// PATCH /listes-prix/:id
+func (h *TarifHandler) UpdateListe(c *fiber.Ctx) error {
+	var req dto.RequeteUpdateListePrix
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Format JSON invalide"})
+	}
+	if err := validate.Struct(req); err != nil {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	boutiqueID, err := getBoutiqueID(c)
+	if err != nil {
+		return err
+	}
+
+	liste, err := h.service.UpdateListe(contexteRequete(c), c.Params("id"), boutiqueID, req)
+	if err != nil {
+		if traite, reponse := reponseTarif(c, err); traite {
+			return reponse
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusOK).JSON(liste)
+}
+
+// DELETE /listes-prix/:id
+func (h *TarifHandler) DeleteListe(c *fiber.Ctx) error {
+	boutiqueID, err := getBoutiqueID(c)
+	if err != nil {
+		return err
+	}
+
+	if err := h.service.DeleteListe(contexteRequete(c), c.Params("id"), boutiqueID); err != nil {
+		if traite, reponse := reponseTarif(c, err); traite {
+			return reponse
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"ok": true})
+}
+
+// GET /listes-prix/:id/prix?produit_id=
+func (h *TarifHandler) ListPrix(c *fiber.Ctx) error {
+	boutiqueID, err := getBoutiqueID(c)
+	if err != nil {
+		return err
+	}
+
+	prix, err := h.service.ListPrix(contexteRequete(c), c.Params("id"), boutiqueID, c.Query("produit_id"))
+	if err != nil {
+		if traite, reponse := reponseTarif(c, err); traite {
+			return reponse
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"prix": prix})
+}
+
+// PUT /listes-prix/:id/prix
+func (h *TarifHandler) DefinirPrix(c *fiber.Ctx) error {
+	var req dto.RequetePrixListe
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Format JSON invalide"})
+	}
+	if err := verifierMontants(c.Body(), &req); err != nil {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err := validate.Struct(req); err != nil {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	boutiqueID, err := getBoutiqueID(c)
+	if err != nil {
+		return err
+	}
+
+	prix, err := h.service.DefinirPrix(contexteRequete(c), c.Params("id"), boutiqueID, req)
+	if err != nil {
+		if traite, reponse := reponseTarif(c, err); traite {
+			return reponse
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"prix": prix})
+}
+
+// GET /taux-change
+func (h *TarifHandler) ListTaux(c *fiber.Ctx) error {
+	boutiqueID, err := getBoutiqueID(c)
+	if err != nil {
+		return err
+	}
+
+	taux, err := h.service.ListTaux(contexteRequete(c), boutiqueID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"taux": taux})
+}
+
+// PUT /taux-change
+func (h *TarifHandler) EnregistrerTaux(c *fiber.Ctx) error {
+	var req dto.RequeteTauxChange
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Format JSON invalide"})
+	}
+	req.DeviseSource = strings.ToUpper(req.DeviseSource)
+	req.DeviseCible = strings.ToUpper(req.DeviseCible)
+	if err := validate.Struct(req); err != nil {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	boutiqueID, err := getBoutiqueID(c)
+	if err != nil {
+		return err
+	}
+
+	taux, err := h.service.EnregistrerTaux(contexteRequete(c), boutiqueID, req)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusOK).JSON(taux)
+}
+
+// DELETE /taux-change/:source/:cible
+func (h *TarifHandler) SupprimerTaux(c *fiber.Ctx) error {
+	boutiqueID, err := getBoutiqueID(c)
+	if err != nil {
+		return err
+	}
+
+	if err := h.service.SupprimerTaux(contexteRequete(c), boutiqueID, c.Params("source"), c.Params("cible")); err != nil {
+		if errors.Is(err, service.ErrTauxIntrouvable) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"ok": true})
+}
+
+// reponsePresentation : 422 si aucun prix ni taux ne permet d'afficher la
+// devise demandée
+func reponsePresentation(c *fiber.Ctx, err error) error {
+	if errors.Is(err, service.ErrTauxIntrouvable) {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+}
+
+// GET /produits/:produitId/paliers
+func (h *TarifHandler) ListPaliers(c *fiber.Ctx) error {
+	boutiqueID, err := getBoutiqueID(c)
+	if err != nil {
+		return err
+	}
+
+	paliers, err := h.service.ListPaliers(contexteRequete(c), c.Params("produitId"), boutiqueID)
+	if err != nil {
+		if err.Error() == "product not found" {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Product not found"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusOK).JSON(paliers)
+}
+
+// PUT /produits/:produitId/paliers
+func (h *TarifHandler) DefinirPaliers(c *fiber.Ctx) error {
+	var req dto.RequetePaliers
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Format JSON invalide"})
+	}
+	if err := verifierMontants(c.Body(), &req); err != nil {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err := validate.Struct(req); err != nil {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	boutiqueID, err := getBoutiqueID(c)
+	if err != nil {
+		return err
+	}
+
+	paliers, err := h.service.DefinirPaliers(contexteRequete(c), c.Params("produitId"), boutiqueID, req)
+	if err != nil {
+		if err.Error() == "product not found" {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Product not found"})
+		}
+		if errors.Is(err, service.ErrPaliers) {
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusOK).JSON(paliers)
+}
+
+// POST /prix/calculer
+func (h *TarifHandler) CalculerPrix(c *fiber.Ctx) error {
+	var req dto.RequeteCalculPrix
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Format JSON invalide"})
+	}
+	if err := validate.Struct(req); err != nil {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	boutiqueID, err := getBoutiqueID(c)
+	if err != nil {
+		return err
+	}
+
+	calcul, err := h.service.CalculerPrix(contexteRequete(c), boutiqueID, service.ContexteTarif{Groupe: groupeRequete(c)}, req)
+	if err != nil {
+		if errors.Is(err, service.ErrCalculPrix) {
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusOK).JSON(calcul)
+}
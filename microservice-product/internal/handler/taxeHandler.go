@@ -0,0 +1,177 @@
+package handler
+
+import (
+	"projet/internal/dto"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// POST /classes-taxe
+func (h *TarifHandler) CreateClasseTaxe(c *fiber.Ctx) error {
+	var req dto.RequeteCreationClasseTaxe
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Format JSON invalide"})
+	}
+	req.Code = strings.TrimSpace(req.Code)
+	if err := validate.Struct(req); err != nil {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	boutiqueID, err := getBoutiqueID(c)
+	if err != nil {
+		return err
+	}
+
+	classe, err := h.service.CreateClasseTaxe(contexteRequete(c), boutiqueID, req)
+	if err != nil {
+		if traite, reponse := reponseTarif(c, err); traite {
+			return reponse
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusCreated).JSON(classe)
+}
+
+// GET /classes-taxe
+func (h *TarifHandler) ListClassesTaxe(c *fiber.Ctx) error {
+	boutiqueID, err := getBoutiqueID(c)
+	if err != nil {
+		return err
+	}
+
+	classes, err := h.service.ListClassesTaxe(contexteRequete(c), boutiqueID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"classes": classes})
+}
+
+// GET /classes-taxe/:id
+func (h *TarifHandler) GetClasseTaxe(c *fiber.Ctx) error {
+	boutiqueID, err := getBoutiqueID(c)
+	if err != nil {
+		return err
+	}
+
+	classe, err := h.service.GetClasseTaxe(contexteRequete(c), c.Params("id"), boutiqueID)
+	if err != nil {
+		if traite, reponse := reponseTarif(c, err); traite {
+			return reponse
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusOK).JSON(classe)
+}
+
+// PATCH /classes-taxe/:id
+func (h *TarifHandler) UpdateClasseTaxe(c *fiber.Ctx) error {
+	var req dto.RequeteUpdateClasseTaxe
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Format JSON invalide"})
+	}
+	if err := validate.Struct(req); err != nil {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	boutiqueID, err := getBoutiqueID(c)
+	if err != nil {
+		return err
+	}
+
+	classe, err := h.service.UpdateClasseTaxe(contexteRequete(c), c.Params("id"), boutiqueID, req)
+	if err != nil {
+		if traite, reponse := reponseTarif(c, err); traite {
+			return reponse
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusOK).JSON(classe)
+}
+
+// DELETE /classes-taxe/:id
+func (h *TarifHandler) DeleteClasseTaxe(c *fiber.Ctx) error {
+	boutiqueID, err := getBoutiqueID(c)
+	if err != nil {
+		return err
+	}
+
+	if err := h.service.DeleteClasseTaxe(contexteRequete(c), c.Params("id"), boutiqueID); err != nil {
+		if traite, reponse := reponseTarif(c, err); traite {
+			return reponse
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"ok": true})
+}
+
+// PUT /classes-taxe/:id/taux
+func (h *TarifHandler) DefinirTauxTaxe(c *fiber.Ctx) error {
+	var req dto.RequeteTauxClasse
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Format JSON invalide"})
+	}
+	for i := range req.Taux {
+		req.Taux[i].Pays = strings.ToUpper(req.Taux[i].Pays)
+	}
+	if err := validate.Struct(req); err != nil {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	boutiqueID, err := getBoutiqueID(c)
+	if err != nil {
+		return err
+	}
+
+	classe, err := h.service.DefinirTauxTaxe(contexteRequete(c), c.Params("id"), boutiqueID, req)
+	if err != nil {
+		if traite, reponse := reponseTarif(c, err); traite {
+			return reponse
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusOK).JSON(classe)
+}
+
+// GET /taxes/parametres
+func (h *TarifHandler) ParametresTaxe(c *fiber.Ctx) error {
+	boutiqueID, err := getBoutiqueID(c)
+	if err != nil {
+		return err
+	}
+
+	parametres, err := h.service.ParametresTaxe(contexteRequete(c), boutiqueID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusOK).JSON(parametres)
+}
+
+// PUT /taxes/parametres
+func (h *TarifHandler) ModifierParametresTaxe(c *fiber.Ctx) error {
+	var req dto.RequeteParametresTaxe
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Format JSON invalide"})
+	}
+	if req.PaysDefaut != nil {
+		pays := strings.ToUpper(*req.PaysDefaut)
+		req.PaysDefaut = &pays
+	}
+	if err := validate.Struct(req); err != nil {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	boutiqueID, err := getBoutiqueID(c)
+	if err != nil {
+		return err
+	}
+
+	parametres, err := h.service.ModifierParametresTaxe(contexteRequete(c), boutiqueID, req)
+	if err != nil {
+		if traite, reponse := reponseTarif(c, err); traite {
+			return reponse
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusOK).JSON(parametres)
+}
@@ -1,8 +1,10 @@
 package handler
 
 import (
+	"errors"
 	"projet/internal/dto"
 	"projet/internal/service"
+	"strings"
 
 	"github.com/gofiber/fiber/v2"
 )
@@ -10,38 +12,37 @@ import (
 type VarianteHandler struct {
 	service        *service.VarianteService
 	produitService *service.ProduitService
+	tarifs         *service.TarifService
 }
 
 func NewVarianteHandler(
 	service *service.VarianteService,
 	produitService *service.ProduitService,
+	tarifs *service.TarifService,
 ) *VarianteHandler {
 	return &VarianteHandler{
 		service:        service,
 		produitService: produitService,
+		tarifs:         tarifs,
 	}
 }
 
-func (h *VarianteHandler) getBoutiqueID(c *fiber.Ctx) (string, error) {
-	boutiqueID := c.Get("X-Boutique-ID")
-	if boutiqueID == "" {
-		return "", fiber.NewError(fiber.StatusUnauthorized, "Missing store context")
-	}
-	return boutiqueID, nil
-}
-
-// Récupérer le prix d'un produit
-func (h *VarianteHandler) getPrixProduit(c *fiber.Ctx, produitID string) (float64, error) {
-	boutiqueID, err := h.getBoutiqueID(c)
+// Récupérer le prix d'un produit (et sa promotion)
+func (h *VarianteHandler) getPrixProduit(c *fiber.Ctx, produitID string) (service.TarifProduit, error) {
+	boutiqueID, err := getBoutiqueID(c)
 	if err != nil {
-		return 0, err
+		return service.TarifProduit{}, err
 	}
+	return h.produitService.Tarif(contexteRequete(c), produitID, boutiqueID)
+}
 
-	produit, err := h.produitService.GetByID(c.Context(), produitID, boutiqueID)
-	if err != nil {
-		return 0, err
+// reponseTarifProduit : produit absent ou d'une autre boutique -> 404 avec
+// introuvable, sans révéler qu'il existe ailleurs
+func reponseTarifProduit(c *fiber.Ctx, err error, introuvable string) error {
+	if err.Error() == "product not found" {
+		return c.Status(404).JSON(fiber.Map{"error": introuvable})
 	}
-	return produit.PrixDefaut, nil
+	return c.Status(500).JSON(fiber.Map{"error": "Erreur récupération produit: " + err.Error()})
 }
 
 // POST /api/produits/:produitId/variantes
@@ -52,7 +53,8 @@ func (h *VarianteHandler) CreateVariante(c *fiber.Ctx) error {
 	}
 
 	// Vérifier la boutique
-	if _, err := h.getBoutiqueID(c); err != nil {
+	boutiqueID, err := getBoutiqueID(c)
+	if err != nil {
 		return err
 	}
 
@@ -60,14 +62,43 @@ func (h *VarianteHandler) CreateVariante(c *fiber.Ctx) error {
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": "JSON invalide"})
 	}
+	if err := verifierMontants(c.Body(), &req); err != nil {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+	}
 
 	prixProduit, err := h.getPrixProduit(c, produitID)
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Erreur récupération produit: " + err.Error()})
+		return reponseTarifProduit(c, err, "Produit non trouvé")
 	}
 
-	variante, err := h.service.Create(c.Context(), produitID, req, prixProduit)
+	// sans SKU, on le génère selon le modèle de la boutique
+	if req.SKU == "" {
+		req.SKU, err = h.produitService.ProposerSKU(contexteRequete(c), produitID, boutiqueID, req.ValeurOptionIDs)
+		if err != nil {
+			if traite, reponse := reponseSKU(c, err); traite {
+				return reponse
+			}
+			return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+		}
+	}
+
+	variante, err := h.service.Create(contexteRequete(c), produitID, boutiqueID, req, prixProduit)
 	if err != nil {
+		if traite, reponse := reponseCodeBarres(c, err); traite {
+			return reponse
+		}
+		if traite, reponse := reponsePromotion(c, err); traite {
+			return reponse
+		}
+		if traite, reponse := reponseSKU(c, err); traite {
+			return reponse
+		}
+		switch {
+		case errors.Is(err, service.ErrCombinaisonInvalide):
+			return c.Status(422).JSON(fiber.Map{"error": err.Error()})
+		case errors.Is(err, service.ErrCombinaisonExistante):
+			return c.Status(409).JSON(fiber.Map{"error": err.Error()})
+		}
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
 
@@ -81,23 +112,140 @@ func (h *VarianteHandler) ListVariantes(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "ID produit requis"})
 	}
 
-	if _, err := h.getBoutiqueID(c); err != nil {
+	boutiqueID, err := getBoutiqueID(c)
+	if err != nil {
+		return err
+	}
+	contexte, err := contexteTarif(c)
+	if err != nil {
 		return err
 	}
 
 	prixProduit, err := h.getPrixProduit(c, produitID)
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Erreur récupération produit: " + err.Error()})
+		return reponseTarifProduit(c, err, "Produit non trouvé")
 	}
 
-	variantes, err := h.service.ListByProduit(c.Context(), produitID, prixProduit)
+	variantes, err := h.service.ListByProduit(contexteRequete(c), produitID, prixProduit)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
+	if err := h.tarifs.PresenterVariantes(contexteRequete(c), boutiqueID, contexte, produitID, variantes); err != nil {
+		return reponsePresentation(c, err)
+	}
 
 	return c.Status(200).JSON(fiber.Map{"variantes": variantes})
 }
 
+// reponseCodeBarres : 422 si le code-barres est mal formé, 409 s'il est déjà pris
+func reponseCodeBarres(c *fiber.Ctx, err error) (bool, error) {
+	switch {
+	case errors.Is(err, service.ErrCodeBarresInvalide):
+		return true, c.Status(422).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, service.ErrCodeBarresPris):
+		return true, c.Status(409).JSON(fiber.Map{"error": err.Error()})
+	}
+	return false, nil
+}
+
+// parametresTarif : paramètres de contexteTarif, jamais lus comme nom d'option
+var parametresTarif = map[string]bool{"devise": true, "pays": true, "region": true, "groupe": true}
+
+// selectionVariante lit ?valeurs=id1,id2 et/ou des paires nom d'option =
+// valeur (?Couleur=Rouge&Taille=M) ; les paramètres de tarif sont ignorés
+func selectionVariante(c *fiber.Ctx) dto.SelectionVariante {
+	sel := dto.SelectionVariante{ParOption: map[string]string{}}
+	c.Context().QueryArgs().VisitAll(func(cle, valeur []byte) {
+		if parametresTarif[string(cle)] {
+			return
+		}
+		if string(cle) != "valeurs" {
+			sel.ParOption[string(cle)] = string(valeur)
+			return
+		}
+		for _, id := range strings.Split(string(valeur), ",") {
+			if id = strings.TrimSpace(id); id != "" {
+				sel.ValeurIDs = append(sel.ValeurIDs, id)
+			}
+		}
+	})
+	return sel
+}
+
+// reponseSelection traduit les erreurs communes de résolution
+func reponseSelection(c *fiber.Ctx, err error) error {
+	var errSelection *service.ErreurSelection
+	switch {
+	case errors.As(err, &errSelection):
+		return c.Status(422).JSON(fiber.Map{"error": "Sélection invalide", "problemes": errSelection.Problemes})
+	case errors.Is(err, service.ErrAucuneVariante):
+		return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+	case err.Error() == "product not found":
+		return c.Status(404).JSON(fiber.Map{"error": "Produit non trouvé"})
+	}
+	return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+}
+
+// GET /api/produits/:produitId/variantes/resoudre?valeurs=id1,id2
+func (h *VarianteHandler) ResoudreVariante(c *fiber.Ctx) error {
+	produitID := c.Params("produitId")
+	if produitID == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "ID produit requis"})
+	}
+
+	boutiqueID, err := getBoutiqueID(c)
+	if err != nil {
+		return err
+	}
+
+	contexte, err := contexteTarif(c)
+	if err != nil {
+		return err
+	}
+
+	resultat, err := h.produitService.ResoudreVariante(contexteRequete(c), produitID, boutiqueID, selectionVariante(c))
+	if err != nil {
+		return reponseSelection(c, err)
+	}
+	presentee := []dto.VarianteResponse{resultat.Variante}
+	if err := h.tarifs.PresenterVariantes(contexteRequete(c), boutiqueID, contexte, produitID, presentee); err != nil {
+		return reponsePresentation(c, err)
+	}
+	resultat.Variante = presentee[0]
+	return c.Status(200).JSON(resultat)
+}
+
+// GET /api/produits/:produitId/variantes/disponibilites?valeurs=id1
+func (h *VarianteHandler) DisponibilitesVariantes(c *fiber.Ctx) error {
+	produitID := c.Params("produitId")
+	if produitID == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "ID produit requis"})
+	}
+
+	boutiqueID, err := getBoutiqueID(c)
+	if err != nil {
+		return err
+	}
+
+	contexte, err := contexteTarif(c)
+	if err != nil {
+		return err
+	}
+
+	matrice, err := h.produitService.Disponibilites(contexteRequete(c), produitID, boutiqueID, selectionVariante(c))
+	if err != nil {
+		return reponseSelection(c, err)
+	}
+	if matrice.Variante != nil {
+		presentee := []dto.VarianteResponse{*matrice.Variante}
+		if err := h.tarifs.PresenterVariantes(contexteRequete(c), boutiqueID, contexte, produitID, presentee); err != nil {
+			return reponsePresentation(c, err)
+		}
+		matrice.Variante = &presentee[0]
+	}
+	return c.Status(200).JSON(matrice)
+}
+
 // GET /api/variantes/:varianteId
 func (h *VarianteHandler) GetVarianteByID(c *fiber.Ctx) error {
 	varianteID := c.Params("varianteId")
@@ -105,13 +253,17 @@ func (h *VarianteHandler) GetVarianteByID(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "ID variante requis"})
 	}
 
-	boutiqueID, err := h.getBoutiqueID(c)
+	boutiqueID, err := getBoutiqueID(c)
+	if err != nil {
+		return err
+	}
+	contexte, err := contexteTarif(c)
 	if err != nil {
 		return err
 	}
 
 	// D'abord récupérer la variante sans prix
-	temp, err := h.service.GetByID(c.Context(), varianteID, 0)
+	temp, err := h.service.GetByID(contexteRequete(c), varianteID, service.TarifProduit{})
 	if err != nil {
 		if err.Error() == "variante non trouvée" {
 			return c.Status(404).JSON(fiber.Map{"error": "Variante non trouvée"})
@@ -120,17 +272,23 @@ func (h *VarianteHandler) GetVarianteByID(c *fiber.Ctx) error {
 	}
 
 	// Récupérer le produit pour avoir son prix par défaut
-	produit, err := h.produitService.GetByID(c.Context(), temp.ProduitID, boutiqueID)
+	tarif, err := h.produitService.Tarif(contexteRequete(c), temp.ProduitID, boutiqueID)
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Erreur récupération produit: " + err.Error()})
+		return reponseTarifProduit(c, err, "Variante non trouvée")
 	}
 
 	// Récupérer la variante avec le bon prix
-	variante, err := h.service.GetByID(c.Context(), varianteID, produit.PrixDefaut)
+	variante, err := h.service.GetByID(contexteRequete(c), varianteID, tarif)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
+	presentee := []dto.VarianteResponse{*variante}
+	if err := h.tarifs.PresenterVariantes(contexteRequete(c), boutiqueID, contexte, temp.ProduitID, presentee); err != nil {
+		return reponsePresentation(c, err)
+	}
+	variante = &presentee[0]
 
+	definirETag(c, variante.Version)
 	return c.Status(200).JSON(variante)
 }
 
@@ -141,7 +299,7 @@ func (h *VarianteHandler) UpdateVariante(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "ID variante requis"})
 	}
 
-	boutiqueID, err := h.getBoutiqueID(c)
+	boutiqueID, err := getBoutiqueID(c)
 	if err != nil {
 		return err
 	}
@@ -150,9 +308,12 @@ func (h *VarianteHandler) UpdateVariante(c *fiber.Ctx) error {
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": "JSON invalide"})
 	}
+	if err := verifierMontants(c.Body(), &req); err != nil {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+	}
 
 	// Récupérer la variante sans prix
-	temp, err := h.service.GetByID(c.Context(), varianteID, 0)
+	temp, err := h.service.GetByID(contexteRequete(c), varianteID, service.TarifProduit{})
 	if err != nil {
 		if err.Error() == "variante non trouvée" {
 			return c.Status(404).JSON(fiber.Map{"error": "Variante non trouvée"})
@@ -161,20 +322,109 @@ func (h *VarianteHandler) UpdateVariante(c *fiber.Ctx) error {
 	}
 
 	// Récupérer le produit
-	produit, err := h.produitService.GetByID(c.Context(), temp.ProduitID, boutiqueID)
+	tarif, err := h.produitService.Tarif(contexteRequete(c), temp.ProduitID, boutiqueID)
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Erreur récupération produit: " + err.Error()})
+		return reponseTarifProduit(c, err, "Variante non trouvée")
+	}
+
+	version, err := versionIfMatch(c)
+	if err != nil {
+		return err
 	}
 
 	// Mettre à jour
-	variante, err := h.service.Update(c.Context(), varianteID, req, produit.PrixDefaut)
+	variante, err := h.service.Update(contexteRequete(c), varianteID, boutiqueID, req, tarif, version)
 	if err != nil {
+		if traite, reponse := reponseCodeBarres(c, err); traite {
+			return reponse
+		}
+		if traite, reponse := reponsePromotion(c, err); traite {
+			return reponse
+		}
+		if traite, reponse := reponseSKU(c, err); traite {
+			return reponse
+		}
 		if err.Error() == "variante non trouvée" {
 			return c.Status(404).JSON(fiber.Map{"error": "Variante non trouvée"})
 		}
+		if versionObsolete(err) {
+			return reponseVersionObsolete(c)
+		}
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
 
+	definirETag(c, variante.Version)
+	return c.Status(200).JSON(variante)
+}
+
+// PATCH /api/variantes/:varianteId
+// merge patch ou JSON Patch (ex: {"op":"add","path":"/images/-","value":"..."})
+func (h *VarianteHandler) PatchVariante(c *fiber.Ctx) error {
+	varianteID := c.Params("varianteId")
+	if varianteID == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "ID variante requis"})
+	}
+
+	boutiqueID, err := getBoutiqueID(c)
+	if err != nil {
+		return err
+	}
+
+	ifMatch, err := versionIfMatch(c)
+	if err != nil {
+		return err
+	}
+
+	document, version, err := h.service.Document(contexteRequete(c), varianteID)
+	if err != nil {
+		if err.Error() == "variante non trouvée" {
+			return c.Status(404).JSON(fiber.Map{"error": "Variante non trouvée"})
+		}
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	if ifMatch != nil && *ifMatch != version {
+		return reponseVersionObsolete(c)
+	}
+
+	var modifie dto.DocumentVariante
+	if statut, err := appliquerPatch(c, document, &modifie); err != nil {
+		return c.Status(statut).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err := validate.Struct(modifie); err != nil {
+		return c.Status(422).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	// Récupérer la variante pour retrouver le prix par défaut du produit
+	temp, err := h.service.GetByID(contexteRequete(c), varianteID, service.TarifProduit{})
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	tarif, err := h.produitService.Tarif(contexteRequete(c), temp.ProduitID, boutiqueID)
+	if err != nil {
+		return reponseTarifProduit(c, err, "Variante non trouvée")
+	}
+
+	variante, err := h.service.Remplacer(contexteRequete(c), varianteID, boutiqueID, modifie, tarif, version)
+	if err != nil {
+		if traite, reponse := reponseCodeBarres(c, err); traite {
+			return reponse
+		}
+		if traite, reponse := reponsePromotion(c, err); traite {
+			return reponse
+		}
+		if traite, reponse := reponseSKU(c, err); traite {
+			return reponse
+		}
+		if err.Error() == "variante non trouvée" {
+			return c.Status(404).JSON(fiber.Map{"error": "Variante non trouvée"})
+		}
+		if versionObsolete(err) {
+			return reponseVersionObsolete(c)
+		}
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	definirETag(c, variante.Version)
 	return c.Status(200).JSON(variante)
 }
 
@@ -185,15 +435,24 @@ func (h *VarianteHandler) DeleteVariante(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "ID variante requis"})
 	}
 
-	if _, err := h.getBoutiqueID(c); err != nil {
+	boutiqueID, err := getBoutiqueID(c)
+	if err != nil {
+		return err
+	}
+
+	version, err := versionIfMatch(c)
+	if err != nil {
 		return err
 	}
 
-	err := h.service.Delete(c.Context(), varianteID)
+	err = h.service.Delete(contexteRequete(c), varianteID, boutiqueID, version)
 	if err != nil {
 		if err.Error() == "variante non trouvée" {
 			return c.Status(404).JSON(fiber.Map{"error": "Variante non trouvée"})
 		}
+		if versionObsolete(err) {
+			return reponseVersionObsolete(c)
+		}
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
 
@@ -0,0 +1,174 @@
+package handler
+
+import (
+	"errors"
+	"projet/internal/dto"
+	"projet/internal/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type WebhookHandler struct {
+	service *service.WebhookService
+}
+
+func NewWebhookHandler(service *service.WebhookService) *WebhookHandler {
+	return &WebhookHandler{service: service}
+}
+
+// POST /webhooks
+func (h *WebhookHandler) CreateWebhook(c *fiber.Ctx) error {
+	var req dto.RequeteCreationWebhook
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid JSON"})
+	}
+
+	if err := validate.Struct(req); err != nil {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	boutiqueID, err := getBoutiqueID(c)
+	if err != nil {
+		return err
+	}
+
+	webhook, err := h.service.Create(c.Context(), boutiqueID, req)
+	if errors.Is(err, service.ErrURLWebhook) {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusCreated).JSON(webhook)
+}
+
+// GET /webhooks
+func (h *WebhookHandler) ListWebhooks(c *fiber.Ctx) error {
+	boutiqueID, err := getBoutiqueID(c)
+	if err != nil {
+		return err
+	}
+
+	webhooks, err := h.service.List(c.Context(), boutiqueID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch webhooks"})
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"webhooks": webhooks})
+}
+
+// GET /webhooks/:id
+func (h *WebhookHandler) GetWebhookByID(c *fiber.Ctx) error {
+	id := c.Params("id")
+	boutiqueID, err := getBoutiqueID(c)
+	if err != nil {
+		return err
+	}
+
+	webhook, err := h.service.GetByID(c.Context(), id, boutiqueID)
+	if err != nil {
+		if err.Error() == "webhook not found" {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Webhook not found"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch webhook"})
+	}
+	return c.Status(fiber.StatusOK).JSON(webhook)
+}
+
+// PUT /webhooks/:id
+func (h *WebhookHandler) UpdateWebhook(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	var req dto.RequeteUpdateWebhook
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid JSON"})
+	}
+
+	if err := validate.Struct(req); err != nil {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	boutiqueID, err := getBoutiqueID(c)
+	if err != nil {
+		return err
+	}
+
+	webhook, err := h.service.Update(c.Context(), id, boutiqueID, req)
+	if err != nil {
+		if err.Error() == "webhook not found" {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Webhook not found"})
+		}
+		if errors.Is(err, service.ErrURLWebhook) {
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusOK).JSON(webhook)
+}
+
+// DELETE /webhooks/:id
+func (h *WebhookHandler) DeleteWebhook(c *fiber.Ctx) error {
+	id := c.Params("id")
+	boutiqueID, err := getBoutiqueID(c)
+	if err != nil {
+		return err
+	}
+
+	if err := h.service.Delete(c.Context(), id, boutiqueID); err != nil {
+		if err.Error() == "webhook not found" {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Webhook not found"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"ok": true})
+}
+
+// GET /webhooks/:id/livraisons?page=&limit=
+func (h *WebhookHandler) ListLivraisons(c *fiber.Ctx) error {
+	id := c.Params("id")
+	boutiqueID, err := getBoutiqueID(c)
+	if err != nil {
+		return err
+	}
+
+	page := c.QueryInt("page", 1)
+	limite := c.QueryInt("limit", 20)
+
+	livraisons, err := h.service.ListLivraisons(c.Context(), id, boutiqueID, page, limite)
+	if err != nil {
+		if err.Error() == "webhook not found" {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Webhook not found"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch deliveries"})
+	}
+	return c.JSON(fiber.Map{
+		"livraisons": livraisons,
+		"page":       page,
+		"limite":     limite,
+	})
+}
+
+// POST /webhooks/:id/livraisons/:livraisonId/relivrer
+// 409 si l'abonnement est désactivé
+func (h *WebhookHandler) RelivrerLivraison(c *fiber.Ctx) error {
+	id := c.Params("id")
+	livraisonID := c.Params("livraisonId")
+	boutiqueID, err := getBoutiqueID(c)
+	if err != nil {
+		return err
+	}
+
+	livraison, err := h.service.Relivrer(c.Context(), livraisonID, id, boutiqueID)
+	if err != nil {
+		if errors.Is(err, service.ErrWebhookInactif) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+		}
+		switch err.Error() {
+		case "webhook not found":
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Webhook not found"})
+		case "delivery not found":
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Delivery not found"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusAccepted).JSON(livraison)
+}
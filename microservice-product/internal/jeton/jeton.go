@@ -0,0 +1,98 @@
+// Package jeton vérifie les jetons JWT signés HS256 émis par le service
+// d'authentification et en expose les revendications (claims).
+package jeton
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+var (
+	ErrFormat          = errors.New("jeton mal formé")
+	ErrAlgorithme      = errors.New("algorithme de signature non supporté (HS256 attendu)")
+	ErrSignature       = errors.New("signature du jeton invalide")
+	ErrExpire          = errors.New("jeton expiré")
+	ErrSansExpire      = errors.New("jeton sans expiration (exp)")
+	ErrPasEncoreValide = errors.New("jeton pas encore valide (nbf)")
+)
+
+// Claims : revendications du jeton ; Groupe est le code du groupe de clients,
+// Boutiques les boutiques que le porteur administre (marchand)
+type Claims struct {
+	Sujet     string   `json:"sub"`
+	Groupe    string   `json:"groupe"`
+	Boutiques []string `json:"boutiques"`
+	Expire    int64    `json:"exp"`
+	PasAvant  int64    `json:"nbf"`
+}
+
+// AdministreBoutique : le porteur du jeton administre boutiqueID
+func (c *Claims) AdministreBoutique(boutiqueID string) bool {
+	for _, id := range c.Boutiques {
+		if id != "" && id == boutiqueID {
+			return true
+		}
+	}
+	return false
+}
+
+// Verifier contrôle la signature HS256 et la période de validité de jeton
+// (sans le préfixe "Bearer ") puis renvoie ses revendications. exp est
+// obligatoire : un jeton sans expiration resterait valide indéfiniment.
+func Verifier(jeton, secret string, maintenant time.Time) (*Claims, error) {
+	parties := strings.Split(jeton, ".")
+	if len(parties) != 3 {
+		return nil, ErrFormat
+	}
+
+	var entete struct {
+		Alg string `json:"alg"`
+	}
+	if err := decoder(parties[0], &entete); err != nil {
+		return nil, err
+	}
+	if entete.Alg != "HS256" {
+		return nil, ErrAlgorithme
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parties[2])
+	if err != nil {
+		return nil, ErrFormat
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(parties[0] + "." + parties[1]))
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return nil, ErrSignature
+	}
+
+	var claims Claims
+	if err := decoder(parties[1], &claims); err != nil {
+		return nil, err
+	}
+	if claims.Expire == 0 {
+		return nil, ErrSansExpire
+	}
+	if !maintenant.Before(time.Unix(claims.Expire, 0)) {
+		return nil, ErrExpire
+	}
+	if claims.PasAvant != 0 && maintenant.Before(time.Unix(claims.PasAvant, 0)) {
+		return nil, ErrPasEncoreValide
+	}
+	return &claims, nil
+}
+
+func decoder(partie string, cible interface{}) error {
+	brut, err := base64.RawURLEncoding.DecodeString(partie)
+	if err != nil {
+		return ErrFormat
+	}
+	if err := json.Unmarshal(brut, cible); err != nil {
+		return ErrFormat
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package jeton
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+const secretTest = "secret-de-test"
+
+// signer fabrique un jeton HS256 portant claims
+func signer(t *testing.T, claims map[string]interface{}) string {
+	t.Helper()
+	entete := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+	corps, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	charge := entete + "." + base64.RawURLEncoding.EncodeToString(corps)
+	mac := hmac.New(sha256.New, []byte(secretTest))
+	mac.Write([]byte(charge))
+	return charge + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifier(t *testing.T) {
+	maintenant := time.Unix(1_800_000_000, 0)
+	avant, apres := maintenant.Add(-time.Hour).Unix(), maintenant.Add(time.Hour).Unix()
+
+	cas := []struct {
+		nom     string
+		claims  map[string]interface{}
+		attendu error
+	}{
+		{"valide", map[string]interface{}{"groupe": "vip", "exp": apres}, nil},
+		{"nbf passé", map[string]interface{}{"exp": apres, "nbf": avant}, nil},
+		{"sans exp", map[string]interface{}{"groupe": "vip"}, ErrSansExpire},
+		{"expiré", map[string]interface{}{"exp": avant}, ErrExpire},
+		{"expire maintenant", map[string]interface{}{"exp": maintenant.Unix()}, ErrExpire},
+		{"nbf futur", map[string]interface{}{"exp": apres, "nbf": apres - 60}, ErrPasEncoreValide},
+	}
+	for _, c := range cas {
+		_, err := Verifier(signer(t, c.claims), secretTest, maintenant)
+		if !errors.Is(err, c.attendu) {
+			t.Errorf("%s : %v, attendu %v", c.nom, err, c.attendu)
+		}
+	}
+
+	if _, err := Verifier(signer(t, map[string]interface{}{"exp": apres}), "autre-secret", maintenant); !errors.Is(err, ErrSignature) {
+		t.Errorf("mauvais secret : %v, attendu ErrSignature", err)
+	}
+	if _, err := Verifier("a.b", secretTest, maintenant); !errors.Is(err, ErrFormat) {
+		t.Errorf("jeton tronqué : %v, attendu ErrFormat", err)
+	}
+}
+
+func TestAdministreBoutique(t *testing.T) {
+	maintenant := time.Unix(1_800_000_000, 0)
+	jeton := signer(t, map[string]interface{}{"exp": maintenant.Add(time.Hour).Unix(), "boutiques": []string{"b1", "b2"}})
+	claims, err := Verifier(jeton, secretTest, maintenant)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !claims.AdministreBoutique("b2") {
+		t.Error("b2 figure dans le claim boutiques")
+	}
+	if claims.AdministreBoutique("b3") || claims.AdministreBoutique("") {
+		t.Error("seules les boutiques du claim sont administrées")
+	}
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// AjustementPrix trace l'application d'une règle de prix en masse : la
+// règle telle que reçue et, par article modifié, le prix avant / après
+// (Lignes, tableau JSON)
+type AjustementPrix struct {
+	ID          string    `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	BoutiqueID  string    `gorm:"type:uuid;not null;index"                       json:"boutique_id"`
+	Acteur      string    `gorm:"type:varchar(255);not null"                     json:"acteur"`
+	Regle       string    `gorm:"type:jsonb;not null"                            json:"regle"`
+	NbProduits  int       `gorm:"not null;default:0"                             json:"nb_produits"`
+	NbVariantes int       `gorm:"not null;default:0"                             json:"nb_variantes"`
+	Lignes      string    `gorm:"type:jsonb;not null;default:'[]'"               json:"lignes"`
+	CreeLe      time.Time `gorm:"autoCreateTime;index"                           json:"cree_le"`
+}
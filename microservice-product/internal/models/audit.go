@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+type ActionAudit string
+
+const (
+	ActionCreation     ActionAudit = "creation"
+	ActionModification ActionAudit = "modification"
+	ActionSuppression  ActionAudit = "suppression"
+	ActionRestauration ActionAudit = "restauration"
+	ActionPurge        ActionAudit = "purge"
+
+	EntiteProduit      = "produit"
+	EntiteOption       = "option"
+	EntiteValeurOption = "valeur_option"
+	EntiteVariante     = "variante"
+	EntiteModeleOption = "modele_option"
+)
+
+// JournalAudit trace une modification du catalogue. ProduitID est le produit
+// concerné (l'entité elle-même ou son parent) pour reconstituer l'historique
+// d'un produit ; Diff vaut {"champ": {"avant": ..., "apres": ...}}.
+type JournalAudit struct {
+	ID         string      `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	BoutiqueID string      `gorm:"type:uuid;not null;index"                       json:"boutique_id"`
+	ProduitID  *string     `gorm:"type:uuid;index"                                json:"produit_id,omitempty"`
+	Acteur     string      `gorm:"type:varchar(255);not null"                     json:"acteur"`
+	TypeEntite string      `gorm:"type:varchar(50);not null"                      json:"type_entite"`
+	EntiteID   string      `gorm:"type:uuid;not null;index"                       json:"entite_id"`
+	Action     ActionAudit `gorm:"type:varchar(20);not null"                      json:"action"`
+	Diff       string      `gorm:"type:jsonb;not null;default:'{}'"               json:"diff"`
+	CreeLe     time.Time   `gorm:"autoCreateTime;index"                           json:"cree_le"`
+}
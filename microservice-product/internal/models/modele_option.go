@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// ModeleOption est une option réutilisable au niveau de la boutique
+// ("Taille: S, M, L, XL"). Appliquée à un produit, elle est soit copiée,
+// soit liée : les OptionProduit/ValeurOption liées gardent ModeleID /
+// ValeurModeleID et peuvent recevoir les mises à jour du modèle.
+type ModeleOption struct {
+	ID         string    `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	BoutiqueID string    `gorm:"type:uuid;not null;index"                       json:"boutique_id"`
+	Nom        string    `gorm:"type:varchar(100);not null"                     json:"nom"`
+	Version    int       `gorm:"not null;default:1"                             json:"version"`
+	CreeLe     time.Time `gorm:"autoCreateTime"                                 json:"cree_le"`
+	MisAJourLe time.Time `gorm:"autoUpdateTime"                                 json:"mis_a_jour_le"`
+
+	// Relations
+	Valeurs []ValeurModeleOption `gorm:"foreignKey:ModeleID;constraint:OnDelete:CASCADE" json:"valeurs,omitempty"`
+}
+
+type ValeurModeleOption struct {
+	ID       string `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	ModeleID string `gorm:"type:uuid;not null;index"                       json:"modele_id"`
+	Valeur   string `gorm:"type:varchar(100);not null"                     json:"valeur"`
+	Position int    `gorm:"not null;default:0"                             json:"position"`
+}
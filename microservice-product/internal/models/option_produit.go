@@ -7,6 +7,8 @@ type OptionProduit struct {
 	ProduitID  string    `gorm:"type:uuid;not null;index;constraint:OnDelete:CASCADE;references:produits(id)" json:"produit_id"`
 	Nom        string    `gorm:"type:varchar(100);not null"                     json:"nom"`
 	Position   int       `gorm:"not null;default:0"                             json:"position"`
+	Version    int       `gorm:"not null;default:1"                             json:"version"`
+	ModeleID   *string   `gorm:"type:uuid;index"                                json:"modele_id,omitempty"`
 	CreeLe     time.Time `gorm:"autoCreateTime"                                 json:"cree_le"`
 	MisAJourLe time.Time `gorm:"autoUpdateTime"                                 json:"mis_a_jour_le"`
 
@@ -14,9 +16,22 @@ type OptionProduit struct {
 	ValeurOpts []ValeurOption `gorm:"foreignKey:OptionID;constraint:OnDelete:CASCADE" json:"valeur_opts,omitempty"`
 }
 
+// ValeurModeleID renvoie à la valeur du modèle dont celle-ci est issue
+// quand l'option est liée à un ModeleOption. CouleurHex, ImageSwatch, Code et
+// Libelles (libellé traduit par code de langue) alimentent les sélecteurs de
+// variantes de la vitrine ; Code est unique au sein de l'option.
+// PositionPropre : la position a été choisie sur le produit, la propagation
+// du modèle ne la remplace plus.
 type ValeurOption struct {
-	ID       string `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
-	OptionID string `gorm:"type:uuid;not null;index;constraint:OnDelete:CASCADE;references:option_produits(id)" json:"option_id"`
-	Valeur   string `gorm:"type:varchar(100);not null"                     json:"valeur"`
-	Position int    `gorm:"not null;default:0"                             json:"position"`
+	ID             string            `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	OptionID       string            `gorm:"type:uuid;not null;index;uniqueIndex:idx_code_valeur_option;constraint:OnDelete:CASCADE;references:option_produits(id)" json:"option_id"`
+	Valeur         string            `gorm:"type:varchar(100);not null"                     json:"valeur"`
+	Position       int               `gorm:"not null;default:0"                             json:"position"`
+	Version        int               `gorm:"not null;default:1"                             json:"version"`
+	ValeurModeleID *string           `gorm:"type:uuid;index"                                json:"valeur_modele_id,omitempty"`
+	CouleurHex     *string           `gorm:"type:varchar(9)"                                json:"couleur_hex,omitempty"`
+	ImageSwatch    *string           `gorm:"type:text"                                      json:"image_swatch,omitempty"`
+	Code           *string           `gorm:"type:varchar(50);uniqueIndex:idx_code_valeur_option" json:"code,omitempty"`
+	Libelles       map[string]string `gorm:"type:jsonb;serializer:json"                     json:"libelles,omitempty"`
+	PositionPropre bool              `gorm:"not null;default:false"                         json:"position_propre,omitempty"`
 }
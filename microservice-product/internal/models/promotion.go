@@ -0,0 +1,35 @@
+package models
+
+import (
+	"projet/internal/monnaie"
+	"time"
+)
+
+// Promotion : prix barré (prix de comparaison affiché) et prix promotionnel
+// programmé, communs au produit et à ses variantes ; DebutPromo ou FinPromo
+// nil = promotion sans borne de ce côté
+type Promotion struct {
+	PrixBarre  *monnaie.Montant `gorm:"type:decimal(12,4)" json:"prix_barre,omitempty"`
+	PrixPromo  *monnaie.Montant `gorm:"type:decimal(12,4)" json:"prix_promo,omitempty"`
+	DebutPromo *time.Time       `gorm:"type:timestamptz"   json:"debut_promo,omitempty"`
+	FinPromo   *time.Time       `gorm:"type:timestamptz"   json:"fin_promo,omitempty"`
+}
+
+// Active : un prix promotionnel est posé et t tombe dans [DebutPromo, FinPromo[
+func (p Promotion) Active(t time.Time) bool {
+	if p.PrixPromo == nil {
+		return false
+	}
+	if p.DebutPromo != nil && t.Before(*p.DebutPromo) {
+		return false
+	}
+	if p.FinPromo != nil && !t.Before(*p.FinPromo) {
+		return false
+	}
+	return true
+}
+
+// Vide : ni prix barré ni prix promotionnel
+func (p Promotion) Vide() bool {
+	return p.PrixBarre == nil && p.PrixPromo == nil
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// RevisionProduit est une photo complète d'un produit (options, valeurs,
+// variantes et leurs liens) prise à chaque modification. Snapshot contient
+// le Produit sérialisé en JSON avec ses relations.
+type RevisionProduit struct {
+	ID         string    `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	ProduitID  string    `gorm:"type:uuid;not null;uniqueIndex:idx_revision_produit_numero" json:"produit_id"`
+	BoutiqueID string    `gorm:"type:uuid;not null;index"                       json:"boutique_id"`
+	Numero     int       `gorm:"not null;uniqueIndex:idx_revision_produit_numero" json:"numero"`
+	Acteur     string    `gorm:"type:varchar(255);not null"                     json:"acteur"`
+	Snapshot   string    `gorm:"type:jsonb;not null"                            json:"snapshot"`
+	CreeLe     time.Time `gorm:"autoCreateTime"                                 json:"cree_le"`
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// ConfigurationSKU : modèle de génération des SKU d'une boutique. Jetons
+// reconnus : {PREFIXE}, {PRODUIT}, {OPTIONS}, {COMPTEUR} et {COMPTEUR:n}
+// (n chiffres, complété par des zéros). Compteur est le dernier numéro
+// attribué, partagé par tous les produits de la boutique.
+type ConfigurationSKU struct {
+	BoutiqueID string    `gorm:"type:uuid;primaryKey"                        json:"boutique_id"`
+	Prefixe    string    `gorm:"type:varchar(20);not null;default:''"        json:"prefixe"`
+	Modele     string    `gorm:"type:varchar(100);not null"                  json:"modele"`
+	Compteur   int64     `gorm:"not null;default:0"                          json:"compteur"`
+	MisAJourLe time.Time `gorm:"autoUpdateTime"                              json:"mis_a_jour_le"`
+}
+
+// ModeleSKUParDefaut s'applique tant que la boutique n'a rien configuré
+const ModeleSKUParDefaut = "{PREFIXE}-{PRODUIT}-{OPTIONS}"
@@ -0,0 +1,89 @@
+package models
+
+import (
+	"projet/internal/monnaie"
+	"time"
+)
+
+// ListePrix : prix de la boutique dans une devise, éventuellement réservés
+// à un marché (pays ISO 3166-1 alpha-2). Sans pays, la liste vaut pour
+// tous les marchés de cette devise.
+type ListePrix struct {
+	ID         string    `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	BoutiqueID string    `gorm:"type:uuid;not null;index"                       json:"boutique_id"`
+	Nom        string    `gorm:"type:varchar(100);not null"                     json:"nom"`
+	Devise     string    `gorm:"type:char(3);not null"                          json:"devise"`
+	Pays       *string   `gorm:"type:char(2)"                                   json:"pays,omitempty"`
+	CreeLe     time.Time `gorm:"autoCreateTime"                                 json:"cree_le"`
+	MisAJourLe time.Time `gorm:"autoUpdateTime"                                 json:"mis_a_jour_le"`
+
+	// Relations
+	Prix []PrixListe `gorm:"foreignKey:ListeID;constraint:OnDelete:CASCADE" json:"prix,omitempty"`
+}
+
+// PrixListe : prix explicite d'un produit (VarianteID nil, hérité par les
+// variantes sans prix propre) ou d'une variante dans une liste. La clé
+// étrangère vers les variantes (migrerTarifsVariantes) est différée à la fin
+// de la transaction : une restauration de révision les recrée avec les mêmes
+// ID, les prix doivent survivre. Les lignes sont purgées avec le produit ou
+// la variante.
+type PrixListe struct {
+	ID         string          `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	ListeID    string          `gorm:"type:uuid;not null;index"                       json:"liste_id"`
+	ProduitID  string          `gorm:"type:uuid;not null;index"                       json:"produit_id"`
+	VarianteID *string         `gorm:"type:uuid;index"                                json:"variante_id,omitempty"`
+	Montant    monnaie.Montant `gorm:"type:decimal(12,4);not null"                    json:"montant"`
+	MisAJourLe time.Time       `gorm:"autoUpdateTime"                                 json:"mis_a_jour_le"`
+}
+
+// TauxChange : 1 DeviseSource = Taux DeviseCible, propre à la boutique.
+// Le taux inverse est déduit quand seul l'autre sens est enregistré.
+type TauxChange struct {
+	BoutiqueID   string       `gorm:"type:uuid;primaryKey"           json:"boutique_id"`
+	DeviseSource string       `gorm:"type:char(3);primaryKey"        json:"devise_source"`
+	DeviseCible  string       `gorm:"type:char(3);primaryKey"        json:"devise_cible"`
+	Taux         monnaie.Taux `gorm:"type:decimal(18,8);not null"    json:"taux"`
+	MisAJourLe   time.Time    `gorm:"autoUpdateTime"                 json:"mis_a_jour_le"`
+}
+
+// PalierQuantite : prix unitaire à partir de QuantiteMin articles, pour un
+// produit (VarianteID nil, paliers des variantes qui n'en ont pas) ou une
+// variante. Un palier court jusqu'au QuantiteMin suivant. Comme PrixListe,
+// clé étrangère différée vers les variantes ; purgé avec le produit ou la variante.
+type PalierQuantite struct {
+	ID          string          `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	ProduitID   string          `gorm:"type:uuid;not null;index"                       json:"produit_id"`
+	VarianteID  *string         `gorm:"type:uuid;index"                                json:"variante_id,omitempty"`
+	QuantiteMin int             `gorm:"not null"                                       json:"quantite_min"`
+	Montant     monnaie.Montant `gorm:"type:decimal(12,4);not null"                    json:"montant"`
+	CreeLe      time.Time       `gorm:"autoCreateTime"                                 json:"cree_le"`
+}
+
+// GroupeClient : groupe de clients de la boutique (grossiste, vip...) désigné
+// par son code dans le jeton ou l'en-tête X-Groupe-Client
+type GroupeClient struct {
+	ID         string    `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	BoutiqueID string    `gorm:"type:uuid;not null;uniqueIndex:idx_groupe_client_code,priority:1" json:"boutique_id"`
+	Code       string    `gorm:"type:varchar(50);not null;uniqueIndex:idx_groupe_client_code,priority:2" json:"code"`
+	Nom        string    `gorm:"type:varchar(100);not null"                     json:"nom"`
+	CreeLe     time.Time `gorm:"autoCreateTime"                                 json:"cree_le"`
+	MisAJourLe time.Time `gorm:"autoUpdateTime"                                 json:"mis_a_jour_le"`
+
+	// Relations
+	Regles []RegleGroupe `gorm:"foreignKey:GroupeID;constraint:OnDelete:CASCADE" json:"regles,omitempty"`
+}
+
+// RegleGroupe : prix imposé (Montant, produit ou variante seulement) ou
+// variation en pourcentage (-15 = 15 % de remise) du prix régulier. La
+// règle vise une variante, un produit (toutes ses variantes), une marque,
+// ou tout le catalogue quand aucune cible n'est renseignée ; la plus
+// précise l'emporte.
+type RegleGroupe struct {
+	ID          string           `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	GroupeID    string           `gorm:"type:uuid;not null;index"                       json:"groupe_id"`
+	ProduitID   *string          `gorm:"type:uuid;index"                                json:"produit_id,omitempty"`
+	VarianteID  *string          `gorm:"type:uuid"                                      json:"variante_id,omitempty"`
+	Marque      *string          `gorm:"type:varchar(255)"                              json:"marque,omitempty"`
+	Montant     *monnaie.Montant `gorm:"type:decimal(12,4)"                             json:"montant,omitempty"`
+	Pourcentage *float64         `gorm:"type:decimal(7,3)"                              json:"pourcentage,omitempty"`
+}
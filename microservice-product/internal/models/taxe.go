@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// ClasseTaxe : catégorie fiscale de la boutique (standard, reduit, exonere...)
+// référencée par son code dans Produit.ClasseTaxe
+type ClasseTaxe struct {
+	ID         string    `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	BoutiqueID string    `gorm:"type:uuid;not null;uniqueIndex:idx_classe_taxe_code,priority:1" json:"boutique_id"`
+	Code       string    `gorm:"type:varchar(100);not null;uniqueIndex:idx_classe_taxe_code,priority:2" json:"code"`
+	Nom        string    `gorm:"type:varchar(100);not null"                     json:"nom"`
+	CreeLe     time.Time `gorm:"autoCreateTime"                                 json:"cree_le"`
+	MisAJourLe time.Time `gorm:"autoUpdateTime"                                 json:"mis_a_jour_le"`
+
+	// Relations
+	Taux []TauxTaxe `gorm:"foreignKey:ClasseID;constraint:OnDelete:CASCADE" json:"taux,omitempty"`
+}
+
+// TauxTaxe : taux en pourcentage (20 = 20 %) de la classe pour un pays et,
+// le cas échéant, une région ; Region vide = tout le pays
+type TauxTaxe struct {
+	ID       string  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	ClasseID string  `gorm:"type:uuid;not null;uniqueIndex:idx_taux_taxe_zone,priority:1" json:"classe_id"`
+	Pays     string  `gorm:"type:char(2);not null;uniqueIndex:idx_taux_taxe_zone,priority:2" json:"pays"`
+	Region   string  `gorm:"type:varchar(10);not null;default:'';uniqueIndex:idx_taux_taxe_zone,priority:3" json:"region,omitempty"`
+	Taux     float64 `gorm:"type:decimal(7,3);not null"                     json:"taux"`
+}
+
+// ParametresTaxe : réglages fiscaux de la boutique. PrixTTC indique que les
+// prix saisis sont taxes comprises ; PaysDefaut s'applique sans ?pays= et
+// ClasseDefaut aux produits sans classe.
+type ParametresTaxe struct {
+	BoutiqueID   string    `gorm:"type:uuid;primaryKey"                 json:"boutique_id"`
+	PrixTTC      bool      `gorm:"not null;default:false"               json:"prix_ttc"`
+	PaysDefaut   *string   `gorm:"type:char(2)"                         json:"pays_defaut,omitempty"`
+	ClasseDefaut *string   `gorm:"type:varchar(100)"                    json:"classe_defaut,omitempty"`
+	MisAJourLe   time.Time `gorm:"autoUpdateTime"                       json:"mis_a_jour_le"`
+}
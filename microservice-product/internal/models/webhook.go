@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+type StatutLivraison string
+
+const (
+	EvenementProduitPublie   = "produit.publie"
+	EvenementProduitArchive  = "produit.archive"
+	EvenementProduitSupprime = "produit.supprime"
+	EvenementVarianteRupture = "variante.rupture"
+
+	LivraisonEnAttente StatutLivraison = "en_attente"
+	LivraisonReussie   StatutLivraison = "reussie"
+	LivraisonEchouee   StatutLivraison = "echouee"
+)
+
+type AbonnementWebhook struct {
+	ID         string    `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	BoutiqueID string    `gorm:"type:uuid;not null;index"                       json:"boutique_id"`
+	URL        string    `gorm:"type:varchar(2048);not null"                    json:"url"`
+	Secret     string    `gorm:"type:varchar(255);not null"                     json:"-"`
+	Evenements []string  `gorm:"type:jsonb;serializer:json"                     json:"evenements"`
+	Actif      bool      `gorm:"not null;default:true"                          json:"actif"`
+	CreeLe     time.Time `gorm:"autoCreateTime"                                 json:"cree_le"`
+	MisAJourLe time.Time `gorm:"autoUpdateTime"                                 json:"mis_a_jour_le"`
+
+	// Relations
+	Livraisons []LivraisonWebhook `gorm:"foreignKey:AbonnementID;constraint:OnDelete:CASCADE" json:"livraisons,omitempty"`
+}
+
+// LivraisonWebhook : EssaisRestants compte les envois restants de la série
+// en cours (une relivraison en ouvre une nouvelle) ; ProchaineTentative est
+// la date à partir de laquelle le worker reprend une livraison en_attente,
+// repoussée pendant un envoi pour qu'un seul processus l'effectue.
+type LivraisonWebhook struct {
+	ID                 string          `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	AbonnementID       string          `gorm:"type:uuid;not null;index"                       json:"abonnement_id"`
+	Evenement          string          `gorm:"type:varchar(100);not null"                     json:"evenement"`
+	Payload            string          `gorm:"type:text;not null"                             json:"payload"`
+	Statut             StatutLivraison `gorm:"type:varchar(20);not null;default:en_attente"   json:"statut"`
+	Tentatives         int             `gorm:"not null;default:0"                             json:"tentatives"`
+	EssaisRestants     int             `gorm:"not null;default:0"                             json:"essais_restants"`
+	ProchaineTentative *time.Time      `gorm:"index"                                          json:"prochaine_tentative,omitempty"`
+	CodeReponse        *int            `json:"code_reponse,omitempty"`
+	Erreur             *string         `gorm:"type:text"                                      json:"erreur,omitempty"`
+	CreeLe             time.Time       `gorm:"autoCreateTime"                                 json:"cree_le"`
+	MisAJourLe         time.Time       `gorm:"autoUpdateTime"                                 json:"mis_a_jour_le"`
+}
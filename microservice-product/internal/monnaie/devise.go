@@ -0,0 +1,76 @@
+package monnaie
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// exposants ISO 4217 différents de 2
+var exposants = map[string]int{
+	// sans unité mineure
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0,
+	"KRW": 0, "PYG": 0, "RWF": 0, "UGX": 0, "UYI": 0, "VND": 0, "VUV": 0,
+	"XAF": 0, "XOF": 0, "XPF": 0,
+	// millièmes
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+	// unité de compte à quatre décimales
+	"CLF": 4, "UYW": 4,
+}
+
+// Exposant : nombre de décimales de la devise (2 si elle n'est pas répertoriée)
+func Exposant(devise string) int {
+	if e, ok := exposants[strings.ToUpper(devise)]; ok {
+		return e
+	}
+	return 2
+}
+
+// FormatJSON : écriture des prix dans les réponses
+type FormatJSON int32
+
+const (
+	// FormatChaine : "19.99", arrondi et complété selon la devise
+	FormatChaine FormatJSON = iota
+	// FormatUnitesMineures : 1999 (centimes, millimes... selon la devise)
+	FormatUnitesMineures
+)
+
+var format atomic.Int32
+
+// DefinirFormat fixe le format au démarrage ("chaine" ou "unites_mineures")
+func DefinirFormat(nom string) error {
+	switch nom {
+	case "", "chaine":
+		format.Store(int32(FormatChaine))
+	case "unites_mineures":
+		format.Store(int32(FormatUnitesMineures))
+	default:
+		return fmt.Errorf("format de montant inconnu %q (chaine, unites_mineures)", nom)
+	}
+	return nil
+}
+
+// FormatActuel : format des montants fixé au démarrage
+func FormatActuel() FormatJSON {
+	return FormatJSON(format.Load())
+}
+
+// Prix : un montant et sa devise, tel qu'il sort dans les réponses
+type Prix struct {
+	Montant Montant
+	Devise  string
+}
+
+func NouveauPrix(m Montant, devise string) Prix {
+	return Prix{Montant: m, Devise: devise}
+}
+
+// MarshalJSON suit le format configuré
+func (p Prix) MarshalJSON() ([]byte, error) {
+	if FormatJSON(format.Load()) == FormatUnitesMineures {
+		return []byte(strconv.FormatInt(p.Montant.UnitesMineures(p.Devise), 10)), nil
+	}
+	return []byte(strconv.Quote(p.Montant.Format(p.Devise))), nil
+}
@@ -0,0 +1,264 @@
+// Package monnaie manipule les montants en décimal exact : un Montant est un
+// entier de dix-millièmes, la précision des colonnes decimal(12,4). Les
+// montants ne passent jamais par float64, 19.99 reste 19.99 de la base au
+// JSON. Seuls les pourcentages et taux de taxe (AppliquerPourcentage,
+// AjouterTaxe, RetirerTaxe) arrivent en float64 : ils sont d'abord ramenés à
+// un entier de centièmes ou millièmes de point, le calcul reste entier. Les
+// montants JSON en notation scientifique (1e3) sont lus via DepuisFloat.
+package monnaie
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Echelle : nombre de décimales stockées
+const Echelle = 4
+
+const unite = 10000 // 10^Echelle
+
+// plafond : premier montant hors de decimal(12,4), 10^8 en valeur absolue
+const plafond = 100000000 * unite
+
+// Montant en dix-millièmes d'unité de devise
+type Montant int64
+
+var (
+	ErrFormat    = errors.New("montant invalide")
+	ErrPrecision = fmt.Errorf("au plus %d décimales", Echelle)
+	ErrDepasse   = errors.New("montant hors limites")
+)
+
+// Parse lit un décimal ("19.99", "-3", "0.5") sans passer par un flottant ;
+// au-delà de decimal(12,4) (10^8 en valeur absolue) : ErrDepasse
+func Parse(s string) (Montant, error) {
+	s = strings.TrimSpace(s)
+	negatif := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "-"), "+")
+	entier, fraction, _ := strings.Cut(s, ".")
+	if entier == "" && fraction == "" || !chiffres(entier) || !chiffres(fraction) {
+		return 0, fmt.Errorf("%w: %q", ErrFormat, s)
+	}
+	fraction = strings.TrimRight(fraction, "0")
+	if len(fraction) > Echelle {
+		return 0, ErrPrecision
+	}
+	fraction += strings.Repeat("0", Echelle-len(fraction))
+	if entier == "" {
+		entier = "0"
+	}
+	valeur, err := strconv.ParseInt(entier+fraction, 10, 64)
+	if err != nil || valeur >= plafond {
+		return 0, ErrDepasse
+	}
+	if negatif {
+		valeur = -valeur
+	}
+	return Montant(valeur), nil
+}
+
+func chiffres(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// String : forme décimale la plus courte ("19.99", "20", "-0.5")
+func (m Montant) String() string {
+	s := m.fixe(Echelle)
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+	return s
+}
+
+// fixe écrit le montant avec exactement decimales chiffres après la virgule
+// (le montant doit déjà être arrondi à cette précision)
+func (m Montant) fixe(decimales int) string {
+	signe := ""
+	v := int64(m)
+	if v < 0 {
+		signe, v = "-", -v
+	}
+	entier, fraction := v/unite, v%unite
+	if decimales == 0 {
+		return fmt.Sprintf("%s%d", signe, entier)
+	}
+	fractionTexte := fmt.Sprintf("%0*d", Echelle, fraction)[:decimales]
+	return fmt.Sprintf("%s%d.%s", signe, entier, fractionTexte)
+}
+
+// Format : montant arrondi à l'exposant de la devise, avec tous ses
+// chiffres ("19.90" EUR, "19.900" TND, "20" JPY)
+func (m Montant) Format(devise string) string {
+	return m.Arrondir(devise).fixe(Exposant(devise))
+}
+
+// Arrondir au plus proche selon l'exposant ISO 4217, la moitié s'éloignant de zéro
+func (m Montant) Arrondir(devise string) Montant {
+	pas := int64(math.Pow10(Echelle - Exposant(devise)))
+	return Montant(arrondiDivision(int64(m), pas) * pas)
+}
+
+// UnitesMineures : montant arrondi exprimé en unités mineures (centimes,
+// millimes...) ; 19.99 EUR -> 1999
+func (m Montant) UnitesMineures(devise string) int64 {
+	pas := int64(math.Pow10(Echelle - Exposant(devise)))
+	return arrondiDivision(int64(m), pas)
+}
+
+// DepuisUnitesMineures : 1999 EUR -> 19.99
+func DepuisUnitesMineures(n int64, devise string) Montant {
+	return Montant(n * int64(math.Pow10(Echelle-Exposant(devise))))
+}
+
+// DepuisFloat ne sert qu'aux valeurs qui arrivent déjà en flottant (anciens
+// JSON) : arrondi au dix-millième
+func DepuisFloat(f float64) Montant {
+	return Montant(math.Round(f * unite))
+}
+
+// Float : pour l'affichage ou les calculs approchés uniquement
+func (m Montant) Float() float64 {
+	return float64(m) / unite
+}
+
+// AppliquerPourcentage : m * (1 + pourcentage/100), pourcentage lu au
+// centième de point près, résultat arrondi à la devise
+func (m Montant) AppliquerPourcentage(pourcentage float64, devise string) Montant {
+	points := int64(math.Round(pourcentage * 100)) // 12.5 % -> 1250
+	return Montant(arrondiDivision(int64(m)*(10000+points), 10000)).Arrondir(devise)
+}
+
+// AjouterTaxe : prix taxes comprises d'un prix hors taxes au taux donné
+// (20 = 20 %), lu au millième de point près, arrondi à la devise
+func (m Montant) AjouterTaxe(taux float64, devise string) Montant {
+	points := int64(math.Round(taux * 1000))
+	return Montant(arrondiDivision(int64(m)*(100000+points), 100000)).Arrondir(devise)
+}
+
+// RetirerTaxe : prix hors taxes d'un prix taxes comprises, inverse d'AjouterTaxe
+func (m Montant) RetirerTaxe(taux float64, devise string) Montant {
+	points := int64(math.Round(taux * 1000))
+	return Montant(arrondiDivision(int64(m)*100000, 100000+points)).Arrondir(devise)
+}
+
+// Terminer : montant le plus proche finissant par terminaison (0.99 ->
+// 19.99, 24.99 ; 9.99 -> 19.99, 29.99), le plus haut à égale distance ; le
+// pas est la plus petite puissance de dix qui dépasse la terminaison
+func (m Montant) Terminer(terminaison Montant) Montant {
+	pas := Montant(unite)
+	for pas <= terminaison {
+		pas *= 10
+	}
+	k := (m - terminaison) / pas
+	if (m-terminaison)%pas < 0 {
+		k--
+	}
+	bas := k*pas + terminaison
+	haut := bas + pas
+	if bas < 0 || m-bas >= haut-m {
+		return haut
+	}
+	return bas
+}
+
+// Multiplier par un entier (quantité)
+func (m Montant) Multiplier(n int64) Montant {
+	return m * Montant(n)
+}
+
+// arrondiDivision : a/b arrondi au plus proche, la moitié s'éloignant de zéro (b > 0)
+func arrondiDivision(a, b int64) int64 {
+	q, r := a/b, a%b
+	if r < 0 {
+		r = -r
+	}
+	if 2*r >= b {
+		if a < 0 {
+			q--
+		} else {
+			q++
+		}
+	}
+	return q
+}
+
+// ------------------------------------------------------------
+// Base de données : decimal(12,4) <-> Montant
+// ------------------------------------------------------------
+func (m Montant) Value() (driver.Value, error) {
+	return m.String(), nil
+}
+
+func (m *Montant) Scan(valeur interface{}) error {
+	switch v := valeur.(type) {
+	case nil:
+		*m = 0
+		return nil
+	case []byte:
+		return m.lire(string(v))
+	case string:
+		return m.lire(v)
+	case int64:
+		*m = Montant(v * unite)
+		return nil
+	case float64:
+		*m = DepuisFloat(v)
+		return nil
+	}
+	return fmt.Errorf("montant: type %T non pris en charge", valeur)
+}
+
+func (m *Montant) lire(s string) error {
+	lu, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	*m = lu
+	return nil
+}
+
+// ------------------------------------------------------------
+// JSON
+// ------------------------------------------------------------
+
+// MarshalJSON : un montant seul ne connaît pas sa devise, il est toujours
+// écrit en chaîne décimale ; voir Prix pour le format configurable
+func (m Montant) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(m.String())), nil
+}
+
+// UnmarshalJSON accepte "19.99" comme 19.99 ; le nombre est lu tel
+// qu'écrit, sans conversion flottante
+func (m *Montant) UnmarshalJSON(donnees []byte) error {
+	texte := string(donnees)
+	if texte == "null" {
+		return nil
+	}
+	if strings.HasPrefix(texte, `"`) {
+		var err error
+		if texte, err = strconv.Unquote(texte); err != nil {
+			return fmt.Errorf("%w: %s", ErrFormat, donnees)
+		}
+	} else if strings.ContainsAny(texte, "eE") {
+		// notation scientifique : rare, on passe par le flottant
+		f, err := strconv.ParseFloat(texte, 64)
+		if err != nil {
+			return fmt.Errorf("%w: %s", ErrFormat, donnees)
+		}
+		if math.Abs(f) >= plafond/unite {
+			return ErrDepasse
+		}
+		*m = DepuisFloat(f)
+		return nil
+	}
+	return m.lire(texte)
+}
@@ -0,0 +1,245 @@
+package monnaie
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func montant(t *testing.T, s string) Montant {
+	t.Helper()
+	m, err := Parse(s)
+	if err != nil {
+		t.Fatalf("%q : %v", s, err)
+	}
+	return m
+}
+
+func taux(t *testing.T, s string) Taux {
+	t.Helper()
+	tx, err := ParseTaux(s)
+	if err != nil {
+		t.Fatalf("taux %q : %v", s, err)
+	}
+	return tx
+}
+
+func TestParse(t *testing.T) {
+	cas := []struct {
+		texte   string
+		attendu Montant
+	}{
+		{"19.99", 199900},
+		{"-3", -30000},
+		{"0.5", 5000},
+		{".5", 5000},
+		{"+2.", 20000},
+		{" 7 ", 70000},
+		{"1.23450", 12345},
+		{"0.0001", 1},
+		{"99999999.9999", 999999999999},
+		{"-99999999.9999", -999999999999},
+	}
+	for _, c := range cas {
+		obtenu, err := Parse(c.texte)
+		if err != nil {
+			t.Errorf("%q : %v", c.texte, err)
+			continue
+		}
+		if obtenu != c.attendu {
+			t.Errorf("%q : %d, attendu %d", c.texte, obtenu, c.attendu)
+		}
+	}
+
+	erreurs := []struct {
+		texte   string
+		attendu error
+	}{
+		{"", ErrFormat},
+		{".", ErrFormat},
+		{"abc", ErrFormat},
+		{"1,5", ErrFormat},
+		{"1.2.3", ErrFormat},
+		{"--1", ErrFormat},
+		{"1.23456", ErrPrecision},
+		{"100000000", ErrDepasse},
+		{"-100000000", ErrDepasse},
+		{"99999999999999999999", ErrDepasse},
+	}
+	for _, c := range erreurs {
+		if obtenu, err := Parse(c.texte); !errors.Is(err, c.attendu) {
+			t.Errorf("%q : %v (%d), attendu %v", c.texte, err, obtenu, c.attendu)
+		}
+	}
+}
+
+func TestArrondir(t *testing.T) {
+	cas := []struct {
+		montant, devise, attendu, format string
+	}{
+		// deux décimales
+		{"19.9949", "EUR", "19.99", "19.99"},
+		{"19.995", "EUR", "20", "20.00"},
+		{"19.9", "eur", "19.9", "19.90"},
+		// sans unité mineure
+		{"18.4999", "JPY", "18", "18"},
+		{"19.5", "JPY", "20", "20"},
+		// millièmes
+		{"1.2344", "TND", "1.234", "1.234"},
+		{"1.2345", "TND", "1.235", "1.235"},
+		{"19.9", "TND", "19.9", "19.900"},
+		// la moitié s'éloigne de zéro, aussi pour les négatifs
+		{"-19.995", "EUR", "-20", "-20.00"},
+		{"-19.9949", "EUR", "-19.99", "-19.99"},
+		{"-0.5", "JPY", "-1", "-1"},
+		{"-0.4999", "JPY", "0", "0"},
+		{"-1.2345", "TND", "-1.235", "-1.235"},
+		{"-1.2344", "TND", "-1.234", "-1.234"},
+	}
+	for _, c := range cas {
+		m := montant(t, c.montant)
+		if obtenu := m.Arrondir(c.devise); obtenu != montant(t, c.attendu) {
+			t.Errorf("%s %s : %s, attendu %s", c.montant, c.devise, obtenu, c.attendu)
+		}
+		if obtenu := m.Format(c.devise); obtenu != c.format {
+			t.Errorf("format %s %s : %q, attendu %q", c.montant, c.devise, obtenu, c.format)
+		}
+	}
+}
+
+func TestUnitesMineures(t *testing.T) {
+	cas := []struct {
+		montant, devise string
+		attendu         int64
+	}{
+		{"19.99", "EUR", 1999},
+		{"19.995", "EUR", 2000},
+		{"1500", "JPY", 1500},
+		{"1.2345", "TND", 1235},
+		{"-0.005", "EUR", -1},
+	}
+	for _, c := range cas {
+		m := montant(t, c.montant)
+		obtenu := m.UnitesMineures(c.devise)
+		if obtenu != c.attendu {
+			t.Errorf("%s %s : %d, attendu %d", c.montant, c.devise, obtenu, c.attendu)
+		}
+		if retour := DepuisUnitesMineures(obtenu, c.devise); retour != m.Arrondir(c.devise) {
+			t.Errorf("%s %s : retour %s, attendu %s", c.montant, c.devise, retour, m.Arrondir(c.devise))
+		}
+	}
+}
+
+func TestTerminer(t *testing.T) {
+	cas := []struct {
+		montant, terminaison, attendu string
+	}{
+		{"19.20", "0.99", "18.99"},
+		{"19.50", "0.99", "19.99"},
+		// à égale distance, le plus haut
+		{"19.49", "0.99", "19.99"},
+		{"0.30", "0.99", "0.99"},
+		{"24", "9.99", "19.99"},
+		{"25", "9.99", "29.99"},
+		{"3.2", "0.5", "3.5"},
+		{"19.5", "0", "20"},
+		{"19.4999", "0", "19"},
+	}
+	for _, c := range cas {
+		obtenu := montant(t, c.montant).Terminer(montant(t, c.terminaison))
+		if obtenu != montant(t, c.attendu) {
+			t.Errorf("%s fin %s : %s, attendu %s", c.montant, c.terminaison, obtenu, c.attendu)
+		}
+	}
+}
+
+func TestAppliquerPourcentage(t *testing.T) {
+	cas := []struct {
+		montant     string
+		pourcentage float64
+		devise      string
+		attendu     string
+	}{
+		{"100", 10, "EUR", "110"},
+		{"19.99", 12.5, "EUR", "22.49"},
+		{"19.99", -15, "EUR", "16.99"},
+		// lu au centième de point : 3.333 -> 3.33
+		{"1000", 3.333, "JPY", "1033"},
+		{"10", -33.33, "TND", "6.667"},
+		{"19.99", -100, "EUR", "0"},
+		{"-10", 5, "EUR", "-10.5"},
+	}
+	for _, c := range cas {
+		obtenu := montant(t, c.montant).AppliquerPourcentage(c.pourcentage, c.devise)
+		if obtenu != montant(t, c.attendu) {
+			t.Errorf("%s %s %+g %% : %s, attendu %s", c.montant, c.devise, c.pourcentage, obtenu, c.attendu)
+		}
+	}
+}
+
+func TestConvertir(t *testing.T) {
+	cas := []struct {
+		montant, taux, devise, attendu string
+	}{
+		{"100", "3.2741", "TND", "327.41"},
+		{"19.99", "161.23456789", "JPY", "3223"},
+		{"-10", "0.3333", "EUR", "-3.33"},
+		{"-10", "0.3333", "TND", "-3.333"},
+		// -0.5 JPY : la moitié s'éloigne de zéro
+		{"-0.25", "2", "JPY", "-1"},
+		{"99999999.9999", "99999.99999999", "EUR", "9999999999989"},
+	}
+	for _, c := range cas {
+		obtenu := montant(t, c.montant).Convertir(taux(t, c.taux), c.devise)
+		if obtenu.String() != c.attendu {
+			t.Errorf("%s x %s %s : %s, attendu %s", c.montant, c.taux, c.devise, obtenu, c.attendu)
+		}
+	}
+}
+
+func TestConvertirInverse(t *testing.T) {
+	cas := []struct {
+		montant, inverse, devise, attendu string
+	}{
+		{"327.41", "3.2741", "EUR", "100"},
+		{"10", "0.92", "USD", "10.87"},
+		{"1000", "161.23456789", "EUR", "6.2"},
+		{"-10", "3", "TND", "-3.333"},
+		{"-0.5", "1", "JPY", "-1"},
+	}
+	for _, c := range cas {
+		obtenu := montant(t, c.montant).ConvertirInverse(taux(t, c.inverse), c.devise)
+		if obtenu.String() != c.attendu {
+			t.Errorf("%s / %s %s : %s, attendu %s", c.montant, c.inverse, c.devise, obtenu, c.attendu)
+		}
+	}
+}
+
+func TestUnmarshalJSON(t *testing.T) {
+	cas := []struct {
+		json    string
+		attendu Montant
+	}{
+		{`"19.99"`, 199900},
+		{`19.99`, 199900},
+		{`1e3`, 10000000},
+		{`-2.5E-1`, -2500},
+	}
+	for _, c := range cas {
+		var m Montant
+		if err := json.Unmarshal([]byte(c.json), &m); err != nil {
+			t.Errorf("%s : %v", c.json, err)
+			continue
+		}
+		if m != c.attendu {
+			t.Errorf("%s : %d, attendu %d", c.json, m, c.attendu)
+		}
+	}
+
+	for _, texte := range []string{`"100000000"`, `100000000`, `1e8`, `-1E9`} {
+		var m Montant
+		if err := json.Unmarshal([]byte(texte), &m); !errors.Is(err, ErrDepasse) {
+			t.Errorf("%s : %v, attendu %v", texte, err, ErrDepasse)
+		}
+	}
+}
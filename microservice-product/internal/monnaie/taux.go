@@ -0,0 +1,128 @@
+package monnaie
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// EchelleTaux : décimales d'un taux de change, la précision de la colonne decimal(18,8)
+const EchelleTaux = 8
+
+const uniteTaux = 100000000 // 10^EchelleTaux
+
+// Taux de change en cent-millionièmes : 1 unité source = Taux unités cible.
+// Comme Montant, il ne passe jamais par un flottant.
+type Taux int64
+
+// ParseTaux lit un décimal positif ("3.2741", "0.30542187")
+func ParseTaux(s string) (Taux, error) {
+	s = strings.TrimSpace(s)
+	entier, fraction, _ := strings.Cut(s, ".")
+	if entier == "" && fraction == "" || !chiffres(entier) || !chiffres(fraction) {
+		return 0, fmt.Errorf("%w: taux %q", ErrFormat, s)
+	}
+	fraction = strings.TrimRight(fraction, "0")
+	if len(fraction) > EchelleTaux {
+		return 0, fmt.Errorf("%w: au plus %d décimales pour un taux", ErrPrecision, EchelleTaux)
+	}
+	fraction += strings.Repeat("0", EchelleTaux-len(fraction))
+	if entier == "" {
+		entier = "0"
+	}
+	valeur, err := strconv.ParseInt(entier+fraction, 10, 64)
+	if err != nil {
+		return 0, ErrDepasse
+	}
+	return Taux(valeur), nil
+}
+
+// String : forme décimale la plus courte ("3.2741", "1")
+func (t Taux) String() string {
+	s := fmt.Sprintf("%d.%0*d", int64(t)/uniteTaux, EchelleTaux, int64(t)%uniteTaux)
+	return strings.TrimRight(strings.TrimRight(s, "0"), ".")
+}
+
+// Convertir dans une autre devise au taux donné, arrondi à l'exposant de la
+// devise cible. Le produit est calculé en entier exact (big.Int) : un montant
+// decimal(12,4) fois un taux decimal(18,8) dépasse int64.
+func (m Montant) Convertir(taux Taux, devise string) Montant {
+	produit := new(big.Int).Mul(big.NewInt(int64(m)), big.NewInt(int64(taux)))
+	return Montant(diviserArrondi(produit, big.NewInt(uniteTaux))).Arrondir(devise)
+}
+
+// ConvertirInverse : conversion quand seul le taux de l'autre sens est
+// connu (1 unité cible = inverse unités source), sans calculer 1/inverse
+func (m Montant) ConvertirInverse(inverse Taux, devise string) Montant {
+	produit := new(big.Int).Mul(big.NewInt(int64(m)), big.NewInt(uniteTaux))
+	return Montant(diviserArrondi(produit, big.NewInt(int64(inverse)))).Arrondir(devise)
+}
+
+// diviserArrondi : a/b arrondi au plus proche, la moitié s'éloignant de zéro
+// (b > 0), comme arrondiDivision
+func diviserArrondi(a, b *big.Int) int64 {
+	q, r := new(big.Int).QuoRem(a, b, new(big.Int))
+	if new(big.Int).Mul(new(big.Int).Abs(r), big.NewInt(2)).Cmp(b) >= 0 {
+		if a.Sign() < 0 {
+			q.Sub(q, big.NewInt(1))
+		} else {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+	return q.Int64()
+}
+
+// ------------------------------------------------------------
+// Base de données : decimal(18,8) <-> Taux
+// ------------------------------------------------------------
+func (t Taux) Value() (driver.Value, error) {
+	return t.String(), nil
+}
+
+func (t *Taux) Scan(valeur interface{}) error {
+	switch v := valeur.(type) {
+	case nil:
+		*t = 0
+		return nil
+	case []byte:
+		return t.lire(string(v))
+	case string:
+		return t.lire(v)
+	case int64:
+		*t = Taux(v * uniteTaux)
+		return nil
+	}
+	return fmt.Errorf("taux: type %T non pris en charge", valeur)
+}
+
+func (t *Taux) lire(s string) error {
+	lu, err := ParseTaux(s)
+	if err != nil {
+		return err
+	}
+	*t = lu
+	return nil
+}
+
+// ------------------------------------------------------------
+// JSON : écrit en chaîne décimale, lu en chaîne ou en nombre tel qu'écrit
+// ------------------------------------------------------------
+func (t Taux) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(t.String())), nil
+}
+
+func (t *Taux) UnmarshalJSON(donnees []byte) error {
+	texte := string(donnees)
+	if texte == "null" {
+		return nil
+	}
+	if strings.HasPrefix(texte, `"`) {
+		var err error
+		if texte, err = strconv.Unquote(texte); err != nil {
+			return fmt.Errorf("%w: %s", ErrFormat, donnees)
+		}
+	}
+	return t.lire(texte)
+}
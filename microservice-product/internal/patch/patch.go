@@ -0,0 +1,368 @@
+// Package patch applique des modifications partielles sur des documents JSON :
+// JSON Merge Patch (RFC 7396) et JSON Patch (RFC 6902).
+//
+// Les documents sont manipulés sous forme générique (map/slice/interface{})
+// puis re-sérialisés ; c'est à l'appelant de décoder et valider le résultat.
+package patch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+const (
+	TypeMergePatch = "application/merge-patch+json"
+	TypeJSONPatch  = "application/json-patch+json"
+)
+
+// ErrTestEchoue : une opération "test" ne correspond pas au document (RFC 6902 §4.6)
+var ErrTestEchoue = errors.New("opération test échouée")
+
+// ------------------------------------------------------------
+// RFC 7396 : JSON Merge Patch
+// ------------------------------------------------------------
+
+// Fusionner applique patch sur doc : null supprime le champ, un objet est
+// fusionné récursivement, toute autre valeur (tableaux compris) remplace.
+func Fusionner(doc, patch []byte) ([]byte, error) {
+	var cible interface{}
+	if err := decoder(doc, &cible); err != nil {
+		return nil, fmt.Errorf("document invalide: %w", err)
+	}
+	var modif interface{}
+	if err := decoder(patch, &modif); err != nil {
+		return nil, fmt.Errorf("merge patch invalide: %w", err)
+	}
+	return json.Marshal(fusionner(cible, modif))
+}
+
+func fusionner(cible, modif interface{}) interface{} {
+	champs, ok := modif.(map[string]interface{})
+	if !ok {
+		return modif
+	}
+	objet, ok := cible.(map[string]interface{})
+	if !ok {
+		objet = map[string]interface{}{}
+	}
+	for cle, valeur := range champs {
+		if valeur == nil {
+			delete(objet, cle)
+			continue
+		}
+		objet[cle] = fusionner(objet[cle], valeur)
+	}
+	return objet
+}
+
+// ------------------------------------------------------------
+// RFC 6902 : JSON Patch
+// ------------------------------------------------------------
+
+type operation struct {
+	Op    string           `json:"op"`
+	Path  *string          `json:"path"`
+	From  *string          `json:"from"`
+	Value *json.RawMessage `json:"value"`
+}
+
+// Appliquer exécute la liste d'opérations dans l'ordre ; la première erreur
+// annule tout (le document d'origine n'est jamais modifié).
+func Appliquer(doc, ops []byte) ([]byte, error) {
+	var cible interface{}
+	if err := decoder(doc, &cible); err != nil {
+		return nil, fmt.Errorf("document invalide: %w", err)
+	}
+	var operations []operation
+	if err := json.Unmarshal(ops, &operations); err != nil {
+		return nil, fmt.Errorf("json patch invalide: %w", err)
+	}
+
+	for i, op := range operations {
+		var err error
+		cible, err = appliquerOperation(cible, op)
+		if err != nil {
+			return nil, fmt.Errorf("opération %d (%s): %w", i, op.Op, err)
+		}
+	}
+	return json.Marshal(cible)
+}
+
+func appliquerOperation(doc interface{}, op operation) (interface{}, error) {
+	if op.Path == nil {
+		return nil, errors.New("path requis")
+	}
+	chemin, err := analyserPointeur(*op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op.Op {
+	case "add", "replace", "test":
+		if op.Value == nil {
+			return nil, errors.New("value requise")
+		}
+		var valeur interface{}
+		if err := decoder(*op.Value, &valeur); err != nil {
+			return nil, fmt.Errorf("value invalide: %w", err)
+		}
+		switch op.Op {
+		case "add":
+			return ajouter(doc, chemin, valeur)
+		case "replace":
+			if doc, err = retirer(doc, chemin); err != nil {
+				return nil, err
+			}
+			return ajouter(doc, chemin, valeur)
+		default:
+			actuelle, err := lire(doc, chemin)
+			if err != nil {
+				return nil, err
+			}
+			if !egalJSON(actuelle, valeur) {
+				return nil, ErrTestEchoue
+			}
+			return doc, nil
+		}
+
+	case "remove":
+		return retirer(doc, chemin)
+
+	case "move", "copy":
+		if op.From == nil {
+			return nil, errors.New("from requis")
+		}
+		source, err := analyserPointeur(*op.From)
+		if err != nil {
+			return nil, err
+		}
+		valeur, err := lire(doc, source)
+		if err != nil {
+			return nil, err
+		}
+		if op.Op == "move" {
+			// on ne peut pas déplacer un noeud dans un de ses descendants
+			if len(chemin) > len(source) && egaux(chemin[:len(source)], source) {
+				return nil, errors.New("from est un ancêtre de path")
+			}
+			if doc, err = retirer(doc, source); err != nil {
+				return nil, err
+			}
+		} else {
+			valeur = copieProfonde(valeur)
+		}
+		return ajouter(doc, chemin, valeur)
+	}
+	return nil, fmt.Errorf("op inconnue %q", op.Op)
+}
+
+// analyserPointeur découpe un JSON Pointer (RFC 6901) en jetons
+func analyserPointeur(pointeur string) ([]string, error) {
+	if pointeur == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointeur, "/") {
+		return nil, fmt.Errorf("pointeur invalide %q", pointeur)
+	}
+	jetons := strings.Split(pointeur[1:], "/")
+	for i, jeton := range jetons {
+		jetons[i] = strings.ReplaceAll(strings.ReplaceAll(jeton, "~1", "/"), "~0", "~")
+	}
+	return jetons, nil
+}
+
+func lire(doc interface{}, chemin []string) (interface{}, error) {
+	courant := doc
+	for _, jeton := range chemin {
+		switch noeud := courant.(type) {
+		case map[string]interface{}:
+			valeur, ok := noeud[jeton]
+			if !ok {
+				return nil, fmt.Errorf("chemin introuvable /%s", jeton)
+			}
+			courant = valeur
+		case []interface{}:
+			i, err := indexTableau(jeton, len(noeud)-1)
+			if err != nil {
+				return nil, err
+			}
+			courant = noeud[i]
+		default:
+			return nil, fmt.Errorf("chemin introuvable /%s", jeton)
+		}
+	}
+	return courant, nil
+}
+
+// ajouter renvoie le document modifié (la racine peut être remplacée)
+func ajouter(doc interface{}, chemin []string, valeur interface{}) (interface{}, error) {
+	if len(chemin) == 0 {
+		return valeur, nil
+	}
+	parent, err := lire(doc, chemin[:len(chemin)-1])
+	if err != nil {
+		return nil, err
+	}
+	dernier := chemin[len(chemin)-1]
+
+	switch noeud := parent.(type) {
+	case map[string]interface{}:
+		noeud[dernier] = valeur
+		return doc, nil
+	case []interface{}:
+		i := len(noeud)
+		if dernier != "-" {
+			if i, err = indexTableau(dernier, len(noeud)); err != nil {
+				return nil, err
+			}
+		}
+		tableau := append(noeud[:i:i], append([]interface{}{valeur}, noeud[i:]...)...)
+		return remplacer(doc, chemin[:len(chemin)-1], tableau)
+	}
+	return nil, fmt.Errorf("chemin introuvable /%s", dernier)
+}
+
+func retirer(doc interface{}, chemin []string) (interface{}, error) {
+	if len(chemin) == 0 {
+		return nil, nil
+	}
+	parent, err := lire(doc, chemin[:len(chemin)-1])
+	if err != nil {
+		return nil, err
+	}
+	dernier := chemin[len(chemin)-1]
+
+	switch noeud := parent.(type) {
+	case map[string]interface{}:
+		if _, ok := noeud[dernier]; !ok {
+			return nil, fmt.Errorf("chemin introuvable /%s", dernier)
+		}
+		delete(noeud, dernier)
+		return doc, nil
+	case []interface{}:
+		i, err := indexTableau(dernier, len(noeud)-1)
+		if err != nil {
+			return nil, err
+		}
+		tableau := append(noeud[:i:i], noeud[i+1:]...)
+		return remplacer(doc, chemin[:len(chemin)-1], tableau)
+	}
+	return nil, fmt.Errorf("chemin introuvable /%s", dernier)
+}
+
+// remplacer réaffecte un tableau dont la longueur a changé chez son parent
+func remplacer(doc interface{}, chemin []string, valeur interface{}) (interface{}, error) {
+	if len(chemin) == 0 {
+		return valeur, nil
+	}
+	parent, err := lire(doc, chemin[:len(chemin)-1])
+	if err != nil {
+		return nil, err
+	}
+	dernier := chemin[len(chemin)-1]
+	switch noeud := parent.(type) {
+	case map[string]interface{}:
+		noeud[dernier] = valeur
+	case []interface{}:
+		i, err := indexTableau(dernier, len(noeud)-1)
+		if err != nil {
+			return nil, err
+		}
+		noeud[i] = valeur
+	}
+	return doc, nil
+}
+
+// indexTableau valide un index sans zéro initial, compris entre 0 et max
+func indexTableau(jeton string, max int) (int, error) {
+	if jeton == "" || (len(jeton) > 1 && jeton[0] == '0') {
+		return 0, fmt.Errorf("index invalide %q", jeton)
+	}
+	i, err := strconv.Atoi(jeton)
+	if err != nil || i < 0 || i > max {
+		return 0, fmt.Errorf("index hors limites %q", jeton)
+	}
+	return i, nil
+}
+
+func egaux(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// egalJSON compare deux valeurs JSON ; les nombres sont comparés par valeur
+// (12.5 == 12.50), pas par représentation textuelle
+func egalJSON(a, b interface{}) bool {
+	switch va := a.(type) {
+	case json.Number:
+		vb, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		ra, okA := new(big.Rat).SetString(va.String())
+		rb, okB := new(big.Rat).SetString(vb.String())
+		return okA && okB && ra.Cmp(rb) == 0
+	case map[string]interface{}:
+		vb, ok := b.(map[string]interface{})
+		if !ok || len(va) != len(vb) {
+			return false
+		}
+		for cle, enfant := range va {
+			autre, ok := vb[cle]
+			if !ok || !egalJSON(enfant, autre) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		vb, ok := b.([]interface{})
+		if !ok || len(va) != len(vb) {
+			return false
+		}
+		for i := range va {
+			if !egalJSON(va[i], vb[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return a == b
+}
+
+func copieProfonde(valeur interface{}) interface{} {
+	switch v := valeur.(type) {
+	case map[string]interface{}:
+		copie := make(map[string]interface{}, len(v))
+		for cle, enfant := range v {
+			copie[cle] = copieProfonde(enfant)
+		}
+		return copie
+	case []interface{}:
+		copie := make([]interface{}, len(v))
+		for i, enfant := range v {
+			copie[i] = copieProfonde(enfant)
+		}
+		return copie
+	}
+	return valeur
+}
+
+// decoder garde les nombres tels quels (json.Number) pour ne pas perdre de
+// précision sur les prix lors de l'aller-retour
+func decoder(donnees []byte, cible interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(donnees))
+	dec.UseNumber()
+	return dec.Decode(cible)
+}
@@ -0,0 +1,161 @@
+package patch
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// memeJSON compare deux documents JSON indépendamment de l'ordre des clés
+func memeJSON(t *testing.T, obtenu []byte, attendu string) bool {
+	t.Helper()
+	var a, b interface{}
+	if err := json.Unmarshal(obtenu, &a); err != nil {
+		t.Fatalf("résultat illisible %s: %v", obtenu, err)
+	}
+	if err := json.Unmarshal([]byte(attendu), &b); err != nil {
+		t.Fatalf("attendu illisible %s: %v", attendu, err)
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// exemples de l'annexe A de la RFC 7396
+func TestFusionnerRFC7396(t *testing.T) {
+	cas := []struct{ doc, patch, attendu string }{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+	for _, c := range cas {
+		obtenu, err := Fusionner([]byte(c.doc), []byte(c.patch))
+		if err != nil {
+			t.Errorf("%s + %s : %v", c.doc, c.patch, err)
+			continue
+		}
+		if !memeJSON(t, obtenu, c.attendu) {
+			t.Errorf("%s + %s : %s, attendu %s", c.doc, c.patch, obtenu, c.attendu)
+		}
+	}
+
+	if _, err := Fusionner([]byte(`{"a":`), []byte(`{}`)); err == nil {
+		t.Error("document tronqué : erreur attendue")
+	}
+}
+
+// exemples de l'annexe A de la RFC 6902 (A.13, membre "op" en double, est
+// laissé au décodeur JSON qui garde la dernière valeur)
+func TestAppliquerRFC6902(t *testing.T) {
+	cas := []struct {
+		nom, doc, ops, attendu string
+	}{
+		{"A.1 ajout d'un membre", `{"foo":"bar"}`,
+			`[{"op":"add","path":"/baz","value":"qux"}]`,
+			`{"baz":"qux","foo":"bar"}`},
+		{"A.2 ajout dans un tableau", `{"foo":["bar","baz"]}`,
+			`[{"op":"add","path":"/foo/1","value":"qux"}]`,
+			`{"foo":["bar","qux","baz"]}`},
+		{"A.3 retrait d'un membre", `{"baz":"qux","foo":"bar"}`,
+			`[{"op":"remove","path":"/baz"}]`,
+			`{"foo":"bar"}`},
+		{"A.4 retrait d'un élément", `{"foo":["bar","qux","baz"]}`,
+			`[{"op":"remove","path":"/foo/1"}]`,
+			`{"foo":["bar","baz"]}`},
+		{"A.5 remplacement", `{"baz":"qux","foo":"bar"}`,
+			`[{"op":"replace","path":"/baz","value":"boo"}]`,
+			`{"baz":"boo","foo":"bar"}`},
+		{"A.6 déplacement d'une valeur", `{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`,
+			`[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`,
+			`{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`},
+		{"A.7 déplacement dans un tableau", `{"foo":["all","grass","cows","eat"]}`,
+			`[{"op":"move","from":"/foo/1","path":"/foo/3"}]`,
+			`{"foo":["all","cows","eat","grass"]}`},
+		{"A.8 test réussi", `{"baz":"qux","foo":["a",2,"c"]}`,
+			`[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2}]`,
+			`{"baz":"qux","foo":["a",2,"c"]}`},
+		{"A.10 ajout d'un membre imbriqué", `{"foo":"bar"}`,
+			`[{"op":"add","path":"/child","value":{"grandchild":{}}}]`,
+			`{"foo":"bar","child":{"grandchild":{}}}`},
+		{"A.11 membres inconnus ignorés", `{"foo":"bar"}`,
+			`[{"op":"add","path":"/baz","value":"qux","xyz":123}]`,
+			`{"foo":"bar","baz":"qux"}`},
+		{"A.14 ordre d'échappement ~01", `{"/":9,"~1":10}`,
+			`[{"op":"test","path":"/~01","value":10}]`,
+			`{"/":9,"~1":10}`},
+		{"A.16 ajout d'un tableau en fin", `{"foo":["bar"]}`,
+			`[{"op":"add","path":"/foo/-","value":["abc","def"]}]`,
+			`{"foo":["bar",["abc","def"]]}`},
+		{"copie profonde", `{"a":{"b":1}}`,
+			`[{"op":"copy","from":"/a","path":"/c"},{"op":"replace","path":"/c/b","value":2}]`,
+			`{"a":{"b":1},"c":{"b":2}}`},
+		{"remplacement de la racine", `{"a":1}`,
+			`[{"op":"replace","path":"","value":[1]}]`,
+			`[1]`},
+		{"nombres comparés par valeur", `{"prix":12.5}`,
+			`[{"op":"test","path":"/prix","value":12.50}]`,
+			`{"prix":12.5}`},
+	}
+	for _, c := range cas {
+		obtenu, err := Appliquer([]byte(c.doc), []byte(c.ops))
+		if err != nil {
+			t.Errorf("%s : %v", c.nom, err)
+			continue
+		}
+		if !memeJSON(t, obtenu, c.attendu) {
+			t.Errorf("%s : %s, attendu %s", c.nom, obtenu, c.attendu)
+		}
+	}
+}
+
+func TestAppliquerErreurs(t *testing.T) {
+	cas := []struct {
+		nom, doc, ops string
+		attendu       error
+	}{
+		{"A.9 test en échec", `{"baz":"qux"}`,
+			`[{"op":"test","path":"/baz","value":"bar"}]`, ErrTestEchoue},
+		{"A.12 cible inexistante", `{"foo":"bar"}`,
+			`[{"op":"add","path":"/baz/bat","value":"qux"}]`, nil},
+		{"A.15 chaîne et nombre", `{"/":9,"~1":10}`,
+			`[{"op":"test","path":"/~01","value":"10"}]`, ErrTestEchoue},
+		{"déplacement dans un descendant", `{"a":{"b":{}}}`,
+			`[{"op":"move","from":"/a","path":"/a/b/c"}]`, nil},
+		{"index avec zéro initial", `{"foo":["a","b"]}`,
+			`[{"op":"remove","path":"/foo/01"}]`, nil},
+		{"index au-delà de la fin", `{"foo":["a"]}`,
+			`[{"op":"add","path":"/foo/2","value":"b"}]`, nil},
+		{"retrait d'un membre absent", `{"foo":"bar"}`,
+			`[{"op":"remove","path":"/baz"}]`, nil},
+		{"pointeur sans /", `{"foo":"bar"}`,
+			`[{"op":"remove","path":"foo"}]`, nil},
+		{"value manquante", `{}`,
+			`[{"op":"add","path":"/a"}]`, nil},
+		{"op inconnue", `{}`,
+			`[{"op":"merge","path":"/a","value":1}]`, nil},
+		// une erreur annule les opérations précédentes
+		{"tout ou rien", `{"a":1}`,
+			`[{"op":"remove","path":"/a"},{"op":"test","path":"/a","value":1}]`, nil},
+	}
+	for _, c := range cas {
+		obtenu, err := Appliquer([]byte(c.doc), []byte(c.ops))
+		if err == nil {
+			t.Errorf("%s : erreur attendue, obtenu %s", c.nom, obtenu)
+			continue
+		}
+		if c.attendu != nil && !errors.Is(err, c.attendu) {
+			t.Errorf("%s : %v, attendu %v", c.nom, err, c.attendu)
+		}
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type OptionProduitValeurRepo struct {
@@ -17,6 +18,33 @@ func NewOptionProduitValeurRepo(db *gorm.DB) *OptionProduitValeurRepo {
 	return &OptionProduitValeurRepo{db: db}
 }
 
+// Transaction exécute fn avec le repo et le journal d'audit liés à une même
+// transaction : l'entrée d'audit est annulée avec l'écriture qu'elle décrit
+func (r *OptionProduitValeurRepo) Transaction(ctx context.Context, fn func(options *OptionProduitValeurRepo, journal *AuditRepo) error) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(NewOptionProduitValeurRepo(tx), NewAuditRepo(tx))
+	})
+}
+
+// BoutiqueDuProduit renvoie la boutique propriétaire du produit (corbeille
+// comprise), "" si le produit n'existe pas
+func (r *OptionProduitValeurRepo) BoutiqueDuProduit(ctx context.Context, produitID string) (string, error) {
+	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var boutiques []string
+	if err := r.db.WithContext(opCtx).Unscoped().Model(&models.Produit{}).
+		Where("id = ?", produitID).
+		Limit(1).
+		Pluck("boutique_id", &boutiques).Error; err != nil {
+		return "", fmt.Errorf("error fetching product store: %w", err)
+	}
+	if len(boutiques) == 0 {
+		return "", nil
+	}
+	return boutiques[0], nil
+}
+
 // creation
 func (r *OptionProduitValeurRepo) CreationOptProduit(ctx context.Context, optProduit *models.OptionProduit) (*models.OptionProduit, error) {
 	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
@@ -28,11 +56,28 @@ func (r *OptionProduitValeurRepo) CreationOptProduit(ctx context.Context, optPro
 	return optProduit, nil
 }
 
+// CreationValeurOption insère la valeur ; position 0 = après la dernière
+// valeur de l'option, calculée sous le verrou du produit
 func (r *OptionProduitValeurRepo) CreationValeurOption(ctx context.Context, optProduit *models.ValeurOption) (*models.ValeurOption, error) {
 	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	if err := r.db.WithContext(opCtx).Create(optProduit).Error; err != nil {
+	err := r.db.WithContext(opCtx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(`SELECT p.id FROM produits p JOIN option_produits o ON o.produit_id = p.id
+			WHERE o.id = ? FOR UPDATE OF p`, optProduit.OptionID).Error; err != nil {
+			return fmt.Errorf("failed to lock product: %w", err)
+		}
+		if optProduit.Position == 0 {
+			if err := tx.Model(&models.ValeurOption{}).
+				Where("option_id = ?", optProduit.OptionID).
+				Select("COALESCE(MAX(position), 0) + 1").
+				Scan(&optProduit.Position).Error; err != nil {
+				return fmt.Errorf("failed to compute valeurOption position: %w", err)
+			}
+		}
+		return tx.Create(optProduit).Error
+	})
+	if err != nil {
 		return nil, fmt.Errorf("failed to insert valeurOption: %w", err)
 	}
 	return optProduit, nil
@@ -46,6 +91,7 @@ func (r *OptionProduitValeurRepo) ListeOptProduits(ctx context.Context, produitI
 	var produits []models.OptionProduit
 	if err := r.db.WithContext(opCtx).
 		Where("produit_id = ?", produitID).
+		Order("position").
 		Preload("ValeurOpts", func(db *gorm.DB) *gorm.DB {
 			return db.Order("position")
 		}).
@@ -60,7 +106,7 @@ func (r *OptionProduitValeurRepo) ListeValeursOption(ctx context.Context, Option
 	defer cancel()
 
 	var valeuropt []models.ValeurOption
-	if err := r.db.WithContext(opCtx).Where("option_id = ?", OptionID).Find(&valeuropt).Error; err != nil {
+	if err := r.db.WithContext(opCtx).Where("option_id = ?", OptionID).Order("position").Find(&valeuropt).Error; err != nil {
 		return nil, fmt.Errorf("find valeurOption failed: %w", err)
 	}
 	return valeuropt, nil
@@ -103,16 +149,16 @@ func (r *OptionProduitValeurRepo) GetByIDValeurOption(ctx context.Context, id st
 }
 
 // update
-func (r *OptionProduitValeurRepo) UpdateProduitOption(ctx context.Context, id string, updates map[string]interface{}) (*models.OptionProduit, error) {
+func (r *OptionProduitValeurRepo) UpdateProduitOption(ctx context.Context, id string, updates map[string]interface{}, version *int) (*models.OptionProduit, error) {
 	/*yhdhr fil context mtaa bdd*/
 	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
 	/*9aad ybdati*/
 	//milloul yimchi li table optProduit bModel ou baad bidhbt win bl id. ou baad yaaml l u^date
-	result := r.db.WithContext(opCtx).Model(&models.OptionProduit{}).
-		Where("id = ?", id).
-		Updates(updates)
+	updates["version"] = gorm.Expr("version + 1")
+	query := r.db.WithContext(opCtx).Model(&models.OptionProduit{}).Where("id = ?", id)
+	result := avecVersion(query, version).Updates(updates)
 
 	/*ytesti l9aha walla mal9ahech w njhit wella*/
 	if result.Error != nil {
@@ -120,7 +166,7 @@ func (r *OptionProduitValeurRepo) UpdateProduitOption(ctx context.Context, id st
 	}
 	//ml9a hatte ligne
 	if result.RowsAffected == 0 {
-		return nil, nil
+		return nil, conflitVersion(r.db.WithContext(opCtx), &models.OptionProduit{}, id, version)
 	}
 
 	/*ki nijhit 9aadin nlwjou bech nrja3ou lprod*/
@@ -130,16 +176,16 @@ func (r *OptionProduitValeurRepo) UpdateProduitOption(ctx context.Context, id st
 	}
 	return &optProduit, nil
 }
-func (r *OptionProduitValeurRepo) UpdateValeurOpt(ctx context.Context, id string, updates map[string]interface{}) (*models.ValeurOption, error) {
+func (r *OptionProduitValeurRepo) UpdateValeurOpt(ctx context.Context, id string, updates map[string]interface{}, version *int) (*models.ValeurOption, error) {
 	/*yhdhr fil context mtaa bdd*/
 	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
 	/*9aad ybdati*/
 	//milloul yimchi li table optProduit bModel ou baad bidhbt win bl id. ou baad yaaml l u^date
-	result := r.db.WithContext(opCtx).Model(&models.ValeurOption{}).
-		Where("id = ?", id).
-		Updates(updates)
+	updates["version"] = gorm.Expr("version + 1")
+	query := r.db.WithContext(opCtx).Model(&models.ValeurOption{}).Where("id = ?", id)
+	result := avecVersion(query, version).Updates(updates)
 
 	/*ytesti l9aha walla mal9ahech w njhit wella*/
 	if result.Error != nil {
@@ -147,7 +193,7 @@ func (r *OptionProduitValeurRepo) UpdateValeurOpt(ctx context.Context, id string
 	}
 	//ml9a hatte ligne
 	if result.RowsAffected == 0 {
-		return nil, nil
+		return nil, conflitVersion(r.db.WithContext(opCtx), &models.ValeurOption{}, id, version)
 	}
 
 	/*ki nijhit 9aadin nlwjou bech nrja3ou lprod*/
@@ -159,49 +205,266 @@ func (r *OptionProduitValeurRepo) UpdateValeurOpt(ctx context.Context, id string
 }
 
 // Suppression
-func (r *OptionProduitValeurRepo) SupprimerOptPById(ctx context.Context, id string) (bool, error) {
+// SupprimerOptPById supprime l'option puis resserre les positions des
+// options restantes du produit (1..n), dans la même transaction. Si des
+// variantes utilisent une de ses valeurs : ErreurVariantesLiees, sauf avec
+// cascade où ces variantes sont supprimées (et renvoyées).
+func (r *OptionProduitValeurRepo) SupprimerOptPById(ctx context.Context, id string, version *int, cascade bool) (bool, []models.Variante, error) {
 	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	result := r.db.WithContext(opCtx).Where("id = ?", id).Delete(&models.OptionProduit{})
-	if result.Error != nil {
-		return false, fmt.Errorf("failed to delete ProductOption: %w", result.Error)
-	}
-	return result.RowsAffected > 0, nil
+	supprime := false
+	var retirees []models.Variante
+	err := r.db.WithContext(opCtx).Transaction(func(tx *gorm.DB) error {
+		var option models.OptionProduit
+		if err := tx.Select("id", "produit_id").Where("id = ?", id).Limit(1).Find(&option).Error; err != nil {
+			return fmt.Errorf("failed to delete ProductOption: %w", err)
+		}
+		if option.ID == "" {
+			return nil
+		}
+
+		var valeurIDs []string
+		if err := tx.Model(&models.ValeurOption{}).Where("option_id = ?", id).Pluck("id", &valeurIDs).Error; err != nil {
+			return fmt.Errorf("failed to delete ProductOption: %w", err)
+		}
+		variantes, err := variantesUtilisant(tx, valeurIDs)
+		if err != nil {
+			return err
+		}
+		if err := retirerVariantes(tx, variantes, cascade); err != nil {
+			return err
+		}
+
+		result := avecVersion(tx.Where("id = ?", id), version).Delete(&models.OptionProduit{})
+		if result.Error != nil {
+			return fmt.Errorf("failed to delete ProductOption: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return conflitVersion(tx, &models.OptionProduit{}, id, version)
+		}
+		supprime = true
+		retirees = variantes
+		return compacterPositions(tx, "option_produits", "produit_id", option.ProduitID)
+	})
+	return supprime, retirees, err
 }
 
-func (r *OptionProduitValeurRepo) SupprimerByIdVOpt(ctx context.Context, id string) (bool, error) {
+// SupprimerByIdVOpt supprime la valeur puis resserre les positions des
+// valeurs restantes de l'option, dans la même transaction. Même règle que
+// SupprimerOptPById pour les variantes qui utilisent la valeur.
+func (r *OptionProduitValeurRepo) SupprimerByIdVOpt(ctx context.Context, id string, version *int, cascade bool) (bool, []models.Variante, error) {
 	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	result := r.db.WithContext(opCtx).Where("id = ?", id).Delete(&models.ValeurOption{})
-	if result.Error != nil {
-		return false, fmt.Errorf("failed to delete valeurOption: %w", result.Error)
+	supprime := false
+	var retirees []models.Variante
+	err := r.db.WithContext(opCtx).Transaction(func(tx *gorm.DB) error {
+		var valeur models.ValeurOption
+		if err := tx.Select("id", "option_id").Where("id = ?", id).Limit(1).Find(&valeur).Error; err != nil {
+			return fmt.Errorf("failed to delete valeurOption: %w", err)
+		}
+		if valeur.ID == "" {
+			return nil
+		}
+
+		variantes, err := variantesUtilisant(tx, []string{id})
+		if err != nil {
+			return err
+		}
+		if err := retirerVariantes(tx, variantes, cascade); err != nil {
+			return err
+		}
+
+		result := avecVersion(tx.Where("id = ?", id), version).Delete(&models.ValeurOption{})
+		if result.Error != nil {
+			return fmt.Errorf("failed to delete valeurOption: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return conflitVersion(tx, &models.ValeurOption{}, id, version)
+		}
+		supprime = true
+		retirees = variantes
+		return compacterPositions(tx, "valeur_options", "option_id", valeur.OptionID)
+	})
+	return supprime, retirees, err
+}
+
+// AjouterOption crée l'option (et ses éventuelles valeurs), à la fin si sa
+// position vaut 0. Les variantes
+// existantes du produit n'auraient pas de valeur pour cette nouvelle option :
+// ErreurVariantesLiees, sauf avec cascade où elles sont supprimées.
+func (r *OptionProduitValeurRepo) AjouterOption(ctx context.Context, option *models.OptionProduit, cascade bool) (*models.OptionProduit, []models.Variante, error) {
+	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var retirees []models.Variante
+	err := r.db.WithContext(opCtx).Transaction(func(tx *gorm.DB) error {
+		// verrou du produit : deux créations simultanées n'obtiennent pas la même position
+		if err := tx.Exec("SELECT id FROM produits WHERE id = ? FOR UPDATE", option.ProduitID).Error; err != nil {
+			return fmt.Errorf("failed to lock product: %w", err)
+		}
+		if option.Position == 0 {
+			if err := tx.Model(&models.OptionProduit{}).
+				Where("produit_id = ?", option.ProduitID).
+				Select("COALESCE(MAX(position), 0) + 1").
+				Scan(&option.Position).Error; err != nil {
+				return fmt.Errorf("failed to compute ProductOption position: %w", err)
+			}
+		}
+
+		var variantes []models.Variante
+		if err := tx.Where("produit_id = ?", option.ProduitID).
+			Order("sku").
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Find(&variantes).Error; err != nil {
+			return fmt.Errorf("failed to list Variantes: %w", err)
+		}
+		if err := retirerVariantes(tx, variantes, cascade); err != nil {
+			return err
+		}
+
+		if err := tx.Create(option).Error; err != nil {
+			return fmt.Errorf("failed to insert ProductOption: %w", err)
+		}
+		retirees = variantes
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
 	}
-	return result.RowsAffected > 0, nil
+	return option, retirees, nil
 }
 
-func (r *OptionProduitValeurRepo) CountOptionsByProduit(ctx context.Context, produitID string) (int, error) {
-	var count int64
-	err := r.db.WithContext(ctx).
-		Model(&models.OptionProduit{}).
-		Where("produit_id = ?", produitID).
-		Count(&count).Error
+// variantesUtilisant renvoie (verrouillées) les variantes dont la combinaison
+// contient une des valeurs
+func variantesUtilisant(tx *gorm.DB, valeurIDs []string) ([]models.Variante, error) {
+	if len(valeurIDs) == 0 {
+		return nil, nil
+	}
+	var variantes []models.Variante
+	err := tx.Where("id IN (?)", tx.Model(&models.VarianteValeurOption{}).
+		Select("variante_id").
+		Where("valeur_option_id IN ?", valeurIDs)).
+		Order("sku").
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Find(&variantes).Error
 	if err != nil {
-		return 0, err
+		return nil, fmt.Errorf("failed to list linked Variantes: %w", err)
 	}
-	return int(count), nil
+	return variantes, nil
 }
 
-// À ajouter dans OptionProduitValeurRepo
-func (r *OptionProduitValeurRepo) CountValeursByOption(ctx context.Context, optionID string) (int, error) {
-	var count int64
-	err := r.db.WithContext(ctx).
-		Model(&models.ValeurOption{}).
-		Where("option_id = ?", optionID).
-		Count(&count).Error
+// retirerVariantes refuse l'opération si des variantes sont touchées, ou les
+// supprime (liens de combinaison compris) quand cascade est demandé
+func retirerVariantes(tx *gorm.DB, variantes []models.Variante, cascade bool) error {
+	if len(variantes) == 0 {
+		return nil
+	}
+	if !cascade {
+		return &ErreurVariantesLiees{Variantes: variantes}
+	}
+	ids := make([]string, len(variantes))
+	for i, v := range variantes {
+		ids[i] = v.ID
+	}
+	if err := tx.Where("variante_id IN ?", ids).Delete(&models.VarianteValeurOption{}).Error; err != nil {
+		return fmt.Errorf("failed to detach Variantes: %w", err)
+	}
+	if err := tx.Where("id IN ?", ids).Delete(&models.Variante{}).Error; err != nil {
+		return fmt.Errorf("failed to delete Variantes: %w", err)
+	}
+	return supprimerTarifsVariantes(tx, ids)
+}
+
+// reordonner réécrit les positions (1..n) des lignes de table appartenant à
+// parentID dans l'ordre de ids. ids doit contenir exactement toutes ces
+// lignes, sinon ErrOrdreIncomplet et rien n'est modifié.
+func (r *OptionProduitValeurRepo) reordonner(ctx context.Context, table, colonneParent, parentID string, ids []string) error {
+	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	return r.db.WithContext(opCtx).Transaction(func(tx *gorm.DB) error {
+		// verrouille les lignes : deux réordonnancements concurrents se sérialisent
+		var existants []string
+		if err := tx.Raw("SELECT id FROM "+table+" WHERE "+colonneParent+" = ? FOR UPDATE", parentID).
+			Scan(&existants).Error; err != nil {
+			return fmt.Errorf("failed to lock %s: %w", table, err)
+		}
+		if !memesIDs(existants, ids) {
+			return ErrOrdreIncomplet
+		}
+		for i, id := range ids {
+			if err := tx.Exec("UPDATE "+table+" SET position = ?, version = version + 1 WHERE id = ? AND position <> ?",
+				i+1, id, i+1).Error; err != nil {
+				return fmt.Errorf("failed to reorder %s: %w", table, err)
+			}
+		}
+		return nil
+	})
+}
+
+func (r *OptionProduitValeurRepo) ReordonnerOptions(ctx context.Context, produitID string, ids []string) error {
+	return r.reordonner(ctx, "option_produits", "produit_id", produitID, ids)
+}
+
+// ReordonnerValeurs : l'ordre est désormais celui du produit, la
+// propagation d'un modèle lié ne le remplace plus (PositionPropre)
+func (r *OptionProduitValeurRepo) ReordonnerValeurs(ctx context.Context, optionID string, ids []string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := NewOptionProduitValeurRepo(tx).reordonner(ctx, "valeur_options", "option_id", optionID, ids); err != nil {
+			return err
+		}
+		if err := tx.Model(&models.ValeurOption{}).
+			Where("option_id = ? AND NOT position_propre", optionID).
+			Update("position_propre", true).Error; err != nil {
+			return fmt.Errorf("failed to reorder valeur_options: %w", err)
+		}
+		return nil
+	})
+}
+
+// compacterPositions renumérote 1..n en conservant l'ordre actuel
+func compacterPositions(tx *gorm.DB, table, colonneParent, parentID string) error {
+	err := tx.Exec(`UPDATE `+table+` t SET position = r.rang, version = t.version + 1
+		FROM (SELECT id, ROW_NUMBER() OVER (ORDER BY position, id) AS rang
+		      FROM `+table+` WHERE `+colonneParent+` = ?) r
+		WHERE t.id = r.id AND t.position <> r.rang`, parentID).Error
 	if err != nil {
-		return 0, err
+		return fmt.Errorf("failed to compact %s positions: %w", table, err)
+	}
+	return nil
+}
+
+// memesIDs : mêmes éléments, sans doublon
+func memesIDs(existants, demandes []string) bool {
+	if len(existants) != len(demandes) {
+		return false
+	}
+	restants := make(map[string]bool, len(existants))
+	for _, id := range existants {
+		restants[id] = true
+	}
+	for _, id := range demandes {
+		if !restants[id] {
+			return false
+		}
+		delete(restants, id)
+	}
+	return true
+}
+
+// CodeValeurPris indique si code est déjà porté par une autre valeur de l'option
+func (r *OptionProduitValeurRepo) CodeValeurPris(ctx context.Context, optionID, code, exclureID string) (bool, error) {
+	query := r.db.WithContext(ctx).
+		Model(&models.ValeurOption{}).
+		Where("option_id = ? AND code = ?", optionID, code)
+	if exclureID != "" {
+		query = query.Where("id <> ?", exclureID)
+	}
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		return false, fmt.Errorf("failed to check valeurOption code: %w", err)
 	}
-	return int(count), nil
+	return count > 0, nil
 }
@@ -17,12 +17,23 @@ func NewVarianteRepo(db *gorm.DB) *VarianteRepo {
 	return &VarianteRepo{db: db}
 }
 
+// Transaction exécute fn avec le repo et le journal d'audit liés à une même
+// transaction : l'entrée d'audit est annulée avec l'écriture qu'elle décrit
+func (r *VarianteRepo) Transaction(ctx context.Context, fn func(variantes *VarianteRepo, journal *AuditRepo) error) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(NewVarianteRepo(tx), NewAuditRepo(tx))
+	})
+}
+
 func (r *VarianteRepo) CreationVariant(ctx context.Context, variante *models.Variante) (*models.Variante, error) {
 	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	if err := r.db.WithContext(opCtx).Create(variante).Error; err != nil {
-		return nil, fmt.Errorf("failed to insert Variante: %w", err)
+	err := avecSKUs(r.db.WithContext(opCtx), variante.BoutiqueID, []string{variante.SKU}, "", "", func(tx *gorm.DB) error {
+		return tx.Create(variante).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert Variante: %w", erreurUnicite(err))
 	}
 	return variante, nil
 }
@@ -53,19 +64,31 @@ func (r *VarianteRepo) GetByID(ctx context.Context, id string) (*models.Variante
 	return &variante, nil
 }
 
-func (r *VarianteRepo) Update(ctx context.Context, id string, updates map[string]interface{}) (*models.Variante, error) {
+func (r *VarianteRepo) Update(ctx context.Context, id string, updates map[string]interface{}, version *int) (*models.Variante, error) {
 	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	result := r.db.WithContext(opCtx).Model(&models.Variante{}).
-		Where("id = ?", id).
-		Updates(updates)
+	skus := skusModifies(updates)
+	var boutiqueID string
+	if len(skus) > 0 {
+		if err := r.db.WithContext(opCtx).Model(&models.Variante{}).Where("id = ?", id).
+			Select("boutique_id").Scan(&boutiqueID).Error; err != nil {
+			return nil, fmt.Errorf("failed to read Variante boutique: %w", err)
+		}
+	}
 
-	if result.Error != nil {
-		return nil, fmt.Errorf("failed to update Variante: %w", result.Error)
+	updates["version"] = gorm.Expr("version + 1")
+	var result *gorm.DB
+	err := avecSKUs(r.db.WithContext(opCtx), boutiqueID, skus, "", id, func(tx *gorm.DB) error {
+		query := tx.Model(&models.Variante{}).Where("id = ?", id)
+		result = avecVersion(query, version).Updates(updates)
+		return result.Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update Variante: %w", erreurUnicite(err))
 	}
 	if result.RowsAffected == 0 {
-		return nil, nil
+		return nil, conflitVersion(r.db.WithContext(opCtx), &models.Variante{}, id, version)
 	}
 
 	var variante models.Variante
@@ -75,33 +98,157 @@ func (r *VarianteRepo) Update(ctx context.Context, id string, updates map[string
 	return &variante, nil
 }
 
-func (r *VarianteRepo) SupprimereById(ctx context.Context, id string) (bool, error) {
+func (r *VarianteRepo) SupprimereById(ctx context.Context, id string, version *int) (bool, error) {
+	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	supprimee := false
+	err := r.db.WithContext(opCtx).Transaction(func(tx *gorm.DB) error {
+		result := avecVersion(tx.Where("id = ?", id), version).Delete(&models.Variante{})
+		if result.Error != nil {
+			return fmt.Errorf("failed to delete Variante: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return conflitVersion(tx, &models.Variante{}, id, version)
+		}
+		supprimee = true
+		return supprimerTarifsVariantes(tx, []string{id})
+	})
+	return supprimee, err
+}
+
+// supprimerTarifsVariantes efface les prix de liste, paliers et règles de
+// groupe propres aux variantes supprimées. Les clés étrangères des prix et
+// paliers vers variantes sont différées (voir db.migrerTarifsVariantes) : sans
+// ce nettoyage la transaction échoue ; les règles de groupe, sans clé
+// étrangère, resteraient orphelines.
+func supprimerTarifsVariantes(tx *gorm.DB, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	for _, requete := range []string{
+		"DELETE FROM prix_listes WHERE variante_id IN ?",
+		"DELETE FROM palier_quantites WHERE variante_id IN ?",
+		"DELETE FROM regle_groupes WHERE variante_id IN ?",
+	} {
+		if err := tx.Exec(requete, ids).Error; err != nil {
+			return fmt.Errorf("failed to delete variant prices: %w", err)
+		}
+	}
+	return nil
+}
+
+// CodeBarresPris : le GTIN normalisé est déjà porté par une autre variante de la boutique
+func (r *VarianteRepo) CodeBarresPris(ctx context.Context, boutiqueID, gtin14, exclureID string) (bool, error) {
 	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	result := r.db.WithContext(opCtx).Where("id = ?", id).Delete(&models.Variante{})
-	if result.Error != nil {
-		return false, fmt.Errorf("failed to delete Variante: %w", result.Error)
+	pris, err := codesBarresPris(r.db.WithContext(opCtx), boutiqueID, []string{gtin14}, exclureID)
+	if err != nil {
+		return false, err
+	}
+	return len(pris) > 0, nil
+}
+
+// SKUPris : le SKU est déjà porté dans la boutique par un produit ou une autre variante
+func (r *VarianteRepo) SKUPris(ctx context.Context, boutiqueID, sku, exclureID string) (bool, error) {
+	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	pris, err := skusPris(r.db.WithContext(opCtx), boutiqueID, []string{sku}, "", exclureID)
+	if err != nil {
+		return false, err
 	}
-	return result.RowsAffected > 0, nil
+	return len(pris) > 0, nil
 }
 
-func (r *VarianteRepo) CheckDuplicateCombination(ctx context.Context, produitID string, valeurOptionIDs []string) (bool, error) {
+// AttacherValeurs lie une variante tout juste créée à sa combinaison de
+// valeurs. À appeler dans la transaction de création : le produit est
+// verrouillé, donc deux créations simultanées de la même combinaison se
+// sérialisent et la seconde voit la première.
+// Chaque valeur doit appartenir à une option du produit, une seule valeur par
+// option (ErrCombinaisonInvalide) ; une autre variante ne doit pas porter
+// exactement la même combinaison (ErrCombinaisonExistante).
+func (r *VarianteRepo) AttacherValeurs(ctx context.Context, produitID, varianteID string, valeurIDs []string) error {
+	if len(valeurIDs) == 0 {
+		return nil
+	}
 	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
+	db := r.db.WithContext(opCtx)
+
+	if err := db.Exec("SELECT id FROM produits WHERE id = ? FOR UPDATE", produitID).Error; err != nil {
+		return fmt.Errorf("failed to lock product: %w", err)
+	}
+
+	var valeurs []struct {
+		ID       string
+		OptionID string
+	}
+	if err := db.Raw(`SELECT v.id, v.option_id FROM valeur_options v
+		JOIN option_produits o ON o.id = v.option_id
+		WHERE v.id IN ? AND o.produit_id = ?`, valeurIDs, produitID).Scan(&valeurs).Error; err != nil {
+		return fmt.Errorf("failed to load option values: %w", err)
+	}
+	trouvees := make(map[string]string, len(valeurs))
+	for _, v := range valeurs {
+		trouvees[v.ID] = v.OptionID
+	}
+	vues := map[string]bool{}
+	options := map[string]bool{}
+	for _, id := range valeurIDs {
+		optionID, ok := trouvees[id]
+		if !ok {
+			return fmt.Errorf("%w: la valeur %s n'appartient pas au produit", ErrCombinaisonInvalide, id)
+		}
+		if vues[id] {
+			return fmt.Errorf("%w: la valeur %s est en double", ErrCombinaisonInvalide, id)
+		}
+		if options[optionID] {
+			return fmt.Errorf("%w: plusieurs valeurs pour l'option %s", ErrCombinaisonInvalide, optionID)
+		}
+		vues[id] = true
+		options[optionID] = true
+	}
+
+	// combinaison exacte : mêmes valeurs, ni plus ni moins
+	var existantes int64
+	if err := db.Raw(`SELECT COUNT(*) FROM (
+			SELECT vvo.variante_id FROM variante_valeur_option vvo
+			JOIN variantes v ON v.id = vvo.variante_id
+			WHERE v.produit_id = ? AND v.id <> ?
+			GROUP BY vvo.variante_id
+			HAVING COUNT(*) = ? AND COUNT(*) FILTER (WHERE vvo.valeur_option_id IN ?) = ?
+		) doublons`, produitID, varianteID, len(valeurIDs), valeurIDs, len(valeurIDs)).
+		Scan(&existantes).Error; err != nil {
+		return fmt.Errorf("failed to check duplicate combination: %w", err)
+	}
+	if existantes > 0 {
+		return ErrCombinaisonExistante
+	}
 
-	var count int64
-	err := r.db.WithContext(opCtx).
-		Model(&models.Variante{}).
-		Joins("JOIN variante_valeur_option vvo ON vvo.variante_id = variantes.id").
-		Where("variantes.produit_id = ?", produitID).
-		Where("vvo.valeur_option_id IN ?", valeurOptionIDs).
-		Group("variantes.id").
-		Having("COUNT(vvo.valeur_option_id) = ?", len(valeurOptionIDs)).
-		Count(&count).Error
+	liens := make([]models.VarianteValeurOption, len(valeurIDs))
+	for i, id := range valeurIDs {
+		liens[i] = models.VarianteValeurOption{VarianteID: varianteID, ValeurOptionID: id}
+	}
+	if err := db.Create(&liens).Error; err != nil {
+		return fmt.Errorf("failed to attach option values: %w", err)
+	}
+	return nil
+}
+
+// GetAvecValeurs : comme GetByID, avec les valeurs d'options de la variante
+func (r *VarianteRepo) GetAvecValeurs(ctx context.Context, id string) (*models.Variante, error) {
+	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
 
+	var variante models.Variante
+	err := r.db.WithContext(opCtx).Preload("ValeurOptions").Where("id = ?", id).First(&variante).Error
 	if err != nil {
-		return false, fmt.Errorf("failed to check duplicate combination: %w", err)
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error fetching Variante: %w", err)
 	}
-	return count > 0, nil
+	return &variante, nil
 }
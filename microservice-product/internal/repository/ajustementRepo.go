@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"projet/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ------------------------------------------------------------
+// Ajustements de prix (historique des règles appliquées)
+// ------------------------------------------------------------
+func (r *ProduitRepo) EnregistrerAjustement(ctx context.Context, ajustement *models.AjustementPrix) error {
+	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	if err := r.db.WithContext(opCtx).Create(ajustement).Error; err != nil {
+		return fmt.Errorf("failed to insert price adjustment: %w", err)
+	}
+	return nil
+}
+
+// ListAjustements : du plus récent au plus ancien, sans le détail des lignes
+func (r *ProduitRepo) ListAjustements(ctx context.Context, boutiqueID string, page, limite int) ([]models.AjustementPrix, int64, error) {
+	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := r.db.WithContext(opCtx).Model(&models.AjustementPrix{}).Where("boutique_id = ?", boutiqueID)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("count price adjustments failed: %w", err)
+	}
+
+	var ajustements []models.AjustementPrix
+	if err := query.Omit("lignes").Order("cree_le DESC").
+		Limit(limite).Offset((page - 1) * limite).
+		Find(&ajustements).Error; err != nil {
+		return nil, 0, fmt.Errorf("find price adjustments failed: %w", err)
+	}
+	return ajustements, total, nil
+}
+
+func (r *ProduitRepo) GetAjustement(ctx context.Context, id, boutiqueID string) (*models.AjustementPrix, error) {
+	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var ajustement models.AjustementPrix
+	err := r.db.WithContext(opCtx).Where("id = ? AND boutique_id = ?", id, boutiqueID).First(&ajustement).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch price adjustment: %w", err)
+	}
+	return &ajustement, nil
+}
@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"projet/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type AuditRepo struct {
+	db *gorm.DB
+}
+
+func NewAuditRepo(db *gorm.DB) *AuditRepo {
+	return &AuditRepo{db: db}
+}
+
+func (r *AuditRepo) Create(ctx context.Context, entree *models.JournalAudit) error {
+	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	if err := r.db.WithContext(opCtx).Create(entree).Error; err != nil {
+		return fmt.Errorf("failed to insert audit entry: %w", err)
+	}
+	return nil
+}
+
+// ListByProduit renvoie l'historique d'un produit (produit, options, valeurs, variantes),
+// du plus récent au plus ancien, avec le nombre total d'entrées
+func (r *AuditRepo) ListByProduit(ctx context.Context, produitID, boutiqueID string, page, limite int) ([]models.JournalAudit, int64, error) {
+	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := r.db.WithContext(opCtx).Model(&models.JournalAudit{}).
+		Where("produit_id = ? AND boutique_id = ?", produitID, boutiqueID)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("count audit entries failed: %w", err)
+	}
+
+	var entrees []models.JournalAudit
+	if err := query.Order("cree_le DESC").
+		Limit(limite).Offset((page - 1) * limite).
+		Find(&entrees).Error; err != nil {
+		return nil, 0, fmt.Errorf("find audit entries failed: %w", err)
+	}
+	return entrees, total, nil
+}
@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"projet/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ConfigurationSKU renvoie la configuration de la boutique, nil si elle n'en a pas
+func (r *ProduitRepo) ConfigurationSKU(ctx context.Context, boutiqueID string) (*models.ConfigurationSKU, error) {
+	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var config models.ConfigurationSKU
+	err := r.db.WithContext(opCtx).Where("boutique_id = ?", boutiqueID).First(&config).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error fetching SKU configuration: %w", err)
+	}
+	return &config, nil
+}
+
+// EnregistrerConfigurationSKU crée ou remplace préfixe et modèle ; le
+// compteur n'est modifié que si compteur est fourni
+func (r *ProduitRepo) EnregistrerConfigurationSKU(ctx context.Context, config *models.ConfigurationSKU, compteur *int64) (*models.ConfigurationSKU, error) {
+	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	colonnes := []string{"prefixe", "modele", "mis_a_jour_le"}
+	if compteur != nil {
+		config.Compteur = *compteur
+		colonnes = append(colonnes, "compteur")
+	}
+	config.MisAJourLe = time.Now()
+	err := r.db.WithContext(opCtx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "boutique_id"}},
+		DoUpdates: clause.AssignmentColumns(colonnes),
+	}).Create(config).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to save SKU configuration: %w", err)
+	}
+
+	var enregistree models.ConfigurationSKU
+	if err := r.db.WithContext(opCtx).Where("boutique_id = ?", config.BoutiqueID).First(&enregistree).Error; err != nil {
+		return nil, fmt.Errorf("SKU configuration saved but failed to fetch: %w", err)
+	}
+	return &enregistree, nil
+}
+
+// ReserverCompteurSKU avance atomiquement le compteur de n et renvoie le
+// premier numéro réservé ; la ligne est créée au besoin avec modele
+func (r *ProduitRepo) ReserverCompteurSKU(ctx context.Context, boutiqueID, modele string, n int) (int64, error) {
+	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var dernier int64
+	err := r.db.WithContext(opCtx).Raw(`
+		INSERT INTO configuration_skus (boutique_id, prefixe, modele, compteur, mis_a_jour_le)
+		VALUES (?, '', ?, ?, NOW())
+		ON CONFLICT (boutique_id) DO UPDATE
+		SET compteur = configuration_skus.compteur + EXCLUDED.compteur, mis_a_jour_le = NOW()
+		RETURNING compteur`, boutiqueID, modele, n).Scan(&dernier).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to reserve SKU counter: %w", err)
+	}
+	return dernier - int64(n) + 1, nil
+}
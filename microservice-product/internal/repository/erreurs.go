@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"projet/internal/models"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+// ErrVersionObsolete : la ligne existe mais sa version ne correspond plus à
+// celle attendue par l'appelant (modification concurrente).
+var ErrVersionObsolete = errors.New("version obsolète")
+
+// ErrOrdreIncomplet : la liste d'un réordonnancement ne reprend pas exactement
+// les éléments existants (manquant, en trop ou en double)
+var ErrOrdreIncomplet = errors.New("la liste doit contenir chaque élément exactement une fois")
+
+// ErrCombinaisonInvalide : valeur d'option étrangère au produit, en double, ou
+// deux valeurs pour la même option
+var ErrCombinaisonInvalide = errors.New("combinaison de valeurs invalide")
+
+// ErrCombinaisonExistante : une autre variante du produit porte déjà exactement ces valeurs
+var ErrCombinaisonExistante = errors.New("cette combinaison existe déjà")
+
+// avecVersion ajoute la condition de verrouillage optimiste quand une version est attendue
+func avecVersion(query *gorm.DB, version *int) *gorm.DB {
+	if version == nil {
+		return query
+	}
+	return query.Where("version = ?", *version)
+}
+
+// conflitVersion explique un RowsAffected nul sur une ligne identifiée par id :
+// ErrVersionObsolete si la ligne existe encore, nil si elle est introuvable.
+func conflitVersion(db *gorm.DB, modele interface{}, id string, version *int) error {
+	if version == nil {
+		return nil
+	}
+	var count int64
+	if err := db.Model(modele).Where("id = ?", id).Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to check version: %w", err)
+	}
+	if count > 0 {
+		return ErrVersionObsolete
+	}
+	return nil
+}
+
+// ErreurVariantesLiees : l'opération casserait la combinaison de ces
+// variantes ; l'appelant peut la forcer en mode cascade
+type ErreurVariantesLiees struct {
+	Variantes []models.Variante
+}
+
+func (e *ErreurVariantesLiees) Error() string {
+	return fmt.Sprintf("%d variante(s) concernée(s)", len(e.Variantes))
+}
+
+// ErrSKUPris : le SKU est déjà porté dans la boutique par un produit ou une variante
+var ErrSKUPris = errors.New("SKU déjà utilisé dans la boutique")
+
+// ErrCodeBarresPris : le code-barres est déjà porté par une variante de la boutique
+var ErrCodeBarresPris = errors.New("code-barres déjà utilisé dans la boutique")
+
+// ErrClasseTaxeUtilisee : des produits portent encore la classe de taxe
+var ErrClasseTaxeUtilisee = errors.New("classe de taxe utilisée par des produits")
+
+// ErrClasseTaxeDefaut : la classe est la classe par défaut de la boutique
+var ErrClasseTaxeDefaut = errors.New("classe de taxe par défaut de la boutique")
+
+// ErrDoublon : une autre contrainte d'unicité a été violée
+var ErrDoublon = errors.New("valeur déjà utilisée")
+
+// index uniques des SKU, posés sur (boutique_id, sku)
+const (
+	indexSKUProduit  = "idx_sku_produit_boutique"
+	indexSKUVariante = "idx_sku_variante_boutique"
+)
+
+// IndexCodeBarresVariante : index unique du GTIN normalisé par boutique (voir db.migrerCodesBarres)
+const IndexCodeBarresVariante = "idx_code_barres_variante_boutique"
+
+// erreurUnicite traduit une violation d'unicité Postgres (23505) en
+// ErrSKUPris, ErrCodeBarresPris ou ErrDoublon ; les autres erreurs sont renvoyées telles quelles.
+// Filet de sécurité : les services vérifient avant d'écrire, mais deux
+// requêtes concurrentes peuvent passer la vérification en même temps.
+func erreurUnicite(err error) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != "23505" {
+		return err
+	}
+	switch pgErr.ConstraintName {
+	case indexSKUProduit, indexSKUVariante:
+		return ErrSKUPris
+	case IndexCodeBarresVariante:
+		return ErrCodeBarresPris
+	}
+	return fmt.Errorf("%w (%s)", ErrDoublon, pgErr.ConstraintName)
+}
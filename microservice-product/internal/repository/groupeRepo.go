@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"projet/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ------------------------------------------------------------
+// Groupes de clients
+// ------------------------------------------------------------
+func (r *TarifRepo) CreateGroupe(ctx context.Context, groupe *models.GroupeClient) (*models.GroupeClient, error) {
+	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	if err := r.db.WithContext(opCtx).Omit("Regles").Create(groupe).Error; err != nil {
+		return nil, fmt.Errorf("failed to insert customer group: %w", erreurUnicite(err))
+	}
+	return groupe, nil
+}
+
+func (r *TarifRepo) ListGroupes(ctx context.Context, boutiqueID string) ([]models.GroupeClient, error) {
+	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var groupes []models.GroupeClient
+	if err := r.db.WithContext(opCtx).Where("boutique_id = ?", boutiqueID).
+		Order("code").Find(&groupes).Error; err != nil {
+		return nil, fmt.Errorf("find customer groups failed: %w", err)
+	}
+	return groupes, nil
+}
+
+// GetGroupe : groupe et ses règles, nil s'il n'existe pas dans la boutique
+func (r *TarifRepo) GetGroupe(ctx context.Context, id, boutiqueID string) (*models.GroupeClient, error) {
+	return r.groupe(ctx, "id = ? AND boutique_id = ?", id, boutiqueID)
+}
+
+// GroupeParCode : groupe désigné par le contexte de la requête
+func (r *TarifRepo) GroupeParCode(ctx context.Context, boutiqueID, code string) (*models.GroupeClient, error) {
+	return r.groupe(ctx, "boutique_id = ? AND code = ?", boutiqueID, code)
+}
+
+func (r *TarifRepo) groupe(ctx context.Context, condition string, args ...interface{}) (*models.GroupeClient, error) {
+	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var groupe models.GroupeClient
+	err := r.db.WithContext(opCtx).Preload("Regles").Where(condition, args...).First(&groupe).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error fetching customer group: %w", err)
+	}
+	return &groupe, nil
+}
+
+func (r *TarifRepo) UpdateGroupe(ctx context.Context, id, boutiqueID string, updates map[string]interface{}) (*models.GroupeClient, error) {
+	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	result := r.db.WithContext(opCtx).Model(&models.GroupeClient{}).
+		Where("id = ? AND boutique_id = ?", id, boutiqueID).Updates(updates)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to update customer group: %w", erreurUnicite(result.Error))
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+	return r.GetGroupe(ctx, id, boutiqueID)
+}
+
+// DeleteGroupe supprime le groupe et ses règles
+func (r *TarifRepo) DeleteGroupe(ctx context.Context, id, boutiqueID string) (bool, error) {
+	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	supprime := false
+	err := r.db.WithContext(opCtx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("id = ? AND boutique_id = ?", id, boutiqueID).Delete(&models.GroupeClient{})
+		if result.Error != nil {
+			return fmt.Errorf("failed to delete customer group: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		supprime = true
+		if err := tx.Where("groupe_id = ?", id).Delete(&models.RegleGroupe{}).Error; err != nil {
+			return fmt.Errorf("failed to delete group rules: %w", err)
+		}
+		return nil
+	})
+	return supprime, err
+}
+
+// RemplacerRegles remplace toutes les règles du groupe dans une transaction
+func (r *TarifRepo) RemplacerRegles(ctx context.Context, groupeID string, regles []models.RegleGroupe) error {
+	opCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	return r.db.WithContext(opCtx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("groupe_id = ?", groupeID).Delete(&models.RegleGroupe{}).Error; err != nil {
+			return fmt.Errorf("failed to replace group rules: %w", err)
+		}
+		if len(regles) > 0 {
+			for i := range regles {
+				regles[i].GroupeID = groupeID
+			}
+			if err := tx.Create(&regles).Error; err != nil {
+				return fmt.Errorf("failed to insert group rules: %w", err)
+			}
+		}
+		return tx.Model(&models.GroupeClient{}).Where("id = ?", groupeID).
+			Update("mis_a_jour_le", time.Now()).Error
+	})
+}
@@ -0,0 +1,322 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"projet/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrModeleIntrouvable : le modèle n'existe pas (ou plus) dans la boutique
+var ErrModeleIntrouvable = errors.New("modèle non trouvé")
+
+type ModeleOptionRepo struct {
+	db *gorm.DB
+}
+
+func NewModeleOptionRepo(db *gorm.DB) *ModeleOptionRepo {
+	return &ModeleOptionRepo{db: db}
+}
+
+func valeursModeleOrdonnees(db *gorm.DB) *gorm.DB {
+	return db.Order("position")
+}
+
+// Transaction exécute fn avec le repo et le journal d'audit liés à une même
+// transaction : l'entrée d'audit est annulée avec l'écriture qu'elle décrit
+func (r *ModeleOptionRepo) Transaction(ctx context.Context, fn func(modeles *ModeleOptionRepo, journal *AuditRepo) error) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(NewModeleOptionRepo(tx), NewAuditRepo(tx))
+	})
+}
+
+// Create insère le modèle et ses valeurs
+func (r *ModeleOptionRepo) Create(ctx context.Context, modele *models.ModeleOption) (*models.ModeleOption, error) {
+	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	if err := r.db.WithContext(opCtx).Create(modele).Error; err != nil {
+		return nil, fmt.Errorf("failed to insert ModeleOption: %w", err)
+	}
+	return modele, nil
+}
+
+func (r *ModeleOptionRepo) List(ctx context.Context, boutiqueID string) ([]models.ModeleOption, error) {
+	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var modeles []models.ModeleOption
+	if err := r.db.WithContext(opCtx).
+		Where("boutique_id = ?", boutiqueID).
+		Order("nom").
+		Preload("Valeurs", valeursModeleOrdonnees).
+		Find(&modeles).Error; err != nil {
+		return nil, fmt.Errorf("find ModeleOption failed: %w", err)
+	}
+	return modeles, nil
+}
+
+func (r *ModeleOptionRepo) GetByID(ctx context.Context, id, boutiqueID string) (*models.ModeleOption, error) {
+	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var modele models.ModeleOption
+	err := r.db.WithContext(opCtx).
+		Where("id = ? AND boutique_id = ?", id, boutiqueID).
+		Preload("Valeurs", valeursModeleOrdonnees).
+		First(&modele).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error fetching ModeleOption: %w", err)
+	}
+	return &modele, nil
+}
+
+// CompterOptionsLiees : nombre d'options de produits liées au modèle
+func (r *ModeleOptionRepo) CompterOptionsLiees(ctx context.Context, id string) (int64, error) {
+	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var count int64
+	err := r.db.WithContext(opCtx).
+		Model(&models.OptionProduit{}).
+		Where("modele_id = ?", id).
+		Count(&count).Error
+	if err != nil {
+		return 0, fmt.Errorf("count linked options failed: %w", err)
+	}
+	return count, nil
+}
+
+// CompterOptionsLieesParModele : CompterOptionsLiees pour plusieurs modèles,
+// en une requête ; un modèle sans option liée est absent de la map
+func (r *ModeleOptionRepo) CompterOptionsLieesParModele(ctx context.Context, ids []string) (map[string]int64, error) {
+	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	comptes := make(map[string]int64, len(ids))
+	if len(ids) == 0 {
+		return comptes, nil
+	}
+	var lignes []struct {
+		ModeleID string
+		Total    int64
+	}
+	if err := r.db.WithContext(opCtx).
+		Model(&models.OptionProduit{}).
+		Select("modele_id, COUNT(*) AS total").
+		Where("modele_id IN ?", ids).
+		Group("modele_id").
+		Scan(&lignes).Error; err != nil {
+		return nil, fmt.Errorf("count linked options failed: %w", err)
+	}
+	for _, l := range lignes {
+		comptes[l.ModeleID] = l.Total
+	}
+	return comptes, nil
+}
+
+// OptionsLiees : options de produits liées au modèle, avec leurs valeurs
+// (état de référence du journal d'audit lors d'une propagation)
+func (r *ModeleOptionRepo) OptionsLiees(ctx context.Context, id string) ([]models.OptionProduit, error) {
+	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var options []models.OptionProduit
+	if err := r.db.WithContext(opCtx).
+		Where("modele_id = ?", id).
+		Order("produit_id, id").
+		Preload("ValeurOpts", func(db *gorm.DB) *gorm.DB {
+			return db.Order("position, id")
+		}).
+		Find(&options).Error; err != nil {
+		return nil, fmt.Errorf("find linked options failed: %w", err)
+	}
+	return options, nil
+}
+
+// ProduitDeLaBoutique vérifie que le produit (non supprimé) appartient à la boutique
+func (r *ModeleOptionRepo) ProduitDeLaBoutique(ctx context.Context, produitID, boutiqueID string) (bool, error) {
+	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var count int64
+	err := r.db.WithContext(opCtx).
+		Model(&models.Produit{}).
+		Where("id = ? AND boutique_id = ?", produitID, boutiqueID).
+		Count(&count).Error
+	if err != nil {
+		return false, fmt.Errorf("error fetching product: %w", err)
+	}
+	return count > 0, nil
+}
+
+// PropagationModele : options liées mises à jour par Enregistrer et produits
+// auxquels elles appartiennent (sans doublon)
+type PropagationModele struct {
+	OptionIDs  []string
+	ProduitIDs []string
+}
+
+// Enregistrer met à jour le modèle et remplace ses valeurs : celles de
+// supprimees disparaissent (les valeurs de produits qui en étaient issues
+// sont déliées, jamais supprimées), les autres sont insérées ou mises à jour.
+// Avec propager, les options liées reprennent le nom, les libellés et les
+// positions du modèle (sauf position choisie sur le produit, voir
+// ValeurOption.PositionPropre), et reçoivent les valeurs ajoutées ; les
+// positions de chaque option touchée sont ensuite recompactées. Renvoie les
+// options mises à jour et leurs produits.
+func (r *ModeleOptionRepo) Enregistrer(ctx context.Context, modele *models.ModeleOption, supprimees []string, propager bool, version *int) (*PropagationModele, error) {
+	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	propagation := &PropagationModele{}
+	err := r.db.WithContext(opCtx).Transaction(func(tx *gorm.DB) error {
+		query := tx.Model(&models.ModeleOption{}).Where("id = ? AND boutique_id = ?", modele.ID, modele.BoutiqueID)
+		result := avecVersion(query, version).Updates(map[string]interface{}{
+			"nom":           modele.Nom,
+			"version":       gorm.Expr("version + 1"),
+			"mis_a_jour_le": time.Now(),
+		})
+		if result.Error != nil {
+			return fmt.Errorf("failed to update ModeleOption: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			if err := conflitVersion(tx, &models.ModeleOption{}, modele.ID, version); err != nil {
+				return err
+			}
+			return ErrModeleIntrouvable
+		}
+
+		if len(supprimees) > 0 {
+			if err := tx.Model(&models.ValeurOption{}).
+				Where("valeur_modele_id IN ?", supprimees).
+				Update("valeur_modele_id", nil).Error; err != nil {
+				return fmt.Errorf("failed to unlink valeurOption: %w", err)
+			}
+			if err := tx.Where("modele_id = ? AND id IN ?", modele.ID, supprimees).
+				Delete(&models.ValeurModeleOption{}).Error; err != nil {
+				return fmt.Errorf("failed to delete ValeurModeleOption: %w", err)
+			}
+		}
+
+		for i := range modele.Valeurs {
+			modele.Valeurs[i].ModeleID = modele.ID
+			if err := tx.Save(&modele.Valeurs[i]).Error; err != nil {
+				return fmt.Errorf("failed to save ValeurModeleOption: %w", err)
+			}
+		}
+
+		if !propager {
+			return nil
+		}
+
+		var liees []models.OptionProduit
+		if err := tx.Select("id", "produit_id").
+			Where("modele_id = ?", modele.ID).
+			Order("produit_id, id").
+			Find(&liees).Error; err != nil {
+			return fmt.Errorf("find linked options failed: %w", err)
+		}
+		if len(liees) == 0 {
+			return nil
+		}
+		for _, o := range liees {
+			propagation.OptionIDs = append(propagation.OptionIDs, o.ID)
+			if n := len(propagation.ProduitIDs); n == 0 || propagation.ProduitIDs[n-1] != o.ProduitID {
+				propagation.ProduitIDs = append(propagation.ProduitIDs, o.ProduitID)
+			}
+		}
+
+		if err := tx.Model(&models.OptionProduit{}).
+			Where("id IN ?", propagation.OptionIDs).
+			Updates(map[string]interface{}{
+				"nom":           modele.Nom,
+				"version":       gorm.Expr("version + 1"),
+				"mis_a_jour_le": time.Now(),
+			}).Error; err != nil {
+			return fmt.Errorf("failed to propagate to ProductOption: %w", err)
+		}
+
+		// libellés et positions des valeurs déjà liées ; une position choisie
+		// sur le produit est conservée
+		if err := tx.Exec(`UPDATE valeur_options v
+			SET valeur = m.valeur,
+			    position = CASE WHEN v.position_propre THEN v.position ELSE m.position END,
+			    version = v.version + 1
+			FROM valeur_modele_options m
+			WHERE v.valeur_modele_id = m.id AND m.modele_id = ?
+			  AND (v.valeur <> m.valeur OR (NOT v.position_propre AND v.position <> m.position))`, modele.ID).Error; err != nil {
+			return fmt.Errorf("failed to propagate to valeurOption: %w", err)
+		}
+
+		// valeurs du modèle encore absentes des options liées
+		if err := tx.Exec(`INSERT INTO valeur_options (id, option_id, valeur, position, version, valeur_modele_id)
+			SELECT gen_random_uuid(), o.id, m.valeur, m.position, 1, m.id
+			FROM option_produits o
+			JOIN valeur_modele_options m ON m.modele_id = o.modele_id
+			WHERE o.modele_id = ?
+			  AND NOT EXISTS (SELECT 1 FROM valeur_options v
+			                  WHERE v.option_id = o.id AND v.valeur_modele_id = m.id)`, modele.ID).Error; err != nil {
+			return fmt.Errorf("failed to propagate new values: %w", err)
+		}
+
+		// les valeurs insérées prennent la position du modèle : on referme
+		// les trous et doublons laissés dans chaque option
+		for _, optionID := range propagation.OptionIDs {
+			if err := compacterPositions(tx, "valeur_options", "option_id", optionID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return propagation, nil
+}
+
+// Delete supprime le modèle ; les options et valeurs de produits liées sont
+// conservées mais déliées. Renvoie false si le modèle est introuvable.
+func (r *ModeleOptionRepo) Delete(ctx context.Context, id, boutiqueID string, version *int) (bool, error) {
+	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	supprime := false
+	err := r.db.WithContext(opCtx).Transaction(func(tx *gorm.DB) error {
+		var modele models.ModeleOption
+		if err := tx.Select("id").Where("id = ? AND boutique_id = ?", id, boutiqueID).Limit(1).Find(&modele).Error; err != nil {
+			return fmt.Errorf("failed to delete ModeleOption: %w", err)
+		}
+		if modele.ID == "" {
+			return nil
+		}
+
+		if err := tx.Exec(`UPDATE valeur_options SET valeur_modele_id = NULL
+			WHERE valeur_modele_id IN (SELECT id FROM valeur_modele_options WHERE modele_id = ?)`, id).Error; err != nil {
+			return fmt.Errorf("failed to unlink valeurOption: %w", err)
+		}
+		if err := tx.Model(&models.OptionProduit{}).
+			Where("modele_id = ?", id).
+			Update("modele_id", nil).Error; err != nil {
+			return fmt.Errorf("failed to unlink ProductOption: %w", err)
+		}
+
+		// annule le déliage si la version ne correspond plus
+		result := avecVersion(tx.Where("id = ?", id), version).Delete(&models.ModeleOption{})
+		if result.Error != nil {
+			return fmt.Errorf("failed to delete ModeleOption: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return ErrVersionObsolete
+		}
+		supprime = true
+		return nil
+	})
+	return supprime, err
+}
@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"projet/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ------------------------------------------------------------
+// Paliers de quantité
+// ------------------------------------------------------------
+
+// PaliersDe : paliers des produits donnés, ceux du produit avant ceux des
+// variantes, par quantité croissante
+func (r *TarifRepo) PaliersDe(ctx context.Context, produitIDs []string) ([]models.PalierQuantite, error) {
+	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var paliers []models.PalierQuantite
+	if len(produitIDs) == 0 {
+		return paliers, nil
+	}
+	if err := r.db.WithContext(opCtx).Where("produit_id IN ?", produitIDs).
+		Order("produit_id, variante_id NULLS FIRST, quantite_min").Find(&paliers).Error; err != nil {
+		return nil, fmt.Errorf("find quantity tiers failed: %w", err)
+	}
+	return paliers, nil
+}
+
+// RemplacerPaliers remplace, dans une transaction, les paliers du produit
+// (varianteID nil) ou d'une de ses variantes ; une liste vide les retire
+func (r *TarifRepo) RemplacerPaliers(ctx context.Context, produitID string, varianteID *string, paliers []models.PalierQuantite) error {
+	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	return r.db.WithContext(opCtx).Transaction(func(tx *gorm.DB) error {
+		query := tx.Where("produit_id = ?", produitID)
+		if varianteID == nil {
+			query = query.Where("variante_id IS NULL")
+		} else {
+			query = query.Where("variante_id = ?", *varianteID)
+		}
+		if err := query.Delete(&models.PalierQuantite{}).Error; err != nil {
+			return fmt.Errorf("failed to replace quantity tiers: %w", err)
+		}
+		if len(paliers) == 0 {
+			return nil
+		}
+		for i := range paliers {
+			paliers[i].ProduitID, paliers[i].VarianteID = produitID, varianteID
+		}
+		if err := tx.Create(&paliers).Error; err != nil {
+			return fmt.Errorf("failed to insert quantity tiers: %w", err)
+		}
+		return nil
+	})
+}
+
+// ProduitsTarifes : produits actifs de la boutique, sans relations, pour
+// calculer leurs prix
+func (r *TarifRepo) ProduitsTarifes(ctx context.Context, boutiqueID string, ids []string) ([]models.Produit, error) {
+	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var produits []models.Produit
+	if len(ids) == 0 {
+		return produits, nil
+	}
+	if err := r.db.WithContext(opCtx).Where("boutique_id = ? AND id IN ?", boutiqueID, ids).
+		Find(&produits).Error; err != nil {
+		return nil, fmt.Errorf("find priced products failed: %w", err)
+	}
+	return produits, nil
+}
+
+// VariantesTarifees : variantes de la boutique dont le produit n'est pas
+// dans la corbeille
+func (r *TarifRepo) VariantesTarifees(ctx context.Context, boutiqueID string, ids []string) ([]models.Variante, error) {
+	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var variantes []models.Variante
+	if len(ids) == 0 {
+		return variantes, nil
+	}
+	if err := r.db.WithContext(opCtx).
+		Joins("JOIN produits p ON p.id = variantes.produit_id AND p.supprime_le IS NULL").
+		Where("p.boutique_id = ? AND variantes.id IN ?", boutiqueID, ids).
+		Find(&variantes).Error; err != nil {
+		return nil, fmt.Errorf("find priced variants failed: %w", err)
+	}
+	return variantes, nil
+}
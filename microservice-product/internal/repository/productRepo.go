@@ -2,12 +2,15 @@ package repository
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"projet/internal/dto"
 	"projet/internal/models"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type ProduitRepo struct {
@@ -22,8 +25,15 @@ func (r *ProduitRepo) CreateProduit(ctx context.Context, produit *models.Produit
 	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	if err := r.db.WithContext(opCtx).Create(produit).Error; err != nil {
-		return nil, fmt.Errorf("failed to insert product: %w", err)
+	skus := []string{}
+	if produit.SKU != nil {
+		skus = append(skus, *produit.SKU)
+	}
+	err := avecSKUs(r.db.WithContext(opCtx), produit.BoutiqueID, skus, "", "", func(tx *gorm.DB) error {
+		return tx.Create(produit).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert product: %w", erreurUnicite(err))
 	}
 	return produit, nil
 }
@@ -53,6 +63,7 @@ func (r *ProduitRepo) GetByID(ctx context.Context, id, boutiqueID string) (*mode
 			return db.Order("position")
 		}).
 		Preload("Variantes").
+		Preload("Variantes.ValeurOptions").
 		First(&produit).Error
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
@@ -63,24 +74,33 @@ func (r *ProduitRepo) GetByID(ctx context.Context, id, boutiqueID string) (*mode
 	return &produit, nil
 }
 
-func (r *ProduitRepo) Update(ctx context.Context, id, boutiqueID string, updates map[string]interface{}) (*models.Produit, error) {
+// Update applique updates et incrémente la version. Si version n'est pas nil,
+// la ligne n'est modifiée que si elle est encore à cette version, sinon
+// ErrVersionObsolete (verrouillage optimiste).
+func (r *ProduitRepo) Update(ctx context.Context, id, boutiqueID string, updates map[string]interface{}, version *int) (*models.Produit, error) {
 	/*yhdhr fil context mtaa bdd*/
 	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
+	updates["version"] = gorm.Expr("version + 1")
+
 	/*9aad ybdati*/
 	//milloul yimchi li table produit bModel ou baad bidhbt win bl id. ou baad yaaml l u^date
-	result := r.db.WithContext(opCtx).Model(&models.Produit{}).
-		Where("id = ? AND boutique_id = ?", id, boutiqueID).
-		Updates(updates)
+	var result *gorm.DB
+	err := avecSKUs(r.db.WithContext(opCtx), boutiqueID, skusModifies(updates), id, "", func(tx *gorm.DB) error {
+		query := tx.Model(&models.Produit{}).
+			Where("id = ? AND boutique_id = ?", id, boutiqueID)
+		result = avecVersion(query, version).Updates(updates)
+		return result.Error
+	})
 
 	/*ytesti l9aha walla mal9ahech w njhit wella*/
-	if result.Error != nil {
-		return nil, fmt.Errorf("failed to update product: %w", result.Error)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update product: %w", erreurUnicite(err))
 	}
 	//ml9a hatte ligne
 	if result.RowsAffected == 0 {
-		return nil, nil
+		return nil, r.versionObsolete(opCtx, id, boutiqueID, version)
 	}
 
 	/*ki nijhit 9aadin nlwjou bech nrja3ou lprod*/
@@ -91,21 +111,32 @@ func (r *ProduitRepo) Update(ctx context.Context, id, boutiqueID string, updates
 		Preload("Options.ValeurOpts", func(db *gorm.DB) *gorm.DB {
 			return db.Order("position")
 		}).
-		Preload("Variantes").First(&produit).Error; err != nil {
+		Preload("Variantes").
+		Preload("Variantes.ValeurOptions").First(&produit).Error; err != nil {
 		return nil, fmt.Errorf("product updated but failed to fetch: %w", err)
 	}
 	return &produit, nil
 }
 
-func (r *ProduitRepo) DeleteById(ctx context.Context, id, boutiqueID string) (bool, error) {
+func (r *ProduitRepo) DeleteById(ctx context.Context, id, boutiqueID string, version *int) (bool, error) {
 	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	result := r.db.WithContext(opCtx).Where("id = ? AND boutique_id = ?", id, boutiqueID).Delete(&models.Produit{})
+	query := r.db.WithContext(opCtx).Where("id = ? AND boutique_id = ?", id, boutiqueID)
+	result := avecVersion(query, version).Delete(&models.Produit{})
 	if result.Error != nil {
 		return false, fmt.Errorf("failed to delete product: %w", result.Error)
 	}
-	return result.RowsAffected > 0, nil
+	if result.RowsAffected == 0 {
+		return false, r.versionObsolete(opCtx, id, boutiqueID, version)
+	}
+	return true, nil
+}
+
+// versionObsolete explique un RowsAffected nul : ErrVersionObsolete si le
+// produit existe toujours (c'est donc la version qui ne correspondait pas)
+func (r *ProduitRepo) versionObsolete(ctx context.Context, id, boutiqueID string, version *int) error {
+	return conflitVersion(r.db.WithContext(ctx).Where("boutique_id = ?", boutiqueID), &models.Produit{}, id, version)
 }
 
 func (r *ProduitRepo) GetWithFilter(ctx context.Context, boutiqueID string, filter dto.FiltreProduit) ([]models.Produit, error) {
@@ -115,23 +146,12 @@ func (r *ProduitRepo) GetWithFilter(ctx context.Context, boutiqueID string, filt
 	var produits []models.Produit
 	//query tab3a db bech taaml les requettes
 	query := r.db.WithContext(opCtx).Where("boutique_id = ?", boutiqueID)
-
-	if filter.Statut != nil {
-		query = query.Where("statut = ?", *filter.Statut)
-	}
-	if filter.Visibilite != nil {
-		query = query.Where("visibilite = ?", *filter.Visibilite)
-	}
-	if filter.Marque != nil {
-		query = query.Where("marque = ?", *filter.Marque)
-	}
-	if filter.Recherche != nil && *filter.Recherche != "" {
-		query = query.Where("titre ILIKE ?", "%"+*filter.Recherche+"%")
-	}
-	if !filter.InclureSupprime {
-		query = query.Where("supprime_le IS NULL")
+	// sans Unscoped, GORM ajoute toujours "supprime_le IS NULL"
+	if filter.InclureSupprime {
+		query = query.Unscoped()
 	}
 
+	query = filtrerProduits(query, filter)
 	//9dech nkhalliw min produits bech ykunu 9ad 9ad fi kl page.
 	offset := (filter.Page - 1) * filter.Limite
 
@@ -152,3 +172,646 @@ func (r *ProduitRepo) GetWithFilter(ctx context.Context, boutiqueID string, filt
 	}
 	return produits, nil
 }
+
+// filtrerProduits : critères communs à la recherche et aux règles de prix
+func filtrerProduits(query *gorm.DB, filter dto.FiltreProduit) *gorm.DB {
+	if filter.Statut != nil {
+		query = query.Where("statut = ?", *filter.Statut)
+	}
+	if filter.Visibilite != nil {
+		query = query.Where("visibilite = ?", *filter.Visibilite)
+	}
+	if filter.Marque != nil {
+		query = query.Where("marque = ?", *filter.Marque)
+	}
+	if filter.Recherche != nil && *filter.Recherche != "" {
+		query = query.Where("titre ILIKE ?", "%"+*filter.Recherche+"%")
+	}
+	return query
+}
+
+// requeteRegle : produits (hors corbeille) visés par une règle de prix, avec
+// l'agrégat complet (options, variantes) qui sert d'état « avant » à l'audit
+// et aux révisions ; devise vide = toutes
+func requeteRegle(db *gorm.DB, boutiqueID string, filter dto.FiltreProduit, devise string) *gorm.DB {
+	query := filtrerProduits(db.Where("boutique_id = ?", boutiqueID), filter)
+	if devise != "" {
+		query = query.Where("devise = ?", devise)
+	}
+	return query.Order("cree_le, id").
+		Preload("Options", func(db *gorm.DB) *gorm.DB {
+			return db.Order("position")
+		}).
+		Preload("Options.ValeurOpts", func(db *gorm.DB) *gorm.DB {
+			return db.Order("position")
+		}).
+		Preload("Variantes", func(db *gorm.DB) *gorm.DB {
+			return db.Order("cree_le, id")
+		}).
+		Preload("Variantes.ValeurOptions")
+}
+
+// ProduitsPourRegle : un lot d'au plus limite produits visés par une règle de
+// prix, lus après dernier (pagination par curseur sur cree_le, id ; nil =
+// premier lot), sans verrou
+func (r *ProduitRepo) ProduitsPourRegle(ctx context.Context, boutiqueID string, filter dto.FiltreProduit, devise string, dernier *models.Produit, limite int) ([]models.Produit, error) {
+	opCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	query := requeteRegle(r.db.WithContext(opCtx), boutiqueID, filter, devise)
+	if dernier != nil {
+		query = query.Where("(cree_le, id) > (?, ?)", dernier.CreeLe, dernier.ID)
+	}
+
+	var produits []models.Produit
+	if err := query.Limit(limite).Find(&produits).Error; err != nil {
+		return nil, fmt.Errorf("price rule selection failed: %w", err)
+	}
+	return produits, nil
+}
+
+// VerrouillerPourRegle relit FOR UPDATE, dans la transaction d'application,
+// ceux des produits ids encore visés par la règle
+func (r *ProduitRepo) VerrouillerPourRegle(ctx context.Context, boutiqueID string, filter dto.FiltreProduit, devise string, ids []string) ([]models.Produit, error) {
+	opCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	var produits []models.Produit
+	if err := requeteRegle(r.db.WithContext(opCtx), boutiqueID, filter, devise).
+		Where("id IN ?", ids).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Find(&produits).Error; err != nil {
+		return nil, fmt.Errorf("price rule selection failed: %w", err)
+	}
+	return produits, nil
+}
+
+// ------------------------------------------------------------
+// Corbeille (produits supprimés logiquement)
+// ------------------------------------------------------------
+func (r *ProduitRepo) ListSupprimes(ctx context.Context, boutiqueID string, page, limite int) ([]models.Produit, error) {
+	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var produits []models.Produit
+	if err := r.db.WithContext(opCtx).Unscoped().
+		Where("boutique_id = ? AND supprime_le IS NOT NULL", boutiqueID).
+		Order("supprime_le DESC").
+		Limit(limite).Offset((page - 1) * limite).
+		Find(&produits).Error; err != nil {
+		return nil, fmt.Errorf("find deleted products failed: %w", err)
+	}
+	return produits, nil
+}
+
+// Restaurer annule la suppression logique ; false si le produit n'est pas dans la corbeille
+func (r *ProduitRepo) Restaurer(ctx context.Context, id, boutiqueID string) (bool, error) {
+	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	result := r.db.WithContext(opCtx).Unscoped().Model(&models.Produit{}).
+		Where("id = ? AND boutique_id = ? AND supprime_le IS NOT NULL", id, boutiqueID).
+		Updates(map[string]interface{}{"supprime_le": nil, "mis_a_jour_le": time.Now(), "version": gorm.Expr("version + 1")})
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to restore product: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// Purger supprime définitivement un produit (dans la corbeille ou non) avec ses options,
+// valeurs et variantes. Avec version, la ligne verrouillée doit encore être à
+// cette version, sinon ErrVersionObsolete.
+func (r *ProduitRepo) Purger(ctx context.Context, id, boutiqueID string, version *int) (bool, error) {
+	opCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	purge := false
+	err := r.db.WithContext(opCtx).Transaction(func(tx *gorm.DB) error {
+		var ids []string
+		query := tx.Unscoped().Model(&models.Produit{}).
+			Where("id = ? AND boutique_id = ?", id, boutiqueID).
+			Clauses(clause.Locking{Strength: "UPDATE"})
+		if err := avecVersion(query, version).Pluck("id", &ids).Error; err != nil {
+			return fmt.Errorf("failed to find product to purge: %w", err)
+		}
+		if len(ids) == 0 {
+			return conflitVersion(tx.Unscoped(), &models.Produit{}, id, version)
+		}
+		purge = true
+		return purgerProduits(tx, ids)
+	})
+	if err != nil {
+		return false, err
+	}
+	return purge, nil
+}
+
+// verrouCorbeille : clé du verrou consultatif de la purge de la corbeille,
+// distincte de celle de la programmation pour que les deux tâches restent indépendantes
+const verrouCorbeille int64 = 72010029
+
+// PurgerCorbeille purge les produits supprimés avant la date donnée, par lots,
+// et retourne les produits purgés (pour l'audit). Chaque lot prend le verrou
+// consultatif de la corbeille : si une autre réplique le tient, on s'arrête là.
+func (r *ProduitRepo) PurgerCorbeille(ctx context.Context, avant time.Time) ([]models.Produit, error) {
+	var purges []models.Produit
+	for {
+		opCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+
+		var lot []models.Produit
+		verrouille := false
+		err := r.db.WithContext(opCtx).Transaction(func(tx *gorm.DB) error {
+			if err := tx.Raw("SELECT pg_try_advisory_xact_lock(?)", verrouCorbeille).Scan(&verrouille).Error; err != nil {
+				return fmt.Errorf("advisory lock failed: %w", err)
+			}
+			if !verrouille {
+				return nil
+			}
+			if err := tx.Unscoped().
+				Where("supprime_le IS NOT NULL AND supprime_le < ?", avant).
+				Order("supprime_le").
+				Limit(100).
+				Find(&lot).Error; err != nil {
+				return err
+			}
+			if len(lot) == 0 {
+				return nil
+			}
+			ids := make([]string, len(lot))
+			for i, p := range lot {
+				ids[i] = p.ID
+			}
+			return purgerProduits(tx, ids)
+		})
+		cancel()
+
+		if err != nil {
+			return purges, fmt.Errorf("failed to purge trash: %w", err)
+		}
+		purges = append(purges, lot...)
+		if !verrouille || len(lot) < 100 {
+			return purges, nil
+		}
+	}
+}
+
+// la table de jointure n'a pas de ON DELETE CASCADE : on supprime tout explicitement
+func purgerProduits(tx *gorm.DB, ids []string) error {
+	if err := supprimerEnfants(tx, ids); err != nil {
+		return err
+	}
+	for _, requete := range []string{
+		"DELETE FROM revision_produits WHERE produit_id IN ?",
+		"DELETE FROM prix_listes WHERE produit_id IN ?",
+		"DELETE FROM palier_quantites WHERE produit_id IN ?",
+		"DELETE FROM regle_groupes WHERE produit_id IN ?",
+		"DELETE FROM produits WHERE id IN ?",
+	} {
+		if err := tx.Exec(requete, ids).Error; err != nil {
+			return fmt.Errorf("failed to purge products: %w", err)
+		}
+	}
+	return nil
+}
+
+// supprimerEnfants efface options, valeurs, variantes et liens des produits donnés
+func supprimerEnfants(tx *gorm.DB, ids []string) error {
+	for _, requete := range []string{
+		"DELETE FROM variante_valeur_option WHERE variante_id IN (SELECT id FROM variantes WHERE produit_id IN ?)",
+		"DELETE FROM variantes WHERE produit_id IN ?",
+		"DELETE FROM valeur_options WHERE option_id IN (SELECT id FROM option_produits WHERE produit_id IN ?)",
+		"DELETE FROM option_produits WHERE produit_id IN ?",
+	} {
+		if err := tx.Exec(requete, ids).Error; err != nil {
+			return fmt.Errorf("failed to delete product children: %w", err)
+		}
+	}
+	return nil
+}
+
+// RestaurerAgregat remplace le contenu du produit par celui du snapshot, dans une
+// transaction : colonnes du produit, puis options, valeurs et variantes recréées
+// avec leurs identifiants d'origine (les liens variante/valeur restent valides).
+// Le statut et la suppression logique ne font pas partie du contenu restauré.
+func (r *ProduitRepo) RestaurerAgregat(ctx context.Context, snapshot *models.Produit) error {
+	opCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	return r.db.WithContext(opCtx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Produit{}).
+			Where("id = ? AND boutique_id = ?", snapshot.ID, snapshot.BoutiqueID).
+			Select("titre", "description", "slug", "prix_defaut", "devise", "sku", "suivi_stock",
+				"quantite_stock", "poids", "dimensions", "marque", "classe_taxe", "visibilite",
+				"images", "date_publication", "date_depublication", "prix_barre", "prix_promo", "debut_promo",
+				"fin_promo", "mis_a_jour_le").
+			Updates(&models.Produit{
+				Titre:             snapshot.Titre,
+				Description:       snapshot.Description,
+				Slug:              snapshot.Slug,
+				PrixDefaut:        snapshot.PrixDefaut,
+				Devise:            snapshot.Devise,
+				SKU:               snapshot.SKU,
+				SuiviStock:        snapshot.SuiviStock,
+				QuantiteStock:     snapshot.QuantiteStock,
+				Poids:             snapshot.Poids,
+				Dimensions:        snapshot.Dimensions,
+				Marque:            snapshot.Marque,
+				ClasseTaxe:        snapshot.ClasseTaxe,
+				Visibilite:        snapshot.Visibilite,
+				Images:            snapshot.Images,
+				DatePublication:   snapshot.DatePublication,
+				DateDepublication: snapshot.DateDepublication,
+				Promotion:         snapshot.Promotion,
+				MisAJourLe:        time.Now(),
+			})
+		if result.Error != nil {
+			return fmt.Errorf("failed to restore product: %w", erreurUnicite(result.Error))
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		if err := tx.Model(&models.Produit{}).Where("id = ?", snapshot.ID).
+			UpdateColumn("version", gorm.Expr("version + 1")).Error; err != nil {
+			return fmt.Errorf("failed to restore product: %w", err)
+		}
+
+		// les enfants recréés ne doivent pas retrouver une ancienne version,
+		// sinon un If-Match antérieur à la restauration redeviendrait valide
+		var versionEnfants int
+		if err := tx.Raw(`SELECT GREATEST(
+			(SELECT COALESCE(MAX(version), 0) FROM option_produits WHERE produit_id = ?),
+			(SELECT COALESCE(MAX(v.version), 0) FROM valeur_options v JOIN option_produits o ON o.id = v.option_id WHERE o.produit_id = ?),
+			(SELECT COALESCE(MAX(version), 0) FROM variantes WHERE produit_id = ?)) + 1`,
+			snapshot.ID, snapshot.ID, snapshot.ID).Scan(&versionEnfants).Error; err != nil {
+			return fmt.Errorf("failed to read children versions: %w", err)
+		}
+		for i := range snapshot.Options {
+			snapshot.Options[i].Version = versionEnfants
+			for j := range snapshot.Options[i].ValeurOpts {
+				snapshot.Options[i].ValeurOpts[j].Version = versionEnfants
+			}
+		}
+		for i := range snapshot.Variantes {
+			snapshot.Variantes[i].Version = versionEnfants
+			// les snapshots antérieurs au SKU par boutique n'ont pas la colonne
+			snapshot.Variantes[i].BoutiqueID = snapshot.BoutiqueID
+		}
+
+		var anciennes []string
+		if err := tx.Model(&models.Variante{}).Where("produit_id = ?", snapshot.ID).Pluck("id", &anciennes).Error; err != nil {
+			return fmt.Errorf("failed to list variants: %w", err)
+		}
+		if err := supprimerEnfants(tx, []string{snapshot.ID}); err != nil {
+			return err
+		}
+		// anciennes variantes retirées : seuls les SKU portés ailleurs comptent
+		if err := reserverSKUs(tx, snapshot.BoutiqueID, skusAgregat(snapshot), snapshot.ID, ""); err != nil {
+			return err
+		}
+		if len(snapshot.Options) > 0 {
+			if err := tx.Create(&snapshot.Options).Error; err != nil {
+				return fmt.Errorf("failed to restore options: %w", err)
+			}
+		}
+		if len(snapshot.Variantes) > 0 {
+			// les valeurs viennent d'être recréées : on n'insère que les liens
+			if err := tx.Omit("ValeurOptions.*").Create(&snapshot.Variantes).Error; err != nil {
+				return fmt.Errorf("failed to restore variants: %w", erreurUnicite(err))
+			}
+		}
+
+		// les variantes absentes du snapshot emportent leurs prix de liste et paliers
+		restaurees := make(map[string]bool, len(snapshot.Variantes))
+		for _, v := range snapshot.Variantes {
+			restaurees[v.ID] = true
+		}
+		var disparues []string
+		for _, id := range anciennes {
+			if !restaurees[id] {
+				disparues = append(disparues, id)
+			}
+		}
+		return supprimerTarifsVariantes(tx, disparues)
+	})
+}
+
+// clé du verrou consultatif Postgres partagé par toutes les répliques du planificateur
+const verrouPlanificateur int64 = 72010027
+
+// ResultatProgrammation regroupe les produits modifiés par un passage du
+// planificateur, par transition (le statut de départ sert à l'audit).
+type ResultatProgrammation struct {
+	Verrouille bool
+	// brouillon -> publie
+	Publies []models.Produit
+	// publie -> archive
+	Archives []models.Produit
+	// brouillon -> archive, quand les deux dates sont passées pendant un arrêt
+	ArchivesDepuisBrouillon []models.Produit
+}
+
+// AppliquerProgrammation publie les brouillons dont la date de publication est
+// atteinte et archive les produits publiés dont la date de dépublication est
+// passée. Tout se fait dans une transaction protégée par pg_try_advisory_xact_lock :
+// si une autre réplique tient déjà le verrou, Verrouille vaut false et rien n'est fait.
+func (r *ProduitRepo) AppliquerProgrammation(ctx context.Context, maintenant time.Time) (*ResultatProgrammation, error) {
+	opCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	resultat := &ResultatProgrammation{}
+	err := r.db.WithContext(opCtx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Raw("SELECT pg_try_advisory_xact_lock(?)", verrouPlanificateur).Scan(&resultat.Verrouille).Error; err != nil {
+			return fmt.Errorf("advisory lock failed: %w", err)
+		}
+		if !resultat.Verrouille {
+			return nil
+		}
+
+		if err := tx.Raw(`UPDATE produits SET statut = ?, mis_a_jour_le = ?, version = version + 1
+			WHERE supprime_le IS NULL AND programme AND statut = ?
+			AND date_depublication IS NOT NULL AND date_depublication <= ?
+			RETURNING *`,
+			models.StatutArchive, maintenant, models.StatutPublie, maintenant).
+			Scan(&resultat.Archives).Error; err != nil {
+			return fmt.Errorf("scheduled archiving failed: %w", err)
+		}
+
+		// Les deux dates peuvent être dépassées après une longue interruption :
+		// le brouillon passe alors directement à l'état final (archive).
+		if err := tx.Raw(`UPDATE produits SET statut = ?, mis_a_jour_le = ?, version = version + 1
+			WHERE supprime_le IS NULL AND programme AND statut = ?
+			AND date_publication IS NOT NULL AND date_publication <= ?
+			AND date_depublication IS NOT NULL AND date_depublication <= ?
+			RETURNING *`,
+			models.StatutArchive, maintenant, models.StatutBrouillon, maintenant, maintenant).
+			Scan(&resultat.ArchivesDepuisBrouillon).Error; err != nil {
+			return fmt.Errorf("scheduled archiving failed: %w", err)
+		}
+
+		// mêmes exigences que service.ExigencesPublication : un produit
+		// incomplet reste en brouillon jusqu'à ce qu'il soit corrigé
+		if err := tx.Raw(`UPDATE produits SET statut = ?, mis_a_jour_le = ?, version = version + 1
+			WHERE supprime_le IS NULL AND programme AND statut = ?
+			AND date_publication IS NOT NULL AND date_publication <= ?
+			AND (date_depublication IS NULL OR date_depublication > ?)
+			AND btrim(titre) <> '' AND prix_defaut > 0
+			AND (CASE WHEN jsonb_typeof(images) = 'array' THEN jsonb_array_length(images) > 0 ELSE false END
+				OR EXISTS (SELECT 1 FROM variantes v WHERE v.produit_id = produits.id))
+			RETURNING *`,
+			models.StatutPublie, maintenant, models.StatutBrouillon, maintenant, maintenant).
+			Scan(&resultat.Publies).Error; err != nil {
+			return fmt.Errorf("scheduled publishing failed: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resultat, nil
+}
+
+// Transaction exécute fn avec des repos liés à une même transaction.
+// Appelée sur un repo déjà transactionnel, GORM pose un SAVEPOINT : un échec
+// de fn n'annule alors que ce qu'elle a fait. journal écrit l'audit dans la
+// même transaction.
+func (r *ProduitRepo) Transaction(ctx context.Context, fn func(produits *ProduitRepo, variantes *VarianteRepo, journal *AuditRepo) error) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(NewRepo(tx), NewVarianteRepo(tx), NewAuditRepo(tx))
+	})
+}
+
+// CreerAgregat insère le produit, ses options et valeurs puis ses variantes
+// (avec leurs liens vers les valeurs) dans une seule transaction. Les ID des
+// options et valeurs doivent être renseignés par l'appelant.
+func (r *ProduitRepo) CreerAgregat(ctx context.Context, produit *models.Produit) (*models.Produit, error) {
+	opCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	err := r.db.WithContext(opCtx).Transaction(func(tx *gorm.DB) error {
+		if err := reserverSKUs(tx, produit.BoutiqueID, skusAgregat(produit), "", ""); err != nil {
+			return err
+		}
+		if err := tx.Omit("Options", "Variantes").Create(produit).Error; err != nil {
+			return fmt.Errorf("failed to insert product: %w", erreurUnicite(err))
+		}
+		for i := range produit.Options {
+			produit.Options[i].ProduitID = produit.ID
+		}
+		for i := range produit.Variantes {
+			produit.Variantes[i].ProduitID = produit.ID
+			produit.Variantes[i].BoutiqueID = produit.BoutiqueID
+		}
+		if len(produit.Options) > 0 {
+			if err := tx.Create(&produit.Options).Error; err != nil {
+				return fmt.Errorf("failed to insert options: %w", erreurUnicite(err))
+			}
+		}
+		if len(produit.Variantes) > 0 {
+			// les valeurs viennent d'être créées : on n'insère que les liens
+			if err := tx.Omit("ValeurOptions.*").Create(&produit.Variantes).Error; err != nil {
+				return fmt.Errorf("failed to insert variants: %w", erreurUnicite(err))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return produit, nil
+}
+
+// SlugDisponible : le slug est unique sur toute la table, corbeille comprise
+func (r *ProduitRepo) SlugDisponible(ctx context.Context, slug string) (bool, error) {
+	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var count int64
+	if err := r.db.WithContext(opCtx).Unscoped().Model(&models.Produit{}).
+		Where("slug = ?", slug).Count(&count).Error; err != nil {
+		return false, fmt.Errorf("failed to check slug: %w", err)
+	}
+	return count == 0, nil
+}
+
+// SKUsPris renvoie, parmi skus, ceux déjà portés dans la boutique par un
+// produit (corbeille comprise) ou une variante, hors produit et variante
+// exclus ("" = aucun)
+func (r *ProduitRepo) SKUsPris(ctx context.Context, boutiqueID string, skus []string, exclureProduitID, exclureVarianteID string) ([]string, error) {
+	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	return skusPris(r.db.WithContext(opCtx), boutiqueID, skus, exclureProduitID, exclureVarianteID)
+}
+
+func skusPris(db *gorm.DB, boutiqueID string, skus []string, exclureProduitID, exclureVarianteID string) ([]string, error) {
+	var pris []string
+	if len(skus) == 0 {
+		return pris, nil
+	}
+	err := db.Raw(`
+		SELECT p.sku FROM produits p
+		WHERE p.boutique_id = @boutique AND p.sku IN @skus AND p.id::text <> @produit
+		UNION
+		SELECT v.sku FROM variantes v JOIN produits p ON p.id = v.produit_id
+		WHERE p.boutique_id = @boutique AND v.sku IN @skus AND v.id::text <> @variante`,
+		sql.Named("boutique", boutiqueID), sql.Named("skus", skus),
+		sql.Named("produit", exclureProduitID), sql.Named("variante", exclureVarianteID)).
+		Scan(&pris).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to check SKUs: %w", err)
+	}
+	return pris, nil
+}
+
+// verrouSKU : clé du verrou consultatif des SKU, combinée à la boutique.
+// Les index uniques ne couvrent chacun qu'une table : sans ce verrou un
+// produit et une variante pourraient prendre le même SKU en même temps.
+const verrouSKU int32 = 72010030
+
+// reserverSKUs prend le verrou SKU de la boutique puis revérifie, dans la
+// transaction tx, que skus sont libres (ErrSKUPris sinon). Le verrou est
+// relâché au commit : les écritures concurrentes de SKU se sérialisent.
+func reserverSKUs(tx *gorm.DB, boutiqueID string, skus []string, exclureProduitID, exclureVarianteID string) error {
+	candidats := []string{}
+	for _, s := range skus {
+		if s != "" {
+			candidats = append(candidats, s)
+		}
+	}
+	if len(candidats) == 0 {
+		return nil
+	}
+	if err := tx.Exec("SELECT pg_advisory_xact_lock(?, hashtext(?))", verrouSKU, boutiqueID).Error; err != nil {
+		return fmt.Errorf("failed to lock SKUs: %w", err)
+	}
+	pris, err := skusPris(tx, boutiqueID, candidats, exclureProduitID, exclureVarianteID)
+	if err != nil {
+		return err
+	}
+	if len(pris) > 0 {
+		return fmt.Errorf("%w: %s", ErrSKUPris, strings.Join(pris, ", "))
+	}
+	return nil
+}
+
+// avecSKUs exécute ecrire sous reserverSKUs, dans une transaction ; sans SKU
+// à poser, ecrire passe directement
+func avecSKUs(db *gorm.DB, boutiqueID string, skus []string, exclureProduitID, exclureVarianteID string, ecrire func(tx *gorm.DB) error) error {
+	vide := true
+	for _, s := range skus {
+		vide = vide && s == ""
+	}
+	if vide {
+		return ecrire(db)
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := reserverSKUs(tx, boutiqueID, skus, exclureProduitID, exclureVarianteID); err != nil {
+			return err
+		}
+		return ecrire(tx)
+	})
+}
+
+// skusModifies extrait le SKU posé par un jeu de modifications (aucun si effacé)
+func skusModifies(updates map[string]interface{}) []string {
+	switch v := updates["sku"].(type) {
+	case string:
+		return []string{v}
+	case *string:
+		if v != nil {
+			return []string{*v}
+		}
+	}
+	return nil
+}
+
+// skusAgregat : SKU du produit et de ses variantes
+func skusAgregat(p *models.Produit) []string {
+	skus := []string{}
+	if p.SKU != nil {
+		skus = append(skus, *p.SKU)
+	}
+	for _, v := range p.Variantes {
+		skus = append(skus, v.SKU)
+	}
+	return skus
+}
+
+// CodesBarresPris renvoie, parmi codes (GTIN normalisés sur 14 chiffres),
+// ceux déjà portés par une variante d'un produit de la boutique, corbeille
+// comprise, en ignorant la variante exclureID
+func (r *ProduitRepo) CodesBarresPris(ctx context.Context, boutiqueID string, codes []string, exclureID string) ([]string, error) {
+	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	return codesBarresPris(r.db.WithContext(opCtx), boutiqueID, codes, exclureID)
+}
+
+func codesBarresPris(db *gorm.DB, boutiqueID string, codes []string, exclureID string) ([]string, error) {
+	var pris []string
+	if len(codes) == 0 {
+		return pris, nil
+	}
+	query := db.Table("variantes v").
+		Joins("JOIN produits p ON p.id = v.produit_id").
+		Where("p.boutique_id = ?", boutiqueID).
+		Where("LPAD(v.code_barres, 14, '0') IN ?", codes)
+	if exclureID != "" {
+		query = query.Where("v.id <> ?", exclureID)
+	}
+	if err := query.Distinct().Pluck("LPAD(v.code_barres, 14, '0')", &pris).Error; err != nil {
+		return nil, fmt.Errorf("failed to check barcodes: %w", err)
+	}
+	return pris, nil
+}
+
+// CorrespondanceCode : un produit (et éventuellement une variante) dont le
+// champ Champ vaut le code recherché
+type CorrespondanceCode struct {
+	ProduitID  string
+	VarianteID *string
+	Champ      string
+}
+
+// ParCode cherche code dans Produit.SKU, Variante.SKU et Variante.CodeBarres
+// des produits non supprimés de la boutique. gtin14 (code normalisé, ou "")
+// fait correspondre un UPC-A scanné à l'EAN-13 enregistré et inversement.
+func (r *ProduitRepo) ParCode(ctx context.Context, boutiqueID, code, gtin14 string) ([]CorrespondanceCode, error) {
+	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var resultats []CorrespondanceCode
+	err := r.db.WithContext(opCtx).Raw(`
+		SELECT p.id AS produit_id, NULL AS variante_id, 'produit.sku' AS champ
+		FROM produits p
+		WHERE p.boutique_id = @boutique AND p.supprime_le IS NULL AND p.sku = @code
+		UNION ALL
+		SELECT v.produit_id, v.id, 'variante.sku'
+		FROM variantes v JOIN produits p ON p.id = v.produit_id
+		WHERE p.boutique_id = @boutique AND p.supprime_le IS NULL AND v.sku = @code
+		UNION ALL
+		SELECT v.produit_id, v.id, 'variante.code_barres'
+		FROM variantes v JOIN produits p ON p.id = v.produit_id
+		WHERE p.boutique_id = @boutique AND p.supprime_le IS NULL
+		  AND (v.code_barres = @code OR (@gtin <> '' AND LPAD(v.code_barres, 14, '0') = @gtin))
+		ORDER BY champ, produit_id`,
+		sql.Named("boutique", boutiqueID), sql.Named("code", code), sql.Named("gtin", gtin14)).
+		Scan(&resultats).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to lookup code: %w", err)
+	}
+	return resultats, nil
+}
+
+// ClasseTaxeExiste : la boutique a une classe de taxe de ce code
+func (r *ProduitRepo) ClasseTaxeExiste(ctx context.Context, boutiqueID, code string) (bool, error) {
+	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var count int64
+	if err := r.db.WithContext(opCtx).Model(&models.ClasseTaxe{}).
+		Where("boutique_id = ? AND code = ?", boutiqueID, code).Count(&count).Error; err != nil {
+		return false, fmt.Errorf("failed to check tax class: %w", err)
+	}
+	return count > 0, nil
+}
@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"projet/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type RevisionRepo struct {
+	db *gorm.DB
+}
+
+func NewRevisionRepo(db *gorm.DB) *RevisionRepo {
+	return &RevisionRepo{db: db}
+}
+
+// Create attribue le numéro suivant du produit. Le verrou consultatif par produit
+// évite que deux modifications simultanées obtiennent le même numéro.
+func (r *RevisionRepo) Create(ctx context.Context, revision *models.RevisionProduit) (*models.RevisionProduit, error) {
+	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	err := r.db.WithContext(opCtx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", revision.ProduitID).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.RevisionProduit{}).
+			Where("produit_id = ?", revision.ProduitID).
+			Select("COALESCE(MAX(numero), 0) + 1").
+			Scan(&revision.Numero).Error; err != nil {
+			return err
+		}
+		return tx.Create(revision).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert product revision: %w", err)
+	}
+	return revision, nil
+}
+
+// Agregat charge le produit avec ses options, valeurs, variantes et liens,
+// comme ProduitRepo.GetByID ; nil si le produit n'existe pas ou est supprimé
+func (r *RevisionRepo) Agregat(ctx context.Context, produitID string) (*models.Produit, error) {
+	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var produit models.Produit
+	err := r.db.WithContext(opCtx).Where("id = ?", produitID).
+		Preload("Options", func(db *gorm.DB) *gorm.DB {
+			return db.Order("position")
+		}).
+		Preload("Options.ValeurOpts", func(db *gorm.DB) *gorm.DB {
+			return db.Order("position")
+		}).
+		Preload("Variantes").
+		Preload("Variantes.ValeurOptions").
+		First(&produit).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error fetching product: %w", err)
+	}
+	return &produit, nil
+}
+
+func (r *RevisionRepo) Count(ctx context.Context, produitID string) (int, error) {
+	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var count int64
+	if err := r.db.WithContext(opCtx).Model(&models.RevisionProduit{}).
+		Where("produit_id = ?", produitID).
+		Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("count product revisions failed: %w", err)
+	}
+	return int(count), nil
+}
+
+// List renvoie les révisions sans leur snapshot, de la plus récente à la plus ancienne
+func (r *RevisionRepo) List(ctx context.Context, produitID, boutiqueID string) ([]models.RevisionProduit, error) {
+	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var revisions []models.RevisionProduit
+	if err := r.db.WithContext(opCtx).
+		Select("id", "produit_id", "boutique_id", "numero", "acteur", "cree_le").
+		Where("produit_id = ? AND boutique_id = ?", produitID, boutiqueID).
+		Order("numero DESC").
+		Find(&revisions).Error; err != nil {
+		return nil, fmt.Errorf("find product revisions failed: %w", err)
+	}
+	return revisions, nil
+}
+
+func (r *RevisionRepo) GetByNumero(ctx context.Context, produitID, boutiqueID string, numero int) (*models.RevisionProduit, error) {
+	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var revision models.RevisionProduit
+	err := r.db.WithContext(opCtx).
+		Where("produit_id = ? AND boutique_id = ? AND numero = ?", produitID, boutiqueID, numero).
+		First(&revision).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error fetching product revision: %w", err)
+	}
+	return &revision, nil
+}
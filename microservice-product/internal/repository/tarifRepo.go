@@ -0,0 +1,261 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"projet/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type TarifRepo struct {
+	db *gorm.DB
+}
+
+func NewTarifRepo(db *gorm.DB) *TarifRepo {
+	return &TarifRepo{db: db}
+}
+
+// ------------------------------------------------------------
+// Listes de prix
+// ------------------------------------------------------------
+func (r *TarifRepo) CreateListe(ctx context.Context, liste *models.ListePrix) (*models.ListePrix, error) {
+	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	if err := r.db.WithContext(opCtx).Omit("Prix").Create(liste).Error; err != nil {
+		return nil, fmt.Errorf("failed to insert price list: %w", err)
+	}
+	return liste, nil
+}
+
+func (r *TarifRepo) ListListes(ctx context.Context, boutiqueID string) ([]models.ListePrix, error) {
+	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var listes []models.ListePrix
+	if err := r.db.WithContext(opCtx).Where("boutique_id = ?", boutiqueID).
+		Order("devise, pays NULLS FIRST, nom").Find(&listes).Error; err != nil {
+		return nil, fmt.Errorf("find price lists failed: %w", err)
+	}
+	return listes, nil
+}
+
+func (r *TarifRepo) GetListe(ctx context.Context, id, boutiqueID string) (*models.ListePrix, error) {
+	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var liste models.ListePrix
+	err := r.db.WithContext(opCtx).Where("id = ? AND boutique_id = ?", id, boutiqueID).First(&liste).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error fetching price list: %w", err)
+	}
+	return &liste, nil
+}
+
+func (r *TarifRepo) UpdateListe(ctx context.Context, id, boutiqueID string, updates map[string]interface{}) (*models.ListePrix, error) {
+	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	result := r.db.WithContext(opCtx).Model(&models.ListePrix{}).
+		Where("id = ? AND boutique_id = ?", id, boutiqueID).Updates(updates)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to update price list: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+	return r.GetListe(ctx, id, boutiqueID)
+}
+
+// DeleteListe supprime la liste et ses prix
+func (r *TarifRepo) DeleteListe(ctx context.Context, id, boutiqueID string) (bool, error) {
+	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	supprimee := false
+	err := r.db.WithContext(opCtx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("id = ? AND boutique_id = ?", id, boutiqueID).Delete(&models.ListePrix{})
+		if result.Error != nil {
+			return fmt.Errorf("failed to delete price list: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		supprimee = true
+		if err := tx.Where("liste_id = ?", id).Delete(&models.PrixListe{}).Error; err != nil {
+			return fmt.Errorf("failed to delete list prices: %w", err)
+		}
+		return nil
+	})
+	return supprimee, err
+}
+
+// MarcheOccupe : une autre liste de la boutique couvre déjà devise + pays (nil = tous marchés)
+func (r *TarifRepo) MarcheOccupe(ctx context.Context, boutiqueID, devise string, pays *string, exclureID string) (bool, error) {
+	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := r.db.WithContext(opCtx).Model(&models.ListePrix{}).
+		Where("boutique_id = ? AND devise = ?", boutiqueID, devise)
+	if pays == nil {
+		query = query.Where("pays IS NULL")
+	} else {
+		query = query.Where("pays = ?", *pays)
+	}
+	if exclureID != "" {
+		query = query.Where("id <> ?", exclureID)
+	}
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		return false, fmt.Errorf("failed to check price lists: %w", err)
+	}
+	return count > 0, nil
+}
+
+// ListePourMarche : la liste de la devise propre au pays, à défaut celle
+// valable pour tous les marchés ; nil si aucune
+func (r *TarifRepo) ListePourMarche(ctx context.Context, boutiqueID, devise, pays string) (*models.ListePrix, error) {
+	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var listes []models.ListePrix
+	if err := r.db.WithContext(opCtx).
+		Where("boutique_id = ? AND devise = ? AND (pays IS NULL OR pays = ?)", boutiqueID, devise, pays).
+		Order("pays NULLS LAST").Limit(1).Find(&listes).Error; err != nil {
+		return nil, fmt.Errorf("failed to find price list: %w", err)
+	}
+	if len(listes) == 0 {
+		return nil, nil
+	}
+	return &listes[0], nil
+}
+
+// ------------------------------------------------------------
+// Prix d'une liste
+// ------------------------------------------------------------
+
+// PrixDeListe renvoie les prix de la liste, limités aux produits donnés si produitIDs n'est pas vide
+func (r *TarifRepo) PrixDeListe(ctx context.Context, listeID string, produitIDs []string) ([]models.PrixListe, error) {
+	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := r.db.WithContext(opCtx).Where("liste_id = ?", listeID)
+	if len(produitIDs) > 0 {
+		query = query.Where("produit_id IN ?", produitIDs)
+	}
+	var prix []models.PrixListe
+	if err := query.Order("produit_id, variante_id NULLS FIRST").Find(&prix).Error; err != nil {
+		return nil, fmt.Errorf("find list prices failed: %w", err)
+	}
+	return prix, nil
+}
+
+// DefinirPrix remplace, dans une transaction, les prix de la liste pour
+// chaque (produit, variante) de lignes ; une ligne sans montant (supprimer)
+// retire le prix explicite
+func (r *TarifRepo) DefinirPrix(ctx context.Context, listeID string, lignes []models.PrixListe, supprimer []bool) error {
+	opCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	return r.db.WithContext(opCtx).Transaction(func(tx *gorm.DB) error {
+		for i, ligne := range lignes {
+			query := tx.Where("liste_id = ? AND produit_id = ?", listeID, ligne.ProduitID)
+			if ligne.VarianteID == nil {
+				query = query.Where("variante_id IS NULL")
+			} else {
+				query = query.Where("variante_id = ?", *ligne.VarianteID)
+			}
+			if err := query.Delete(&models.PrixListe{}).Error; err != nil {
+				return fmt.Errorf("failed to replace list price: %w", err)
+			}
+			if supprimer[i] {
+				continue
+			}
+			ligne.ListeID = listeID
+			if err := tx.Create(&ligne).Error; err != nil {
+				return fmt.Errorf("failed to insert list price: %w", err)
+			}
+		}
+		return tx.Model(&models.ListePrix{}).Where("id = ?", listeID).
+			Update("mis_a_jour_le", time.Now()).Error
+	})
+}
+
+// ReferencesCatalogue : produits de la boutique parmi produitIDs, et pour
+// chacun les ID de ses variantes
+func (r *TarifRepo) ReferencesCatalogue(ctx context.Context, boutiqueID string, produitIDs []string) (map[string]map[string]bool, error) {
+	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	references := map[string]map[string]bool{}
+	if len(produitIDs) == 0 {
+		return references, nil
+	}
+	var produits []string
+	if err := r.db.WithContext(opCtx).Model(&models.Produit{}).
+		Where("boutique_id = ? AND id IN ?", boutiqueID, produitIDs).
+		Pluck("id", &produits).Error; err != nil {
+		return nil, fmt.Errorf("failed to check products: %w", err)
+	}
+	for _, id := range produits {
+		references[id] = map[string]bool{}
+	}
+	var variantes []models.Variante
+	if err := r.db.WithContext(opCtx).Select("id", "produit_id").
+		Where("produit_id IN ?", produits).Find(&variantes).Error; err != nil {
+		return nil, fmt.Errorf("failed to check variants: %w", err)
+	}
+	for _, v := range variantes {
+		references[v.ProduitID][v.ID] = true
+	}
+	return references, nil
+}
+
+// ------------------------------------------------------------
+// Taux de change
+// ------------------------------------------------------------
+func (r *TarifRepo) ListTaux(ctx context.Context, boutiqueID string) ([]models.TauxChange, error) {
+	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var taux []models.TauxChange
+	if err := r.db.WithContext(opCtx).Where("boutique_id = ?", boutiqueID).
+		Order("devise_source, devise_cible").Find(&taux).Error; err != nil {
+		return nil, fmt.Errorf("find exchange rates failed: %w", err)
+	}
+	return taux, nil
+}
+
+func (r *TarifRepo) EnregistrerTaux(ctx context.Context, taux *models.TauxChange) (*models.TauxChange, error) {
+	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	taux.MisAJourLe = time.Now()
+	err := r.db.WithContext(opCtx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "boutique_id"}, {Name: "devise_source"}, {Name: "devise_cible"}},
+		DoUpdates: clause.AssignmentColumns([]string{"taux", "mis_a_jour_le"}),
+	}).Create(taux).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to save exchange rate: %w", err)
+	}
+	return taux, nil
+}
+
+func (r *TarifRepo) SupprimerTaux(ctx context.Context, boutiqueID, source, cible string) (bool, error) {
+	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	result := r.db.WithContext(opCtx).
+		Where("boutique_id = ? AND devise_source = ? AND devise_cible = ?", boutiqueID, source, cible).
+		Delete(&models.TauxChange{})
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to delete exchange rate: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
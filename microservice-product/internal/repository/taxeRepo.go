@@ -0,0 +1,186 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"projet/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ------------------------------------------------------------
+// Classes de taxe
+// ------------------------------------------------------------
+func (r *TarifRepo) CreateClasseTaxe(ctx context.Context, classe *models.ClasseTaxe) (*models.ClasseTaxe, error) {
+	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	if err := r.db.WithContext(opCtx).Omit("Taux").Create(classe).Error; err != nil {
+		return nil, fmt.Errorf("failed to insert tax class: %w", erreurUnicite(err))
+	}
+	return classe, nil
+}
+
+// ListClassesTaxe : classes de la boutique avec leurs taux
+func (r *TarifRepo) ListClassesTaxe(ctx context.Context, boutiqueID string) ([]models.ClasseTaxe, error) {
+	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var classes []models.ClasseTaxe
+	if err := r.db.WithContext(opCtx).Preload("Taux", func(db *gorm.DB) *gorm.DB {
+		return db.Order("pays, region")
+	}).Where("boutique_id = ?", boutiqueID).Order("code").Find(&classes).Error; err != nil {
+		return nil, fmt.Errorf("find tax classes failed: %w", err)
+	}
+	return classes, nil
+}
+
+// GetClasseTaxe : classe et ses taux, nil si elle n'existe pas dans la boutique
+func (r *TarifRepo) GetClasseTaxe(ctx context.Context, id, boutiqueID string) (*models.ClasseTaxe, error) {
+	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var classe models.ClasseTaxe
+	err := r.db.WithContext(opCtx).Preload("Taux", func(db *gorm.DB) *gorm.DB {
+		return db.Order("pays, region")
+	}).Where("id = ? AND boutique_id = ?", id, boutiqueID).First(&classe).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error fetching tax class: %w", err)
+	}
+	return &classe, nil
+}
+
+func (r *TarifRepo) UpdateClasseTaxe(ctx context.Context, id, boutiqueID string, updates map[string]interface{}) (*models.ClasseTaxe, error) {
+	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	result := r.db.WithContext(opCtx).Model(&models.ClasseTaxe{}).
+		Where("id = ? AND boutique_id = ?", id, boutiqueID).Updates(updates)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to update tax class: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+	return r.GetClasseTaxe(ctx, id, boutiqueID)
+}
+
+// DeleteClasseTaxe supprime la classe et ses taux dans une transaction qui
+// verrouille la classe et les paramètres de la boutique : refusée si des
+// produits la portent encore, corbeille comprise (ErrClasseTaxeUtilisee), ou
+// si c'est la classe par défaut de la boutique (ErrClasseTaxeDefaut)
+func (r *TarifRepo) DeleteClasseTaxe(ctx context.Context, classe *models.ClasseTaxe) error {
+	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	return r.db.WithContext(opCtx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT id FROM classe_taxes WHERE id = ? FOR UPDATE", classe.ID).Error; err != nil {
+			return fmt.Errorf("failed to lock tax class: %w", err)
+		}
+		// FOR UPDATE refuse les agrégats : on verrouille les lignes lues
+		var defaut []string
+		if err := tx.Raw("SELECT boutique_id FROM parametres_taxes WHERE boutique_id = ? AND classe_defaut = ? FOR UPDATE",
+			classe.BoutiqueID, classe.Code).Scan(&defaut).Error; err != nil {
+			return fmt.Errorf("failed to check default tax class: %w", err)
+		}
+		if len(defaut) > 0 {
+			return ErrClasseTaxeDefaut
+		}
+		produits, err := classeTaxeUtilisee(tx, classe.BoutiqueID, classe.Code)
+		if err != nil {
+			return err
+		}
+		if produits > 0 {
+			return fmt.Errorf("%w: %d produit(s)", ErrClasseTaxeUtilisee, produits)
+		}
+
+		if err := tx.Where("classe_id = ?", classe.ID).Delete(&models.TauxTaxe{}).Error; err != nil {
+			return fmt.Errorf("failed to delete tax rates: %w", err)
+		}
+		if err := tx.Delete(&models.ClasseTaxe{}, "id = ?", classe.ID).Error; err != nil {
+			return fmt.Errorf("failed to delete tax class: %w", err)
+		}
+		return nil
+	})
+}
+
+// ClasseTaxeUtilisee : nombre de produits de la boutique, corbeille comprise,
+// qui portent le code de la classe
+func (r *TarifRepo) ClasseTaxeUtilisee(ctx context.Context, boutiqueID, code string) (int64, error) {
+	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	return classeTaxeUtilisee(r.db.WithContext(opCtx), boutiqueID, code)
+}
+
+func classeTaxeUtilisee(db *gorm.DB, boutiqueID, code string) (int64, error) {
+	var count int64
+	if err := db.Unscoped().Model(&models.Produit{}).
+		Where("boutique_id = ? AND classe_taxe = ?", boutiqueID, code).Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("failed to count tax class usage: %w", err)
+	}
+	return count, nil
+}
+
+// RemplacerTauxTaxe remplace tous les taux de la classe dans une transaction
+func (r *TarifRepo) RemplacerTauxTaxe(ctx context.Context, classeID string, taux []models.TauxTaxe) error {
+	opCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	return r.db.WithContext(opCtx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("classe_id = ?", classeID).Delete(&models.TauxTaxe{}).Error; err != nil {
+			return fmt.Errorf("failed to replace tax rates: %w", err)
+		}
+		if len(taux) > 0 {
+			for i := range taux {
+				taux[i].ClasseID = classeID
+			}
+			if err := tx.Create(&taux).Error; err != nil {
+				return fmt.Errorf("failed to insert tax rates: %w", err)
+			}
+		}
+		return tx.Model(&models.ClasseTaxe{}).Where("id = ?", classeID).
+			Update("mis_a_jour_le", time.Now()).Error
+	})
+}
+
+// ------------------------------------------------------------
+// Paramètres fiscaux
+// ------------------------------------------------------------
+
+// ParametresTaxe renvoie les réglages de la boutique, nil si elle n'en a pas
+func (r *TarifRepo) ParametresTaxe(ctx context.Context, boutiqueID string) (*models.ParametresTaxe, error) {
+	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var parametres models.ParametresTaxe
+	err := r.db.WithContext(opCtx).Where("boutique_id = ?", boutiqueID).First(&parametres).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error fetching tax settings: %w", err)
+	}
+	return &parametres, nil
+}
+
+// EnregistrerParametresTaxe crée ou remplace les réglages de la boutique
+func (r *TarifRepo) EnregistrerParametresTaxe(ctx context.Context, parametres *models.ParametresTaxe) (*models.ParametresTaxe, error) {
+	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	parametres.MisAJourLe = time.Now()
+	err := r.db.WithContext(opCtx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "boutique_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"prix_ttc", "pays_defaut", "classe_defaut", "mis_a_jour_le"}),
+	}).Create(parametres).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to save tax settings: %w", err)
+	}
+	return parametres, nil
+}
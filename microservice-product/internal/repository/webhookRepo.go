@@ -0,0 +1,201 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"projet/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type WebhookRepo struct {
+	db *gorm.DB
+}
+
+func NewWebhookRepo(db *gorm.DB) *WebhookRepo {
+	return &WebhookRepo{db: db}
+}
+
+// ------------------------------------------------------------
+// Abonnements
+// ------------------------------------------------------------
+func (r *WebhookRepo) CreateAbonnement(ctx context.Context, abonnement *models.AbonnementWebhook) (*models.AbonnementWebhook, error) {
+	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	if err := r.db.WithContext(opCtx).Create(abonnement).Error; err != nil {
+		return nil, fmt.Errorf("failed to insert webhook subscription: %w", err)
+	}
+	return abonnement, nil
+}
+
+func (r *WebhookRepo) ListAbonnements(ctx context.Context, boutiqueID string) ([]models.AbonnementWebhook, error) {
+	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var abonnements []models.AbonnementWebhook
+	if err := r.db.WithContext(opCtx).Where("boutique_id = ?", boutiqueID).
+		Order("cree_le").
+		Find(&abonnements).Error; err != nil {
+		return nil, fmt.Errorf("find webhook subscriptions failed: %w", err)
+	}
+	return abonnements, nil
+}
+
+// les abonnements actifs d'une boutique, le filtrage par événement se fait cote service
+func (r *WebhookRepo) ListAbonnementsActifs(ctx context.Context, boutiqueID string) ([]models.AbonnementWebhook, error) {
+	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var abonnements []models.AbonnementWebhook
+	if err := r.db.WithContext(opCtx).Where("boutique_id = ? AND actif = ?", boutiqueID, true).
+		Find(&abonnements).Error; err != nil {
+		return nil, fmt.Errorf("find active webhook subscriptions failed: %w", err)
+	}
+	return abonnements, nil
+}
+
+func (r *WebhookRepo) GetAbonnement(ctx context.Context, id, boutiqueID string) (*models.AbonnementWebhook, error) {
+	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var abonnement models.AbonnementWebhook
+	err := r.db.WithContext(opCtx).Where("id = ? AND boutique_id = ?", id, boutiqueID).First(&abonnement).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error fetching webhook subscription: %w", err)
+	}
+	return &abonnement, nil
+}
+
+func (r *WebhookRepo) UpdateAbonnement(ctx context.Context, id, boutiqueID string, updates map[string]interface{}) (*models.AbonnementWebhook, error) {
+	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	result := r.db.WithContext(opCtx).Model(&models.AbonnementWebhook{}).
+		Where("id = ? AND boutique_id = ?", id, boutiqueID).
+		Updates(updates)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to update webhook subscription: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+
+	var abonnement models.AbonnementWebhook
+	if err := r.db.WithContext(opCtx).Where("id = ?", id).First(&abonnement).Error; err != nil {
+		return nil, fmt.Errorf("webhook subscription updated but failed to fetch: %w", err)
+	}
+	return &abonnement, nil
+}
+
+func (r *WebhookRepo) DeleteAbonnement(ctx context.Context, id, boutiqueID string) (bool, error) {
+	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	result := r.db.WithContext(opCtx).Where("id = ? AND boutique_id = ?", id, boutiqueID).Delete(&models.AbonnementWebhook{})
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to delete webhook subscription: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// ------------------------------------------------------------
+// Livraisons
+// ------------------------------------------------------------
+func (r *WebhookRepo) CreateLivraison(ctx context.Context, livraison *models.LivraisonWebhook) (*models.LivraisonWebhook, error) {
+	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	if err := r.db.WithContext(opCtx).Create(livraison).Error; err != nil {
+		return nil, fmt.Errorf("failed to insert webhook delivery: %w", err)
+	}
+	return livraison, nil
+}
+
+func (r *WebhookRepo) ListLivraisons(ctx context.Context, abonnementID string, page, limite int) ([]models.LivraisonWebhook, error) {
+	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var livraisons []models.LivraisonWebhook
+	if err := r.db.WithContext(opCtx).Where("abonnement_id = ?", abonnementID).
+		Order("cree_le DESC").
+		Limit(limite).Offset((page - 1) * limite).
+		Find(&livraisons).Error; err != nil {
+		return nil, fmt.Errorf("find webhook deliveries failed: %w", err)
+	}
+	return livraisons, nil
+}
+
+func (r *WebhookRepo) GetLivraison(ctx context.Context, id, abonnementID string) (*models.LivraisonWebhook, error) {
+	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var livraison models.LivraisonWebhook
+	err := r.db.WithContext(opCtx).Where("id = ? AND abonnement_id = ?", id, abonnementID).First(&livraison).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error fetching webhook delivery: %w", err)
+	}
+	return &livraison, nil
+}
+
+func (r *WebhookRepo) UpdateLivraison(ctx context.Context, id string, updates map[string]interface{}) error {
+	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	if err := r.db.WithContext(opCtx).Model(&models.LivraisonWebhook{}).
+		Where("id = ?", id).
+		Updates(updates).Error; err != nil {
+		return fmt.Errorf("failed to update webhook delivery: %w", err)
+	}
+	return nil
+}
+
+// ReserverLivraisonsDues repousse à bail la prochaine tentative des livraisons
+// en_attente échues (ou sans date, laissées par une version précédente) et
+// les renvoie : SKIP LOCKED garantit qu'une livraison n'est prise que par un
+// seul processus, le bail qu'elle sera reprise si ce processus s'arrête.
+func (r *WebhookRepo) ReserverLivraisonsDues(ctx context.Context, maintenant, bail time.Time, limite int) ([]models.LivraisonWebhook, error) {
+	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var livraisons []models.LivraisonWebhook
+	err := r.db.WithContext(opCtx).Raw(`
+		UPDATE livraison_webhooks SET prochaine_tentative = ?, mis_a_jour_le = ?
+		WHERE id IN (
+			SELECT id FROM livraison_webhooks
+			WHERE statut = ? AND (prochaine_tentative IS NULL OR prochaine_tentative <= ?)
+			ORDER BY cree_le
+			LIMIT ?
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *`,
+		bail, maintenant, models.LivraisonEnAttente, maintenant, limite).
+		Scan(&livraisons).Error
+	if err != nil {
+		return nil, fmt.Errorf("reserve due webhook deliveries failed: %w", err)
+	}
+	return livraisons, nil
+}
+
+// GetAbonnementParID : pour le worker de livraison, qui n'a pas de boutique en contexte
+func (r *WebhookRepo) GetAbonnementParID(ctx context.Context, id string) (*models.AbonnementWebhook, error) {
+	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var abonnement models.AbonnementWebhook
+	err := r.db.WithContext(opCtx).Where("id = ?", id).First(&abonnement).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error fetching webhook subscription: %w", err)
+	}
+	return &abonnement, nil
+}
@@ -0,0 +1,20 @@
+package routes
+
+import (
+	handlers "projet/internal/handler"
+	"projet/internal/repository"
+	services "projet/internal/service"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+func RegisterCatalogueRoutes(app *fiber.App, db *gorm.DB, evenements services.PublieurEvenements) {
+	auditService := services.NewAuditService(repository.NewAuditRepo(db))
+	revisionService := services.NewRevisionService(repository.NewRevisionRepo(db))
+	produitService := services.NewProduitService(repository.NewRepo(db), evenements, auditService, revisionService)
+	catalogueHandler := handlers.NewCatalogueHandler(produitService)
+
+	catalogue := app.Group("/catalogue")
+	catalogue.Get("/lookup", catalogueHandler.Lookup)
+}
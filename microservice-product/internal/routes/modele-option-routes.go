@@ -0,0 +1,34 @@
+package routes
+
+import (
+	handlers "projet/internal/handler"
+	"projet/internal/repository"
+	services "projet/internal/service"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+func RegisterModeleOptionRoutes(app *fiber.App, db *gorm.DB) {
+	// Repositories
+	modeleRepo := repository.NewModeleOptionRepo(db)
+	optionRepo := repository.NewOptionProduitValeurRepo(db)
+
+	// Services
+	auditService := services.NewAuditService(repository.NewAuditRepo(db))
+	revisionService := services.NewRevisionService(repository.NewRevisionRepo(db))
+	optionService := services.NewOptionProduitService(optionRepo, auditService, revisionService)
+	modeleService := services.NewModeleOptionService(modeleRepo, optionService, auditService)
+
+	// Handlers
+	modeleHandler := handlers.NewModeleOptionHandler(modeleService)
+
+	modeles := app.Group("/modeles-options")
+	modeles.Post("/", modeleHandler.CreateModele)
+	modeles.Get("/", modeleHandler.ListModeles)
+	modeles.Get("/:id", modeleHandler.GetModele)
+	modeles.Put("/:id", modeleHandler.UpdateModele)
+	modeles.Delete("/:id", modeleHandler.DeleteModele)
+
+	app.Post("/produits/:produitId/options/depuis-modele", modeleHandler.AppliquerModele)
+}
@@ -13,8 +13,12 @@ func RegisterOptionRoutes(app *fiber.App, db *gorm.DB) {
 	// Repositories
 	optionRepo := repository.NewOptionProduitValeurRepo(db)
 
+	auditRepo := repository.NewAuditRepo(db)
+
 	// Services
-	optionService := services.NewOptionProduitService(optionRepo)
+	auditService := services.NewAuditService(auditRepo)
+	revisionService := services.NewRevisionService(repository.NewRevisionRepo(db))
+	optionService := services.NewOptionProduitService(optionRepo, auditService, revisionService)
 
 	// Handlers
 	optionHandler := handlers.NewOptionProduitHandler(optionService)
@@ -22,6 +26,7 @@ func RegisterOptionRoutes(app *fiber.App, db *gorm.DB) {
 	options := app.Group("/produits/:produitId/options")
 	options.Post("/", optionHandler.CreateOption)
 	options.Get("/", optionHandler.ListOptions)
+	options.Put("/ordre", optionHandler.ReordonnerOptions)
 
 	option := app.Group("/options/:optionId")
 	option.Get("/", optionHandler.GetOptionByID)
@@ -31,6 +36,7 @@ func RegisterOptionRoutes(app *fiber.App, db *gorm.DB) {
 	valeurs := app.Group("/options/:optionId/valeurs")
 	valeurs.Post("/", optionHandler.CreateValeur)
 	valeurs.Get("/", optionHandler.ListValeurs)
+	valeurs.Put("/ordre", optionHandler.ReordonnerValeurs)
 
 	valeur := app.Group("/valeurs/:valeurId")
 	valeur.Put("/", optionHandler.UpdateValeur)
@@ -9,16 +9,40 @@ import (
 	"gorm.io/gorm"
 )
 
-func RegisterProduitRoutes(app *fiber.App, db *gorm.DB) {
+func RegisterProduitRoutes(app *fiber.App, db *gorm.DB, evenements services.PublieurEvenements) {
 	repo := repository.NewRepo(db)
-	service := services.NewProduitService(repo)
-	handler := handlers.NewProduitHandler(service)
+	auditService := services.NewAuditService(repository.NewAuditRepo(db))
+	revisionService := services.NewRevisionService(repository.NewRevisionRepo(db))
+	service := services.NewProduitService(repo, evenements, auditService, revisionService)
+	tarifService := services.NewTarifService(repository.NewTarifRepo(db))
+	handler := handlers.NewProduitHandler(service, tarifService)
+	auditHandler := handlers.NewAuditHandler(auditService)
+	revisionHandler := handlers.NewRevisionHandler(revisionService, service)
+	varianteService := services.NewVarianteService(repository.NewVarianteRepo(db), evenements, auditService, revisionService)
+	masseHandler := handlers.NewMasseHandler(services.NewMasseService(service, varianteService))
 
 	produits := app.Group("/produits")
 	produits.Post("/", handler.CreateProduit)
 	produits.Get("/", handler.ListProduits)
 	produits.Get("/search", handler.SearchProduits)
+	produits.Post("/bulk", masseHandler.ExecuterMasse)
+	produits.Post("/regles-prix/apercu", masseHandler.ApercuReglePrix)
+	produits.Post("/regles-prix/appliquer", masseHandler.AppliquerReglePrix)
+	produits.Get("/regles-prix/ajustements", masseHandler.ListAjustements)
+	produits.Get("/regles-prix/ajustements/:id", masseHandler.GetAjustement)
+	produits.Get("/corbeille", handler.ListCorbeille)
+	produits.Post("/corbeille/:id/restaurer", handler.RestaurerCorbeille)
 	produits.Get("/:id", handler.GetProduitByID)
 	produits.Put("/:id", handler.UpdateProduit)
+	produits.Patch("/:id", handler.PatchProduit)
 	produits.Delete("/:id", handler.DeleteProduit)
+	produits.Post("/:id/publier", handler.PublierProduit)
+	produits.Post("/:id/archiver", handler.ArchiverProduit)
+	produits.Post("/:id/restaurer", handler.RestaurerProduit)
+	produits.Post("/:id/dupliquer", handler.DupliquerProduit)
+	produits.Get("/:id/historique", auditHandler.HistoriqueProduit)
+	produits.Get("/:id/revisions", revisionHandler.ListRevisions)
+	produits.Get("/:id/revisions/:n", revisionHandler.GetRevision)
+	produits.Get("/:id/revisions/:n/diff", revisionHandler.DiffRevisions)
+	produits.Post("/:id/revisions/:n/restaurer", revisionHandler.RestaurerRevision)
 }
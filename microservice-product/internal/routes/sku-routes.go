@@ -0,0 +1,23 @@
+package routes
+
+import (
+	handlers "projet/internal/handler"
+	"projet/internal/repository"
+	services "projet/internal/service"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+func RegisterSKURoutes(app *fiber.App, db *gorm.DB, evenements services.PublieurEvenements) {
+	auditService := services.NewAuditService(repository.NewAuditRepo(db))
+	revisionService := services.NewRevisionService(repository.NewRevisionRepo(db))
+	produitService := services.NewProduitService(repository.NewRepo(db), evenements, auditService, revisionService)
+	skuHandler := handlers.NewSKUHandler(produitService)
+
+	sku := app.Group("/sku")
+	sku.Get("/configuration", skuHandler.GetConfiguration)
+	sku.Put("/configuration", skuHandler.UpdateConfiguration)
+
+	app.Post("/produits/:produitId/variantes/generer-skus", skuHandler.GenererSKUs)
+}
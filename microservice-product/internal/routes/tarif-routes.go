@@ -0,0 +1,50 @@
+package routes
+
+import (
+	handlers "projet/internal/handler"
+	"projet/internal/repository"
+	services "projet/internal/service"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+func RegisterTarifRoutes(app *fiber.App, db *gorm.DB) {
+	tarifHandler := handlers.NewTarifHandler(services.NewTarifService(repository.NewTarifRepo(db)))
+
+	listes := app.Group("/listes-prix")
+	listes.Post("/", tarifHandler.CreateListe)
+	listes.Get("/", tarifHandler.ListListes)
+	listes.Get("/:id", tarifHandler.GetListe)
+	listes.Patch("/:id", tarifHandler.UpdateListe)
+	listes.Delete("/:id", tarifHandler.DeleteListe)
+	listes.Get("/:id/prix", tarifHandler.ListPrix)
+	listes.Put("/:id/prix", tarifHandler.DefinirPrix)
+
+	taux := app.Group("/taux-change")
+	taux.Get("/", tarifHandler.ListTaux)
+	taux.Put("/", tarifHandler.EnregistrerTaux)
+	taux.Delete("/:source/:cible", tarifHandler.SupprimerTaux)
+
+	groupes := app.Group("/groupes-clients")
+	groupes.Post("/", tarifHandler.CreateGroupe)
+	groupes.Get("/", tarifHandler.ListGroupes)
+	groupes.Get("/:id", tarifHandler.GetGroupe)
+	groupes.Patch("/:id", tarifHandler.UpdateGroupe)
+	groupes.Delete("/:id", tarifHandler.DeleteGroupe)
+	groupes.Put("/:id/regles", tarifHandler.DefinirRegles)
+
+	classes := app.Group("/classes-taxe")
+	classes.Post("/", tarifHandler.CreateClasseTaxe)
+	classes.Get("/", tarifHandler.ListClassesTaxe)
+	classes.Get("/:id", tarifHandler.GetClasseTaxe)
+	classes.Patch("/:id", tarifHandler.UpdateClasseTaxe)
+	classes.Delete("/:id", tarifHandler.DeleteClasseTaxe)
+	classes.Put("/:id/taux", tarifHandler.DefinirTauxTaxe)
+	app.Get("/taxes/parametres", tarifHandler.ParametresTaxe)
+	app.Put("/taxes/parametres", tarifHandler.ModifierParametresTaxe)
+
+	app.Get("/produits/:produitId/paliers", tarifHandler.ListPaliers)
+	app.Put("/produits/:produitId/paliers", tarifHandler.DefinirPaliers)
+	app.Post("/prix/calculer", tarifHandler.CalculerPrix)
+}
@@ -9,24 +9,31 @@ import (
 	"gorm.io/gorm"
 )
 
-func RegisterVarianteRoutes(app *fiber.App, db *gorm.DB) {
+func RegisterVarianteRoutes(app *fiber.App, db *gorm.DB, evenements services.PublieurEvenements) {
 	// Repositories
 	varianteRepo := repository.NewVarianteRepo(db)
 	produitRepo := repository.NewRepo(db)
+	auditRepo := repository.NewAuditRepo(db)
 
 	// Services
-	produitService := services.NewProduitService(produitRepo)
-	varianteService := services.NewVarianteService(varianteRepo)
+	auditService := services.NewAuditService(auditRepo)
+	revisionService := services.NewRevisionService(repository.NewRevisionRepo(db))
+	produitService := services.NewProduitService(produitRepo, evenements, auditService, revisionService)
+	varianteService := services.NewVarianteService(varianteRepo, evenements, auditService, revisionService)
+	tarifService := services.NewTarifService(repository.NewTarifRepo(db))
 
 	// Handlers
-	varianteHandler := handlers.NewVarianteHandler(varianteService, produitService)
+	varianteHandler := handlers.NewVarianteHandler(varianteService, produitService, tarifService)
 
 	variantes := app.Group("/produits/:produitId/variantes")
 	variantes.Post("/", varianteHandler.CreateVariante)
 	variantes.Get("/", varianteHandler.ListVariantes)
+	variantes.Get("/resoudre", varianteHandler.ResoudreVariante)
+	variantes.Get("/disponibilites", varianteHandler.DisponibilitesVariantes)
 
 	variante := app.Group("/variantes/:varianteId")
 	variante.Get("/", varianteHandler.GetVarianteByID)
 	variante.Put("/", varianteHandler.UpdateVariante)
+	variante.Patch("/", varianteHandler.PatchVariante)
 	variante.Delete("/", varianteHandler.DeleteVariante)
 }
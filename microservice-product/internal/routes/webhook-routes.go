@@ -0,0 +1,21 @@
+package routes
+
+import (
+	handlers "projet/internal/handler"
+	services "projet/internal/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func RegisterWebhookRoutes(app *fiber.App, webhookService *services.WebhookService) {
+	handler := handlers.NewWebhookHandler(webhookService)
+
+	webhooks := app.Group("/webhooks")
+	webhooks.Post("/", handler.CreateWebhook)
+	webhooks.Get("/", handler.ListWebhooks)
+	webhooks.Get("/:id", handler.GetWebhookByID)
+	webhooks.Put("/:id", handler.UpdateWebhook)
+	webhooks.Delete("/:id", handler.DeleteWebhook)
+	webhooks.Get("/:id/livraisons", handler.ListLivraisons)
+	webhooks.Post("/:id/livraisons/:livraisonId/relivrer", handler.RelivrerLivraison)
+}
@@ -0,0 +1,154 @@
+package service
+
+import (
+	"fmt"
+	"projet/internal/dto"
+	"projet/internal/gtin"
+	"projet/internal/models"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErreurAgregat regroupe les incohérences d'un produit créé avec ses options,
+// valeurs et variantes ; rien n'est écrit tant qu'il en reste une.
+type ErreurAgregat struct {
+	Problemes []string
+}
+
+func (e *ErreurAgregat) Error() string {
+	return "agrégat invalide: " + strings.Join(e.Problemes, ", ")
+}
+
+// construireAgregat complète produit avec les options, valeurs et variantes
+// de la requête. Les identifiants sont générés ici pour que les variantes
+// puissent pointer vers des valeurs qui n'existent pas encore en base.
+func construireAgregat(produit *models.Produit, options []dto.RequeteCreationOptionImbriquee, variantes []dto.RequeteCreationVarianteImbriquee) error {
+	problemes := []string{}
+	maintenant := time.Now()
+
+	// nom d'option (insensible à la casse) -> valeur -> modèle
+	valeursParOption := map[string]map[string]models.ValeurOption{}
+	nomsOptions := map[string]string{}
+
+	for i, opt := range options {
+		cle := strings.ToLower(strings.TrimSpace(opt.Nom))
+		if _, existe := valeursParOption[cle]; existe {
+			problemes = append(problemes, fmt.Sprintf("option %q en double", opt.Nom))
+			continue
+		}
+		optionID, err := nouvelIdentifiant()
+		if err != nil {
+			return err
+		}
+		position := opt.Position
+		if position == 0 {
+			position = i + 1
+		}
+		option := models.OptionProduit{
+			ID:         optionID,
+			Nom:        opt.Nom,
+			Position:   position,
+			CreeLe:     maintenant,
+			MisAJourLe: maintenant,
+		}
+
+		valeurs := map[string]models.ValeurOption{}
+		for j, val := range opt.Valeurs {
+			cleValeur := strings.ToLower(strings.TrimSpace(val.Valeur))
+			if _, existe := valeurs[cleValeur]; existe {
+				problemes = append(problemes, fmt.Sprintf("valeur %q en double pour l'option %q", val.Valeur, opt.Nom))
+				continue
+			}
+			valeurID, err := nouvelIdentifiant()
+			if err != nil {
+				return err
+			}
+			positionValeur := val.Position
+			if positionValeur == 0 {
+				positionValeur = j + 1
+			}
+			valeur := models.ValeurOption{ID: valeurID, OptionID: optionID, Valeur: val.Valeur, Position: positionValeur}
+			valeurs[cleValeur] = valeur
+			option.ValeurOpts = append(option.ValeurOpts, valeur)
+		}
+
+		valeursParOption[cle] = valeurs
+		nomsOptions[cle] = opt.Nom
+		produit.Options = append(produit.Options, option)
+	}
+
+	skus := map[string]bool{}
+	codes := map[string]bool{}
+	combinaisons := map[string]int{}
+	for i, v := range variantes {
+		if skus[v.SKU] {
+			problemes = append(problemes, fmt.Sprintf("variante %d: sku %q en double", i, v.SKU))
+		}
+		skus[v.SKU] = true
+
+		if v.CodeBarres != nil && *v.CodeBarres != "" {
+			if err := gtin.Valider(*v.CodeBarres); err != nil {
+				problemes = append(problemes, fmt.Sprintf("variante %d: code-barres %q: %v", i, *v.CodeBarres, err))
+			} else if cle := gtin.Normaliser(*v.CodeBarres); codes[cle] {
+				problemes = append(problemes, fmt.Sprintf("variante %d: code-barres %q en double", i, *v.CodeBarres))
+			} else {
+				codes[cle] = true
+			}
+		}
+
+		variante := models.Variante{
+			SKU:           v.SKU,
+			Prix:          v.Prix,
+			QuantiteStock: v.QuantiteStock,
+			CodeBarres:    codeBarresOuNul(v.CodeBarres),
+			Poids:         v.Poids,
+			Images:        v.Images,
+			CreeLe:        maintenant,
+			MisAJourLe:    maintenant,
+		}
+
+		// une variante choisit exactement une valeur par option
+		ids := []string{}
+		vues := map[string]bool{}
+		for nomOption, nomValeur := range v.Valeurs {
+			cle := strings.ToLower(strings.TrimSpace(nomOption))
+			valeurs, ok := valeursParOption[cle]
+			if !ok {
+				problemes = append(problemes, fmt.Sprintf("variante %d: option %q inconnue", i, nomOption))
+				continue
+			}
+			if vues[cle] {
+				problemes = append(problemes, fmt.Sprintf("variante %d: option %q répétée", i, nomOption))
+				continue
+			}
+			vues[cle] = true
+			valeur, ok := valeurs[strings.ToLower(strings.TrimSpace(nomValeur))]
+			if !ok {
+				problemes = append(problemes, fmt.Sprintf("variante %d: valeur %q inconnue pour l'option %q", i, nomValeur, nomOption))
+				continue
+			}
+			variante.ValeurOptions = append(variante.ValeurOptions, valeur)
+			ids = append(ids, valeur.ID)
+		}
+		for cle, nom := range nomsOptions {
+			if !vues[cle] {
+				problemes = append(problemes, fmt.Sprintf("variante %d: valeur manquante pour l'option %q", i, nom))
+			}
+		}
+
+		sort.Strings(ids)
+		signature := strings.Join(ids, ",")
+		if j, existe := combinaisons[signature]; existe && len(ids) > 0 {
+			problemes = append(problemes, fmt.Sprintf("variante %d: même combinaison que la variante %d", i, j))
+		}
+		combinaisons[signature] = i
+		produit.Variantes = append(produit.Variantes, variante)
+	}
+
+	if len(problemes) > 0 {
+		sort.Strings(problemes)
+		return &ErreurAgregat{Problemes: problemes}
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"projet/internal/dto"
+	"projet/internal/models"
+	"projet/internal/repository"
+	"reflect"
+)
+
+// champs jamais repris dans un diff : horodatages techniques et relations
+// (les enfants ont leurs propres entrées d'audit)
+var champsIgnoresAudit = map[string]bool{
+	"cree_le":        true,
+	"mis_a_jour_le":  true,
+	"options":        true,
+	"variantes":      true,
+	"valeur_opts":    true,
+	"valeur_options": true,
+}
+
+type AuditService struct {
+	repo *repository.AuditRepo
+}
+
+func NewAuditService(repo *repository.AuditRepo) *AuditService {
+	return &AuditService{repo: repo}
+}
+
+// Enregistrer ajoute une entrée au journal. avant vaut nil pour une création,
+// apres vaut nil pour une suppression. Une modification sans changement
+// effectif n'est pas journalisée. L'échec de l'audit est journalisé mais ne
+// fait pas échouer l'opération métier, déjà effectuée à ce stade.
+func (s *AuditService) Enregistrer(ctx context.Context, boutiqueID, produitID, typeEntite, entiteID string, action models.ActionAudit, avant, apres interface{}) {
+	if err := s.Journaliser(ctx, boutiqueID, produitID, typeEntite, entiteID, action, avant, apres); err != nil {
+		log.Printf("journal d'audit: %v", err)
+	}
+}
+
+// Dans renvoie le service d'audit écrivant via journal, un AuditRepo lié à
+// la transaction de l'opération (voir Journaliser)
+func (s *AuditService) Dans(journal *repository.AuditRepo) *AuditService {
+	if s == nil {
+		return nil
+	}
+	return &AuditService{repo: journal}
+}
+
+// Journaliser fait le travail d'Enregistrer mais renvoie l'erreur : appelé
+// dans la transaction de l'opération (Dans), un échec de l'audit l'annule
+func (s *AuditService) Journaliser(ctx context.Context, boutiqueID, produitID, typeEntite, entiteID string, action models.ActionAudit, avant, apres interface{}) error {
+	if s == nil {
+		return nil
+	}
+	if boutiqueID == "" {
+		boutiqueID = boutiqueDepuis(ctx)
+	}
+
+	diff, err := calculerDiff(avant, apres)
+	if err != nil {
+		return fmt.Errorf("audit %s %s: %w", typeEntite, entiteID, err)
+	}
+	if action == models.ActionModification && len(diff) == 0 {
+		return nil
+	}
+	contenu, err := json.Marshal(diff)
+	if err != nil {
+		return fmt.Errorf("audit %s %s: %w", typeEntite, entiteID, err)
+	}
+
+	entree := &models.JournalAudit{
+		BoutiqueID: boutiqueID,
+		Acteur:     acteurDepuis(ctx),
+		TypeEntite: typeEntite,
+		EntiteID:   entiteID,
+		Action:     action,
+		Diff:       string(contenu),
+	}
+	if produitID != "" {
+		entree.ProduitID = &produitID
+	}
+	if err := s.repo.Create(ctx, entree); err != nil {
+		return fmt.Errorf("audit %s %s: %w", typeEntite, entiteID, err)
+	}
+	return nil
+}
+
+// HistoriqueProduit pagine le journal d'un produit, du plus récent au plus ancien
+func (s *AuditService) HistoriqueProduit(ctx context.Context, produitID, boutiqueID string, page, limite int) ([]dto.JournalAuditResponse, int64, int, int, error) {
+	if boutiqueID == "" {
+		return nil, 0, 0, 0, errors.New("boutique ID is required")
+	}
+	if page <= 0 {
+		page = 1
+	}
+	if limite <= 0 {
+		limite = 20
+	}
+
+	entrees, total, err := s.repo.ListByProduit(ctx, produitID, boutiqueID, page, limite)
+	if err != nil {
+		return nil, 0, 0, 0, err
+	}
+
+	resp := make([]dto.JournalAuditResponse, len(entrees))
+	for i, e := range entrees {
+		resp[i] = dto.JournalAuditResponse{
+			ID:         e.ID,
+			BoutiqueID: e.BoutiqueID,
+			ProduitID:  e.ProduitID,
+			Acteur:     e.Acteur,
+			TypeEntite: e.TypeEntite,
+			EntiteID:   e.EntiteID,
+			Action:     e.Action,
+			Diff:       json.RawMessage(e.Diff),
+			CreeLe:     e.CreeLe,
+		}
+	}
+	return resp, total, page, limite, nil
+}
+
+// calculerDiff compare les représentations JSON de avant et apres champ par champ
+func calculerDiff(avant, apres interface{}) (map[string]dto.ChangementChamp, error) {
+	champsAvant, err := versChamps(avant)
+	if err != nil {
+		return nil, err
+	}
+	champsApres, err := versChamps(apres)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := make(map[string]dto.ChangementChamp)
+	for champ, valeur := range champsAvant {
+		if champsIgnoresAudit[champ] {
+			continue
+		}
+		if nouvelle, ok := champsApres[champ]; !ok || !reflect.DeepEqual(valeur, nouvelle) {
+			diff[champ] = dto.ChangementChamp{Avant: valeur, Apres: champsApres[champ]}
+		}
+	}
+	for champ, valeur := range champsApres {
+		if champsIgnoresAudit[champ] {
+			continue
+		}
+		if _, ok := champsAvant[champ]; !ok {
+			diff[champ] = dto.ChangementChamp{Avant: nil, Apres: valeur}
+		}
+	}
+	return diff, nil
+}
+
+func versChamps(v interface{}) (map[string]interface{}, error) {
+	champs := map[string]interface{}{}
+	if v == nil || (reflect.ValueOf(v).Kind() == reflect.Ptr && reflect.ValueOf(v).IsNil()) {
+		return champs, nil
+	}
+	contenu, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(contenu, &champs); err != nil {
+		return nil, err
+	}
+	return champs, nil
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"projet/internal/dto"
+	"projet/internal/gtin"
+	"projet/internal/models"
+	"strings"
+)
+
+// ErrCodeIntrouvable : aucun produit ni variante de la boutique ne porte ce code
+var ErrCodeIntrouvable = errors.New("aucun article pour ce code")
+
+// RechercherCode retrouve l'article scanné : SKU produit, SKU variante ou
+// code-barres (un UPC-A correspond à l'EAN-13 équivalent)
+func (s *ProduitService) RechercherCode(ctx context.Context, boutiqueID, code string) (*dto.ReponseRechercheCode, error) {
+	if boutiqueID == "" {
+		return nil, errors.New("boutique ID is required")
+	}
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return nil, errors.New("code requis")
+	}
+
+	correspondances, err := s.repo.ParCode(ctx, boutiqueID, code, gtin.Normaliser(code))
+	if err != nil {
+		return nil, err
+	}
+	if len(correspondances) == 0 {
+		return nil, ErrCodeIntrouvable
+	}
+
+	produits := map[string]*models.Produit{}
+	reponse := &dto.ReponseRechercheCode{Code: code}
+	for _, c := range correspondances {
+		produit, ok := produits[c.ProduitID]
+		if !ok {
+			if produit, err = s.repo.GetByID(ctx, c.ProduitID, boutiqueID); err != nil {
+				return nil, err
+			}
+			produits[c.ProduitID] = produit
+		}
+		if produit == nil {
+			continue
+		}
+
+		resultat := dto.CorrespondanceCatalogue{Champ: c.Champ, Produit: s.toResponse(*produit)}
+		if c.VarianteID != nil {
+			for _, v := range produit.Variantes {
+				if v.ID == *c.VarianteID {
+					variante := varianteVersResponse(v, tarifProduit(produit))
+					resultat.Variante = &variante
+					break
+				}
+			}
+		}
+		reponse.Resultats = append(reponse.Resultats, resultat)
+	}
+	if len(reponse.Resultats) == 0 {
+		return nil, ErrCodeIntrouvable
+	}
+	return reponse, nil
+}
@@ -0,0 +1,34 @@
+package service
+
+import "context"
+
+type cleContexte string
+
+const (
+	cleActeur   cleContexte = "acteur"
+	cleBoutique cleContexte = "boutique"
+
+	// acteur utilisé quand la requête ne précise pas X-User-ID
+	ActeurInconnu = "inconnu"
+	// acteur des modifications faites par le planificateur
+	ActeurPlanificateur = "planificateur"
+)
+
+// AvecActeur attache à ctx l'auteur de la requête et sa boutique, utilisés
+// par le journal d'audit (les services option/variante ne reçoivent pas la boutique).
+func AvecActeur(ctx context.Context, acteur, boutiqueID string) context.Context {
+	ctx = context.WithValue(ctx, cleActeur, acteur)
+	return context.WithValue(ctx, cleBoutique, boutiqueID)
+}
+
+func acteurDepuis(ctx context.Context) string {
+	if acteur, ok := ctx.Value(cleActeur).(string); ok && acteur != "" {
+		return acteur
+	}
+	return ActeurInconnu
+}
+
+func boutiqueDepuis(ctx context.Context) string {
+	boutiqueID, _ := ctx.Value(cleBoutique).(string)
+	return boutiqueID
+}
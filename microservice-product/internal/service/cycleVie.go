@@ -0,0 +1,147 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"projet/internal/dto"
+	"projet/internal/models"
+	"strings"
+	"time"
+)
+
+// ------------------------------------------------------------
+// Cycle de vie d'un produit
+//
+//	brouillon -> publie    (exigences de publication requises)
+//	brouillon -> archive
+//	publie    -> brouillon
+//	publie    -> archive
+//	archive   -> brouillon (un produit archivé repasse toujours par brouillon)
+//
+// ------------------------------------------------------------
+var transitionsStatut = map[models.StatutProduit][]models.StatutProduit{
+	models.StatutBrouillon: {models.StatutPublie, models.StatutArchive},
+	models.StatutPublie:    {models.StatutBrouillon, models.StatutArchive},
+	models.StatutArchive:   {models.StatutBrouillon},
+}
+
+// ErreurTransition est renvoyée quand un changement de statut est refusé,
+// soit parce que la transition n'existe pas, soit parce que des exigences
+// de publication ne sont pas remplies (ExigencesManquantes non vide).
+type ErreurTransition struct {
+	De                  models.StatutProduit
+	Vers                models.StatutProduit
+	ExigencesManquantes []string
+}
+
+func (e *ErreurTransition) Error() string {
+	if len(e.ExigencesManquantes) > 0 {
+		return fmt.Sprintf("publication impossible: %s", strings.Join(e.ExigencesManquantes, ", "))
+	}
+	return fmt.Sprintf("transition %s -> %s non autorisée", e.De, e.Vers)
+}
+
+// ExigencesPublication liste ce qui manque au produit pour être publié.
+// Les variantes doivent être chargées (Preload) sur p.
+func ExigencesPublication(p models.Produit) []string {
+	manquantes := []string{}
+	if strings.TrimSpace(p.Titre) == "" {
+		manquantes = append(manquantes, "titre requis")
+	}
+	if p.PrixDefaut <= 0 {
+		manquantes = append(manquantes, "prix_defaut doit être supérieur à 0")
+	}
+
+	if len(p.Images) == 0 && len(p.Variantes) == 0 {
+		manquantes = append(manquantes, "au moins une image ou une variante requise")
+	}
+	return manquantes
+}
+
+// produitApres : avant tel qu'il sera après la mise à jour partielle req, sur
+// les champs qu'évaluent les exigences de publication (PUT /produits/:id et
+// l'action "modifier" des opérations en masse)
+func produitApres(avant models.Produit, req dto.RequeteUpdateProduit) models.Produit {
+	apres := avant
+	if req.Titre != nil {
+		apres.Titre = *req.Titre
+	}
+	if req.PrixDefaut != nil {
+		apres.PrixDefaut = *req.PrixDefaut
+	}
+	if req.Images != nil {
+		apres.Images = req.Images
+	}
+	return apres
+}
+
+// verifierTransition valide le passage de p.Statut vers vers.
+// Rester dans le même statut est toujours permis.
+func verifierTransition(p models.Produit, vers models.StatutProduit) error {
+	if p.Statut == vers {
+		return nil
+	}
+
+	autorisee := false
+	for _, s := range transitionsStatut[p.Statut] {
+		if s == vers {
+			autorisee = true
+			break
+		}
+	}
+	if !autorisee {
+		return &ErreurTransition{De: p.Statut, Vers: vers}
+	}
+
+	if vers == models.StatutPublie {
+		if manquantes := ExigencesPublication(p); len(manquantes) > 0 {
+			return &ErreurTransition{De: p.Statut, Vers: vers, ExigencesManquantes: manquantes}
+		}
+	}
+	return nil
+}
+
+// ------------------------------------------------------------
+// Programmation (date_publication / date_depublication)
+//
+// Seuls les produits dont une date a été posée par l'API depuis l'arrivée
+// du planificateur (colonne programme) sont publiés ou archivés
+// automatiquement : les dates plus anciennes, déjà présentes en base, ne
+// déclenchent rien tant qu'elles ne sont pas réenregistrées.
+// ------------------------------------------------------------
+
+// ErrDatesPublication : la dépublication ne suit pas la publication
+var ErrDatesPublication = errors.New("dates de publication invalides")
+
+func verifierDatesPublication(publication, depublication *time.Time) error {
+	if publication != nil && depublication != nil && !depublication.After(*publication) {
+		return fmt.Errorf("%w: date_depublication doit suivre date_publication", ErrDatesPublication)
+	}
+	return nil
+}
+
+// preparerProgrammation vérifie les dates après application des
+// modifications et marque le produit comme programmé dès qu'une date est posée
+func preparerProgrammation(avant models.Produit, modifications map[string]interface{}) error {
+	publication, depublication := avant.DatePublication, avant.DateDepublication
+	posee := false
+	date := func(colonne string, cible **time.Time) {
+		switch v := modifications[colonne].(type) {
+		case time.Time:
+			*cible, posee = &v, true
+		case *time.Time:
+			*cible = v
+			posee = posee || v != nil
+		}
+	}
+	date("date_publication", &publication)
+	date("date_depublication", &depublication)
+
+	if err := verifierDatesPublication(publication, depublication); err != nil {
+		return err
+	}
+	if posee {
+		modifications["programme"] = true
+	}
+	return nil
+}
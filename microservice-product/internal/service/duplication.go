@@ -0,0 +1,205 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"projet/internal/dto"
+	"projet/internal/models"
+	"time"
+)
+
+const (
+	suffixeSlugCopie = "-copie"
+	suffixeSKUCopie  = "-COPIE"
+	// au-delà, on considère que le marchand a un problème de nommage
+	tentativesSuffixe = 50
+)
+
+// Dupliquer copie le produit avec ses options, valeurs et variantes. La copie
+// repart en brouillon, sans programmation, avec un slug et des SKU inédits ;
+// les codes-barres identifient l'article d'origine et ne sont pas repris.
+func (s *ProduitService) Dupliquer(ctx context.Context, id, boutiqueID string, req dto.RequeteDuplication) (*dto.ProduitResponse, error) {
+	if boutiqueID == "" {
+		return nil, errors.New("boutique ID is required")
+	}
+
+	source, err := s.repo.GetByID(ctx, id, boutiqueID)
+	if err != nil {
+		return nil, err
+	}
+	if source == nil {
+		return nil, errors.New("product not found")
+	}
+
+	cible := boutiqueID
+	if req.BoutiqueID != nil && *req.BoutiqueID != "" {
+		cible = *req.BoutiqueID
+	}
+	titre := source.Titre + " (copie)"
+	if req.Titre != nil {
+		titre = *req.Titre
+	}
+	suffixe := suffixeSKUCopie
+	if req.SuffixeSKU != nil {
+		suffixe = *req.SuffixeSKU
+	}
+
+	slug, err := s.slugLibre(ctx, source.Slug+suffixeSlugCopie)
+	if err != nil {
+		return nil, err
+	}
+	suffixe, err = s.suffixeSKULibre(ctx, cible, source, suffixe)
+	if err != nil {
+		return nil, err
+	}
+
+	maintenant := time.Now()
+	copie := &models.Produit{
+		BoutiqueID:    cible,
+		Titre:         titre,
+		Description:   source.Description,
+		Slug:          slug,
+		Statut:        models.StatutBrouillon,
+		PrixDefaut:    source.PrixDefaut,
+		Devise:        source.Devise,
+		SuiviStock:    source.SuiviStock,
+		QuantiteStock: source.QuantiteStock,
+		Poids:         source.Poids,
+		Dimensions:    source.Dimensions,
+		Marque:        source.Marque,
+		ClasseTaxe:    source.ClasseTaxe,
+		Visibilite:    source.Visibilite,
+		Images:        source.Images,
+		Promotion:     source.Promotion,
+	}
+	if source.SKU != nil {
+		sku := *source.SKU + suffixe
+		copie.SKU = &sku
+	}
+	// les classes de taxe sont propres à la boutique : gardée si la cible a la même
+	if cible != boutiqueID {
+		if err := verifierClasseTaxe(ctx, s.repo, cible, copie.ClasseTaxe); errors.Is(err, ErrClasseTaxe) {
+			copie.ClasseTaxe = nil
+		} else if err != nil {
+			return nil, err
+		}
+	}
+
+	// ancien ID de valeur -> nouvelle valeur, pour recâbler les variantes
+	valeurs := map[string]models.ValeurOption{}
+	for _, opt := range source.Options {
+		optionID, err := nouvelIdentifiant()
+		if err != nil {
+			return nil, err
+		}
+		option := models.OptionProduit{
+			ID:         optionID,
+			Nom:        opt.Nom,
+			Position:   opt.Position,
+			CreeLe:     maintenant,
+			MisAJourLe: maintenant,
+		}
+		// les modèles d'options sont propres à la boutique : lien gardé seulement chez elle
+		if cible == boutiqueID {
+			option.ModeleID = opt.ModeleID
+		}
+		for _, val := range opt.ValeurOpts {
+			valeurID, err := nouvelIdentifiant()
+			if err != nil {
+				return nil, err
+			}
+			nouvelle := models.ValeurOption{
+				ID:          valeurID,
+				OptionID:    optionID,
+				Valeur:      val.Valeur,
+				Position:    val.Position,
+				CouleurHex:  val.CouleurHex,
+				ImageSwatch: val.ImageSwatch,
+				Code:        val.Code,
+				Libelles:    val.Libelles,
+			}
+			if cible == boutiqueID {
+				nouvelle.ValeurModeleID = val.ValeurModeleID
+			}
+			valeurs[val.ID] = nouvelle
+			option.ValeurOpts = append(option.ValeurOpts, nouvelle)
+		}
+		copie.Options = append(copie.Options, option)
+	}
+
+	for _, v := range source.Variantes {
+		variante := models.Variante{
+			SKU:           v.SKU + suffixe,
+			Prix:          v.Prix,
+			QuantiteStock: v.QuantiteStock,
+			Poids:         v.Poids,
+			Images:        append([]string(nil), v.Images...),
+			Promotion:     v.Promotion,
+			CreeLe:        maintenant,
+			MisAJourLe:    maintenant,
+		}
+		for _, vo := range v.ValeurOptions {
+			nouvelle, ok := valeurs[vo.ID]
+			if !ok {
+				return nil, fmt.Errorf("variante %s: valeur %s hors du produit", v.ID, vo.ID)
+			}
+			variante.ValeurOptions = append(variante.ValeurOptions, nouvelle)
+		}
+		copie.Variantes = append(copie.Variantes, variante)
+	}
+
+	creee, err := s.repo.CreerAgregat(ctx, copie)
+	if err != nil {
+		return nil, err
+	}
+	s.auditerCreation(ctx, creee)
+
+	resp := s.toResponse(*creee)
+	return &resp, nil
+}
+
+// slugLibre renvoie base, ou base-2, base-3... selon la première disponible
+func (s *ProduitService) slugLibre(ctx context.Context, base string) (string, error) {
+	for n := 1; n <= tentativesSuffixe; n++ {
+		candidat := base
+		if n > 1 {
+			candidat = fmt.Sprintf("%s-%d", base, n)
+		}
+		libre, err := s.repo.SlugDisponible(ctx, candidat)
+		if err != nil {
+			return "", err
+		}
+		if libre {
+			return candidat, nil
+		}
+	}
+	return "", fmt.Errorf("aucun slug libre pour %q", base)
+}
+
+// suffixeSKULibre choisit un suffixe (suffixe, suffixe2, ...) qui ne crée
+// aucune collision avec les SKU de produits et variantes de la boutique cible
+func (s *ProduitService) suffixeSKULibre(ctx context.Context, cible string, source *models.Produit, suffixe string) (string, error) {
+	origine := skusAgregat(source)
+	if len(origine) == 0 {
+		return suffixe, nil
+	}
+	for n := 1; n <= tentativesSuffixe; n++ {
+		candidat := suffixe
+		if n > 1 {
+			candidat = fmt.Sprintf("%s%d", suffixe, n)
+		}
+		skus := make([]string, len(origine))
+		for i, sku := range origine {
+			skus[i] = sku + candidat
+		}
+		pris, err := s.repo.SKUsPris(ctx, cible, skus, "", "")
+		if err != nil {
+			return "", err
+		}
+		if len(pris) == 0 {
+			return candidat, nil
+		}
+	}
+	return "", fmt.Errorf("%w: aucun suffixe libre pour %q", ErrSKUPris, suffixe)
+}
@@ -0,0 +1,370 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"projet/internal/dto"
+	"projet/internal/models"
+	"projet/internal/monnaie"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrGroupeIntrouvable : groupe absent ou d'une autre boutique
+	ErrGroupeIntrouvable = errors.New("groupe de clients non trouvé")
+	// ErrRegleGroupe : cible inconnue, ambiguë ou règle sans effet
+	ErrRegleGroupe = errors.New("règle de groupe invalide")
+)
+
+// ------------------------------------------------------------
+// Convertisseurs
+// ------------------------------------------------------------
+func groupeVersResponse(g models.GroupeClient) dto.GroupeClientResponse {
+	resp := dto.GroupeClientResponse{
+		ID:         g.ID,
+		Code:       g.Code,
+		Nom:        g.Nom,
+		CreeLe:     g.CreeLe,
+		MisAJourLe: g.MisAJourLe,
+	}
+	for _, r := range g.Regles {
+		resp.Regles = append(resp.Regles, dto.RegleGroupeResponse{
+			ProduitID:   r.ProduitID,
+			VarianteID:  r.VarianteID,
+			Marque:      r.Marque,
+			Montant:     r.Montant,
+			Pourcentage: r.Pourcentage,
+		})
+	}
+	return resp
+}
+
+// ------------------------------------------------------------
+// Groupes de clients
+// ------------------------------------------------------------
+func (s *TarifService) CreateGroupe(ctx context.Context, boutiqueID string, req dto.RequeteCreationGroupe) (*dto.GroupeClientResponse, error) {
+	if boutiqueID == "" {
+		return nil, errors.New("boutique ID is required")
+	}
+	groupe, err := s.repo.CreateGroupe(ctx, &models.GroupeClient{
+		BoutiqueID: boutiqueID,
+		Code:       strings.ToLower(req.Code),
+		Nom:        req.Nom,
+	})
+	if err != nil {
+		return nil, err
+	}
+	resp := groupeVersResponse(*groupe)
+	return &resp, nil
+}
+
+func (s *TarifService) ListGroupes(ctx context.Context, boutiqueID string) ([]dto.GroupeClientResponse, error) {
+	if boutiqueID == "" {
+		return nil, errors.New("boutique ID is required")
+	}
+	groupes, err := s.repo.ListGroupes(ctx, boutiqueID)
+	if err != nil {
+		return nil, err
+	}
+	resp := make([]dto.GroupeClientResponse, len(groupes))
+	for i, g := range groupes {
+		resp[i] = groupeVersResponse(g)
+	}
+	return resp, nil
+}
+
+func (s *TarifService) GetGroupe(ctx context.Context, id, boutiqueID string) (*dto.GroupeClientResponse, error) {
+	groupe, err := s.groupe(ctx, id, boutiqueID)
+	if err != nil {
+		return nil, err
+	}
+	resp := groupeVersResponse(*groupe)
+	return &resp, nil
+}
+
+func (s *TarifService) UpdateGroupe(ctx context.Context, id, boutiqueID string, req dto.RequeteUpdateGroupe) (*dto.GroupeClientResponse, error) {
+	if boutiqueID == "" {
+		return nil, errors.New("boutique ID is required")
+	}
+	updates := map[string]interface{}{"mis_a_jour_le": time.Now()}
+	if req.Nom != nil {
+		updates["nom"] = *req.Nom
+	}
+	groupe, err := s.repo.UpdateGroupe(ctx, id, boutiqueID, updates)
+	if err != nil {
+		return nil, err
+	}
+	if groupe == nil {
+		return nil, ErrGroupeIntrouvable
+	}
+	resp := groupeVersResponse(*groupe)
+	return &resp, nil
+}
+
+func (s *TarifService) DeleteGroupe(ctx context.Context, id, boutiqueID string) error {
+	if boutiqueID == "" {
+		return errors.New("boutique ID is required")
+	}
+	supprime, err := s.repo.DeleteGroupe(ctx, id, boutiqueID)
+	if err != nil {
+		return err
+	}
+	if !supprime {
+		return ErrGroupeIntrouvable
+	}
+	return nil
+}
+
+// DefinirRegles remplace les règles du groupe. Une règle vise au plus une
+// cible (variante avec son produit, produit, ou marque) ; un prix imposé
+// n'a de sens que pour un produit ou une variante, les montants sont
+// arrondis à la devise du produit
+func (s *TarifService) DefinirRegles(ctx context.Context, id, boutiqueID string, req dto.RequeteReglesGroupe) (*dto.GroupeClientResponse, error) {
+	groupe, err := s.groupe(ctx, id, boutiqueID)
+	if err != nil {
+		return nil, err
+	}
+
+	produitIDs := []string{}
+	for _, r := range req.Regles {
+		if r.ProduitID != nil {
+			produitIDs = append(produitIDs, *r.ProduitID)
+		}
+	}
+	produits, err := s.repo.ProduitsTarifes(ctx, boutiqueID, produitIDs)
+	if err != nil {
+		return nil, err
+	}
+	devises := map[string]string{}
+	for _, p := range produits {
+		devises[p.ID] = p.Devise
+	}
+	references, err := s.repo.ReferencesCatalogue(ctx, boutiqueID, produitIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	problemes := []string{}
+	vues := map[string]bool{}
+	regles := make([]models.RegleGroupe, len(req.Regles))
+	for i, r := range req.Regles {
+		switch {
+		case (r.Montant == nil) == (r.Pourcentage == nil):
+			problemes = append(problemes, fmt.Sprintf("règle %d: montant ou pourcentage requis (l'un des deux)", i))
+		case r.Marque != nil && r.ProduitID != nil:
+			problemes = append(problemes, fmt.Sprintf("règle %d: marque et produit_id exclusifs", i))
+		case r.VarianteID != nil && r.ProduitID == nil:
+			problemes = append(problemes, fmt.Sprintf("règle %d: produit_id requis avec variante_id", i))
+		case r.Montant != nil && r.ProduitID == nil:
+			problemes = append(problemes, fmt.Sprintf("règle %d: un montant vise un produit ou une variante", i))
+		}
+
+		cle := "*"
+		switch {
+		case r.VarianteID != nil:
+			cle = "v:" + *r.VarianteID
+		case r.ProduitID != nil:
+			cle = "p:" + *r.ProduitID
+		case r.Marque != nil:
+			cle = "m:" + strings.ToLower(*r.Marque)
+		}
+		if vues[cle] {
+			problemes = append(problemes, fmt.Sprintf("règle %d: cible en double", i))
+		}
+		vues[cle] = true
+
+		regles[i] = models.RegleGroupe{ProduitID: r.ProduitID, VarianteID: r.VarianteID, Marque: r.Marque, Pourcentage: r.Pourcentage}
+		if r.ProduitID != nil {
+			variantes, ok := references[*r.ProduitID]
+			switch {
+			case !ok:
+				problemes = append(problemes, fmt.Sprintf("règle %d: produit %s inconnu", i, *r.ProduitID))
+				continue
+			case r.VarianteID != nil && !variantes[*r.VarianteID]:
+				problemes = append(problemes, fmt.Sprintf("règle %d: variante %s hors du produit", i, *r.VarianteID))
+			}
+			regles[i].Montant = arrondirMontant(r.Montant, devises[*r.ProduitID])
+		}
+	}
+	if len(problemes) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrRegleGroupe, strings.Join(problemes, ", "))
+	}
+
+	if err := s.repo.RemplacerRegles(ctx, groupe.ID, regles); err != nil {
+		return nil, err
+	}
+	return s.GetGroupe(ctx, id, boutiqueID)
+}
+
+func (s *TarifService) groupe(ctx context.Context, id, boutiqueID string) (*models.GroupeClient, error) {
+	if boutiqueID == "" {
+		return nil, errors.New("boutique ID is required")
+	}
+	groupe, err := s.repo.GetGroupe(ctx, id, boutiqueID)
+	if err != nil {
+		return nil, err
+	}
+	if groupe == nil {
+		return nil, ErrGroupeIntrouvable
+	}
+	return groupe, nil
+}
+
+// ------------------------------------------------------------
+// Prix du groupe
+// ------------------------------------------------------------
+
+// reglesGroupe indexe les règles d'un groupe par cible
+type reglesGroupe struct {
+	code      string
+	variantes map[string]*models.RegleGroupe
+	produits  map[string]*models.RegleGroupe
+	marques   map[string]*models.RegleGroupe // marque en minuscules
+	catalogue *models.RegleGroupe
+}
+
+// reglesDuGroupe : règles du groupe désigné par son code ; nil sans code ou
+// pour un code inconnu de la boutique (prix publics)
+func (s *TarifService) reglesDuGroupe(ctx context.Context, boutiqueID, code string) (*reglesGroupe, error) {
+	if code == "" {
+		return nil, nil
+	}
+	groupe, err := s.repo.GroupeParCode(ctx, boutiqueID, strings.ToLower(code))
+	if err != nil || groupe == nil {
+		return nil, err
+	}
+	r := &reglesGroupe{
+		code:      groupe.Code,
+		variantes: map[string]*models.RegleGroupe{},
+		produits:  map[string]*models.RegleGroupe{},
+		marques:   map[string]*models.RegleGroupe{},
+	}
+	for i := range groupe.Regles {
+		regle := &groupe.Regles[i]
+		switch {
+		case regle.VarianteID != nil:
+			r.variantes[*regle.VarianteID] = regle
+		case regle.ProduitID != nil:
+			r.produits[*regle.ProduitID] = regle
+		case regle.Marque != nil:
+			r.marques[strings.ToLower(*regle.Marque)] = regle
+		default:
+			r.catalogue = regle
+		}
+	}
+	return r, nil
+}
+
+// pour : la règle la plus précise (variante, produit, marque, catalogue)
+func (r *reglesGroupe) pour(produitID, varianteID string, marque *string) *models.RegleGroupe {
+	if regle, ok := r.variantes[varianteID]; ok && varianteID != "" {
+		return regle
+	}
+	if regle, ok := r.produits[produitID]; ok {
+		return regle
+	}
+	if marque != nil {
+		if regle, ok := r.marques[strings.ToLower(*marque)]; ok {
+			return regle
+		}
+	}
+	return r.catalogue
+}
+
+// prixGroupe : prix imposé, sinon prix régulier corrigé du pourcentage
+func prixGroupe(regle *models.RegleGroupe, regulier monnaie.Prix) monnaie.Prix {
+	if regle.Montant != nil {
+		return monnaie.NouveauPrix(*regle.Montant, regulier.Devise)
+	}
+	return monnaie.NouveauPrix(regulier.Montant.AppliquerPourcentage(*regle.Pourcentage, regulier.Devise), regulier.Devise)
+}
+
+// affichageGroupe : le prix du groupe remplace le prix régulier ; une
+// promotion publique active plus avantageuse reste appliquée. Le prix
+// d'origine est le prix public (ou le prix barré s'il est plus haut).
+func affichageGroupe(public, groupe monnaie.Prix, promo models.Promotion, t time.Time) affichagePrix {
+	affichage := calculerAffichage(public, promo, t)
+	if affichage.active && affichage.effectif.Montant <= groupe.Montant {
+		return affichage
+	}
+
+	affichage = affichagePrix{effectif: groupe}
+	reference := public.Montant
+	if promo.PrixBarre != nil && *promo.PrixBarre > reference {
+		reference = *promo.PrixBarre
+	}
+	if reference > groupe.Montant {
+		origine := monnaie.NouveauPrix(reference, public.Devise)
+		remise := remisePourcentage(reference, groupe.Montant)
+		affichage.origine, affichage.remise = &origine, &remise
+	}
+	return affichage
+}
+
+// paliersGroupe : une règle en pourcentage s'applique aussi aux paliers ;
+// un prix imposé les laisse tels quels (le moins cher l'emporte au calcul)
+func paliersGroupe(regle *models.RegleGroupe, paliers []dto.PalierResponse) {
+	if regle.Pourcentage == nil {
+		return
+	}
+	for i := range paliers {
+		prix := paliers[i].PrixUnitaire
+		paliers[i].PrixUnitaire = monnaie.NouveauPrix(prix.Montant.AppliquerPourcentage(*regle.Pourcentage, prix.Devise), prix.Devise)
+	}
+}
+
+// promotionReponse : promotion telle qu'exposée dans une réponse
+func promotionReponse(barre, promo *monnaie.Prix, debut, fin *time.Time) models.Promotion {
+	p := models.Promotion{DebutPromo: debut, FinPromo: fin}
+	if barre != nil {
+		p.PrixBarre = &barre.Montant
+	}
+	if promo != nil {
+		p.PrixPromo = &promo.Montant
+	}
+	return p
+}
+
+// appliquerProduit réécrit les prix du produit et de ses variantes (réponses
+// aux prix publics, paliers compris)
+func (r *reglesGroupe) appliquerProduit(p *dto.ProduitResponse, t time.Time) {
+	tarif := TarifProduit{Prix: p.PrixDefaut, Promotion: promotionReponse(p.PrixBarre, p.PrixPromo, p.DebutPromo, p.FinPromo)}
+	if regle := r.pour(p.ID, "", p.Marque); regle != nil {
+		groupe := prixGroupe(regle, p.PrixDefaut)
+		affichage := affichageGroupe(p.PrixDefaut, groupe, tarif.Promotion, t)
+		p.PrixDefaut = groupe
+		p.PrixEffectif, p.PrixOrigine = &affichage.effectif, affichage.origine
+		p.RemisePourcentage, p.PromoActive = affichage.remise, affichage.active
+		paliersGroupe(regle, p.Paliers)
+		p.GroupeClient = r.code
+	}
+	r.appliquerVariantes(tarif, p.ID, p.Marque, p.Variantes, t)
+}
+
+// appliquerVariantes : variantes d'un produit, tarif = prix public du produit
+func (r *reglesGroupe) appliquerVariantes(tarif TarifProduit, produitID string, marque *string, variantes []dto.VarianteResponse, t time.Time) {
+	for i := range variantes {
+		v := &variantes[i]
+		regle := r.pour(produitID, v.ID, marque)
+		if regle == nil {
+			continue
+		}
+		regulier, promotion := tarif.Prix, promotionReponse(v.PrixBarre, v.PrixPromo, v.DebutPromo, v.FinPromo)
+		if v.Prix != nil {
+			regulier = *v.Prix
+		} else if promotion.Vide() {
+			promotion = tarif.Promotion
+		}
+		groupe := prixGroupe(regle, regulier)
+		affichage := affichageGroupe(regulier, groupe, promotion, t)
+		if v.Prix != nil {
+			v.Prix = &groupe
+		}
+		v.PrixEffectif, v.PrixOrigine = affichage.effectif, affichage.origine
+		v.RemisePourcentage, v.PromoActive = affichage.remise, affichage.active
+		paliersGroupe(regle, v.Paliers)
+		v.GroupeClient = r.code
+	}
+}
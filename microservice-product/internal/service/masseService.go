@@ -0,0 +1,421 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"projet/internal/dto"
+	"projet/internal/models"
+	"projet/internal/repository"
+	"time"
+)
+
+const tailleLotDefaut = 100
+
+// MasseService exécute des lots d'opérations sur produits et variantes.
+// Les écritures passent par des transactions ; audit, révisions et webhooks
+// ne sont émis qu'une fois la transaction validée.
+type MasseService struct {
+	produits  *ProduitService
+	variantes *VarianteService
+}
+
+func NewMasseService(produits *ProduitService, variantes *VarianteService) *MasseService {
+	return &MasseService{produits: produits, variantes: variantes}
+}
+
+// erreurOperation marque l'échec d'une opération métier (par opposition à
+// une panne de la base) ; en mode tout_ou_rien elle annule la transaction
+type erreurOperation struct {
+	index int
+	err   error
+}
+
+func (e *erreurOperation) Error() string { return e.err.Error() }
+
+// ------------------------------------------------------------
+// Exécuter un lot
+// ------------------------------------------------------------
+func (s *MasseService) Executer(ctx context.Context, boutiqueID string, req dto.RequeteMasse) (*dto.ReponseMasse, error) {
+	if boutiqueID == "" {
+		return nil, errors.New("boutique ID is required")
+	}
+
+	reponse := &dto.ReponseMasse{
+		Total:      len(req.Operations),
+		ToutOuRien: req.ToutOuRien,
+		Resultats:  make([]dto.ResultatOperationMasse, len(req.Operations)),
+	}
+	for i, op := range req.Operations {
+		reponse.Resultats[i] = dto.ResultatOperationMasse{Index: i, Cible: op.Cible, ID: op.ID, Action: op.Action}
+	}
+
+	if req.ToutOuRien {
+		if err := s.executerToutOuRien(ctx, boutiqueID, req.Operations, reponse); err != nil {
+			return nil, err
+		}
+	} else {
+		taille := req.TailleLot
+		if taille <= 0 {
+			taille = tailleLotDefaut
+		}
+		for debut := 0; debut < len(req.Operations); debut += taille {
+			fin := debut + taille
+			if fin > len(req.Operations) {
+				fin = len(req.Operations)
+			}
+			s.executerLot(ctx, boutiqueID, req.Operations, debut, fin, reponse)
+		}
+	}
+
+	for _, r := range reponse.Resultats {
+		if r.Succes {
+			reponse.Reussies++
+		} else {
+			reponse.Echouees++
+		}
+	}
+	return reponse, nil
+}
+
+// executerToutOuRien : une seule transaction, la première erreur annule tout
+func (s *MasseService) executerToutOuRien(ctx context.Context, boutiqueID string, ops []dto.OperationMasse, reponse *dto.ReponseMasse) error {
+	var suivis []func()
+	err := s.produits.repo.Transaction(ctx, func(produits *repository.ProduitRepo, variantes *repository.VarianteRepo, journal *repository.AuditRepo) error {
+		for i, op := range ops {
+			version, suivi, err := s.executer(ctx, produits, variantes, journal, boutiqueID, op)
+			if err != nil {
+				return &erreurOperation{index: i, err: err}
+			}
+			reponse.Resultats[i].Succes = true
+			reponse.Resultats[i].Version = version
+			suivis = append(suivis, suivi)
+		}
+		return nil
+	})
+
+	var echec *erreurOperation
+	if errors.As(err, &echec) {
+		reponse.Annulee = true
+		for i := range reponse.Resultats {
+			reponse.Resultats[i].Succes = false
+			reponse.Resultats[i].Version = 0
+			if i == echec.index {
+				reponse.Resultats[i].Erreur = echec.Error()
+			} else {
+				reponse.Resultats[i].Erreur = fmt.Sprintf("annulée (échec de l'opération %d)", echec.index)
+			}
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, suivi := range suivis {
+		suivi()
+	}
+	return nil
+}
+
+// executerLot : une transaction par lot, un SAVEPOINT par opération pour
+// qu'un échec isolé n'annule pas les autres opérations du lot
+func (s *MasseService) executerLot(ctx context.Context, boutiqueID string, ops []dto.OperationMasse, debut, fin int, reponse *dto.ReponseMasse) {
+	var suivis []func()
+	err := s.produits.repo.Transaction(ctx, func(produits *repository.ProduitRepo, _ *repository.VarianteRepo, _ *repository.AuditRepo) error {
+		for i := debut; i < fin; i++ {
+			var (
+				version int
+				suivi   func()
+			)
+			err := produits.Transaction(ctx, func(p *repository.ProduitRepo, v *repository.VarianteRepo, journal *repository.AuditRepo) error {
+				var err error
+				version, suivi, err = s.executer(ctx, p, v, journal, boutiqueID, ops[i])
+				return err
+			})
+			if err != nil {
+				reponse.Resultats[i].Erreur = err.Error()
+				continue
+			}
+			reponse.Resultats[i].Succes = true
+			reponse.Resultats[i].Version = version
+			suivis = append(suivis, suivi)
+		}
+		return nil
+	})
+
+	// échec du COMMIT : rien de ce lot n'a été écrit
+	if err != nil {
+		for i := debut; i < fin; i++ {
+			if reponse.Resultats[i].Succes {
+				reponse.Resultats[i].Succes = false
+				reponse.Resultats[i].Version = 0
+				reponse.Resultats[i].Erreur = "lot annulé: " + err.Error()
+			}
+		}
+		return
+	}
+	for _, suivi := range suivis {
+		suivi()
+	}
+}
+
+// executer applique une opération avec les repos de la transaction courante,
+// audit compris (journal). Renvoie la nouvelle version et les effets à
+// déclencher après le commit.
+func (s *MasseService) executer(ctx context.Context, produits *repository.ProduitRepo, variantes *repository.VarianteRepo, journal *repository.AuditRepo, boutiqueID string, op dto.OperationMasse) (int, func(), error) {
+	switch op.Cible {
+	case dto.CibleProduit:
+		return s.executerProduit(ctx, produits, s.produits.audit.Dans(journal), boutiqueID, op)
+	case dto.CibleVariante:
+		return s.executerVariante(ctx, produits, variantes, s.variantes.audit.Dans(journal), boutiqueID, op)
+	}
+	return 0, nil, fmt.Errorf("cible inconnue %q", op.Cible)
+}
+
+// ------------------------------------------------------------
+// Produits
+// ------------------------------------------------------------
+func (s *MasseService) executerProduit(ctx context.Context, repo *repository.ProduitRepo, audit *AuditService, boutiqueID string, op dto.OperationMasse) (int, func(), error) {
+	avant, err := repo.GetByID(ctx, op.ID, boutiqueID)
+	if err != nil {
+		return 0, nil, err
+	}
+	if avant == nil {
+		return 0, nil, errors.New("product not found")
+	}
+	if op.Version != nil && *op.Version != avant.Version {
+		return 0, nil, ErrVersionObsolete
+	}
+
+	var updates map[string]interface{}
+	switch op.Action {
+	case dto.ActionMasseModifier:
+		if op.Produit == nil {
+			return 0, nil, errors.New("champ produit requis pour modifier")
+		}
+		// comme ProduitService.Update
+		if op.Produit.Statut != nil {
+			if err := verifierTransition(produitApres(*avant, *op.Produit), *op.Produit.Statut); err != nil {
+				return 0, nil, err
+			}
+		}
+		updates = champsProduit(*op.Produit)
+
+	case dto.ActionMasseStatut:
+		if op.Statut == nil {
+			return 0, nil, errors.New("statut requis")
+		}
+		if err := verifierTransition(*avant, *op.Statut); err != nil {
+			return 0, nil, err
+		}
+		updates = map[string]interface{}{"statut": *op.Statut}
+
+	case dto.ActionMasseAjusterPrix:
+		if op.Pourcentage == nil {
+			return 0, nil, errors.New("pourcentage requis")
+		}
+		updates = map[string]interface{}{"prix_defaut": avant.PrixDefaut.AppliquerPourcentage(*op.Pourcentage, avant.Devise)}
+
+	case dto.ActionMasseStock:
+		if op.Stock == nil {
+			return 0, nil, errors.New("quantite_stock requise")
+		}
+		updates = map[string]interface{}{"quantite_stock": *op.Stock}
+
+	case dto.ActionMasseSupprimer:
+		supprime, err := repo.DeleteById(ctx, op.ID, boutiqueID, op.Version)
+		if err != nil {
+			return 0, nil, err
+		}
+		// comme DELETE /produits/:id : rien supprimé, rien journalisé
+		if !supprime {
+			return 0, nil, errors.New("product not found")
+		}
+		if err := audit.Journaliser(ctx, boutiqueID, avant.ID, models.EntiteProduit, avant.ID, models.ActionSuppression, avant, nil); err != nil {
+			return 0, nil, err
+		}
+		return 0, func() {
+			s.produits.publier(ctx, boutiqueID, models.EvenementProduitSupprime, map[string]string{
+				"id":          avant.ID,
+				"boutique_id": boutiqueID,
+			})
+		}, nil
+
+	default:
+		return 0, nil, fmt.Errorf("action inconnue %q", op.Action)
+	}
+
+	devise := deviseModifiee(updates, avant.Devise)
+	arrondirPrix(updates, "prix_defaut", devise)
+	regulier := avant.PrixDefaut
+	if p := prixModifie(updates, "prix_defaut", &avant.PrixDefaut); p != nil {
+		regulier = *p
+	}
+	if err := preparerPromotion(avant.Promotion, updates, devise, regulier, "prix_defaut"); err != nil {
+		return 0, nil, err
+	}
+	if err := preparerProgrammation(*avant, updates); err != nil {
+		return 0, nil, err
+	}
+	if sku, ok := skuModifie(updates); ok {
+		if err := verifierSKUs(ctx, repo, boutiqueID, []string{sku}, op.ID); err != nil {
+			return 0, nil, err
+		}
+	}
+	// comme ProduitService.enregistrer : une classe héritée reste tolérée tant qu'elle ne change pas
+	if classe, ok := classeTaxeModifiee(updates); ok && !memeChaine(classe, avant.ClasseTaxe) {
+		if err := verifierClasseTaxe(ctx, repo, boutiqueID, classe); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	updates["mis_a_jour_le"] = time.Now()
+	apres, err := repo.Update(ctx, op.ID, boutiqueID, updates, op.Version)
+	if err != nil {
+		return 0, nil, err
+	}
+	if apres == nil {
+		return 0, nil, errors.New("product not found")
+	}
+	if err := audit.Journaliser(ctx, boutiqueID, apres.ID, models.EntiteProduit, apres.ID, models.ActionModification, avant, apres); err != nil {
+		return 0, nil, err
+	}
+
+	return apres.Version, func() {
+		if err := s.produits.revisions.CapturerInitiale(ctx, avant); err != nil {
+			log.Printf("révision produit %s: %v", apres.ID, err)
+		}
+		if err := s.produits.revisions.Capturer(ctx, apres); err != nil {
+			log.Printf("révision produit %s: %v", apres.ID, err)
+		}
+		if avant.Statut != apres.Statut {
+			resp := s.produits.toResponse(*apres)
+			switch apres.Statut {
+			case models.StatutPublie:
+				s.produits.publier(ctx, boutiqueID, models.EvenementProduitPublie, resp)
+			case models.StatutArchive:
+				s.produits.publier(ctx, boutiqueID, models.EvenementProduitArchive, resp)
+			}
+		}
+	}, nil
+}
+
+// ------------------------------------------------------------
+// Variantes
+// ------------------------------------------------------------
+func (s *MasseService) executerVariante(ctx context.Context, produits *repository.ProduitRepo, repo *repository.VarianteRepo, audit *AuditService, boutiqueID string, op dto.OperationMasse) (int, func(), error) {
+	avant, err := repo.GetByID(ctx, op.ID)
+	if err != nil {
+		return 0, nil, err
+	}
+	if avant == nil {
+		return 0, nil, errors.New("variante non trouvée")
+	}
+	// la variante doit appartenir à un produit de la boutique
+	produit, err := produits.GetByID(ctx, avant.ProduitID, boutiqueID)
+	if err != nil {
+		return 0, nil, err
+	}
+	if produit == nil {
+		return 0, nil, errors.New("variante non trouvée")
+	}
+	if op.Version != nil && *op.Version != avant.Version {
+		return 0, nil, ErrVersionObsolete
+	}
+
+	var modifications map[string]interface{}
+	switch op.Action {
+	case dto.ActionMasseModifier:
+		if op.Variante == nil {
+			return 0, nil, errors.New("champ variante requis pour modifier")
+		}
+		modifications = champsVariante(*op.Variante)
+
+	case dto.ActionMasseAjusterPrix:
+		if op.Pourcentage == nil {
+			return 0, nil, errors.New("pourcentage requis")
+		}
+		if avant.Prix == nil {
+			return 0, nil, errors.New("la variante hérite du prix du produit, ajustez le produit")
+		}
+		modifications = map[string]interface{}{"prix": avant.Prix.AppliquerPourcentage(*op.Pourcentage, produit.Devise)}
+
+	case dto.ActionMasseStock:
+		if op.Stock == nil {
+			return 0, nil, errors.New("quantite_stock requise")
+		}
+		modifications = map[string]interface{}{"quantite_stock": *op.Stock}
+
+	case dto.ActionMasseSupprimer:
+		supprimee, err := repo.SupprimereById(ctx, op.ID, op.Version)
+		if err != nil {
+			return 0, nil, err
+		}
+		if !supprimee {
+			return 0, nil, errors.New("variante non trouvée")
+		}
+		if err := audit.Journaliser(ctx, boutiqueID, avant.ProduitID, models.EntiteVariante, avant.ID, models.ActionSuppression, avant, nil); err != nil {
+			return 0, nil, err
+		}
+		return 0, func() {
+			s.reviserProduitDeVariante(ctx, produit)
+		}, nil
+
+	case dto.ActionMasseStatut:
+		return 0, nil, errors.New("le statut ne s'applique qu'aux produits")
+
+	default:
+		return 0, nil, fmt.Errorf("action inconnue %q", op.Action)
+	}
+
+	viderCodeBarres(modifications)
+	if code, ok := codeBarresModifie(modifications); ok {
+		if err := verifierCodeBarres(ctx, repo, boutiqueID, code, op.ID); err != nil {
+			return 0, nil, err
+		}
+	}
+	arrondirPrix(modifications, "prix", produit.Devise)
+	regulier := produit.PrixDefaut
+	if p := prixModifie(modifications, "prix", avant.Prix); p != nil {
+		regulier = *p
+	}
+	if err := preparerPromotion(avant.Promotion, modifications, produit.Devise, regulier, "prix"); err != nil {
+		return 0, nil, err
+	}
+	if sku, ok := skuModifie(modifications); ok {
+		if err := verifierSKUVariante(ctx, repo, boutiqueID, sku, op.ID); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	modifications["mis_a_jour_le"] = time.Now()
+	apres, err := repo.Update(ctx, op.ID, modifications, op.Version)
+	if err != nil {
+		return 0, nil, err
+	}
+	if apres == nil {
+		return 0, nil, errors.New("variante non trouvée")
+	}
+	if err := audit.Journaliser(ctx, boutiqueID, apres.ProduitID, models.EntiteVariante, apres.ID, models.ActionModification, avant, apres); err != nil {
+		return 0, nil, err
+	}
+
+	return apres.Version, func() {
+		s.reviserProduitDeVariante(ctx, produit)
+		if avant.QuantiteStock > 0 && apres.QuantiteStock <= 0 && s.variantes.evenements != nil {
+			s.variantes.evenements.Publier(ctx, boutiqueID, models.EvenementVarianteRupture, s.variantes.toResponse(*apres, tarifProduit(produit)))
+		}
+	}, nil
+}
+
+// reviserProduitDeVariante : révisions du produit parent après une opération
+// sur une de ses variantes (produit = agrégat chargé avant l'opération)
+func (s *MasseService) reviserProduitDeVariante(ctx context.Context, produit *models.Produit) {
+	if err := s.produits.revisions.CapturerInitiale(ctx, produit); err != nil {
+		log.Printf("révision produit %s: %v", produit.ID, err)
+	}
+	if err := s.produits.revisions.CapturerProduit(ctx, produit.ID); err != nil {
+		log.Printf("révision produit %s: %v", produit.ID, err)
+	}
+}
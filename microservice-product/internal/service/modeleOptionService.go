@@ -0,0 +1,373 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"projet/internal/dto"
+	"projet/internal/models"
+	"projet/internal/repository"
+	"sort"
+	"time"
+)
+
+// ErrModeleIntrouvable : modèle d'option absent de la boutique
+var ErrModeleIntrouvable = repository.ErrModeleIntrouvable
+
+// ErrValeursModele : liste de valeurs d'un modèle incohérente
+var ErrValeursModele = errors.New("valeurs du modèle invalides")
+
+// ModeleOptionService gère les options réutilisables d'une boutique
+// ("Taille", "Couleur"...) et leur application aux produits
+type ModeleOptionService struct {
+	repo    *repository.ModeleOptionRepo
+	options *OptionProduitService
+	audit   *AuditService
+}
+
+func NewModeleOptionService(repo *repository.ModeleOptionRepo, options *OptionProduitService, audit *AuditService) *ModeleOptionService {
+	return &ModeleOptionService{repo: repo, options: options, audit: audit}
+}
+
+// ------------------------------------------------------------
+// Convertisseurs
+// ------------------------------------------------------------
+func (s *ModeleOptionService) toResponse(m models.ModeleOption, liees int64) dto.ModeleOptionResponse {
+	valeurs := make([]dto.ValeurModeleResponse, len(m.Valeurs))
+	for i, v := range m.Valeurs {
+		valeurs[i] = dto.ValeurModeleResponse{ID: v.ID, Valeur: v.Valeur, Position: v.Position}
+	}
+	return dto.ModeleOptionResponse{
+		ID:           m.ID,
+		BoutiqueID:   m.BoutiqueID,
+		Nom:          m.Nom,
+		Version:      m.Version,
+		CreeLe:       m.CreeLe,
+		MisAJourLe:   m.MisAJourLe,
+		Valeurs:      valeurs,
+		OptionsLiees: liees,
+	}
+}
+
+// valeursModele construit les valeurs du modèle ; une position nulle prend
+// le rang dans la liste, et deux libellés identiques sont refusés
+func valeursModele(req []dto.RequeteValeurModele) ([]models.ValeurModeleOption, error) {
+	vues := map[string]bool{}
+	valeurs := make([]models.ValeurModeleOption, len(req))
+	for i, v := range req {
+		if vues[v.Valeur] {
+			return nil, fmt.Errorf("%w: %s en double", ErrValeursModele, v.Valeur)
+		}
+		vues[v.Valeur] = true
+
+		position := v.Position
+		if position == 0 {
+			position = i + 1
+		}
+		valeurs[i] = models.ValeurModeleOption{Valeur: v.Valeur, Position: position}
+		if v.ID != nil {
+			valeurs[i].ID = *v.ID
+		}
+	}
+	return valeurs, nil
+}
+
+// ------------------------------------------------------------
+// Créer un modèle avec ses valeurs
+// ------------------------------------------------------------
+func (s *ModeleOptionService) Create(ctx context.Context, boutiqueID string, req dto.RequeteCreationModeleOption) (*dto.ModeleOptionResponse, error) {
+	if boutiqueID == "" {
+		return nil, errors.New("boutique ID is required")
+	}
+	for _, v := range req.Valeurs {
+		if v.ID != nil {
+			return nil, fmt.Errorf("%w: id interdit à la création", ErrValeursModele)
+		}
+	}
+	valeurs, err := valeursModele(req.Valeurs)
+	if err != nil {
+		return nil, err
+	}
+
+	cree, err := s.repo.Create(ctx, &models.ModeleOption{
+		BoutiqueID: boutiqueID,
+		Nom:        req.Nom,
+		Valeurs:    valeurs,
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.audit.Enregistrer(ctx, boutiqueID, "", models.EntiteModeleOption, cree.ID, models.ActionCreation, nil, cree)
+
+	resp := s.toResponse(*cree, 0)
+	return &resp, nil
+}
+
+// ------------------------------------------------------------
+// Lire / lister
+// ------------------------------------------------------------
+func (s *ModeleOptionService) List(ctx context.Context, boutiqueID string) ([]dto.ModeleOptionResponse, error) {
+	modeles, err := s.repo.List(ctx, boutiqueID)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, len(modeles))
+	for i, m := range modeles {
+		ids[i] = m.ID
+	}
+	liees, err := s.repo.CompterOptionsLieesParModele(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	resultats := make([]dto.ModeleOptionResponse, len(modeles))
+	for i, m := range modeles {
+		resultats[i] = s.toResponse(m, liees[m.ID])
+	}
+	return resultats, nil
+}
+
+func (s *ModeleOptionService) GetByID(ctx context.Context, id, boutiqueID string) (*dto.ModeleOptionResponse, error) {
+	modele, err := s.repo.GetByID(ctx, id, boutiqueID)
+	if err != nil {
+		return nil, err
+	}
+	if modele == nil {
+		return nil, ErrModeleIntrouvable
+	}
+	liees, err := s.repo.CompterOptionsLiees(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	resp := s.toResponse(*modele, liees)
+	return &resp, nil
+}
+
+// ------------------------------------------------------------
+// Modifier un modèle, avec propagation optionnelle aux produits liés
+// ------------------------------------------------------------
+func (s *ModeleOptionService) Update(ctx context.Context, id, boutiqueID string, req dto.RequeteUpdateModeleOption, version *int) (*dto.ReponseMajModele, error) {
+	avant, err := s.repo.GetByID(ctx, id, boutiqueID)
+	if err != nil {
+		return nil, err
+	}
+	if avant == nil {
+		return nil, ErrModeleIntrouvable
+	}
+	if version != nil && *version != avant.Version {
+		return nil, ErrVersionObsolete
+	}
+
+	modele := *avant
+	modele.Valeurs = nil
+	if req.Nom != nil {
+		modele.Nom = *req.Nom
+	}
+
+	var supprimees []string
+	if req.Valeurs != nil {
+		existantes := make(map[string]bool, len(avant.Valeurs))
+		for _, v := range avant.Valeurs {
+			existantes[v.ID] = true
+		}
+		if modele.Valeurs, err = valeursModele(req.Valeurs); err != nil {
+			return nil, err
+		}
+		for _, v := range modele.Valeurs {
+			if v.ID == "" {
+				continue
+			}
+			if !existantes[v.ID] {
+				return nil, fmt.Errorf("%w: %s absente du modèle", ErrValeursModele, v.ID)
+			}
+			delete(existantes, v.ID)
+		}
+		for vid := range existantes {
+			supprimees = append(supprimees, vid)
+		}
+		sort.Strings(supprimees)
+	}
+
+	// révision initiale des produits touchés par la propagation, avant écriture
+	if req.Propager {
+		options, err := s.repo.OptionsLiees(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		for _, produitID := range produitsDesOptions(options) {
+			if err := s.options.revisions.CapturerInitialeProduit(ctx, produitID); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var apres *models.ModeleOption
+	var propagation *repository.PropagationModele
+	err = s.repo.Transaction(ctx, func(modeles *repository.ModeleOptionRepo, journal *repository.AuditRepo) error {
+		var err error
+		var optionsAvant []models.OptionProduit
+		if req.Propager {
+			if optionsAvant, err = modeles.OptionsLiees(ctx, id); err != nil {
+				return err
+			}
+		}
+		if propagation, err = modeles.Enregistrer(ctx, &modele, supprimees, req.Propager, version); err != nil {
+			return err
+		}
+		if apres, err = modeles.GetByID(ctx, id, boutiqueID); err != nil {
+			return err
+		}
+		if apres == nil {
+			return ErrModeleIntrouvable
+		}
+
+		audit := s.audit.Dans(journal)
+		if err := audit.Journaliser(ctx, boutiqueID, "", models.EntiteModeleOption, id, models.ActionModification, avant, apres); err != nil {
+			return err
+		}
+		if len(propagation.OptionIDs) == 0 {
+			return nil
+		}
+		optionsApres, err := modeles.OptionsLiees(ctx, id)
+		if err != nil {
+			return err
+		}
+		return journaliserPropagation(ctx, audit, boutiqueID, optionsAvant, optionsApres)
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, produitID := range propagation.ProduitIDs {
+		if err := s.options.revisions.CapturerProduit(ctx, produitID); err != nil {
+			log.Printf("révision produit %s: %v", produitID, err)
+		}
+	}
+
+	liees, err := s.repo.CompterOptionsLiees(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return &dto.ReponseMajModele{Modele: s.toResponse(*apres, liees), OptionsPropagees: int64(len(propagation.OptionIDs))}, nil
+}
+
+// produitsDesOptions : produits des options, sans doublon, dans l'ordre
+func produitsDesOptions(options []models.OptionProduit) []string {
+	vus := make(map[string]bool, len(options))
+	var produits []string
+	for _, o := range options {
+		if !vus[o.ProduitID] {
+			vus[o.ProduitID] = true
+			produits = append(produits, o.ProduitID)
+		}
+	}
+	return produits
+}
+
+// journaliserPropagation journalise, sur le produit concerné, chaque option
+// liée et chaque valeur modifiée ou ajoutée par la propagation d'un modèle
+func journaliserPropagation(ctx context.Context, audit *AuditService, boutiqueID string, avant, apres []models.OptionProduit) error {
+	options := make(map[string]models.OptionProduit, len(avant))
+	valeurs := make(map[string]models.ValeurOption)
+	for _, o := range avant {
+		options[o.ID] = o
+		for _, v := range o.ValeurOpts {
+			valeurs[v.ID] = v
+		}
+	}
+
+	for _, o := range apres {
+		if ancienne, ok := options[o.ID]; ok {
+			if err := audit.Journaliser(ctx, boutiqueID, o.ProduitID, models.EntiteOption, o.ID, models.ActionModification, ancienne, o); err != nil {
+				return err
+			}
+		}
+		for _, v := range o.ValeurOpts {
+			ancienne, ok := valeurs[v.ID]
+			if !ok {
+				if err := audit.Journaliser(ctx, boutiqueID, o.ProduitID, models.EntiteValeurOption, v.ID, models.ActionCreation, nil, v); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := audit.Journaliser(ctx, boutiqueID, o.ProduitID, models.EntiteValeurOption, v.ID, models.ActionModification, ancienne, v); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ------------------------------------------------------------
+// Supprimer un modèle (les options liées sont conservées, déliées)
+// ------------------------------------------------------------
+func (s *ModeleOptionService) Delete(ctx context.Context, id, boutiqueID string, version *int) error {
+	avant, err := s.repo.GetByID(ctx, id, boutiqueID)
+	if err != nil {
+		return err
+	}
+	if avant == nil {
+		return ErrModeleIntrouvable
+	}
+	if version != nil && *version != avant.Version {
+		return ErrVersionObsolete
+	}
+
+	supprime, err := s.repo.Delete(ctx, id, boutiqueID, version)
+	if err != nil {
+		return err
+	}
+	if !supprime {
+		return ErrModeleIntrouvable
+	}
+	s.audit.Enregistrer(ctx, boutiqueID, "", models.EntiteModeleOption, id, models.ActionSuppression, avant, nil)
+	return nil
+}
+
+// ------------------------------------------------------------
+// Appliquer un modèle à un produit : crée l'option et ses valeurs,
+// liées au modèle si req.Lier
+// ------------------------------------------------------------
+func (s *ModeleOptionService) Appliquer(ctx context.Context, produitID, boutiqueID string, req dto.RequeteApplicationModele, cascade bool) (*dto.OptionProduitResponse, error) {
+	present, err := s.repo.ProduitDeLaBoutique(ctx, produitID, boutiqueID)
+	if err != nil {
+		return nil, err
+	}
+	if !present {
+		return nil, errors.New("product not found")
+	}
+	modele, err := s.repo.GetByID(ctx, req.ModeleID, boutiqueID)
+	if err != nil {
+		return nil, err
+	}
+	if modele == nil {
+		return nil, ErrModeleIntrouvable
+	}
+
+	maintenant := time.Now()
+	option := &models.OptionProduit{
+		ProduitID:  produitID,
+		Nom:        modele.Nom,
+		Position:   req.Position,
+		CreeLe:     maintenant,
+		MisAJourLe: maintenant,
+	}
+	if req.Lier {
+		option.ModeleID = &modele.ID
+	}
+	for _, v := range modele.Valeurs {
+		valeur := models.ValeurOption{Valeur: v.Valeur, Position: v.Position}
+		if req.Lier {
+			valeurModeleID := v.ID
+			valeur.ValeurModeleID = &valeurModeleID
+		}
+		option.ValeurOpts = append(option.ValeurOpts, valeur)
+	}
+
+	cree, err := s.options.ajouterOption(ctx, boutiqueID, option, cascade)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := s.options.toResponseOptProd(*cree)
+	return &resp, nil
+}
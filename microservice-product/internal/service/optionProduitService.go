@@ -2,31 +2,113 @@ package service
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"log"
 	"projet/internal/dto"
 	"projet/internal/models"
 	"projet/internal/repository"
+	"strings"
 	"time"
 )
 
 type OptionProduitService struct {
-	repo *repository.OptionProduitValeurRepo
+	repo      *repository.OptionProduitValeurRepo
+	audit     *AuditService
+	revisions *RevisionService
 }
 
-func NewOptionProduitService(repo *repository.OptionProduitValeurRepo) *OptionProduitService {
-	return &OptionProduitService{repo: repo}
+func NewOptionProduitService(repo *repository.OptionProduitValeurRepo, audit *AuditService, revisions *RevisionService) *OptionProduitService {
+	return &OptionProduitService{repo: repo, audit: audit, revisions: revisions}
+}
+
+// ecrire exécute fn dans une transaction où l'audit est écrit avec la
+// modification, encadrée par les révisions du produit parent (état initial
+// avant la première modification, agrégat complet après)
+func (s *OptionProduitService) ecrire(ctx context.Context, produitID string, fn func(options *repository.OptionProduitValeurRepo, audit *AuditService) error) error {
+	if err := s.revisions.CapturerInitialeProduit(ctx, produitID); err != nil {
+		return err
+	}
+	err := s.repo.Transaction(ctx, func(options *repository.OptionProduitValeurRepo, journal *repository.AuditRepo) error {
+		return fn(options, s.audit.Dans(journal))
+	})
+	if err != nil {
+		return err
+	}
+	if err := s.revisions.CapturerProduit(ctx, produitID); err != nil {
+		log.Printf("révision produit %s: %v", produitID, err)
+	}
+	return nil
+}
+
+// ErrProduitIntrouvable : le produit parent de l'option n'existe pas
+var ErrProduitIntrouvable = errors.New("produit non trouvé")
+
+// boutiqueDuProduit vérifie que le produit appartient à boutiqueID, la
+// boutique de la requête : un produit d'une autre boutique est traité comme
+// absent (ErrProduitIntrouvable)
+func (s *OptionProduitService) boutiqueDuProduit(ctx context.Context, produitID, boutiqueID string) (string, error) {
+	proprietaire, err := s.repo.BoutiqueDuProduit(ctx, produitID)
+	if err != nil {
+		return "", err
+	}
+	if proprietaire == "" || proprietaire != boutiqueID {
+		return "", ErrProduitIntrouvable
+	}
+	return proprietaire, nil
+}
+
+// optionDeBoutique : l'option si son produit appartient à boutiqueID
+func (s *OptionProduitService) optionDeBoutique(ctx context.Context, optionID, boutiqueID string) (*models.OptionProduit, error) {
+	option, err := s.repo.GetByIdOptionproduit(ctx, optionID)
+	if err != nil {
+		return nil, err
+	}
+	if option == nil {
+		return nil, errors.New("option non trouvée")
+	}
+	if _, err := s.boutiqueDuProduit(ctx, option.ProduitID, boutiqueID); err != nil {
+		return nil, err
+	}
+	return option, nil
+}
+
+// proprietaireValeur : produit parent et boutique d'une valeur, pour l'historique du produit
+func (s *OptionProduitService) proprietaireValeur(ctx context.Context, valeur models.ValeurOption, boutiqueID string) (string, string, error) {
+	option, err := s.repo.GetByIdOptionproduit(ctx, valeur.OptionID)
+	if err != nil {
+		return "", "", err
+	}
+	if option == nil {
+		return "", "", errors.New("option non trouvée")
+	}
+	if _, err := s.boutiqueDuProduit(ctx, option.ProduitID, boutiqueID); err != nil {
+		return "", "", err
+	}
+	return option.ProduitID, boutiqueID, nil
 }
 
 // ------------------------------------------------------------
 // Convertisseurs
 // ------------------------------------------------------------
 func (s *OptionProduitService) toResponseValeurOpt(vo models.ValeurOption) dto.ValeurOptionResponse {
+	return valeurOptionVersResponse(vo)
+}
+
+// valeurOptionVersResponse est partagé par les réponses option, produit et variante
+func valeurOptionVersResponse(vo models.ValeurOption) dto.ValeurOptionResponse {
 	return dto.ValeurOptionResponse{
-		ID:       vo.ID,
-		OptionID: vo.OptionID,
-		Valeur:   vo.Valeur,
-		Position: vo.Position,
+		ID:             vo.ID,
+		OptionID:       vo.OptionID,
+		Valeur:         vo.Valeur,
+		Position:       vo.Position,
+		Version:        vo.Version,
+		ValeurModeleID: vo.ValeurModeleID,
+		CouleurHex:     vo.CouleurHex,
+		ImageSwatch:    vo.ImageSwatch,
+		Code:           vo.Code,
+		Libelles:       vo.Libelles,
 	}
 }
 
@@ -41,43 +123,108 @@ func (s *OptionProduitService) toResponseOptProd(po models.OptionProduit) dto.Op
 		ProduitID:  po.ProduitID,
 		Nom:        po.Nom,
 		Position:   po.Position,
+		Version:    po.Version,
+		ModeleID:   po.ModeleID,
 		CreeLe:     po.CreeLe,
 		MisAJourLe: po.MisAJourLe,
 		ValeurOpts: vopts,
 	}
 }
 
+// ErrCodeValeurPris : le code machine est déjà utilisé par une autre valeur de l'option
+var ErrCodeValeurPris = errors.New("code déjà utilisé dans cette option")
+
+func (s *OptionProduitService) verifierCode(ctx context.Context, optionID, code, exclureID string) error {
+	pris, err := s.repo.CodeValeurPris(ctx, optionID, code, exclureID)
+	if err != nil {
+		return err
+	}
+	if pris {
+		return ErrCodeValeurPris
+	}
+	return nil
+}
+
+// normaliserCouleur : #rgb, #rgba, #rrggbb ou #rrggbbaa stockés en minuscules, "" efface
+func normaliserCouleur(couleur *string) *string {
+	if couleur == nil || *couleur == "" {
+		return nil
+	}
+	minuscule := strings.ToLower(*couleur)
+	return &minuscule
+}
+
+func videEnNil(valeur string) *string {
+	if valeur == "" {
+		return nil
+	}
+	return &valeur
+}
+
+// ErreurVariantesLiees : suppression ou ajout refusé car des variantes en
+// dépendent ; la liste est renvoyée au client (409), cascade force l'opération
+type ErreurVariantesLiees = repository.ErreurVariantesLiees
+
+// ajouterOption crée l'option en appliquant la règle des variantes existantes
+// et journalise la création (et les variantes retirées) dans la même transaction
+func (s *OptionProduitService) ajouterOption(ctx context.Context, boutiqueID string, option *models.OptionProduit, cascade bool) (*models.OptionProduit, error) {
+	err := s.ecrire(ctx, option.ProduitID, func(options *repository.OptionProduitValeurRepo, audit *AuditService) error {
+		cree, retirees, err := options.AjouterOption(ctx, option, cascade)
+		if err != nil {
+			var liees *ErreurVariantesLiees
+			if errors.As(err, &liees) {
+				return err
+			}
+			return fmt.Errorf("échec de la création: %v", err)
+		}
+		if err := auditerVariantesRetirees(ctx, audit, boutiqueID, option.ProduitID, retirees); err != nil {
+			return err
+		}
+		return audit.Journaliser(ctx, boutiqueID, option.ProduitID, models.EntiteOption, cree.ID, models.ActionCreation, nil, cree)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return option, nil
+}
+
+// auditerVariantesRetirees trace les variantes supprimées en mode cascade
+func auditerVariantesRetirees(ctx context.Context, audit *AuditService, boutiqueID, produitID string, variantes []models.Variante) error {
+	for _, v := range variantes {
+		if err := audit.Journaliser(ctx, boutiqueID, produitID, models.EntiteVariante, v.ID, models.ActionSuppression, v, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // ------------------------------------------------------------
 // Créer une option avec ses valeurs (les valeurs sont créées séparément)
 // ------------------------------------------------------------
 func (s *OptionProduitService) CreationOptionProduit(
 	ctx context.Context,
-	produitID string,
+	produitID, boutiqueID string,
 	req dto.RequeteCreationOption,
+	cascade bool,
 ) (*dto.OptionProduitResponse, error) {
 
-	// Gérer la position
-	position := req.Position
-	if position == 0 {
-		count, err := s.repo.CountOptionsByProduit(ctx, produitID)
-		if err != nil {
-			return nil, fmt.Errorf("impossible de compter les options: %v", err)
-		}
-		position = count + 1
+	if _, err := s.boutiqueDuProduit(ctx, produitID, boutiqueID); err != nil {
+		return nil, err
 	}
 
-	// Créer l'option seulement (sans valeurs)
+	// Créer l'option seulement (sans valeurs) ; position 0 = à la fin,
+	// calculée par le repo sous le verrou du produit
 	nouvelleOption := &models.OptionProduit{
 		ProduitID:  produitID,
 		Nom:        req.Nom,
-		Position:   position,
+		Position:   req.Position,
 		CreeLe:     time.Now(),
 		MisAJourLe: time.Now(),
 	}
 
-	cree, err := s.repo.CreationOptProduit(ctx, nouvelleOption)
+	cree, err := s.ajouterOption(ctx, boutiqueID, nouvelleOption, cascade)
 	if err != nil {
-		return nil, fmt.Errorf("échec de la création: %v", err)
+		return nil, err
 	}
 
 	// Retourner l'option (sans valeurs pour l'instant)
@@ -90,52 +237,57 @@ func (s *OptionProduitService) CreationOptionProduit(
 // ------------------------------------------------------------
 func (s *OptionProduitService) CreationValeurOption(
 	ctx context.Context,
-	optionID string,
+	optionID, boutiqueID string,
 	req dto.RequeteCreationValeurOption,
 ) (*dto.ValeurOptionResponse, error) {
 
-	// Vérifier que l'option existe
-	option, err := s.repo.GetByIdOptionproduit(ctx, optionID)
+	// Vérifier que l'option existe et appartient à la boutique
+	option, err := s.optionDeBoutique(ctx, optionID, boutiqueID)
 	if err != nil {
 		return nil, err
 	}
-	if option == nil {
-		return nil, errors.New("option non trouvée")
-	}
 
-	// Gérer la position
-	position := req.Position
-	if position == 0 {
-		count, err := s.repo.CountValeursByOption(ctx, optionID)
-		if err != nil {
-			return nil, fmt.Errorf("impossible de compter les valeurs: %v", err)
+	if req.Code != nil {
+		if err := s.verifierCode(ctx, optionID, *req.Code, ""); err != nil {
+			return nil, err
 		}
-		position = count + 1
 	}
 
 	// Créer la valeur
 	nouvelleValeur := &models.ValeurOption{
-		OptionID: optionID,
-		Valeur:   req.Valeur,
-		Position: position,
-	}
-
-	cree, err := s.repo.CreationValeurOption(ctx, nouvelleValeur)
+		OptionID:    optionID,
+		Valeur:      req.Valeur,
+		Position:    req.Position, // 0 = à la fin (repo)
+		CouleurHex:  normaliserCouleur(req.CouleurHex),
+		ImageSwatch: req.ImageSwatch,
+		Code:        req.Code,
+		Libelles:    req.Libelles,
+	}
+
+	err = s.ecrire(ctx, option.ProduitID, func(options *repository.OptionProduitValeurRepo, audit *AuditService) error {
+		if _, err := options.CreationValeurOption(ctx, nouvelleValeur); err != nil {
+			return fmt.Errorf("échec de la création de la valeur: %v", err)
+		}
+		return audit.Journaliser(ctx, boutiqueID, option.ProduitID, models.EntiteValeurOption, nouvelleValeur.ID, models.ActionCreation, nil, nouvelleValeur)
+	})
 	if err != nil {
-		return nil, fmt.Errorf("échec de la création de la valeur: %v", err)
+		return nil, err
 	}
 
-	reponse := s.toResponseValeurOpt(*cree)
+	reponse := s.toResponseValeurOpt(*nouvelleValeur)
 	return &reponse, nil
 }
 
 // ------------------------------------------------------------
 // Lister toutes les options d'un produit (avec leurs valeurs)
 // ------------------------------------------------------------
-func (s *OptionProduitService) ListOptionProduit(ctx context.Context, produitID string) ([]dto.OptionProduitResponse, error) {
+func (s *OptionProduitService) ListOptionProduit(ctx context.Context, produitID, boutiqueID string) ([]dto.OptionProduitResponse, error) {
 	if produitID == "" {
 		return nil, errors.New("ID du produit requis")
 	}
+	if _, err := s.boutiqueDuProduit(ctx, produitID, boutiqueID); err != nil {
+		return nil, err
+	}
 
 	options, err := s.repo.ListeOptProduits(ctx, produitID)
 	if err != nil {
@@ -160,14 +312,11 @@ func (s *OptionProduitService) ListOptionProduit(ctx context.Context, produitID
 // ------------------------------------------------------------
 // Récupérer une option spécifique par son ID (avec ses valeurs)
 // ------------------------------------------------------------
-func (s *OptionProduitService) GetByIDOptionProduit(ctx context.Context, id string) (*dto.OptionProduitResponse, error) {
-	option, err := s.repo.GetByIdOptionproduit(ctx, id)
+func (s *OptionProduitService) GetByIDOptionProduit(ctx context.Context, id, boutiqueID string) (*dto.OptionProduitResponse, error) {
+	option, err := s.optionDeBoutique(ctx, id, boutiqueID)
 	if err != nil {
 		return nil, err
 	}
-	if option == nil {
-		return nil, errors.New("option non trouvée")
-	}
 
 	// Récupérer les valeurs de cette option
 	valeurs, err := s.repo.ListeValeursOption(ctx, option.ID)
@@ -183,11 +332,16 @@ func (s *OptionProduitService) GetByIDOptionProduit(ctx context.Context, id stri
 // ------------------------------------------------------------
 // Mettre à jour une option (nom, position)
 // ------------------------------------------------------------
-func (s *OptionProduitService) Update(ctx context.Context, id string, req dto.RequeteUpdateOption) (*dto.OptionProduitResponse, error) {
-	// Vérifier que l'option existe
-	if _, err := s.GetByIDOptionProduit(ctx, id); err != nil {
+// Update : version non nil active le verrouillage optimiste (If-Match)
+func (s *OptionProduitService) Update(ctx context.Context, id, boutiqueID string, req dto.RequeteUpdateOption, version *int) (*dto.OptionProduitResponse, error) {
+	// Vérifier que l'option existe et appartient à la boutique
+	avant, err := s.optionDeBoutique(ctx, id, boutiqueID)
+	if err != nil {
 		return nil, err
 	}
+	if version != nil && *version != avant.Version {
+		return nil, ErrVersionObsolete
+	}
 
 	modifications := make(map[string]interface{})
 	if req.Nom != nil {
@@ -199,15 +353,24 @@ func (s *OptionProduitService) Update(ctx context.Context, id string, req dto.Re
 	modifications["mis_a_jour_le"] = time.Now()
 
 	if len(modifications) == 0 {
-		return s.GetByIDOptionProduit(ctx, id)
+		return s.GetByIDOptionProduit(ctx, id, boutiqueID)
 	}
 
-	_, err := s.repo.UpdateProduitOption(ctx, id, modifications)
+	err = s.ecrire(ctx, avant.ProduitID, func(options *repository.OptionProduitValeurRepo, audit *AuditService) error {
+		apres, err := options.UpdateProduitOption(ctx, id, modifications, version)
+		if err != nil {
+			return err
+		}
+		if apres == nil {
+			return errors.New("option non trouvée")
+		}
+		return audit.Journaliser(ctx, boutiqueID, avant.ProduitID, models.EntiteOption, id, models.ActionModification, avant, apres)
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	return s.GetByIDOptionProduit(ctx, id)
+	return s.GetByIDOptionProduit(ctx, id, boutiqueID)
 }
 
 // ------------------------------------------------------------
@@ -215,11 +378,12 @@ func (s *OptionProduitService) Update(ctx context.Context, id string, req dto.Re
 // ------------------------------------------------------------
 func (s *OptionProduitService) UpdateValeur(
 	ctx context.Context,
-	id string,
+	id, boutiqueID string,
 	req dto.RequeteUpdateValeurOption,
+	version *int,
 ) (*dto.ValeurOptionResponse, error) {
 
-	// Vérifier que la valeur existe
+	// Vérifier que la valeur existe et appartient à la boutique
 	valeur, err := s.repo.GetByIDValeurOption(ctx, id)
 	if err != nil {
 		return nil, err
@@ -227,6 +391,10 @@ func (s *OptionProduitService) UpdateValeur(
 	if valeur == nil {
 		return nil, errors.New("valeur non trouvée")
 	}
+	produitID, _, err := s.proprietaireValeur(ctx, *valeur, boutiqueID)
+	if err != nil {
+		return nil, err
+	}
 
 	modifications := make(map[string]interface{})
 	if req.Valeur != nil {
@@ -234,14 +402,54 @@ func (s *OptionProduitService) UpdateValeur(
 	}
 	if req.Position != nil {
 		modifications["position"] = *req.Position
+		modifications["position_propre"] = true
+	}
+	if req.CouleurHex != nil {
+		modifications["couleur_hex"] = normaliserCouleur(req.CouleurHex)
+	}
+	if req.ImageSwatch != nil {
+		modifications["image_swatch"] = videEnNil(*req.ImageSwatch)
+	}
+	if req.Code != nil {
+		if *req.Code != "" {
+			if err := s.verifierCode(ctx, valeur.OptionID, *req.Code, id); err != nil {
+				return nil, err
+			}
+		}
+		modifications["code"] = videEnNil(*req.Code)
+	}
+	if req.Libelles != nil {
+		// Updates(map) ignore le serializer : on passe le JSON nous-mêmes
+		libelles, err := json.Marshal(req.Libelles)
+		if err != nil {
+			return nil, err
+		}
+		if len(req.Libelles) == 0 {
+			modifications["libelles"] = nil
+		} else {
+			modifications["libelles"] = string(libelles)
+		}
 	}
 
 	if len(modifications) == 0 {
+		if version != nil && *version != valeur.Version {
+			return nil, ErrVersionObsolete
+		}
 		reponse := s.toResponseValeurOpt(*valeur)
 		return &reponse, nil
 	}
 
-	updated, err := s.repo.UpdateValeurOpt(ctx, id, modifications)
+	var updated *models.ValeurOption
+	err = s.ecrire(ctx, produitID, func(options *repository.OptionProduitValeurRepo, audit *AuditService) error {
+		updated, err = options.UpdateValeurOpt(ctx, id, modifications, version)
+		if err != nil {
+			return err
+		}
+		if updated == nil {
+			return errors.New("valeur non trouvée")
+		}
+		return audit.Journaliser(ctx, boutiqueID, produitID, models.EntiteValeurOption, id, models.ActionModification, valeur, updated)
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -253,35 +461,64 @@ func (s *OptionProduitService) UpdateValeur(
 // ------------------------------------------------------------
 // Supprimer une option (et ses valeurs par CASCADE)
 // ------------------------------------------------------------
-func (s *OptionProduitService) Delete(ctx context.Context, id string) error {
-	supprime, err := s.repo.SupprimerOptPById(ctx, id)
+func (s *OptionProduitService) Delete(ctx context.Context, id, boutiqueID string, version *int, cascade bool) error {
+	avant, err := s.optionDeBoutique(ctx, id, boutiqueID)
 	if err != nil {
 		return err
 	}
-	if !supprime {
-		return errors.New("option non trouvée")
-	}
-	return nil
+	return s.ecrire(ctx, avant.ProduitID, func(options *repository.OptionProduitValeurRepo, audit *AuditService) error {
+		supprime, retirees, err := options.SupprimerOptPById(ctx, id, version, cascade)
+		if err != nil {
+			return err
+		}
+		if !supprime {
+			return errors.New("option non trouvée")
+		}
+		if err := auditerVariantesRetirees(ctx, audit, boutiqueID, avant.ProduitID, retirees); err != nil {
+			return err
+		}
+		return audit.Journaliser(ctx, boutiqueID, avant.ProduitID, models.EntiteOption, id, models.ActionSuppression, avant, nil)
+	})
 }
 
 // ------------------------------------------------------------
 // Supprimer une valeur d'option
 // ------------------------------------------------------------
-func (s *OptionProduitService) DeleteValeur(ctx context.Context, id string) error {
-	supprime, err := s.repo.SupprimerByIdVOpt(ctx, id)
+func (s *OptionProduitService) DeleteValeur(ctx context.Context, id, boutiqueID string, version *int, cascade bool) error {
+	avant, err := s.repo.GetByIDValeurOption(ctx, id)
 	if err != nil {
 		return err
 	}
-	if !supprime {
+	if avant == nil {
 		return errors.New("valeur non trouvée")
 	}
-	return nil
+	produitID, _, err := s.proprietaireValeur(ctx, *avant, boutiqueID)
+	if err != nil {
+		return err
+	}
+
+	return s.ecrire(ctx, produitID, func(options *repository.OptionProduitValeurRepo, audit *AuditService) error {
+		supprime, retirees, err := options.SupprimerByIdVOpt(ctx, id, version, cascade)
+		if err != nil {
+			return err
+		}
+		if !supprime {
+			return errors.New("valeur non trouvée")
+		}
+		if err := auditerVariantesRetirees(ctx, audit, boutiqueID, produitID, retirees); err != nil {
+			return err
+		}
+		return audit.Journaliser(ctx, boutiqueID, produitID, models.EntiteValeurOption, id, models.ActionSuppression, avant, nil)
+	})
 }
 
 // ------------------------------------------------------------
 // Lister les valeurs d'une option
 // ------------------------------------------------------------
-func (s *OptionProduitService) ListValeursByOption(ctx context.Context, optionID string) ([]dto.ValeurOptionResponse, error) {
+func (s *OptionProduitService) ListValeursByOption(ctx context.Context, optionID, boutiqueID string) ([]dto.ValeurOptionResponse, error) {
+	if _, err := s.optionDeBoutique(ctx, optionID, boutiqueID); err != nil {
+		return nil, err
+	}
 	valeurs, err := s.repo.ListeValeursOption(ctx, optionID)
 	if err != nil {
 		return nil, err
@@ -293,3 +530,93 @@ func (s *OptionProduitService) ListValeursByOption(ctx context.Context, optionID
 	}
 	return resultats, nil
 }
+
+// ErrOrdreIncomplet : la liste d'IDs d'un réordonnancement est incomplète ou contient des intrus
+var ErrOrdreIncomplet = repository.ErrOrdreIncomplet
+
+// ------------------------------------------------------------
+// Réordonner les options d'un produit (positions 1..n)
+// ------------------------------------------------------------
+func (s *OptionProduitService) ReordonnerOptions(ctx context.Context, produitID, boutiqueID string, ids []string) ([]dto.OptionProduitResponse, error) {
+	if _, err := s.boutiqueDuProduit(ctx, produitID, boutiqueID); err != nil {
+		return nil, err
+	}
+	avant, err := s.repo.ListeOptProduits(ctx, produitID)
+	if err != nil {
+		return nil, err
+	}
+
+	positions := make(map[string]models.OptionProduit, len(avant))
+	for _, opt := range avant {
+		positions[opt.ID] = opt
+	}
+	var apres []models.OptionProduit
+	err = s.ecrire(ctx, produitID, func(options *repository.OptionProduitValeurRepo, audit *AuditService) error {
+		if err := options.ReordonnerOptions(ctx, produitID, ids); err != nil {
+			return err
+		}
+		var err error
+		if apres, err = options.ListeOptProduits(ctx, produitID); err != nil {
+			return err
+		}
+		for _, opt := range apres {
+			if ancienne, ok := positions[opt.ID]; ok && ancienne.Position != opt.Position {
+				if err := audit.Journaliser(ctx, boutiqueID, produitID, models.EntiteOption, opt.ID, models.ActionModification, ancienne, opt); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resultats := make([]dto.OptionProduitResponse, len(apres))
+	for i, opt := range apres {
+		resultats[i] = s.toResponseOptProd(opt)
+	}
+	return resultats, nil
+}
+
+// ------------------------------------------------------------
+// Réordonner les valeurs d'une option (positions 1..n)
+// ------------------------------------------------------------
+func (s *OptionProduitService) ReordonnerValeurs(ctx context.Context, optionID, boutiqueID string, ids []string) ([]dto.ValeurOptionResponse, error) {
+	option, err := s.optionDeBoutique(ctx, optionID, boutiqueID)
+	if err != nil {
+		return nil, err
+	}
+
+	positions := make(map[string]models.ValeurOption, len(option.ValeurOpts))
+	for _, val := range option.ValeurOpts {
+		positions[val.ID] = val
+	}
+	var apres []models.ValeurOption
+	err = s.ecrire(ctx, option.ProduitID, func(options *repository.OptionProduitValeurRepo, audit *AuditService) error {
+		if err := options.ReordonnerValeurs(ctx, optionID, ids); err != nil {
+			return err
+		}
+		var err error
+		if apres, err = options.ListeValeursOption(ctx, optionID); err != nil {
+			return err
+		}
+		for _, val := range apres {
+			if ancienne, ok := positions[val.ID]; ok && ancienne.Position != val.Position {
+				if err := audit.Journaliser(ctx, boutiqueID, option.ProduitID, models.EntiteValeurOption, val.ID, models.ActionModification, ancienne, val); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resultats := make([]dto.ValeurOptionResponse, len(apres))
+	for i, val := range apres {
+		resultats[i] = s.toResponseValeurOpt(val)
+	}
+	return resultats, nil
+}
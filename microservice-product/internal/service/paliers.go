@@ -0,0 +1,335 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"projet/internal/dto"
+	"projet/internal/models"
+	"projet/internal/monnaie"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrPaliers : paliers en double ou variante hors du produit
+	ErrPaliers = errors.New("paliers invalides")
+	// ErrCalculPrix : ligne vers un article inconnu ou ambigu
+	ErrCalculPrix = errors.New("calcul de prix impossible")
+)
+
+// ------------------------------------------------------------
+// Grille de paliers
+// ------------------------------------------------------------
+
+// grillePaliers : paliers par produit (ceux hérités par les variantes) et
+// par variante, triés par quantité croissante
+type grillePaliers struct {
+	produits  map[string][]models.PalierQuantite
+	variantes map[string][]models.PalierQuantite
+}
+
+func (s *TarifService) grille(ctx context.Context, produitIDs []string) (*grillePaliers, error) {
+	paliers, err := s.repo.PaliersDe(ctx, produitIDs)
+	if err != nil {
+		return nil, err
+	}
+	g := &grillePaliers{produits: map[string][]models.PalierQuantite{}, variantes: map[string][]models.PalierQuantite{}}
+	for _, p := range paliers {
+		if p.VarianteID == nil {
+			g.produits[p.ProduitID] = append(g.produits[p.ProduitID], p)
+		} else {
+			g.variantes[*p.VarianteID] = append(g.variantes[*p.VarianteID], p)
+		}
+	}
+	return g, nil
+}
+
+// pourVariante : paliers propres de la variante, sinon ceux du produit ;
+// propres indique lesquels
+func (g *grillePaliers) pourVariante(produitID, varianteID string) (paliers []models.PalierQuantite, propres bool) {
+	if propres := g.variantes[varianteID]; len(propres) > 0 {
+		return propres, true
+	}
+	return g.produits[produitID], false
+}
+
+// palierApplicable : palier de plus grande quantité minimale atteinte
+func palierApplicable(paliers []dto.PalierResponse, quantite int) *dto.PalierResponse {
+	var applicable *dto.PalierResponse
+	for i := range paliers {
+		if paliers[i].QuantiteMin <= quantite {
+			applicable = &paliers[i]
+		}
+	}
+	return applicable
+}
+
+func paliersVersResponse(paliers []models.PalierQuantite, devise string) []dto.PalierResponse {
+	if len(paliers) == 0 {
+		return nil
+	}
+	resp := make([]dto.PalierResponse, len(paliers))
+	for i, p := range paliers {
+		resp[i] = dto.PalierResponse{
+			QuantiteMin:  p.QuantiteMin,
+			PrixUnitaire: monnaie.NouveauPrix(p.Montant, devise),
+		}
+		if i+1 < len(paliers) {
+			max := paliers[i+1].QuantiteMin - 1
+			resp[i].QuantiteMax = &max
+		}
+	}
+	return resp
+}
+
+// ------------------------------------------------------------
+// Gestion des paliers
+// ------------------------------------------------------------
+func (s *TarifService) ListPaliers(ctx context.Context, produitID, boutiqueID string) (*dto.PaliersProduitResponse, error) {
+	if boutiqueID == "" {
+		return nil, errors.New("boutique ID is required")
+	}
+	produits, err := s.repo.ProduitsTarifes(ctx, boutiqueID, []string{produitID})
+	if err != nil {
+		return nil, err
+	}
+	if len(produits) == 0 {
+		return nil, errors.New("product not found")
+	}
+	g, err := s.grille(ctx, []string{produitID})
+	if err != nil {
+		return nil, err
+	}
+
+	devise := produits[0].Devise
+	resp := &dto.PaliersProduitResponse{
+		ProduitID: produitID,
+		Paliers:   paliersVersResponse(g.produits[produitID], devise),
+		Variantes: map[string][]dto.PalierResponse{},
+	}
+	if resp.Paliers == nil {
+		resp.Paliers = []dto.PalierResponse{}
+	}
+	for varianteID, paliers := range g.variantes {
+		resp.Variantes[varianteID] = paliersVersResponse(paliers, devise)
+	}
+	return resp, nil
+}
+
+// DefinirPaliers remplace les paliers du produit ou d'une de ses variantes ;
+// les prix sont arrondis à la devise du produit
+func (s *TarifService) DefinirPaliers(ctx context.Context, produitID, boutiqueID string, req dto.RequetePaliers) (*dto.PaliersProduitResponse, error) {
+	if boutiqueID == "" {
+		return nil, errors.New("boutique ID is required")
+	}
+	produits, err := s.repo.ProduitsTarifes(ctx, boutiqueID, []string{produitID})
+	if err != nil {
+		return nil, err
+	}
+	if len(produits) == 0 {
+		return nil, errors.New("product not found")
+	}
+	if req.VarianteID != nil {
+		references, err := s.repo.ReferencesCatalogue(ctx, boutiqueID, []string{produitID})
+		if err != nil {
+			return nil, err
+		}
+		if !references[produitID][*req.VarianteID] {
+			return nil, fmt.Errorf("%w: variante %s hors du produit", ErrPaliers, *req.VarianteID)
+		}
+	}
+
+	vues := map[int]bool{}
+	paliers := make([]models.PalierQuantite, len(req.Paliers))
+	for i, p := range req.Paliers {
+		if vues[p.QuantiteMin] {
+			return nil, fmt.Errorf("%w: quantite_min %d en double", ErrPaliers, p.QuantiteMin)
+		}
+		vues[p.QuantiteMin] = true
+		paliers[i] = models.PalierQuantite{QuantiteMin: p.QuantiteMin, Montant: p.Prix.Arrondir(produits[0].Devise)}
+	}
+	sort.Slice(paliers, func(i, j int) bool { return paliers[i].QuantiteMin < paliers[j].QuantiteMin })
+
+	if err := s.repo.RemplacerPaliers(ctx, produitID, req.VarianteID, paliers); err != nil {
+		return nil, err
+	}
+	return s.ListPaliers(ctx, produitID, boutiqueID)
+}
+
+func (g *grillePaliers) completerVariantes(produitID string, variantes []dto.VarianteResponse) {
+	for i := range variantes {
+		paliers, _ := g.pourVariante(produitID, variantes[i].ID)
+		variantes[i].Paliers = paliersVersResponse(paliers, variantes[i].PrixEffectif.Devise)
+	}
+}
+
+// ------------------------------------------------------------
+// Calcul de prix par quantité
+// ------------------------------------------------------------
+
+// CalculerPrix applique les paliers aux lignes demandées. Les quantités
+// d'une même variante s'additionnent ; celles des variantes qui héritent des
+// paliers du produit s'additionnent par produit (tailles panachées). Le
+// client paie le moins cher du palier et du prix effectif (promotion comprise),
+// aux prix de son groupe de clients le cas échéant.
+func (s *TarifService) CalculerPrix(ctx context.Context, boutiqueID string, contexte ContexteTarif, req dto.RequeteCalculPrix) (*dto.ReponseCalculPrix, error) {
+	if boutiqueID == "" {
+		return nil, errors.New("boutique ID is required")
+	}
+
+	varianteIDs := []string{}
+	for _, l := range req.Lignes {
+		if l.VarianteID != nil {
+			varianteIDs = append(varianteIDs, *l.VarianteID)
+		}
+	}
+	variantes, err := s.repo.VariantesTarifees(ctx, boutiqueID, varianteIDs)
+	if err != nil {
+		return nil, err
+	}
+	parVariante := map[string]models.Variante{}
+	for _, v := range variantes {
+		parVariante[v.ID] = v
+	}
+
+	// produit de chaque ligne, désigné ou déduit de la variante
+	problemes := []string{}
+	produitsLignes := make([]string, len(req.Lignes))
+	for i, l := range req.Lignes {
+		if l.VarianteID != nil {
+			v, ok := parVariante[*l.VarianteID]
+			switch {
+			case !ok:
+				problemes = append(problemes, fmt.Sprintf("ligne %d: variante %s inconnue", i, *l.VarianteID))
+			case l.ProduitID != nil && *l.ProduitID != v.ProduitID:
+				problemes = append(problemes, fmt.Sprintf("ligne %d: variante %s hors du produit", i, *l.VarianteID))
+			}
+			produitsLignes[i] = v.ProduitID
+		} else {
+			produitsLignes[i] = *l.ProduitID
+		}
+	}
+	if len(problemes) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrCalculPrix, strings.Join(problemes, ", "))
+	}
+	references, err := s.repo.ReferencesCatalogue(ctx, boutiqueID, produitsLignes)
+	if err != nil {
+		return nil, err
+	}
+	for i, l := range req.Lignes {
+		if l.VarianteID != nil {
+			continue
+		}
+		variantesProduit, ok := references[produitsLignes[i]]
+		switch {
+		case !ok:
+			problemes = append(problemes, fmt.Sprintf("ligne %d: produit %s inconnu", i, produitsLignes[i]))
+		case len(variantesProduit) > 0:
+			problemes = append(problemes, fmt.Sprintf("ligne %d: le produit a des variantes, variante_id requis", i))
+		}
+	}
+	if len(problemes) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrCalculPrix, strings.Join(problemes, ", "))
+	}
+
+	produits, err := s.repo.ProduitsTarifes(ctx, boutiqueID, produitsLignes)
+	if err != nil {
+		return nil, err
+	}
+	parProduit := map[string]*models.Produit{}
+	for i := range produits {
+		parProduit[produits[i].ID] = &produits[i]
+	}
+	g, err := s.grille(ctx, produitsLignes)
+	if err != nil {
+		return nil, err
+	}
+	regles, err := s.reglesDuGroupe(ctx, boutiqueID, contexte.Groupe)
+	if err != nil {
+		return nil, err
+	}
+
+	// quantités cumulées par grille de paliers
+	cles := make([]string, len(req.Lignes))
+	cumuls := map[string]int{}
+	for i, l := range req.Lignes {
+		cles[i] = "p:" + produitsLignes[i]
+		if l.VarianteID != nil {
+			if _, propres := g.pourVariante(produitsLignes[i], *l.VarianteID); propres {
+				cles[i] = "v:" + *l.VarianteID
+			}
+		}
+		cumuls[cles[i]] += l.Quantite
+	}
+
+	maintenant := time.Now()
+	resp := &dto.ReponseCalculPrix{Lignes: make([]dto.LigneCalculResponse, len(req.Lignes))}
+	for i, l := range req.Lignes {
+		produit := parProduit[produitsLignes[i]]
+		var base monnaie.Prix
+		var paliers []dto.PalierResponse
+		if l.VarianteID != nil {
+			variante := []dto.VarianteResponse{varianteVersResponse(parVariante[*l.VarianteID], tarifProduit(produit))}
+			g.completerVariantes(produit.ID, variante)
+			if regles != nil {
+				regles.appliquerVariantes(tarifProduit(produit), produit.ID, produit.Marque, variante, maintenant)
+			}
+			base, paliers = variante[0].PrixEffectif, variante[0].Paliers
+		} else {
+			tarif := ligneProduit(produit, g.produits[produit.ID], maintenant)
+			if regles != nil {
+				regles.appliquerProduit(&tarif, maintenant)
+			}
+			base, paliers = *tarif.PrixEffectif, tarif.Paliers
+		}
+
+		ligne := dto.LigneCalculResponse{
+			ProduitID:    produit.ID,
+			VarianteID:   l.VarianteID,
+			Quantite:     l.Quantite,
+			PrixBase:     base,
+			PrixUnitaire: base,
+		}
+		if palier := palierApplicable(paliers, cumuls[cles[i]]); palier != nil && palier.PrixUnitaire.Montant < base.Montant {
+			min := palier.QuantiteMin
+			ligne.PrixUnitaire = palier.PrixUnitaire
+			ligne.PalierMin = &min
+		}
+		ligne.TotalLigne = monnaie.NouveauPrix(ligne.PrixUnitaire.Montant.Multiplier(int64(l.Quantite)), base.Devise)
+		resp.Lignes[i] = ligne
+	}
+
+	// total seulement dans une devise unique
+	total := monnaie.NouveauPrix(0, resp.Lignes[0].TotalLigne.Devise)
+	for _, l := range resp.Lignes {
+		if l.TotalLigne.Devise != total.Devise {
+			return resp, nil
+		}
+		total.Montant += l.TotalLigne.Montant
+	}
+	resp.Total = &total
+	return resp, nil
+}
+
+// ligneProduit : le tarif d'un produit sans variante sous forme de réponse,
+// pour lui appliquer les règles du groupe comme à l'affichage
+func ligneProduit(p *models.Produit, paliers []models.PalierQuantite, t time.Time) dto.ProduitResponse {
+	affichage := calculerAffichage(prixDefaut(p), p.Promotion, t)
+	return dto.ProduitResponse{
+		ID:                p.ID,
+		PrixDefaut:        prixDefaut(p),
+		Devise:            p.Devise,
+		Marque:            p.Marque,
+		PrixBarre:         prixOptionnel(p.PrixBarre, p.Devise),
+		PrixPromo:         prixOptionnel(p.PrixPromo, p.Devise),
+		DebutPromo:        p.DebutPromo,
+		FinPromo:          p.FinPromo,
+		PromoActive:       affichage.active,
+		PrixEffectif:      &affichage.effectif,
+		PrixOrigine:       affichage.origine,
+		RemisePourcentage: affichage.remise,
+		Paliers:           paliersVersResponse(paliers, p.Devise),
+	}
+}
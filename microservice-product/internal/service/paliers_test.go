@@ -0,0 +1,136 @@
+package service
+
+import (
+	"projet/internal/dto"
+	"projet/internal/models"
+	"projet/internal/monnaie"
+	"testing"
+)
+
+func montant(t *testing.T, s string) monnaie.Montant {
+	t.Helper()
+	m, err := monnaie.Parse(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// grilleTest : le produit p1 a trois paliers, sa variante v1 deux paliers
+// propres, v2 n'en a pas et hérite de ceux du produit
+func grilleTest(t *testing.T) *grillePaliers {
+	v1 := "v1"
+	return &grillePaliers{
+		produits: map[string][]models.PalierQuantite{"p1": {
+			{ProduitID: "p1", QuantiteMin: 10, Montant: montant(t, "9")},
+			{ProduitID: "p1", QuantiteMin: 50, Montant: montant(t, "8")},
+			{ProduitID: "p1", QuantiteMin: 100, Montant: montant(t, "7.5")},
+		}},
+		variantes: map[string][]models.PalierQuantite{"v1": {
+			{ProduitID: "p1", VarianteID: &v1, QuantiteMin: 5, Montant: montant(t, "11")},
+			{ProduitID: "p1", VarianteID: &v1, QuantiteMin: 20, Montant: montant(t, "10")},
+		}},
+	}
+}
+
+func TestPaliersVariante(t *testing.T) {
+	g := grilleTest(t)
+
+	paliers, propres := g.pourVariante("p1", "v1")
+	if !propres || len(paliers) != 2 || paliers[0].QuantiteMin != 5 {
+		t.Fatalf("v1 : paliers propres attendus, obtenu %v (propres=%v)", paliers, propres)
+	}
+	paliers, propres = g.pourVariante("p1", "v2")
+	if propres || len(paliers) != 3 || paliers[0].QuantiteMin != 10 {
+		t.Fatalf("v2 : paliers du produit attendus, obtenu %v (propres=%v)", paliers, propres)
+	}
+	if paliers, _ := g.pourVariante("p2", "v3"); len(paliers) != 0 {
+		t.Fatalf("p2 sans paliers, obtenu %v", paliers)
+	}
+}
+
+func TestPaliersVersResponse(t *testing.T) {
+	resp := paliersVersResponse(grilleTest(t).produits["p1"], "EUR")
+	if len(resp) != 3 {
+		t.Fatalf("3 paliers attendus, obtenu %d", len(resp))
+	}
+	// chaque palier court jusqu'au suivant, le dernier est ouvert
+	if resp[0].QuantiteMax == nil || *resp[0].QuantiteMax != 49 {
+		t.Errorf("palier 10 : quantite_max 49 attendue, obtenu %v", resp[0].QuantiteMax)
+	}
+	if resp[1].QuantiteMax == nil || *resp[1].QuantiteMax != 99 {
+		t.Errorf("palier 50 : quantite_max 99 attendue, obtenu %v", resp[1].QuantiteMax)
+	}
+	if resp[2].QuantiteMax != nil {
+		t.Errorf("dernier palier ouvert attendu, obtenu %d", *resp[2].QuantiteMax)
+	}
+	if resp[2].PrixUnitaire.Devise != "EUR" || resp[2].PrixUnitaire.Montant != montant(t, "7.5") {
+		t.Errorf("prix du palier 100 : %v", resp[2].PrixUnitaire)
+	}
+	if paliersVersResponse(nil, "EUR") != nil {
+		t.Error("sans paliers, nil attendu")
+	}
+}
+
+func TestPalierApplicable(t *testing.T) {
+	paliers := paliersVersResponse(grilleTest(t).produits["p1"], "EUR")
+	cas := map[int]int{ // quantité -> quantite_min du palier (0 = aucun)
+		1:   0,
+		9:   0,
+		10:  10,
+		49:  10,
+		50:  50,
+		99:  50,
+		100: 100,
+		500: 100,
+	}
+	for quantite, attendu := range cas {
+		palier := palierApplicable(paliers, quantite)
+		switch {
+		case attendu == 0 && palier != nil:
+			t.Errorf("quantité %d : aucun palier attendu, obtenu %d", quantite, palier.QuantiteMin)
+		case attendu != 0 && (palier == nil || palier.QuantiteMin != attendu):
+			t.Errorf("quantité %d : palier %d attendu, obtenu %v", quantite, attendu, palier)
+		}
+	}
+}
+
+func TestCompleterVariantes(t *testing.T) {
+	variantes := []dto.VarianteResponse{
+		{ID: "v1", PrixEffectif: monnaie.NouveauPrix(montant(t, "12"), "EUR")},
+		{ID: "v2", PrixEffectif: monnaie.NouveauPrix(montant(t, "10"), "EUR")},
+	}
+	grilleTest(t).completerVariantes("p1", variantes)
+
+	if len(variantes[0].Paliers) != 2 || variantes[0].Paliers[0].QuantiteMin != 5 {
+		t.Errorf("v1 : ses paliers propres attendus, obtenu %v", variantes[0].Paliers)
+	}
+	if len(variantes[1].Paliers) != 3 || variantes[1].Paliers[0].QuantiteMin != 10 {
+		t.Errorf("v2 : paliers du produit attendus, obtenu %v", variantes[1].Paliers)
+	}
+}
+
+func TestPaliersConvertis(t *testing.T) {
+	taux, err := monnaie.ParseTaux("3.3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conv := &convertisseur{
+		loc:      ContexteTarif{Devise: "TND"},
+		explicit: map[string]monnaie.Montant{},
+		taux:     map[string]monnaie.Taux{"EUR/TND": taux},
+	}
+	paliers := conv.paliers(paliersVersResponse(grilleTest(t).produits["p1"], "EUR"))
+	if len(paliers) != 3 {
+		t.Fatalf("3 paliers convertis attendus, obtenu %v", paliers)
+	}
+	if p := paliers[0].PrixUnitaire; p.Devise != "TND" || p.Montant != montant(t, "29.7") {
+		t.Errorf("9 EUR à 3.3 : 29.700 TND attendu, obtenu %s %s", p.Montant, p.Devise)
+	}
+
+	// sans taux, les paliers sont omis plutôt que laissés en euros
+	conv.loc.Devise = "USD"
+	if paliers := conv.paliers(paliersVersResponse(grilleTest(t).produits["p1"], "EUR")); paliers != nil {
+		t.Errorf("sans taux EUR/USD, paliers omis attendus, obtenu %v", paliers)
+	}
+}
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Planificateur applique périodiquement DatePublication / DateDepublication.
+// Chaque passage traite tout ce qui est échu (date <= maintenant), donc les
+// ticks manqués pendant un arrêt sont rattrapés au premier passage suivant.
+// Seuls les produits marqués programme sont concernés (voir cycleVie.go) :
+// les dates antérieures au planificateur ne publient rien au déploiement.
+// Plusieurs répliques peuvent tourner en même temps : le repo prend un verrou
+// consultatif Postgres et une seule réplique travaille par passage.
+//
+// Il purge aussi la corbeille : un produit supprimé depuis plus de
+// retentionCorbeille est supprimé définitivement (0 désactive la purge).
+type Planificateur struct {
+	produits           *ProduitService
+	intervalle         time.Duration
+	retentionCorbeille time.Duration
+}
+
+func NewPlanificateur(produits *ProduitService, intervalle, retentionCorbeille time.Duration) *Planificateur {
+	if intervalle <= 0 {
+		intervalle = time.Minute
+	}
+	return &Planificateur{produits: produits, intervalle: intervalle, retentionCorbeille: retentionCorbeille}
+}
+
+// Demarrer bloque jusqu'à l'annulation de ctx : à lancer dans une goroutine.
+func (p *Planificateur) Demarrer(ctx context.Context) {
+	// premier passage immédiat pour rattraper ce qui a été manqué pendant l'arrêt
+	p.executer(ctx)
+
+	ticker := time.NewTicker(p.intervalle)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.executer(ctx)
+		}
+	}
+}
+
+// executer lance les deux tâches indépendamment : l'échec de l'une n'empêche
+// pas l'autre de passer
+func (p *Planificateur) executer(ctx context.Context) {
+	p.programmer(ctx)
+	p.purger(ctx)
+}
+
+func (p *Planificateur) programmer(ctx context.Context) {
+	publies, archives, err := p.produits.AppliquerProgrammation(ctx, time.Now())
+	if err != nil {
+		log.Printf("planificateur: %v", err)
+		return
+	}
+	if publies > 0 || archives > 0 {
+		log.Printf("planificateur: %d produit(s) publié(s), %d archivé(s)", publies, archives)
+	}
+}
+
+func (p *Planificateur) purger(ctx context.Context) {
+	if p.retentionCorbeille <= 0 {
+		return
+	}
+	purges, err := p.produits.PurgerCorbeille(ctx, p.retentionCorbeille)
+	if err != nil {
+		log.Printf("planificateur: purge corbeille: %v", err)
+	}
+	if purges > 0 {
+		log.Printf("planificateur: %d produit(s) purgé(s) de la corbeille", purges)
+	}
+}
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"context"
+	"projet/internal/dto"
+	"projet/internal/models"
+	"time"
+)
+
+// ContexteTarif : à qui et où s'affichent les prix. Devise, pays et région
+// viennent de ?devise=TND&pays=TN&region= (Devise vide = devise du produit) ;
+// Groupe est le code du groupe de clients (vide = prix publics)
+type ContexteTarif struct {
+	Devise string
+	Pays   string
+	Region string
+	Groupe string
+}
+
+// ------------------------------------------------------------
+// Présentation des réponses
+// ------------------------------------------------------------
+
+// PresenterProduits complète les réponses produits : paliers de quantité,
+// prix du groupe de clients, prix dans la devise demandée, puis ventilation
+// hors taxes / taxes comprises
+func (s *TarifService) PresenterProduits(ctx context.Context, boutiqueID string, contexte ContexteTarif, produits ...*dto.ProduitResponse) error {
+	if len(produits) == 0 {
+		return nil
+	}
+	ids := make([]string, len(produits))
+	for i, p := range produits {
+		ids[i] = p.ID
+	}
+	g, err := s.grille(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for _, p := range produits {
+		p.Paliers = paliersVersResponse(g.produits[p.ID], p.Devise)
+		g.completerVariantes(p.ID, p.Variantes)
+	}
+
+	regles, err := s.reglesDuGroupe(ctx, boutiqueID, contexte.Groupe)
+	if err != nil {
+		return err
+	}
+	if regles != nil {
+		maintenant := time.Now()
+		for _, p := range produits {
+			regles.appliquerProduit(p, maintenant)
+		}
+	}
+	if err := s.localiserProduits(ctx, boutiqueID, contexte, produits...); err != nil {
+		return err
+	}
+
+	taxes, err := s.moteurTaxe(ctx, boutiqueID, contexte)
+	if err != nil || taxes == nil {
+		return err
+	}
+	for _, p := range produits {
+		taxes.taxerProduit(p)
+	}
+	return nil
+}
+
+// PresenterVariantes : même chose pour des variantes d'un même produit
+func (s *TarifService) PresenterVariantes(ctx context.Context, boutiqueID string, contexte ContexteTarif, produitID string, variantes []dto.VarianteResponse) error {
+	if len(variantes) == 0 {
+		return nil
+	}
+	g, err := s.grille(ctx, []string{produitID})
+	if err != nil {
+		return err
+	}
+	g.completerVariantes(produitID, variantes)
+
+	// prix public, marque et classe de taxe du produit, hérités par les variantes
+	// (produit dans la corbeille : ni groupe ni taxes)
+	produits, err := s.repo.ProduitsTarifes(ctx, boutiqueID, []string{produitID})
+	if err != nil {
+		return err
+	}
+	var produit *models.Produit
+	if len(produits) > 0 {
+		produit = &produits[0]
+	}
+
+	regles, err := s.reglesDuGroupe(ctx, boutiqueID, contexte.Groupe)
+	if err != nil {
+		return err
+	}
+	if regles != nil && produit != nil {
+		regles.appliquerVariantes(tarifProduit(produit), produitID, produit.Marque, variantes, time.Now())
+	}
+	if err := s.localiserVariantes(ctx, boutiqueID, contexte, produitID, variantes); err != nil {
+		return err
+	}
+
+	taxes, err := s.moteurTaxe(ctx, boutiqueID, contexte)
+	if err != nil || taxes == nil || produit == nil {
+		return err
+	}
+	taxes.taxerVariantes(produit.ClasseTaxe, variantes)
+	return nil
+}
@@ -0,0 +1,48 @@
+package service
+
+import (
+	"projet/internal/models"
+	"projet/internal/monnaie"
+)
+
+// prixDefaut : prix par défaut du produit dans sa devise, hérité par les
+// variantes sans prix propre
+func prixDefaut(p *models.Produit) monnaie.Prix {
+	return monnaie.NouveauPrix(p.PrixDefaut, p.Devise)
+}
+
+// arrondirPrix ramène la colonne de prix d'un jeu de modifications à
+// l'exposant de la devise (19.999 EUR -> 20.00, 19.9995 TND -> 20.000)
+func arrondirPrix(modifications map[string]interface{}, colonne, devise string) {
+	switch prix := modifications[colonne].(type) {
+	case monnaie.Montant:
+		modifications[colonne] = prix.Arrondir(devise)
+	case *monnaie.Montant:
+		if prix != nil {
+			arrondi := prix.Arrondir(devise)
+			modifications[colonne] = &arrondi
+		}
+	}
+}
+
+// deviseModifiee : la devise après modification, sinon celle d'origine
+func deviseModifiee(modifications map[string]interface{}, origine string) string {
+	switch devise := modifications["devise"].(type) {
+	case string:
+		return devise
+	case *string:
+		if devise != nil {
+			return *devise
+		}
+	}
+	return origine
+}
+
+// arrondirMontant : variante optionnelle de Montant.Arrondir
+func arrondirMontant(m *monnaie.Montant, devise string) *monnaie.Montant {
+	if m == nil {
+		return nil
+	}
+	arrondi := m.Arrondir(devise)
+	return &arrondi
+}
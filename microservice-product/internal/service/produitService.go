@@ -2,21 +2,38 @@ package service
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"log"
 	"projet/internal/dto"
+	"projet/internal/gtin"
 	"projet/internal/models"
 	"projet/internal/repository"
 	"strings"
 	"time"
 )
 
+// ErrVersionObsolete : l'If-Match du client ne correspond plus à la version en base
+var ErrVersionObsolete = repository.ErrVersionObsolete
+
 /*kik 3ada loula injectionde dépendance ou thneya constructeur*/
 type ProduitService struct {
-	repo *repository.ProduitRepo
+	repo       *repository.ProduitRepo
+	evenements PublieurEvenements
+	audit      *AuditService
+	revisions  *RevisionService
+}
+
+func NewProduitService(repo *repository.ProduitRepo, evenements PublieurEvenements, audit *AuditService, revisions *RevisionService) *ProduitService {
+	return &ProduitService{repo: repo, evenements: evenements, audit: audit, revisions: revisions}
 }
 
-func NewProduitService(repo *repository.ProduitRepo) *ProduitService {
-	return &ProduitService{repo: repo}
+// evenements est optionnel : sans publieur on ne notifie personne
+func (s *ProduitService) publier(ctx context.Context, boutiqueID, evenement string, donnees interface{}) {
+	if s.evenements != nil {
+		s.evenements.Publier(ctx, boutiqueID, evenement, donnees)
+	}
 }
 
 /*t7wl ll produit model il reponse illi fo dto*/
@@ -27,12 +44,7 @@ func (s *ProduitService) toResponse(p models.Produit) dto.ProduitResponse {
 		// Convertir les valeurs de cette option
 		valeurs := make([]dto.ValeurOptionResponse, len(opt.ValeurOpts))
 		for j, val := range opt.ValeurOpts {
-			valeurs[j] = dto.ValeurOptionResponse{
-				ID:       val.ID,
-				OptionID: val.OptionID,
-				Valeur:   val.Valeur,
-				Position: val.Position,
-			}
+			valeurs[j] = valeurOptionVersResponse(val)
 		}
 
 		options[i] = dto.OptionProduitResponse{
@@ -40,33 +52,59 @@ func (s *ProduitService) toResponse(p models.Produit) dto.ProduitResponse {
 			ProduitID:  opt.ProduitID,
 			Nom:        opt.Nom,
 			Position:   opt.Position,
+			Version:    opt.Version,
+			ModeleID:   opt.ModeleID,
 			CreeLe:     opt.CreeLe,
 			MisAJourLe: opt.MisAJourLe,
 			ValeurOpts: valeurs,
 		}
 	}
 
+	variantes := make([]dto.VarianteResponse, len(p.Variantes))
+	for i, v := range p.Variantes {
+		variantes[i] = varianteVersResponse(v, tarifProduit(&p))
+	}
+
+	var supprimeLe *time.Time
+	if p.SupprimeLe.Valid {
+		supprimeLe = &p.SupprimeLe.Time
+	}
+	affichage := calculerAffichage(prixDefaut(&p), p.Promotion, time.Now())
+
 	return dto.ProduitResponse{
-		ID:              p.ID,
-		Titre:           p.Titre,
-		Description:     p.Description,
-		Slug:            p.Slug,
-		Statut:          p.Statut,
-		PrixDefaut:      p.PrixDefaut,
-		Devise:          p.Devise,
-		SKU:             p.SKU,
-		SuiviStock:      p.SuiviStock,
-		QuantiteStock:   p.QuantiteStock,
-		Poids:           p.Poids,
-		Dimensions:      p.Dimensions,
-		Marque:          p.Marque,
-		ClasseTaxe:      p.ClasseTaxe,
-		Visibilite:      p.Visibilite,
-		DatePublication: p.DatePublication,
-		CreeLe:          p.CreeLe,
-		MisAJourLe:      p.MisAJourLe,
-		Options:         options,
-		Variantes:       []dto.VarianteResponse{},
+		ID:                p.ID,
+		BoutiqueID:        p.BoutiqueID,
+		Titre:             p.Titre,
+		Description:       p.Description,
+		Slug:              p.Slug,
+		Statut:            p.Statut,
+		PrixDefaut:        prixDefaut(&p),
+		Devise:            p.Devise,
+		SKU:               p.SKU,
+		SuiviStock:        p.SuiviStock,
+		QuantiteStock:     p.QuantiteStock,
+		Poids:             p.Poids,
+		Dimensions:        p.Dimensions,
+		Marque:            p.Marque,
+		ClasseTaxe:        p.ClasseTaxe,
+		Visibilite:        p.Visibilite,
+		Images:            p.Images,
+		DatePublication:   p.DatePublication,
+		DateDepublication: p.DateDepublication,
+		CreeLe:            p.CreeLe,
+		MisAJourLe:        p.MisAJourLe,
+		SupprimeLe:        supprimeLe,
+		Version:           p.Version,
+		Options:           options,
+		Variantes:         variantes,
+		PrixBarre:         prixOptionnel(p.PrixBarre, p.Devise),
+		PrixPromo:         prixOptionnel(p.PrixPromo, p.Devise),
+		DebutPromo:        p.DebutPromo,
+		FinPromo:          p.FinPromo,
+		PromoActive:       affichage.active,
+		PrixEffectif:      &affichage.effectif,
+		PrixOrigine:       affichage.origine,
+		RemisePourcentage: affichage.remise,
 	}
 }
 
@@ -85,34 +123,122 @@ func (s *ProduitService) Create(ctx context.Context, boutiqueID string, req dto.
 
 	//tisnaa3 produit
 	produit := &models.Produit{
-		BoutiqueID:      boutiqueID,
-		Titre:           req.Titre,
-		Description:     req.Description,
-		Slug:            *req.Slug,
-		Statut:          req.Statut,
-		PrixDefaut:      req.PrixDefaut,
-		Devise:          req.Devise,
-		SKU:             req.SKU,
-		SuiviStock:      req.SuiviStock,
-		QuantiteStock:   req.QuantiteStock,
-		Poids:           req.Poids,
-		Dimensions:      req.Dimensions,
-		Marque:          req.Marque,
-		ClasseTaxe:      req.ClasseTaxe,
-		Visibilite:      req.Visibilite,
-		DatePublication: req.DatePublication,
+		BoutiqueID:        boutiqueID,
+		Titre:             req.Titre,
+		Description:       req.Description,
+		Slug:              *req.Slug,
+		Statut:            req.Statut,
+		PrixDefaut:        req.PrixDefaut.Arrondir(req.Devise),
+		Devise:            req.Devise,
+		SKU:               skuProduit(req.SKU),
+		SuiviStock:        req.SuiviStock,
+		QuantiteStock:     req.QuantiteStock,
+		Poids:             req.Poids,
+		Dimensions:        req.Dimensions,
+		Marque:            req.Marque,
+		ClasseTaxe:        req.ClasseTaxe,
+		Visibilite:        req.Visibilite,
+		Images:            req.Images,
+		DatePublication:   req.DatePublication,
+		DateDepublication: req.DateDepublication,
+	}
+	promotion, err := arrondirPromotion(models.Promotion{
+		PrixBarre:  req.PrixBarre,
+		PrixPromo:  req.PrixPromo,
+		DebutPromo: req.DebutPromo,
+		FinPromo:   req.FinPromo,
+	}, req.Devise, req.PrixDefaut)
+	if err != nil {
+		return nil, err
+	}
+	produit.Promotion = promotion
+	if err := verifierDatesPublication(produit.DatePublication, produit.DateDepublication); err != nil {
+		return nil, err
+	}
+	produit.Programme = produit.DatePublication != nil || produit.DateDepublication != nil
+	if err := verifierClasseTaxe(ctx, s.repo, boutiqueID, req.ClasseTaxe); err != nil {
+		return nil, err
+	}
+
+	// options, valeurs et variantes imbriquées : validées avant toute écriture
+	agregat := len(req.Options) > 0 || len(req.Variantes) > 0
+	if agregat {
+		if err := construireAgregat(produit, req.Options, req.Variantes); err != nil {
+			return nil, err
+		}
+		for i := range produit.Variantes {
+			produit.Variantes[i].Prix = arrondirMontant(produit.Variantes[i].Prix, produit.Devise)
+		}
+		if err := s.verifierCodesBarres(ctx, boutiqueID, produit.Variantes); err != nil {
+			return nil, err
+		}
+	}
+	// SKU uniques dans la boutique, produits et variantes confondus
+	if err := verifierSKUs(ctx, s.repo, boutiqueID, skusAgregat(produit), ""); err != nil {
+		return nil, err
+	}
+
+	// le créer directement publié passe par les mêmes exigences que
+	// POST /produits/:id/publier (variantes imbriquées comprises)
+	brouillon := *produit
+	brouillon.Statut = models.StatutBrouillon
+	if err := verifierTransition(brouillon, req.Statut); err != nil {
+		return nil, err
 	}
 
 	//t3yt li repositroy
-	created, err := s.repo.CreateProduit(ctx, produit)
+	var created *models.Produit
+	if agregat {
+		created, err = s.repo.CreerAgregat(ctx, produit)
+	} else {
+		created, err = s.repo.CreateProduit(ctx, produit)
+	}
 	if err != nil {
 		return nil, err
 	}
+	s.auditerCreation(ctx, created)
+
 	//t3yt ll helper (func tit3wd bech nhiw redendance) illi lfou9
 	resp := s.toResponse(*created)
+	if created.Statut == models.StatutPublie {
+		s.publier(ctx, boutiqueID, models.EvenementProduitPublie, resp)
+	}
 	return &resp, nil
 }
 
+// verifierCodesBarres refuse les codes-barres de variantes neuves déjà
+// utilisés dans la boutique (format et doublons internes : construireAgregat)
+func (s *ProduitService) verifierCodesBarres(ctx context.Context, boutiqueID string, variantes []models.Variante) error {
+	codes := []string{}
+	for _, v := range variantes {
+		if v.CodeBarres != nil && *v.CodeBarres != "" {
+			codes = append(codes, gtin.Normaliser(*v.CodeBarres))
+		}
+	}
+	pris, err := s.repo.CodesBarresPris(ctx, boutiqueID, codes, "")
+	if err != nil {
+		return err
+	}
+	if len(pris) > 0 {
+		return fmt.Errorf("%w: %s", ErrCodeBarresPris, strings.Join(pris, ", "))
+	}
+	return nil
+}
+
+// auditerCreation journalise un produit neuf et tout son agrégat
+func (s *ProduitService) auditerCreation(ctx context.Context, p *models.Produit) {
+	s.audit.Enregistrer(ctx, p.BoutiqueID, p.ID, models.EntiteProduit, p.ID, models.ActionCreation, nil, p)
+	for _, opt := range p.Options {
+		s.audit.Enregistrer(ctx, p.BoutiqueID, p.ID, models.EntiteOption, opt.ID, models.ActionCreation, nil, opt)
+		for _, val := range opt.ValeurOpts {
+			s.audit.Enregistrer(ctx, p.BoutiqueID, p.ID, models.EntiteValeurOption, val.ID, models.ActionCreation, nil, val)
+		}
+	}
+	for _, v := range p.Variantes {
+		s.audit.Enregistrer(ctx, p.BoutiqueID, p.ID, models.EntiteVariante, v.ID, models.ActionCreation, nil, v)
+	}
+}
+
 func (s *ProduitService) List(ctx context.Context, boutiqueID string) ([]dto.ProduitResponse, error) {
 	if boutiqueID == "" {
 		return nil, errors.New("boutique ID is required")
@@ -149,16 +275,58 @@ func (s *ProduitService) GetByID(ctx context.Context, id, boutiqueID string) (*d
 	return &resp, nil
 }
 
-func (s *ProduitService) Update(ctx context.Context, id, boutiqueID string, req dto.RequeteUpdateProduit) (*dto.ProduitResponse, error) {
+// Tarif : prix par défaut et promotion du produit, base des prix de ses variantes
+func (s *ProduitService) Tarif(ctx context.Context, id, boutiqueID string) (TarifProduit, error) {
+	if boutiqueID == "" {
+		return TarifProduit{}, errors.New("boutique ID is required")
+	}
+	produit, err := s.repo.GetByID(ctx, id, boutiqueID)
+	if err != nil {
+		return TarifProduit{}, err
+	}
+	if produit == nil {
+		return TarifProduit{}, errors.New("product not found")
+	}
+	return tarifProduit(produit), nil
+}
+
+// Update applique une mise à jour partielle. version (If-Match) est optionnelle :
+// si elle est fournie et ne correspond plus, ErrVersionObsolete est renvoyée.
+func (s *ProduitService) Update(ctx context.Context, id, boutiqueID string, req dto.RequeteUpdateProduit, version *int) (*dto.ProduitResponse, error) {
 	if boutiqueID == "" {
 		return nil, errors.New("boutique ID is required")
 	}
 
-	//t3yt ll func illi fou9ha
-	_, err := s.GetByID(ctx, id, boutiqueID)
+	avant, err := s.repo.GetByID(ctx, id, boutiqueID)
 	if err != nil {
 		return nil, err
 	}
+	if avant == nil {
+		return nil, errors.New("product not found")
+	}
+	if version != nil && *version != avant.Version {
+		return nil, ErrVersionObsolete
+	}
+
+	// le tout premier état doit rester restaurable
+	if err := s.revisions.CapturerInitiale(ctx, avant); err != nil {
+		return nil, err
+	}
+
+	// le statut suit le cycle de vie, évalué sur l'état après modification
+	if req.Statut != nil {
+		if err := verifierTransition(produitApres(*avant, req), *req.Statut); err != nil {
+			return nil, err
+		}
+	}
+	updates := champsProduit(req)
+	updates["mis_a_jour_le"] = time.Now()
+
+	return s.enregistrer(ctx, avant, updates, version)
+}
+
+// champsProduit traduit une mise à jour partielle en colonnes : nil = inchangé
+func champsProduit(req dto.RequeteUpdateProduit) map[string]interface{} {
 	//tisnaa slice
 	updates := make(map[string]interface{})
 
@@ -182,7 +350,7 @@ func (s *ProduitService) Update(ctx context.Context, id, boutiqueID string, req
 		updates["devise"] = *req.Devise
 	}
 	if req.SKU != nil {
-		updates["sku"] = *req.SKU
+		updates["sku"] = skuProduit(req.SKU)
 	}
 	if req.SuiviStock != nil {
 		updates["suivi_stock"] = *req.SuiviStock
@@ -205,33 +373,251 @@ func (s *ProduitService) Update(ctx context.Context, id, boutiqueID string, req
 	if req.Visibilite != nil {
 		updates["visibilite"] = *req.Visibilite
 	}
+	if req.Images != nil {
+		updates["images"] = imagesJSON(req.Images)
+	}
 	if req.DatePublication != nil {
 		updates["date_publication"] = *req.DatePublication
 	}
-	updates["mis_a_jour_le"] = time.Now()
+	if req.DateDepublication != nil {
+		updates["date_depublication"] = *req.DateDepublication
+	}
+	champsPromotion(updates, req.PrixBarre, req.PrixPromo, req.DebutPromo, req.FinPromo)
+	return updates
+}
+
+// imagesJSON : serializer:json ne s'applique pas aux updates par map, la
+// colonne jsonb reçoit le tableau déjà encodé (NULL quand il n'y en a pas)
+func imagesJSON(images []string) interface{} {
+	if len(images) == 0 {
+		return nil
+	}
+	encode, _ := json.Marshal(images)
+	return string(encode)
+}
+
+// Document renvoie la représentation modifiable du produit et sa version,
+// base sur laquelle un PATCH est appliqué
+func (s *ProduitService) Document(ctx context.Context, id, boutiqueID string) (*dto.DocumentProduit, int, error) {
+	if boutiqueID == "" {
+		return nil, 0, errors.New("boutique ID is required")
+	}
+	produit, err := s.repo.GetByID(ctx, id, boutiqueID)
+	if err != nil {
+		return nil, 0, err
+	}
+	if produit == nil {
+		return nil, 0, errors.New("product not found")
+	}
+	return &dto.DocumentProduit{
+		Titre:             produit.Titre,
+		Description:       produit.Description,
+		Slug:              produit.Slug,
+		Statut:            produit.Statut,
+		PrixDefaut:        produit.PrixDefaut,
+		Devise:            produit.Devise,
+		SKU:               produit.SKU,
+		SuiviStock:        produit.SuiviStock,
+		QuantiteStock:     produit.QuantiteStock,
+		Poids:             produit.Poids,
+		Dimensions:        produit.Dimensions,
+		Marque:            produit.Marque,
+		ClasseTaxe:        produit.ClasseTaxe,
+		Visibilite:        produit.Visibilite,
+		Images:            produit.Images,
+		DatePublication:   produit.DatePublication,
+		DateDepublication: produit.DateDepublication,
+		PrixBarre:         produit.PrixBarre,
+		PrixPromo:         produit.PrixPromo,
+		DebutPromo:        produit.DebutPromo,
+		FinPromo:          produit.FinPromo,
+	}, produit.Version, nil
+}
+
+// Remplacer écrit le document complet (résultat d'un PATCH déjà validé) :
+// contrairement à Update, un champ nil efface la colonne.
+func (s *ProduitService) Remplacer(ctx context.Context, id, boutiqueID string, doc dto.DocumentProduit, version int) (*dto.ProduitResponse, error) {
+	if boutiqueID == "" {
+		return nil, errors.New("boutique ID is required")
+	}
+
+	avant, err := s.repo.GetByID(ctx, id, boutiqueID)
+	if err != nil {
+		return nil, err
+	}
+	if avant == nil {
+		return nil, errors.New("product not found")
+	}
+	if version != avant.Version {
+		return nil, ErrVersionObsolete
+	}
+
+	if err := s.revisions.CapturerInitiale(ctx, avant); err != nil {
+		return nil, err
+	}
+
+	apres := *avant
+	apres.Titre = doc.Titre
+	apres.PrixDefaut = doc.PrixDefaut
+	apres.Images = doc.Images
+	if err := verifierTransition(apres, doc.Statut); err != nil {
+		return nil, err
+	}
+
+	updates := map[string]interface{}{
+		"titre":              doc.Titre,
+		"description":        doc.Description,
+		"slug":               doc.Slug,
+		"statut":             doc.Statut,
+		"prix_defaut":        doc.PrixDefaut,
+		"devise":             doc.Devise,
+		"sku":                skuProduit(doc.SKU),
+		"suivi_stock":        doc.SuiviStock,
+		"quantite_stock":     doc.QuantiteStock,
+		"poids":              doc.Poids,
+		"dimensions":         doc.Dimensions,
+		"marque":             doc.Marque,
+		"classe_taxe":        doc.ClasseTaxe,
+		"visibilite":         doc.Visibilite,
+		"images":             imagesJSON(doc.Images),
+		"date_publication":   doc.DatePublication,
+		"date_depublication": doc.DateDepublication,
+		"prix_barre":         doc.PrixBarre,
+		"prix_promo":         doc.PrixPromo,
+		"debut_promo":        doc.DebutPromo,
+		"fin_promo":          doc.FinPromo,
+		"mis_a_jour_le":      time.Now(),
+	}
+	return s.enregistrer(ctx, avant, updates, &version)
+}
+
+// enregistrer applique updates sur avant et les journalise dans la même
+// transaction, puis capture la révision et publie les événements de changement de statut
+func (s *ProduitService) enregistrer(ctx context.Context, avant *models.Produit, updates map[string]interface{}, version *int) (*dto.ProduitResponse, error) {
+	id, boutiqueID := avant.ID, avant.BoutiqueID
+	devise := deviseModifiee(updates, avant.Devise)
+	arrondirPrix(updates, "prix_defaut", devise)
+	regulier := avant.PrixDefaut
+	if p := prixModifie(updates, "prix_defaut", &avant.PrixDefaut); p != nil {
+		regulier = *p
+	}
+	if err := preparerPromotion(avant.Promotion, updates, devise, regulier, "prix_defaut"); err != nil {
+		return nil, err
+	}
+	if err := preparerProgrammation(*avant, updates); err != nil {
+		return nil, err
+	}
+
+	if sku, ok := skuModifie(updates); ok {
+		if err := verifierSKUs(ctx, s.repo, boutiqueID, []string{sku}, id); err != nil {
+			return nil, err
+		}
+	}
+	// une classe héritée d'avant les classes de taxe reste tolérée tant qu'elle ne change pas
+	if classe, ok := classeTaxeModifiee(updates); ok && !memeChaine(classe, avant.ClasseTaxe) {
+		if err := verifierClasseTaxe(ctx, s.repo, boutiqueID, classe); err != nil {
+			return nil, err
+		}
+	}
+
+	// l'audit est écrit dans la transaction de la modification : l'un ne va pas sans l'autre
+	var updated *models.Produit
+	err := s.repo.Transaction(ctx, func(produits *repository.ProduitRepo, _ *repository.VarianteRepo, journal *repository.AuditRepo) error {
+		var err error
+		updated, err = produits.Update(ctx, id, boutiqueID, updates, version)
+		if err != nil {
+			return err
+		}
+		if updated == nil {
+			return errors.New("product not found after update")
+		}
+		return s.audit.Dans(journal).Journaliser(ctx, boutiqueID, id, models.EntiteProduit, id, models.ActionModification, avant, updated)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := s.revisions.Capturer(ctx, updated); err != nil {
+		log.Printf("révision produit %s: %v", id, err)
+	}
+
+	resp := s.toResponse(*updated)
+	if avant.Statut != updated.Statut {
+		switch updated.Statut {
+		case models.StatutPublie:
+			s.publier(ctx, boutiqueID, models.EvenementProduitPublie, resp)
+		case models.StatutArchive:
+			s.publier(ctx, boutiqueID, models.EvenementProduitArchive, resp)
+		}
+	}
+	return &resp, nil
+}
+
+// ChangerStatut applique une transition du cycle de vie (publier, archiver, restaurer)
+func (s *ProduitService) ChangerStatut(ctx context.Context, id, boutiqueID string, vers models.StatutProduit, version *int) (*dto.ProduitResponse, error) {
+	if boutiqueID == "" {
+		return nil, errors.New("boutique ID is required")
+	}
+
+	produit, err := s.repo.GetByID(ctx, id, boutiqueID)
+	if err != nil {
+		return nil, err
+	}
+	if produit == nil {
+		return nil, errors.New("product not found")
+	}
+	if version != nil && *version != produit.Version {
+		return nil, ErrVersionObsolete
+	}
+	if err := verifierTransition(*produit, vers); err != nil {
+		return nil, err
+	}
+	if produit.Statut == vers {
+		resp := s.toResponse(*produit)
+		return &resp, nil
+	}
 
-	updated, err := s.repo.Update(ctx, id, boutiqueID, updates)
+	updated, err := s.repo.Update(ctx, id, boutiqueID, map[string]interface{}{
+		"statut":        vers,
+		"mis_a_jour_le": time.Now(),
+	}, version)
 	if err != nil {
 		return nil, err
 	}
 	if updated == nil {
 		return nil, errors.New("product not found after update")
 	}
+	s.audit.Enregistrer(ctx, boutiqueID, id, models.EntiteProduit, id, models.ActionModification, produit, updated)
+
 	resp := s.toResponse(*updated)
+	switch vers {
+	case models.StatutPublie:
+		s.publier(ctx, boutiqueID, models.EvenementProduitPublie, resp)
+	case models.StatutArchive:
+		s.publier(ctx, boutiqueID, models.EvenementProduitArchive, resp)
+	}
 	return &resp, nil
 }
 
-func (s *ProduitService) Delete(ctx context.Context, id, boutiqueID string) error {
+func (s *ProduitService) Delete(ctx context.Context, id, boutiqueID string, version *int) error {
 	if boutiqueID == "" {
 		return errors.New("boutique ID is required")
 	}
-	deleted, err := s.repo.DeleteById(ctx, id, boutiqueID)
+	avant, err := s.repo.GetByID(ctx, id, boutiqueID)
+	if err != nil {
+		return err
+	}
+	deleted, err := s.repo.DeleteById(ctx, id, boutiqueID, version)
 	if err != nil {
 		return err
 	}
 	if !deleted {
 		return errors.New("product not found")
 	}
+	s.audit.Enregistrer(ctx, boutiqueID, id, models.EntiteProduit, id, models.ActionSuppression, avant, nil)
+	s.publier(ctx, boutiqueID, models.EvenementProduitSupprime, map[string]string{
+		"id":          id,
+		"boutique_id": boutiqueID,
+	})
 	return nil
 }
 
@@ -261,3 +647,191 @@ func (s *ProduitService) Search(ctx context.Context, boutiqueID string, filter d
 	}
 	return resp, filter.Page, filter.Limite, nil
 }
+
+// AppliquerProgrammation exécute un passage du planificateur, journalise et notifie
+// chaque transition. Retourne le nombre de produits publiés et archivés.
+func (s *ProduitService) AppliquerProgrammation(ctx context.Context, maintenant time.Time) (int, int, error) {
+	resultat, err := s.repo.AppliquerProgrammation(ctx, maintenant)
+	if err != nil {
+		return 0, 0, err
+	}
+	if !resultat.Verrouille {
+		// une autre réplique s'en occupe
+		return 0, 0, nil
+	}
+
+	ctx = AvecActeur(ctx, ActeurPlanificateur, "")
+	transition := func(p models.Produit, de models.StatutProduit, evenement string) {
+		avant := p
+		avant.Statut = de
+		s.audit.Enregistrer(ctx, p.BoutiqueID, p.ID, models.EntiteProduit, p.ID, models.ActionModification, avant, p)
+		s.publier(ctx, p.BoutiqueID, evenement, s.toResponse(p))
+	}
+
+	for _, p := range resultat.Publies {
+		transition(p, models.StatutBrouillon, models.EvenementProduitPublie)
+	}
+	for _, p := range resultat.Archives {
+		transition(p, models.StatutPublie, models.EvenementProduitArchive)
+	}
+	for _, p := range resultat.ArchivesDepuisBrouillon {
+		transition(p, models.StatutBrouillon, models.EvenementProduitArchive)
+	}
+	return len(resultat.Publies), len(resultat.Archives) + len(resultat.ArchivesDepuisBrouillon), nil
+}
+
+// ------------------------------------------------------------
+// Corbeille
+// ------------------------------------------------------------
+func (s *ProduitService) ListCorbeille(ctx context.Context, boutiqueID string, page, limite int) ([]dto.ProduitResponse, int, int, error) {
+	if boutiqueID == "" {
+		return nil, 0, 0, errors.New("boutique ID is required")
+	}
+	if page <= 0 {
+		page = 1
+	}
+	if limite <= 0 {
+		limite = 20
+	}
+
+	produits, err := s.repo.ListSupprimes(ctx, boutiqueID, page, limite)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	resp := make([]dto.ProduitResponse, len(produits))
+	for i, p := range produits {
+		resp[i] = s.toResponse(p)
+	}
+	return resp, page, limite, nil
+}
+
+// RestaurerCorbeille sort le produit de la corbeille (son statut est conservé) ;
+// "product not found" s'il n'y est pas. La transition archive -> brouillon passe
+// par ChangerStatut.
+func (s *ProduitService) RestaurerCorbeille(ctx context.Context, id, boutiqueID string) (*dto.ProduitResponse, error) {
+	if boutiqueID == "" {
+		return nil, errors.New("boutique ID is required")
+	}
+
+	restaure, err := s.repo.Restaurer(ctx, id, boutiqueID)
+	if err != nil {
+		return nil, err
+	}
+	if !restaure {
+		return nil, errors.New("product not found")
+	}
+	s.audit.Enregistrer(ctx, boutiqueID, id, models.EntiteProduit, id, models.ActionRestauration, nil, nil)
+	return s.GetByID(ctx, id, boutiqueID)
+}
+
+// Purger supprime définitivement le produit, qu'il soit dans la corbeille ou non.
+// version non nil (If-Match) : ErrVersionObsolete si le produit a changé
+func (s *ProduitService) Purger(ctx context.Context, id, boutiqueID string, version *int) error {
+	if boutiqueID == "" {
+		return errors.New("boutique ID is required")
+	}
+
+	// un produit encore actif n'a pas encore notifié sa suppression
+	actif, err := s.repo.GetByID(ctx, id, boutiqueID)
+	if err != nil {
+		return err
+	}
+
+	purge, err := s.repo.Purger(ctx, id, boutiqueID, version)
+	if err != nil {
+		return err
+	}
+	if !purge {
+		return errors.New("product not found")
+	}
+	s.audit.Enregistrer(ctx, boutiqueID, id, models.EntiteProduit, id, models.ActionPurge, actif, nil)
+	if actif != nil {
+		s.publier(ctx, boutiqueID, models.EvenementProduitSupprime, map[string]string{
+			"id":          id,
+			"boutique_id": boutiqueID,
+		})
+	}
+	return nil
+}
+
+// PurgerCorbeille supprime définitivement ce qui est dans la corbeille depuis plus
+// de retention et journalise chaque purge au nom du planificateur. Comme
+// AppliquerProgrammation, une seule réplique purge à la fois (verrou consultatif).
+func (s *ProduitService) PurgerCorbeille(ctx context.Context, retention time.Duration) (int, error) {
+	ctx = AvecActeur(ctx, ActeurPlanificateur, "")
+	purges, err := s.repo.PurgerCorbeille(ctx, time.Now().Add(-retention))
+	for _, p := range purges {
+		s.audit.Enregistrer(ctx, p.BoutiqueID, p.ID, models.EntiteProduit, p.ID, models.ActionPurge, p, nil)
+	}
+	return len(purges), err
+}
+
+// RestaurerRevision remet le produit (options, valeurs, variantes) dans l'état de
+// la révision numero, en une transaction, puis enregistre le résultat comme
+// nouvelle révision. Le statut courant est conservé : il suit le cycle de vie.
+func (s *ProduitService) RestaurerRevision(ctx context.Context, id, boutiqueID string, numero int) (*dto.ProduitResponse, error) {
+	if boutiqueID == "" {
+		return nil, errors.New("boutique ID is required")
+	}
+
+	actuel, err := s.repo.GetByID(ctx, id, boutiqueID)
+	if err != nil {
+		return nil, err
+	}
+	if actuel == nil {
+		return nil, errors.New("product not found")
+	}
+
+	snapshot, err := s.revisions.Snapshot(ctx, id, boutiqueID, numero)
+	if err != nil {
+		return nil, err
+	}
+	snapshot.ID = id
+	snapshot.BoutiqueID = boutiqueID
+
+	if err := s.verifierSKUsRestauration(ctx, actuel, snapshot); err != nil {
+		return nil, err
+	}
+	if err := s.repo.RestaurerAgregat(ctx, snapshot); err != nil {
+		return nil, err
+	}
+
+	restaure, err := s.repo.GetByID(ctx, id, boutiqueID)
+	if err != nil {
+		return nil, err
+	}
+	if restaure == nil {
+		return nil, errors.New("product not found after update")
+	}
+	s.audit.Enregistrer(ctx, boutiqueID, id, models.EntiteProduit, id, models.ActionModification, actuel, restaure)
+	if err := s.revisions.Capturer(ctx, restaure); err != nil {
+		log.Printf("révision produit %s: %v", id, err)
+	}
+
+	resp := s.toResponse(*restaure)
+	return &resp, nil
+}
+
+// verifierSKUsRestauration : les SKU du snapshot ont pu être repris ailleurs
+// dans la boutique depuis ; ceux des variantes actuelles, recréées, ne comptent pas
+func (s *ProduitService) verifierSKUsRestauration(ctx context.Context, actuel, snapshot *models.Produit) error {
+	liberes := map[string]bool{}
+	for _, v := range actuel.Variantes {
+		liberes[v.SKU] = true
+	}
+	pris, err := s.repo.SKUsPris(ctx, snapshot.BoutiqueID, skusAgregat(snapshot), snapshot.ID, "")
+	if err != nil {
+		return err
+	}
+	conflits := []string{}
+	for _, p := range pris {
+		if !liberes[p] {
+			conflits = append(conflits, p)
+		}
+	}
+	if len(conflits) > 0 {
+		return fmt.Errorf("%w: %s", ErrSKUPris, strings.Join(conflits, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"projet/internal/models"
+	"projet/internal/monnaie"
+	"time"
+)
+
+// ErrPromotion : période promotionnelle incohérente
+var ErrPromotion = errors.New("promotion invalide")
+
+// TarifProduit : prix par défaut et promotion du produit, hérités par les
+// variantes sans prix propre
+type TarifProduit struct {
+	Prix      monnaie.Prix
+	Promotion models.Promotion
+}
+
+// tarifProduit : prix de référence passé aux variantes d'un produit chargé
+func tarifProduit(p *models.Produit) TarifProduit {
+	return TarifProduit{Prix: prixDefaut(p), Promotion: p.Promotion}
+}
+
+// affichagePrix : ce que voit le client à un instant donné
+type affichagePrix struct {
+	effectif monnaie.Prix
+	origine  *monnaie.Prix
+	remise   *float64
+	active   bool
+}
+
+// calculerAffichage : le prix promotionnel actif remplace le prix régulier ;
+// le prix d'origine affiché est le prix barré s'il est posé, sinon le prix
+// régulier pendant la promotion, et seulement s'il dépasse le prix effectif
+func calculerAffichage(regulier monnaie.Prix, promo models.Promotion, t time.Time) affichagePrix {
+	affichage := affichagePrix{effectif: regulier, active: promo.Active(t)}
+	if affichage.active {
+		affichage.effectif = monnaie.NouveauPrix(*promo.PrixPromo, regulier.Devise)
+	}
+
+	var reference *monnaie.Montant
+	switch {
+	case promo.PrixBarre != nil:
+		reference = promo.PrixBarre
+	case affichage.active:
+		reference = &regulier.Montant
+	}
+	if reference != nil && *reference > affichage.effectif.Montant {
+		origine := monnaie.NouveauPrix(*reference, regulier.Devise)
+		remise := remisePourcentage(*reference, affichage.effectif.Montant)
+		affichage.origine, affichage.remise = &origine, &remise
+	}
+	return affichage
+}
+
+// remisePourcentage : (origine - effectif) / origine, arrondi au centième
+func remisePourcentage(origine, effectif monnaie.Montant) float64 {
+	if origine <= 0 {
+		return 0
+	}
+	pct := float64(origine-effectif) * 100 / float64(origine)
+	return math.Round(pct*100) / 100
+}
+
+// prixOptionnel : montant optionnel exprimé dans la devise du produit
+func prixOptionnel(m *monnaie.Montant, devise string) *monnaie.Prix {
+	if m == nil {
+		return nil
+	}
+	prix := monnaie.NouveauPrix(*m, devise)
+	return &prix
+}
+
+// verifierPromotion : la fin de promotion doit suivre son début
+func verifierPromotion(p models.Promotion) error {
+	if p.DebutPromo != nil && p.FinPromo != nil && !p.FinPromo.After(*p.DebutPromo) {
+		return fmt.Errorf("%w: fin_promo doit suivre debut_promo", ErrPromotion)
+	}
+	return nil
+}
+
+// verifierPrixPromo : une promotion doit baisser le prix régulier
+// (prix_defaut du produit, prix de la variante ou à défaut celui du produit)
+func verifierPrixPromo(p models.Promotion, regulier monnaie.Montant) error {
+	if p.PrixPromo != nil && *p.PrixPromo >= regulier {
+		return fmt.Errorf("%w: prix_promo (%s) doit être inférieur au prix régulier (%s)", ErrPromotion, *p.PrixPromo, regulier)
+	}
+	return nil
+}
+
+// promotionModifiee : la promotion après application d'un jeu de
+// modifications (valeur ou pointeur, nil efface)
+func promotionModifiee(avant models.Promotion, modifications map[string]interface{}) models.Promotion {
+	apres := avant
+	montant := func(colonne string, cible **monnaie.Montant) {
+		switch v := modifications[colonne].(type) {
+		case monnaie.Montant:
+			*cible = &v
+		case *monnaie.Montant:
+			*cible = v
+		}
+	}
+	date := func(colonne string, cible **time.Time) {
+		switch v := modifications[colonne].(type) {
+		case time.Time:
+			*cible = &v
+		case *time.Time:
+			*cible = v
+		}
+	}
+	montant("prix_barre", &apres.PrixBarre)
+	montant("prix_promo", &apres.PrixPromo)
+	date("debut_promo", &apres.DebutPromo)
+	date("fin_promo", &apres.FinPromo)
+	return apres
+}
+
+// champsPromotion : colonnes d'une mise à jour partielle, nil = inchangé
+func champsPromotion(modifications map[string]interface{}, barre, promo *monnaie.Montant, debut, fin *time.Time) {
+	if barre != nil {
+		modifications["prix_barre"] = *barre
+	}
+	if promo != nil {
+		modifications["prix_promo"] = *promo
+	}
+	if debut != nil {
+		modifications["debut_promo"] = *debut
+	}
+	if fin != nil {
+		modifications["fin_promo"] = *fin
+	}
+}
+
+// preparerPromotion arrondit les prix promotionnels modifiés à la devise
+// et vérifie la période résultante. regulier est le prix régulier après
+// modification (colonnePrix : "prix_defaut" ou "prix") ; il n'est comparé au
+// prix promotionnel que si l'un des deux change, pour ne pas bloquer une
+// mise à jour sans rapport sur une ligne existante.
+func preparerPromotion(avant models.Promotion, modifications map[string]interface{}, devise string, regulier monnaie.Montant, colonnePrix string) error {
+	arrondirPrix(modifications, "prix_barre", devise)
+	arrondirPrix(modifications, "prix_promo", devise)
+	apres := promotionModifiee(avant, modifications)
+	if err := verifierPromotion(apres); err != nil {
+		return err
+	}
+	_, promoModifiee := modifications["prix_promo"]
+	_, prixModifie := modifications[colonnePrix]
+	if promoModifiee || prixModifie {
+		return verifierPrixPromo(apres, regulier.Arrondir(devise))
+	}
+	return nil
+}
+
+// arrondirPromotion : variante de preparerPromotion pour une promotion neuve
+func arrondirPromotion(p models.Promotion, devise string, regulier monnaie.Montant) (models.Promotion, error) {
+	p.PrixBarre = arrondirMontant(p.PrixBarre, devise)
+	p.PrixPromo = arrondirMontant(p.PrixPromo, devise)
+	if err := verifierPromotion(p); err != nil {
+		return p, err
+	}
+	return p, verifierPrixPromo(p, regulier.Arrondir(devise))
+}
+
+// prixModifie : valeur d'une colonne de prix après modifications (valeur ou
+// pointeur, nil efface), avant sinon
+func prixModifie(modifications map[string]interface{}, colonne string, avant *monnaie.Montant) *monnaie.Montant {
+	switch v := modifications[colonne].(type) {
+	case monnaie.Montant:
+		return &v
+	case *monnaie.Montant:
+		return v
+	case nil:
+		if _, efface := modifications[colonne]; efface {
+			return nil
+		}
+	}
+	return avant
+}
@@ -0,0 +1,445 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"projet/internal/dto"
+	"projet/internal/models"
+	"projet/internal/monnaie"
+	"projet/internal/repository"
+	"strings"
+	"time"
+)
+
+const (
+	// limiteRegleProduits : au-delà, la règle est refusée et le marchand doit
+	// resserrer le filtre (l'ajustement garde une ligne par article modifié)
+	limiteRegleProduits = 2000
+	// lotRegle : produits lus par requête lors de la simulation
+	lotRegle = 100
+)
+
+var (
+	// ErrReglePrix : transformation incomplète ou incohérente
+	ErrReglePrix = errors.New("règle de prix invalide")
+	// ErrSelectionTropLarge : le filtre vise trop de produits
+	ErrSelectionTropLarge = fmt.Errorf("le filtre vise plus de %d produits, précisez-le", limiteRegleProduits)
+	// ErrReglePrixLignes : au moins un article aurait un prix invalide
+	ErrReglePrixLignes = errors.New("la règle produit des prix invalides, voir les lignes en erreur")
+	// ErrAjustementIntrouvable : ajustement absent ou d'une autre boutique
+	ErrAjustementIntrouvable = errors.New("ajustement de prix non trouvé")
+)
+
+// articleRegle : une ligne du plan et la ligne en base qu'elle modifie
+type articleRegle struct {
+	ligne    dto.LigneReglePrix
+	produit  *models.Produit
+	variante *models.Variante
+	prix     monnaie.Montant
+}
+
+// ------------------------------------------------------------
+// Vérification et transformation
+// ------------------------------------------------------------
+func verifierReglePrix(req *dto.RequeteReglePrix) error {
+	if req.Portee == "" {
+		req.Portee = dto.PorteeTout
+	}
+	t := req.Transformation
+	switch t.Type {
+	case dto.TransformationPourcentage:
+		if t.Pourcentage == nil {
+			return fmt.Errorf("%w: pourcentage requis", ErrReglePrix)
+		}
+	case dto.TransformationDelta, dto.TransformationFixer:
+		if t.Montant == nil {
+			return fmt.Errorf("%w: montant requis pour %s", ErrReglePrix, t.Type)
+		}
+		// un montant n'a de sens que dans une devise
+		if req.Devise == "" {
+			return fmt.Errorf("%w: devise requise pour %s", ErrReglePrix, t.Type)
+		}
+		if t.Type == dto.TransformationFixer && *t.Montant < 0 {
+			return fmt.Errorf("%w: le prix fixé ne peut pas être négatif", ErrReglePrix)
+		}
+	case dto.TransformationArrondir:
+		if t.Terminaison == nil {
+			return fmt.Errorf("%w: terminaison requise pour arrondir", ErrReglePrix)
+		}
+	default:
+		return fmt.Errorf("%w: type %q inconnu", ErrReglePrix, t.Type)
+	}
+	return nil
+}
+
+// transformer calcule le nouveau prix ; l'erreur concerne l'article seul
+func transformer(t dto.TransformationPrix, prix monnaie.Montant, devise string) (monnaie.Montant, error) {
+	apres := prix
+	switch t.Type {
+	case dto.TransformationPourcentage:
+		apres = prix.AppliquerPourcentage(*t.Pourcentage, devise)
+	case dto.TransformationDelta:
+		apres = (prix + *t.Montant).Arrondir(devise)
+	case dto.TransformationFixer:
+		apres = t.Montant.Arrondir(devise)
+	}
+	if t.Terminaison != nil {
+		if t.Terminaison.Arrondir(devise) != *t.Terminaison {
+			return 0, fmt.Errorf("terminaison %s plus fine que la devise %s", t.Terminaison.String(), devise)
+		}
+		apres = apres.Terminer(*t.Terminaison)
+	}
+	if apres < 0 {
+		return 0, errors.New("le prix deviendrait négatif")
+	}
+	return apres, nil
+}
+
+// verifierLigne : comme preparerPromotion, le nouveau prix régulier doit
+// rester au-dessus des prix promotionnels qui en dépendent (ceux des
+// variantes sans prix propre pour un produit) ; un produit publié doit
+// continuer de remplir les exigences de publication
+func verifierLigne(a articleRegle, prix monnaie.Montant) error {
+	if a.variante != nil {
+		return verifierPrixPromo(a.variante.Promotion, prix)
+	}
+	p := a.produit
+	if err := verifierPrixPromo(p.Promotion, prix); err != nil {
+		return err
+	}
+	for _, v := range p.Variantes {
+		if v.Prix != nil {
+			continue
+		}
+		if err := verifierPrixPromo(v.Promotion, prix); err != nil {
+			return fmt.Errorf("variante %s: %w", v.SKU, err)
+		}
+	}
+	if p.Statut != models.StatutPublie {
+		return nil
+	}
+	// seules les exigences que le nouveau prix ferait perdre sont reprochées
+	deja := map[string]bool{}
+	for _, m := range ExigencesPublication(*p) {
+		deja[m] = true
+	}
+	apres := *p
+	apres.PrixDefaut = prix
+	var perdues []string
+	for _, m := range ExigencesPublication(apres) {
+		if !deja[m] {
+			perdues = append(perdues, m)
+		}
+	}
+	if len(perdues) > 0 {
+		return fmt.Errorf("produit publié: %s", strings.Join(perdues, ", "))
+	}
+	return nil
+}
+
+// planifier : une ligne par prix qui change, ajoutée à reponse (appelé lot par
+// lot) ; les variantes sans prix propre héritent du produit et ne sont pas touchées
+func planifier(req dto.RequeteReglePrix, produits []models.Produit, reponse *dto.ReponseReglePrix) []articleRegle {
+	var plan []articleRegle
+	ajouter := func(a articleRegle, avant monnaie.Montant, devise string) {
+		apres, err := transformer(req.Transformation, avant, devise)
+		if err == nil && apres != avant {
+			err = verifierLigne(a, apres)
+		}
+		a.ligne.Avant = monnaie.NouveauPrix(avant, devise)
+		if err != nil {
+			a.ligne.Erreur = err.Error()
+			a.ligne.Apres = a.ligne.Avant
+			reponse.Erreurs++
+		} else if apres == avant {
+			reponse.Inchanges++
+			return
+		} else {
+			a.ligne.Apres = monnaie.NouveauPrix(apres, devise)
+		}
+		a.prix = apres
+		plan = append(plan, a)
+	}
+
+	for i := range produits {
+		p := &produits[i]
+		if req.Portee != dto.PorteeVariantes {
+			ajouter(articleRegle{
+				ligne:   dto.LigneReglePrix{Cible: dto.CibleProduit, ProduitID: p.ID, Titre: p.Titre},
+				produit: p,
+			}, p.PrixDefaut, p.Devise)
+		}
+		if req.Portee == dto.PorteeProduits {
+			continue
+		}
+		for j := range p.Variantes {
+			v := &p.Variantes[j]
+			if v.Prix == nil {
+				continue
+			}
+			id := v.ID
+			ajouter(articleRegle{
+				ligne:    dto.LigneReglePrix{Cible: dto.CibleVariante, ProduitID: p.ID, VarianteID: &id, Titre: p.Titre + " / " + v.SKU},
+				produit:  p,
+				variante: v,
+			}, *v.Prix, p.Devise)
+		}
+	}
+
+	for _, a := range plan {
+		reponse.Lignes = append(reponse.Lignes, a.ligne)
+		if a.ligne.Erreur != "" {
+			continue
+		}
+		if a.variante != nil {
+			reponse.Variantes++
+		} else {
+			reponse.Produits++
+		}
+	}
+	return plan
+}
+
+// parcourirSelection lit les produits visés lot par lot (curseur, sans verrou)
+func parcourirSelection(ctx context.Context, repo *repository.ProduitRepo, boutiqueID string, req dto.RequeteReglePrix, fn func(lot []models.Produit)) error {
+	var dernier *models.Produit
+	total := 0
+	for {
+		lot, err := repo.ProduitsPourRegle(ctx, boutiqueID, req.Filtre, req.Devise, dernier, lotRegle)
+		if err != nil {
+			return err
+		}
+		if total += len(lot); total > limiteRegleProduits {
+			return ErrSelectionTropLarge
+		}
+		fn(lot)
+		if len(lot) < lotRegle {
+			return nil
+		}
+		dernier = &lot[len(lot)-1]
+	}
+}
+
+// ------------------------------------------------------------
+// Aperçu (simulation)
+// ------------------------------------------------------------
+func (s *MasseService) ApercuReglePrix(ctx context.Context, boutiqueID string, req dto.RequeteReglePrix) (*dto.ReponseReglePrix, error) {
+	if boutiqueID == "" {
+		return nil, errors.New("boutique ID is required")
+	}
+	if err := verifierReglePrix(&req); err != nil {
+		return nil, err
+	}
+	reponse := &dto.ReponseReglePrix{Simulation: true, Lignes: []dto.LigneReglePrix{}}
+	err := parcourirSelection(ctx, s.produits.repo, boutiqueID, req, func(lot []models.Produit) {
+		planifier(req, lot, reponse)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return reponse, nil
+}
+
+// ------------------------------------------------------------
+// Application
+// ------------------------------------------------------------
+
+// AppliquerReglePrix simule d'abord toute la sélection, sans verrou : la
+// moindre ligne en erreur annule tout (la réponse les détaille avec
+// ErrReglePrixLignes). Les produits retenus (au plus limiteRegleProduits) sont
+// ensuite relus FOR UPDATE, recalculés et écrits dans une seule transaction,
+// avec un seul ajustement : une nouvelle tentative après échec repart des
+// prix d'origine.
+func (s *MasseService) AppliquerReglePrix(ctx context.Context, boutiqueID string, req dto.RequeteReglePrix) (*dto.ReponseReglePrix, error) {
+	if boutiqueID == "" {
+		return nil, errors.New("boutique ID is required")
+	}
+	if err := verifierReglePrix(&req); err != nil {
+		return nil, err
+	}
+
+	simulation := &dto.ReponseReglePrix{Simulation: true, Lignes: []dto.LigneReglePrix{}}
+	var (
+		ids []string
+		// articles inchangés des lots sans aucune modification, qui ne sont pas relus
+		inchanges int
+	)
+	err := parcourirSelection(ctx, s.produits.repo, boutiqueID, req, func(lot []models.Produit) {
+		deja := simulation.Inchanges
+		if len(planifier(req, lot, simulation)) == 0 {
+			inchanges += simulation.Inchanges - deja
+			return
+		}
+		for _, p := range lot {
+			ids = append(ids, p.ID)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if simulation.Erreurs > 0 {
+		return simulation, ErrReglePrixLignes
+	}
+	if len(ids) == 0 {
+		simulation.Simulation = false
+		return simulation, nil
+	}
+
+	regle, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode price rule: %w", err)
+	}
+	reponse := &dto.ReponseReglePrix{Inchanges: inchanges, Lignes: []dto.LigneReglePrix{}}
+	var suivis []func()
+	err = s.produits.repo.Transaction(ctx, func(produits *repository.ProduitRepo, variantes *repository.VarianteRepo, journal *repository.AuditRepo) error {
+		selection, err := produits.VerrouillerPourRegle(ctx, boutiqueID, req.Filtre, req.Devise, ids)
+		if err != nil {
+			return err
+		}
+		// recalculé sur les lignes verrouillées : un prix a pu changer depuis la simulation
+		plan := planifier(req, selection, reponse)
+		if reponse.Erreurs > 0 {
+			return ErrReglePrixLignes
+		}
+		if len(plan) == 0 {
+			return nil
+		}
+
+		audit := s.produits.audit.Dans(journal)
+		maintenant := time.Now()
+		for _, a := range plan {
+			if a.variante == nil {
+				avant := a.produit
+				apres, err := produits.Update(ctx, avant.ID, boutiqueID, map[string]interface{}{
+					"prix_defaut":   a.prix,
+					"mis_a_jour_le": maintenant,
+				}, &avant.Version)
+				if err != nil {
+					return err
+				}
+				if apres == nil {
+					return errors.New("product not found")
+				}
+				if err := audit.Journaliser(ctx, boutiqueID, apres.ID, models.EntiteProduit, apres.ID, models.ActionModification, avant, apres); err != nil {
+					return err
+				}
+				suivis = append(suivis, func() {
+					if err := s.produits.revisions.CapturerInitiale(ctx, avant); err != nil {
+						log.Printf("révision produit %s: %v", apres.ID, err)
+					}
+					if err := s.produits.revisions.Capturer(ctx, apres); err != nil {
+						log.Printf("révision produit %s: %v", apres.ID, err)
+					}
+				})
+				continue
+			}
+
+			avant := a.variante
+			prix := a.prix
+			apres, err := variantes.Update(ctx, avant.ID, map[string]interface{}{
+				"prix":          &prix,
+				"mis_a_jour_le": maintenant,
+			}, &avant.Version)
+			if err != nil {
+				return err
+			}
+			if apres == nil {
+				return errors.New("variante non trouvée")
+			}
+			if err := audit.Journaliser(ctx, boutiqueID, apres.ProduitID, models.EntiteVariante, apres.ID, models.ActionModification, avant, apres); err != nil {
+				return err
+			}
+		}
+
+		lignes, err := json.Marshal(reponse.Lignes)
+		if err != nil {
+			return fmt.Errorf("failed to encode price rule lines: %w", err)
+		}
+		ajustement := &models.AjustementPrix{
+			BoutiqueID:  boutiqueID,
+			Acteur:      acteurDepuis(ctx),
+			Regle:       string(regle),
+			NbProduits:  reponse.Produits,
+			NbVariantes: reponse.Variantes,
+			Lignes:      string(lignes),
+		}
+		if err := produits.EnregistrerAjustement(ctx, ajustement); err != nil {
+			return err
+		}
+		reponse.AjustementID = ajustement.ID
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, ErrReglePrixLignes) {
+			// rien n'est écrit : les lignes recalculées sous verrou
+			reponse.Simulation = true
+			reponse.AjustementID = ""
+			return reponse, err
+		}
+		return nil, err
+	}
+
+	for _, suivi := range suivis {
+		suivi()
+	}
+	return reponse, nil
+}
+
+// ------------------------------------------------------------
+// Historique des ajustements
+// ------------------------------------------------------------
+func (s *MasseService) ListAjustements(ctx context.Context, boutiqueID string, page, limite int) ([]dto.AjustementPrixResponse, int64, int, int, error) {
+	if boutiqueID == "" {
+		return nil, 0, 0, 0, errors.New("boutique ID is required")
+	}
+	if page <= 0 {
+		page = 1
+	}
+	if limite <= 0 {
+		limite = 20
+	}
+
+	ajustements, total, err := s.produits.repo.ListAjustements(ctx, boutiqueID, page, limite)
+	if err != nil {
+		return nil, 0, 0, 0, err
+	}
+	resp := make([]dto.AjustementPrixResponse, len(ajustements))
+	for i, a := range ajustements {
+		resp[i] = ajustementVersResponse(a)
+	}
+	return resp, total, page, limite, nil
+}
+
+func (s *MasseService) GetAjustement(ctx context.Context, id, boutiqueID string) (*dto.AjustementPrixResponse, error) {
+	if boutiqueID == "" {
+		return nil, errors.New("boutique ID is required")
+	}
+	ajustement, err := s.produits.repo.GetAjustement(ctx, id, boutiqueID)
+	if err != nil {
+		return nil, err
+	}
+	if ajustement == nil {
+		return nil, ErrAjustementIntrouvable
+	}
+	resp := ajustementVersResponse(*ajustement)
+	return &resp, nil
+}
+
+func ajustementVersResponse(a models.AjustementPrix) dto.AjustementPrixResponse {
+	resp := dto.AjustementPrixResponse{
+		ID:          a.ID,
+		BoutiqueID:  a.BoutiqueID,
+		Acteur:      a.Acteur,
+		Regle:       json.RawMessage(a.Regle),
+		NbProduits:  a.NbProduits,
+		NbVariantes: a.NbVariantes,
+		CreeLe:      a.CreeLe,
+	}
+	if a.Lignes != "" {
+		resp.Lignes = json.RawMessage(a.Lignes)
+	}
+	return resp
+}
@@ -0,0 +1,229 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"projet/internal/dto"
+	"projet/internal/models"
+	"sort"
+	"strings"
+)
+
+// ErreurSelection : la sélection de valeurs ne correspond pas aux options du produit
+type ErreurSelection struct {
+	Problemes []string
+}
+
+func (e *ErreurSelection) Error() string {
+	return "sélection invalide: " + strings.Join(e.Problemes, ", ")
+}
+
+// ErrAucuneVariante : la combinaison choisie n'existe pas pour ce produit
+var ErrAucuneVariante = errors.New("aucune variante pour cette sélection")
+
+// ------------------------------------------------------------
+// Résoudre la variante correspondant à une sélection complète
+// ------------------------------------------------------------
+func (s *ProduitService) ResoudreVariante(ctx context.Context, produitID, boutiqueID string, sel dto.SelectionVariante) (*dto.ReponseResolutionVariante, error) {
+	produit, choix, err := s.chargerSelection(ctx, produitID, boutiqueID, sel)
+	if err != nil {
+		return nil, err
+	}
+
+	manquantes := []string{}
+	for _, opt := range produit.Options {
+		if _, ok := choix[opt.ID]; !ok {
+			manquantes = append(manquantes, fmt.Sprintf("option %q non choisie", opt.Nom))
+		}
+	}
+	if len(manquantes) > 0 {
+		return nil, &ErreurSelection{Problemes: manquantes}
+	}
+
+	variante := varianteExacte(produit.Variantes, choix)
+	if variante == nil {
+		return nil, ErrAucuneVariante
+	}
+	return &dto.ReponseResolutionVariante{
+		Variante:   varianteVersResponse(*variante, tarifProduit(produit)),
+		Disponible: enStock(*produit, *variante),
+	}, nil
+}
+
+// ------------------------------------------------------------
+// Matrice de disponibilité pour une sélection partielle : pour chaque
+// valeur, existe-t-il une variante (en stock) qui la combine avec les
+// choix faits sur les autres options ?
+// ------------------------------------------------------------
+func (s *ProduitService) Disponibilites(ctx context.Context, produitID, boutiqueID string, sel dto.SelectionVariante) (*dto.MatriceDisponibilite, error) {
+	produit, choix, err := s.chargerSelection(ctx, produitID, boutiqueID, sel)
+	if err != nil {
+		return nil, err
+	}
+
+	matrice := &dto.MatriceDisponibilite{
+		Selection: []string{},
+		Options:   make([]dto.DisponibiliteOption, len(produit.Options)),
+	}
+	for _, valeurID := range choix {
+		matrice.Selection = append(matrice.Selection, valeurID)
+	}
+	sort.Strings(matrice.Selection)
+
+	for i, opt := range produit.Options {
+		// les autres choix restent fixes, celui de cette option varie
+		autres := make(map[string]string, len(choix))
+		for optionID, valeurID := range choix {
+			if optionID != opt.ID {
+				autres[optionID] = valeurID
+			}
+		}
+
+		ligne := dto.DisponibiliteOption{ID: opt.ID, Nom: opt.Nom, Valeurs: make([]dto.DisponibiliteValeur, len(opt.ValeurOpts))}
+		for j, val := range opt.ValeurOpts {
+			cellule := dto.DisponibiliteValeur{
+				ID:           val.ID,
+				Valeur:       val.Valeur,
+				Code:         val.Code,
+				Selectionnee: choix[opt.ID] == val.ID,
+			}
+			for _, v := range produit.Variantes {
+				if !contient(v, val.ID) || !compatible(v, autres) {
+					continue
+				}
+				cellule.Existe = true
+				cellule.Stock += v.QuantiteStock
+				if enStock(*produit, v) {
+					cellule.Disponible = true
+				}
+			}
+			ligne.Valeurs[j] = cellule
+		}
+		matrice.Options[i] = ligne
+	}
+
+	if len(produit.Options) > 0 && len(choix) == len(produit.Options) {
+		if variante := varianteExacte(produit.Variantes, choix); variante != nil {
+			resp := varianteVersResponse(*variante, tarifProduit(produit))
+			matrice.Variante = &resp
+		}
+	}
+	return matrice, nil
+}
+
+// chargerSelection charge le produit et traduit la sélection en
+// option ID -> valeur ID (une valeur au plus par option)
+func (s *ProduitService) chargerSelection(ctx context.Context, produitID, boutiqueID string, sel dto.SelectionVariante) (*models.Produit, map[string]string, error) {
+	if boutiqueID == "" {
+		return nil, nil, errors.New("boutique ID is required")
+	}
+	produit, err := s.repo.GetByID(ctx, produitID, boutiqueID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if produit == nil {
+		return nil, nil, errors.New("product not found")
+	}
+
+	optionDeValeur := map[string]models.OptionProduit{}
+	optionsParNom := map[string]models.OptionProduit{}
+	for _, opt := range produit.Options {
+		optionsParNom[strings.ToLower(opt.Nom)] = opt
+		for _, val := range opt.ValeurOpts {
+			optionDeValeur[val.ID] = opt
+		}
+	}
+
+	problemes := []string{}
+	choix := map[string]string{}
+	choisir := func(opt models.OptionProduit, valeurID string) {
+		if deja, ok := choix[opt.ID]; ok && deja != valeurID {
+			problemes = append(problemes, fmt.Sprintf("plusieurs valeurs pour l'option %q", opt.Nom))
+			return
+		}
+		choix[opt.ID] = valeurID
+	}
+
+	for _, valeurID := range sel.ValeurIDs {
+		opt, ok := optionDeValeur[valeurID]
+		if !ok {
+			problemes = append(problemes, fmt.Sprintf("valeur %s inconnue pour ce produit", valeurID))
+			continue
+		}
+		choisir(opt, valeurID)
+	}
+
+	noms := make([]string, 0, len(sel.ParOption))
+	for nom := range sel.ParOption {
+		noms = append(noms, nom)
+	}
+	sort.Strings(noms)
+	for _, nom := range noms {
+		opt, ok := optionsParNom[strings.ToLower(nom)]
+		if !ok {
+			problemes = append(problemes, fmt.Sprintf("option %q inconnue", nom))
+			continue
+		}
+		valeurID := valeurParLibelle(opt, sel.ParOption[nom])
+		if valeurID == "" {
+			problemes = append(problemes, fmt.Sprintf("valeur %q inconnue pour l'option %q", sel.ParOption[nom], opt.Nom))
+			continue
+		}
+		choisir(opt, valeurID)
+	}
+
+	if len(problemes) > 0 {
+		return nil, nil, &ErreurSelection{Problemes: problemes}
+	}
+	return produit, choix, nil
+}
+
+// valeurParLibelle cherche la valeur par libellé puis par code machine
+func valeurParLibelle(opt models.OptionProduit, libelle string) string {
+	for _, val := range opt.ValeurOpts {
+		if strings.EqualFold(val.Valeur, libelle) {
+			return val.ID
+		}
+	}
+	for _, val := range opt.ValeurOpts {
+		if val.Code != nil && strings.EqualFold(*val.Code, libelle) {
+			return val.ID
+		}
+	}
+	return ""
+}
+
+// varianteExacte : la variante dont la combinaison est exactement choix
+func varianteExacte(variantes []models.Variante, choix map[string]string) *models.Variante {
+	for i, v := range variantes {
+		if len(v.ValeurOptions) == len(choix) && compatible(v, choix) {
+			return &variantes[i]
+		}
+	}
+	return nil
+}
+
+// compatible : la variante contient toutes les valeurs choisies
+func compatible(v models.Variante, choix map[string]string) bool {
+	for _, valeurID := range choix {
+		if !contient(v, valeurID) {
+			return false
+		}
+	}
+	return true
+}
+
+func contient(v models.Variante, valeurID string) bool {
+	for _, vo := range v.ValeurOptions {
+		if vo.ID == valeurID {
+			return true
+		}
+	}
+	return false
+}
+
+// enStock : sans suivi de stock, une variante est toujours disponible
+func enStock(p models.Produit, v models.Variante) bool {
+	return !p.SuiviStock || v.QuantiteStock > 0
+}
@@ -0,0 +1,232 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"projet/internal/dto"
+	"projet/internal/models"
+	"projet/internal/repository"
+	"sort"
+)
+
+type RevisionService struct {
+	repo *repository.RevisionRepo
+}
+
+func NewRevisionService(repo *repository.RevisionRepo) *RevisionService {
+	return &RevisionService{repo: repo}
+}
+
+func (s *RevisionService) toResponse(r models.RevisionProduit, avecSnapshot bool) dto.RevisionResponse {
+	resp := dto.RevisionResponse{
+		ID:        r.ID,
+		ProduitID: r.ProduitID,
+		Numero:    r.Numero,
+		Acteur:    r.Acteur,
+		CreeLe:    r.CreeLe,
+	}
+	if avecSnapshot {
+		resp.Snapshot = json.RawMessage(r.Snapshot)
+	}
+	return resp
+}
+
+// Capturer enregistre l'agrégat complet (options, valeurs, variantes et liens
+// préchargés) comme nouvelle révision numérotée.
+func (s *RevisionService) Capturer(ctx context.Context, produit *models.Produit) error {
+	if s == nil {
+		return nil
+	}
+	snapshot, err := json.Marshal(produit)
+	if err != nil {
+		return fmt.Errorf("encodage de la révision: %w", err)
+	}
+	_, err = s.repo.Create(ctx, &models.RevisionProduit{
+		ProduitID:  produit.ID,
+		BoutiqueID: produit.BoutiqueID,
+		Acteur:     acteurDepuis(ctx),
+		Snapshot:   string(snapshot),
+	})
+	return err
+}
+
+// CapturerInitiale photographie l'état d'origine d'un produit qui n'a encore
+// aucune révision, pour pouvoir annuler sa toute première modification.
+func (s *RevisionService) CapturerInitiale(ctx context.Context, produit *models.Produit) error {
+	if s == nil {
+		return nil
+	}
+	count, err := s.repo.Count(ctx, produit.ID)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	return s.Capturer(ctx, produit)
+}
+
+// CapturerInitialeProduit : CapturerInitiale sur l'agrégat rechargé, avant
+// l'écriture d'une option, d'une valeur ou d'une variante du produit
+func (s *RevisionService) CapturerInitialeProduit(ctx context.Context, produitID string) error {
+	if s == nil {
+		return nil
+	}
+	produit, err := s.repo.Agregat(ctx, produitID)
+	if err != nil || produit == nil {
+		return err
+	}
+	return s.CapturerInitiale(ctx, produit)
+}
+
+// CapturerProduit : Capturer sur l'agrégat rechargé, après l'écriture d'une
+// option, d'une valeur ou d'une variante du produit
+func (s *RevisionService) CapturerProduit(ctx context.Context, produitID string) error {
+	if s == nil {
+		return nil
+	}
+	produit, err := s.repo.Agregat(ctx, produitID)
+	if err != nil || produit == nil {
+		return err
+	}
+	return s.Capturer(ctx, produit)
+}
+
+func (s *RevisionService) List(ctx context.Context, produitID, boutiqueID string) ([]dto.RevisionResponse, error) {
+	if boutiqueID == "" {
+		return nil, errors.New("boutique ID is required")
+	}
+	revisions, err := s.repo.List(ctx, produitID, boutiqueID)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := make([]dto.RevisionResponse, len(revisions))
+	for i, r := range revisions {
+		resp[i] = s.toResponse(r, false)
+	}
+	return resp, nil
+}
+
+func (s *RevisionService) GetByNumero(ctx context.Context, produitID, boutiqueID string, numero int) (*dto.RevisionResponse, error) {
+	revision, err := s.repo.GetByNumero(ctx, produitID, boutiqueID, numero)
+	if err != nil {
+		return nil, err
+	}
+	if revision == nil {
+		return nil, errors.New("revision not found")
+	}
+	resp := s.toResponse(*revision, true)
+	return &resp, nil
+}
+
+// Snapshot décode l'agrégat stocké dans la révision numero
+func (s *RevisionService) Snapshot(ctx context.Context, produitID, boutiqueID string, numero int) (*models.Produit, error) {
+	revision, err := s.repo.GetByNumero(ctx, produitID, boutiqueID, numero)
+	if err != nil {
+		return nil, err
+	}
+	if revision == nil {
+		return nil, errors.New("revision not found")
+	}
+
+	var produit models.Produit
+	if err := json.Unmarshal([]byte(revision.Snapshot), &produit); err != nil {
+		return nil, fmt.Errorf("révision %d illisible: %w", numero, err)
+	}
+	return &produit, nil
+}
+
+// Diff compare la révision de à la révision vers
+func (s *RevisionService) Diff(ctx context.Context, produitID, boutiqueID string, de, vers int) (*dto.DiffRevisionsResponse, error) {
+	avant, err := s.Snapshot(ctx, produitID, boutiqueID, de)
+	if err != nil {
+		return nil, err
+	}
+	apres, err := s.Snapshot(ctx, produitID, boutiqueID, vers)
+	if err != nil {
+		return nil, err
+	}
+
+	diffProduit, err := calculerDiff(avant, apres)
+	if err != nil {
+		return nil, err
+	}
+
+	options := func(p *models.Produit) map[string]interface{} {
+		m := map[string]interface{}{}
+		for _, o := range p.Options {
+			m[o.ID] = o
+		}
+		return m
+	}
+	valeurs := func(p *models.Produit) map[string]interface{} {
+		m := map[string]interface{}{}
+		for _, o := range p.Options {
+			for _, v := range o.ValeurOpts {
+				m[v.ID] = v
+			}
+		}
+		return m
+	}
+	// les liens vers les valeurs sont comparés via leurs identifiants
+	variantes := func(p *models.Produit) map[string]interface{} {
+		m := map[string]interface{}{}
+		for _, v := range p.Variantes {
+			ids := make([]string, len(v.ValeurOptions))
+			for i, vo := range v.ValeurOptions {
+				ids[i] = vo.ID
+			}
+			sort.Strings(ids)
+			m[v.ID] = struct {
+				models.Variante
+				ValeurOptionIDs []string `json:"valeur_option_ids"`
+			}{v, ids}
+		}
+		return m
+	}
+
+	resp := &dto.DiffRevisionsResponse{De: de, Vers: vers, Produit: diffProduit}
+	if resp.Options, err = diffCollection(options(avant), options(apres)); err != nil {
+		return nil, err
+	}
+	if resp.Valeurs, err = diffCollection(valeurs(avant), valeurs(apres)); err != nil {
+		return nil, err
+	}
+	if resp.Variantes, err = diffCollection(variantes(avant), variantes(apres)); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func diffCollection(avant, apres map[string]interface{}) (dto.DiffCollection, error) {
+	diff := dto.DiffCollection{
+		Ajoutees:   []string{},
+		Supprimees: []string{},
+		Modifiees:  map[string]map[string]dto.ChangementChamp{},
+	}
+	for id, a := range avant {
+		b, ok := apres[id]
+		if !ok {
+			diff.Supprimees = append(diff.Supprimees, id)
+			continue
+		}
+		champs, err := calculerDiff(a, b)
+		if err != nil {
+			return diff, err
+		}
+		if len(champs) > 0 {
+			diff.Modifiees[id] = champs
+		}
+	}
+	for id := range apres {
+		if _, ok := avant[id]; !ok {
+			diff.Ajoutees = append(diff.Ajoutees, id)
+		}
+	}
+	sort.Strings(diff.Ajoutees)
+	sort.Strings(diff.Supprimees)
+	return diff, nil
+}
@@ -0,0 +1,343 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"projet/internal/dto"
+	"projet/internal/models"
+	"projet/internal/repository"
+	"projet/internal/sku"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrSKUPris : SKU déjà porté dans la boutique par un produit ou une variante
+var ErrSKUPris = repository.ErrSKUPris
+
+// ErrDoublon : autre contrainte d'unicité violée à l'écriture
+var ErrDoublon = repository.ErrDoublon
+
+// ErrModeleSKU : modèle de génération invalide, ou qui produit des SKU identiques
+var ErrModeleSKU = sku.ErrModele
+
+// skuProduit : un SKU de produit vide vaut absence de SKU (NULL), seule
+// valeur que l'index unique partiel (boutique_id, sku) tolère en plusieurs exemplaires
+func skuProduit(sku *string) *string {
+	if sku == nil {
+		return nil
+	}
+	return videEnNil(*sku)
+}
+
+// skuModifie extrait le SKU d'un jeu de modifications (nil = SKU effacé)
+func skuModifie(modifications map[string]interface{}) (string, bool) {
+	switch v := modifications["sku"].(type) {
+	case string:
+		return v, v != ""
+	case *string:
+		if v != nil && *v != "" {
+			return *v, true
+		}
+	}
+	return "", false
+}
+
+// verifierSKUVariante : le SKU ne doit être porté par aucun produit ni autre variante de la boutique
+func verifierSKUVariante(ctx context.Context, repo *repository.VarianteRepo, boutiqueID, valeur, exclureID string) error {
+	pris, err := repo.SKUPris(ctx, boutiqueID, valeur, exclureID)
+	if err != nil {
+		return err
+	}
+	if pris {
+		return fmt.Errorf("%w: %s", ErrSKUPris, valeur)
+	}
+	return nil
+}
+
+// verifierSKUs refuse les doublons à l'intérieur de skus puis ceux déjà en
+// base ; exclureProduitID est le produit modifié, dont l'ancien SKU ne compte pas
+func verifierSKUs(ctx context.Context, repo *repository.ProduitRepo, boutiqueID string, skus []string, exclureProduitID string) error {
+	vus := map[string]bool{}
+	candidats := []string{}
+	for _, v := range skus {
+		if v == "" {
+			continue
+		}
+		if vus[v] {
+			return fmt.Errorf("%w: %s en double dans la requête", ErrSKUPris, v)
+		}
+		vus[v] = true
+		candidats = append(candidats, v)
+	}
+	pris, err := repo.SKUsPris(ctx, boutiqueID, candidats, exclureProduitID, "")
+	if err != nil {
+		return err
+	}
+	if len(pris) > 0 {
+		sort.Strings(pris)
+		return fmt.Errorf("%w: %s", ErrSKUPris, strings.Join(pris, ", "))
+	}
+	return nil
+}
+
+// skusAgregat : SKU du produit et de ses variantes, dans l'ordre
+func skusAgregat(p *models.Produit) []string {
+	skus := []string{}
+	if p.SKU != nil {
+		skus = append(skus, *p.SKU)
+	}
+	for _, v := range p.Variantes {
+		skus = append(skus, v.SKU)
+	}
+	return skus
+}
+
+// ------------------------------------------------------------
+// Configuration du générateur
+// ------------------------------------------------------------
+func (s *ProduitService) ConfigurationSKU(ctx context.Context, boutiqueID string) (*dto.ConfigurationSKUResponse, error) {
+	if boutiqueID == "" {
+		return nil, errors.New("boutique ID is required")
+	}
+	config, err := s.configurationSKU(ctx, boutiqueID)
+	if err != nil {
+		return nil, err
+	}
+	return configurationSKUVersResponse(config), nil
+}
+
+func (s *ProduitService) ModifierConfigurationSKU(ctx context.Context, boutiqueID string, req dto.RequeteConfigurationSKU) (*dto.ConfigurationSKUResponse, error) {
+	if boutiqueID == "" {
+		return nil, errors.New("boutique ID is required")
+	}
+	config, err := s.configurationSKU(ctx, boutiqueID)
+	if err != nil {
+		return nil, err
+	}
+	if req.Prefixe != nil {
+		config.Prefixe = *req.Prefixe
+	}
+	if req.Modele != nil {
+		config.Modele = *req.Modele
+	}
+	if err := sku.Valider(config.Modele); err != nil {
+		return nil, err
+	}
+
+	enregistree, err := s.repo.EnregistrerConfigurationSKU(ctx, config, req.Compteur)
+	if err != nil {
+		return nil, err
+	}
+	return configurationSKUVersResponse(enregistree), nil
+}
+
+// configurationSKU : celle de la boutique, ou la configuration par défaut
+func (s *ProduitService) configurationSKU(ctx context.Context, boutiqueID string) (*models.ConfigurationSKU, error) {
+	config, err := s.repo.ConfigurationSKU(ctx, boutiqueID)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		config = &models.ConfigurationSKU{BoutiqueID: boutiqueID, Modele: models.ModeleSKUParDefaut}
+	}
+	return config, nil
+}
+
+func configurationSKUVersResponse(c *models.ConfigurationSKU) *dto.ConfigurationSKUResponse {
+	resp := &dto.ConfigurationSKUResponse{Prefixe: c.Prefixe, Modele: c.Modele, Compteur: c.Compteur}
+	if !c.MisAJourLe.IsZero() {
+		resp.MisAJourLe = &c.MisAJourLe
+	}
+	return resp
+}
+
+// ------------------------------------------------------------
+// Génération
+// ------------------------------------------------------------
+
+// ProposerSKU génère le SKU d'une nouvelle variante du produit à partir des
+// valeurs choisies. Le numéro éventuel est réservé tout de suite : une
+// création qui échoue ensuite laisse un trou, comme une séquence.
+func (s *ProduitService) ProposerSKU(ctx context.Context, produitID, boutiqueID string, valeurIDs []string) (string, error) {
+	if boutiqueID == "" {
+		return "", errors.New("boutique ID is required")
+	}
+	produit, err := s.repo.GetByID(ctx, produitID, boutiqueID)
+	if err != nil {
+		return "", err
+	}
+	if produit == nil {
+		return "", errors.New("product not found")
+	}
+	config, err := s.configurationSKU(ctx, boutiqueID)
+	if err != nil {
+		return "", err
+	}
+
+	var compteur int64
+	if sku.UtiliseCompteur(config.Modele) {
+		if compteur, err = s.repo.ReserverCompteurSKU(ctx, boutiqueID, config.Modele, 1); err != nil {
+			return "", err
+		}
+	}
+	valeur := sku.Generer(config.Modele, sku.Donnees{
+		Prefixe:  config.Prefixe,
+		Produit:  segmentProduit(produit),
+		Options:  codesOptions(produit.Options, valeurIDs),
+		Compteur: compteur,
+	})
+	if valeur == "" {
+		return "", fmt.Errorf("%w: le modèle %q donne un SKU vide", ErrModeleSKU, config.Modele)
+	}
+	if err := verifierSKUs(ctx, s.repo, boutiqueID, []string{valeur}, ""); err != nil {
+		return "", err
+	}
+	return valeur, nil
+}
+
+// GenererSKUs recalcule le SKU de toutes les variantes du produit. En
+// aperçu rien n'est écrit et les numéros affichés partent du compteur actuel.
+func (s *ProduitService) GenererSKUs(ctx context.Context, produitID, boutiqueID string, req dto.RequeteGenerationSKU) (*dto.ReponseGenerationSKU, error) {
+	if boutiqueID == "" {
+		return nil, errors.New("boutique ID is required")
+	}
+	produit, err := s.repo.GetByID(ctx, produitID, boutiqueID)
+	if err != nil {
+		return nil, err
+	}
+	if produit == nil {
+		return nil, errors.New("product not found")
+	}
+	config, err := s.configurationSKU(ctx, boutiqueID)
+	if err != nil {
+		return nil, err
+	}
+	if req.Modele != nil {
+		config.Modele = *req.Modele
+	}
+	if err := sku.Valider(config.Modele); err != nil {
+		return nil, err
+	}
+
+	reponse := &dto.ReponseGenerationSKU{
+		Apercu:    req.Apercu,
+		Modele:    config.Modele,
+		Variantes: make([]dto.PropositionSKU, len(produit.Variantes)),
+	}
+	if len(produit.Variantes) == 0 {
+		return reponse, nil
+	}
+
+	premier := config.Compteur + 1
+	if !req.Apercu && sku.UtiliseCompteur(config.Modele) {
+		if premier, err = s.repo.ReserverCompteurSKU(ctx, boutiqueID, config.Modele, len(produit.Variantes)); err != nil {
+			return nil, err
+		}
+	}
+
+	actuels := map[string]bool{}
+	vus := map[string]bool{}
+	proposes := make([]string, len(produit.Variantes))
+	for i, v := range produit.Variantes {
+		valeurIDs := make([]string, len(v.ValeurOptions))
+		for j, vo := range v.ValeurOptions {
+			valeurIDs[j] = vo.ID
+		}
+		propose := sku.Generer(config.Modele, sku.Donnees{
+			Prefixe:  config.Prefixe,
+			Produit:  segmentProduit(produit),
+			Options:  codesOptions(produit.Options, valeurIDs),
+			Compteur: premier + int64(i),
+		})
+		if propose == "" || vus[propose] {
+			return nil, fmt.Errorf("%w: %q donne des SKU vides ou identiques, ajoutez {OPTIONS} ou {COMPTEUR}", ErrModeleSKU, config.Modele)
+		}
+		vus[propose] = true
+		actuels[v.SKU] = true
+		proposes[i] = propose
+		reponse.Variantes[i] = dto.PropositionSKU{VarianteID: v.ID, Actuel: v.SKU, Propose: propose}
+	}
+
+	// les SKU actuels des variantes du produit sont libérés par la génération
+	pris, err := s.repo.SKUsPris(ctx, boutiqueID, proposes, produitID, "")
+	if err != nil {
+		return nil, err
+	}
+	conflits := []string{}
+	for _, p := range pris {
+		if !actuels[p] {
+			conflits = append(conflits, p)
+		}
+	}
+	if len(conflits) > 0 {
+		sort.Strings(conflits)
+		return nil, fmt.Errorf("%w: %s", ErrSKUPris, strings.Join(conflits, ", "))
+	}
+	if req.Apercu {
+		return reponse, nil
+	}
+
+	err = s.repo.Transaction(ctx, func(_ *repository.ProduitRepo, variantes *repository.VarianteRepo, journal *repository.AuditRepo) error {
+		// deux variantes peuvent échanger leurs SKU : on passe d'abord par un
+		// SKU provisoire pour ne pas heurter l'index unique en cours de route
+		for _, v := range produit.Variantes {
+			if _, err := variantes.Update(ctx, v.ID, map[string]interface{}{"sku": "~" + v.ID}, nil); err != nil {
+				return err
+			}
+		}
+		for i, v := range produit.Variantes {
+			modifiee, err := variantes.Update(ctx, v.ID, map[string]interface{}{
+				"sku":           proposes[i],
+				"mis_a_jour_le": time.Now(),
+			}, nil)
+			if err != nil {
+				return err
+			}
+			if modifiee != nil && modifiee.SKU != v.SKU {
+				ancienne := v
+				if err := s.audit.Dans(journal).Journaliser(ctx, boutiqueID, produitID, models.EntiteVariante, v.ID, models.ActionModification, &ancienne, modifiee); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return reponse, nil
+}
+
+// segmentProduit : le SKU du produit s'il en a un, sinon son slug
+func segmentProduit(p *models.Produit) string {
+	if p.SKU != nil && *p.SKU != "" {
+		return *p.SKU
+	}
+	return p.Slug
+}
+
+// codesOptions : pour chaque option du produit (déjà triées par position),
+// le code de la valeur retenue parmi valeurIDs, à défaut son libellé
+func codesOptions(options []models.OptionProduit, valeurIDs []string) []string {
+	choisies := map[string]bool{}
+	for _, id := range valeurIDs {
+		choisies[id] = true
+	}
+	codes := []string{}
+	for _, opt := range options {
+		for _, val := range opt.ValeurOpts {
+			if !choisies[val.ID] {
+				continue
+			}
+			if val.Code != nil && *val.Code != "" {
+				codes = append(codes, *val.Code)
+			} else {
+				codes = append(codes, val.Valeur)
+			}
+			break
+		}
+	}
+	return codes
+}
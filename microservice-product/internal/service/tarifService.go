@@ -0,0 +1,509 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"projet/internal/dto"
+	"projet/internal/models"
+	"projet/internal/monnaie"
+	"projet/internal/repository"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrListeIntrouvable : liste absente ou d'une autre boutique
+	ErrListeIntrouvable = errors.New("liste de prix non trouvée")
+	// ErrMarcheOccupe : une liste couvre déjà cette devise pour ce marché
+	ErrMarcheOccupe = errors.New("une liste existe déjà pour cette devise et ce marché")
+	// ErrPrixListe : ligne de prix vers un produit ou une variante hors boutique
+	ErrPrixListe = errors.New("prix de liste invalide")
+	// ErrTauxIntrouvable : ni prix explicite ni taux pour convertir
+	ErrTauxIntrouvable = errors.New("aucun taux de change")
+)
+
+// Sources du prix effectif localisé
+const (
+	SourcePrixListe      = "liste"
+	SourcePrixConversion = "conversion"
+	SourcePrixBase       = "base"
+)
+
+type TarifService struct {
+	repo *repository.TarifRepo
+}
+
+func NewTarifService(repo *repository.TarifRepo) *TarifService {
+	return &TarifService{repo: repo}
+}
+
+// ------------------------------------------------------------
+// Convertisseurs
+// ------------------------------------------------------------
+func listePrixVersResponse(l models.ListePrix) dto.ListePrixResponse {
+	return dto.ListePrixResponse{
+		ID:         l.ID,
+		Nom:        l.Nom,
+		Devise:     l.Devise,
+		Pays:       l.Pays,
+		CreeLe:     l.CreeLe,
+		MisAJourLe: l.MisAJourLe,
+	}
+}
+
+func tauxVersResponse(t models.TauxChange) dto.TauxChangeResponse {
+	return dto.TauxChangeResponse{
+		DeviseSource: t.DeviseSource,
+		DeviseCible:  t.DeviseCible,
+		Taux:         t.Taux,
+		MisAJourLe:   t.MisAJourLe,
+	}
+}
+
+// ------------------------------------------------------------
+// Listes de prix
+// ------------------------------------------------------------
+func (s *TarifService) CreateListe(ctx context.Context, boutiqueID string, req dto.RequeteCreationListePrix) (*dto.ListePrixResponse, error) {
+	if boutiqueID == "" {
+		return nil, errors.New("boutique ID is required")
+	}
+	pays := paysNormalise(req.Pays)
+	occupe, err := s.repo.MarcheOccupe(ctx, boutiqueID, req.Devise, pays, "")
+	if err != nil {
+		return nil, err
+	}
+	if occupe {
+		return nil, ErrMarcheOccupe
+	}
+
+	liste, err := s.repo.CreateListe(ctx, &models.ListePrix{
+		BoutiqueID: boutiqueID,
+		Nom:        req.Nom,
+		Devise:     req.Devise,
+		Pays:       pays,
+	})
+	if err != nil {
+		return nil, err
+	}
+	resp := listePrixVersResponse(*liste)
+	return &resp, nil
+}
+
+func (s *TarifService) ListListes(ctx context.Context, boutiqueID string) ([]dto.ListePrixResponse, error) {
+	if boutiqueID == "" {
+		return nil, errors.New("boutique ID is required")
+	}
+	listes, err := s.repo.ListListes(ctx, boutiqueID)
+	if err != nil {
+		return nil, err
+	}
+	resp := make([]dto.ListePrixResponse, len(listes))
+	for i, l := range listes {
+		resp[i] = listePrixVersResponse(l)
+	}
+	return resp, nil
+}
+
+func (s *TarifService) GetListe(ctx context.Context, id, boutiqueID string) (*dto.ListePrixResponse, error) {
+	liste, err := s.liste(ctx, id, boutiqueID)
+	if err != nil {
+		return nil, err
+	}
+	resp := listePrixVersResponse(*liste)
+	return &resp, nil
+}
+
+func (s *TarifService) UpdateListe(ctx context.Context, id, boutiqueID string, req dto.RequeteUpdateListePrix) (*dto.ListePrixResponse, error) {
+	liste, err := s.liste(ctx, id, boutiqueID)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := map[string]interface{}{"mis_a_jour_le": time.Now()}
+	if req.Nom != nil {
+		updates["nom"] = *req.Nom
+	}
+	if req.Pays != nil {
+		pays := paysNormalise(req.Pays)
+		occupe, err := s.repo.MarcheOccupe(ctx, boutiqueID, liste.Devise, pays, id)
+		if err != nil {
+			return nil, err
+		}
+		if occupe {
+			return nil, ErrMarcheOccupe
+		}
+		updates["pays"] = pays
+	}
+
+	modifiee, err := s.repo.UpdateListe(ctx, id, boutiqueID, updates)
+	if err != nil {
+		return nil, err
+	}
+	if modifiee == nil {
+		return nil, ErrListeIntrouvable
+	}
+	resp := listePrixVersResponse(*modifiee)
+	return &resp, nil
+}
+
+func (s *TarifService) DeleteListe(ctx context.Context, id, boutiqueID string) error {
+	if boutiqueID == "" {
+		return errors.New("boutique ID is required")
+	}
+	supprimee, err := s.repo.DeleteListe(ctx, id, boutiqueID)
+	if err != nil {
+		return err
+	}
+	if !supprimee {
+		return ErrListeIntrouvable
+	}
+	return nil
+}
+
+func (s *TarifService) liste(ctx context.Context, id, boutiqueID string) (*models.ListePrix, error) {
+	if boutiqueID == "" {
+		return nil, errors.New("boutique ID is required")
+	}
+	liste, err := s.repo.GetListe(ctx, id, boutiqueID)
+	if err != nil {
+		return nil, err
+	}
+	if liste == nil {
+		return nil, ErrListeIntrouvable
+	}
+	return liste, nil
+}
+
+// paysNormalise : "" = tous les marchés, sinon code en majuscules
+func paysNormalise(pays *string) *string {
+	if pays == nil || *pays == "" {
+		return nil
+	}
+	code := strings.ToUpper(*pays)
+	return &code
+}
+
+// ------------------------------------------------------------
+// Prix d'une liste
+// ------------------------------------------------------------
+func (s *TarifService) ListPrix(ctx context.Context, id, boutiqueID, produitID string) ([]dto.PrixListeResponse, error) {
+	liste, err := s.liste(ctx, id, boutiqueID)
+	if err != nil {
+		return nil, err
+	}
+	var produits []string
+	if produitID != "" {
+		produits = []string{produitID}
+	}
+	prix, err := s.repo.PrixDeListe(ctx, liste.ID, produits)
+	if err != nil {
+		return nil, err
+	}
+	resp := make([]dto.PrixListeResponse, len(prix))
+	for i, p := range prix {
+		resp[i] = dto.PrixListeResponse{
+			ProduitID:  p.ProduitID,
+			VarianteID: p.VarianteID,
+			Montant:    monnaie.NouveauPrix(p.Montant, liste.Devise),
+		}
+	}
+	return resp, nil
+}
+
+// DefinirPrix pose ou retire des prix explicites ; chaque ligne doit viser
+// un produit de la boutique et, le cas échéant, une de ses variantes
+func (s *TarifService) DefinirPrix(ctx context.Context, id, boutiqueID string, req dto.RequetePrixListe) ([]dto.PrixListeResponse, error) {
+	liste, err := s.liste(ctx, id, boutiqueID)
+	if err != nil {
+		return nil, err
+	}
+
+	produitIDs := []string{}
+	for _, l := range req.Prix {
+		produitIDs = append(produitIDs, l.ProduitID)
+	}
+	references, err := s.repo.ReferencesCatalogue(ctx, boutiqueID, produitIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	problemes := []string{}
+	vues := map[string]bool{}
+	lignes := make([]models.PrixListe, len(req.Prix))
+	supprimer := make([]bool, len(req.Prix))
+	for i, l := range req.Prix {
+		variantes, ok := references[l.ProduitID]
+		cle := l.ProduitID
+		switch {
+		case !ok:
+			problemes = append(problemes, fmt.Sprintf("ligne %d: produit %s inconnu", i, l.ProduitID))
+		case l.VarianteID != nil && !variantes[*l.VarianteID]:
+			problemes = append(problemes, fmt.Sprintf("ligne %d: variante %s hors du produit", i, *l.VarianteID))
+		}
+		if l.VarianteID != nil {
+			cle += "/" + *l.VarianteID
+		}
+		if vues[cle] {
+			problemes = append(problemes, fmt.Sprintf("ligne %d: prix en double", i))
+		}
+		vues[cle] = true
+
+		lignes[i] = models.PrixListe{ProduitID: l.ProduitID, VarianteID: l.VarianteID}
+		if l.Montant == nil {
+			supprimer[i] = true
+		} else {
+			lignes[i].Montant = l.Montant.Arrondir(liste.Devise)
+		}
+	}
+	if len(problemes) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrPrixListe, strings.Join(problemes, ", "))
+	}
+
+	if err := s.repo.DefinirPrix(ctx, liste.ID, lignes, supprimer); err != nil {
+		return nil, err
+	}
+	return s.ListPrix(ctx, id, boutiqueID, "")
+}
+
+// ------------------------------------------------------------
+// Taux de change
+// ------------------------------------------------------------
+func (s *TarifService) ListTaux(ctx context.Context, boutiqueID string) ([]dto.TauxChangeResponse, error) {
+	if boutiqueID == "" {
+		return nil, errors.New("boutique ID is required")
+	}
+	taux, err := s.repo.ListTaux(ctx, boutiqueID)
+	if err != nil {
+		return nil, err
+	}
+	resp := make([]dto.TauxChangeResponse, len(taux))
+	for i, t := range taux {
+		resp[i] = tauxVersResponse(t)
+	}
+	return resp, nil
+}
+
+func (s *TarifService) EnregistrerTaux(ctx context.Context, boutiqueID string, req dto.RequeteTauxChange) (*dto.TauxChangeResponse, error) {
+	if boutiqueID == "" {
+		return nil, errors.New("boutique ID is required")
+	}
+	taux, err := s.repo.EnregistrerTaux(ctx, &models.TauxChange{
+		BoutiqueID:   boutiqueID,
+		DeviseSource: req.DeviseSource,
+		DeviseCible:  req.DeviseCible,
+		Taux:         req.Taux,
+	})
+	if err != nil {
+		return nil, err
+	}
+	resp := tauxVersResponse(*taux)
+	return &resp, nil
+}
+
+func (s *TarifService) SupprimerTaux(ctx context.Context, boutiqueID, source, cible string) error {
+	if boutiqueID == "" {
+		return errors.New("boutique ID is required")
+	}
+	supprime, err := s.repo.SupprimerTaux(ctx, boutiqueID, strings.ToUpper(source), strings.ToUpper(cible))
+	if err != nil {
+		return err
+	}
+	if !supprime {
+		return fmt.Errorf("%w de %s vers %s", ErrTauxIntrouvable, source, cible)
+	}
+	return nil
+}
+
+// ------------------------------------------------------------
+// Localisation des réponses
+// ------------------------------------------------------------
+
+// convertisseur résout le prix dans la devise demandée : prix explicite de
+// la liste du marché, sinon conversion par le taux de la boutique
+type convertisseur struct {
+	loc      ContexteTarif
+	explicit map[string]monnaie.Montant // produit ou produit/variante
+	taux     map[string]monnaie.Taux    // "EUR/TND"
+}
+
+func (s *TarifService) convertisseur(ctx context.Context, boutiqueID string, loc ContexteTarif, produitIDs []string) (*convertisseur, error) {
+	conv := &convertisseur{loc: loc, explicit: map[string]monnaie.Montant{}, taux: map[string]monnaie.Taux{}}
+
+	liste, err := s.repo.ListePourMarche(ctx, boutiqueID, loc.Devise, loc.Pays)
+	if err != nil {
+		return nil, err
+	}
+	if liste != nil {
+		prix, err := s.repo.PrixDeListe(ctx, liste.ID, produitIDs)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range prix {
+			cle := p.ProduitID
+			if p.VarianteID != nil {
+				cle += "/" + *p.VarianteID
+			}
+			conv.explicit[cle] = p.Montant
+		}
+	}
+
+	taux, err := s.repo.ListTaux(ctx, boutiqueID)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range taux {
+		conv.taux[t.DeviseSource+"/"+t.DeviseCible] = t.Taux
+	}
+	return conv, nil
+}
+
+// convertir un prix de base dans la devise demandée
+func (c *convertisseur) convertir(base monnaie.Prix) (monnaie.Prix, string, error) {
+	if strings.EqualFold(base.Devise, c.loc.Devise) {
+		return monnaie.NouveauPrix(base.Montant, c.loc.Devise), SourcePrixBase, nil
+	}
+	if taux, ok := c.taux[base.Devise+"/"+c.loc.Devise]; ok {
+		return monnaie.NouveauPrix(base.Montant.Convertir(taux, c.loc.Devise), c.loc.Devise), SourcePrixConversion, nil
+	}
+	if inverse, ok := c.taux[c.loc.Devise+"/"+base.Devise]; ok && inverse > 0 {
+		return monnaie.NouveauPrix(base.Montant.ConvertirInverse(inverse, c.loc.Devise), c.loc.Devise), SourcePrixConversion, nil
+	}
+	return monnaie.Prix{}, "", fmt.Errorf("%w de %s vers %s", ErrTauxIntrouvable, base.Devise, c.loc.Devise)
+}
+
+// origine : prix d'origine barré dans la devise demandée ; un prix de liste
+// est le prix du marché, la promotion de la devise du produit ne s'y applique pas
+func (c *convertisseur) origine(source string, origine *monnaie.Prix) (*monnaie.Prix, error) {
+	if origine == nil || source == SourcePrixListe {
+		return nil, nil
+	}
+	prix, _, err := c.convertir(*origine)
+	if err != nil {
+		return nil, err
+	}
+	return &prix, nil
+}
+
+// paliers : prix des paliers convertis au taux de la boutique. Sans taux
+// (le prix affiché vient alors d'une liste du marché), les paliers sont
+// omis : un prix unitaire dans une autre devise que celle demandée serait
+// pris pour un prix local.
+func (c *convertisseur) paliers(paliers []dto.PalierResponse) []dto.PalierResponse {
+	for i := range paliers {
+		prix, _, err := c.convertir(paliers[i].PrixUnitaire)
+		if err != nil {
+			return nil
+		}
+		paliers[i].PrixUnitaire = prix
+	}
+	return paliers
+}
+
+// produit : prix explicite du produit dans la liste, sinon prix effectif converti
+func (c *convertisseur) produit(produitID string, base monnaie.Prix) (monnaie.Prix, string, error) {
+	if montant, ok := c.explicit[produitID]; ok {
+		return monnaie.NouveauPrix(montant, c.loc.Devise), SourcePrixListe, nil
+	}
+	return c.convertir(base)
+}
+
+// variante : son prix explicite ; sans prix propre elle hérite de celui du
+// produit dans la liste ; à défaut son prix effectif est converti
+func (c *convertisseur) variante(produitID string, v dto.VarianteResponse) (monnaie.Prix, string, error) {
+	if montant, ok := c.explicit[produitID+"/"+v.ID]; ok {
+		return monnaie.NouveauPrix(montant, c.loc.Devise), SourcePrixListe, nil
+	}
+	if v.Prix == nil {
+		if montant, ok := c.explicit[produitID]; ok {
+			return monnaie.NouveauPrix(montant, c.loc.Devise), SourcePrixListe, nil
+		}
+	}
+	return c.convertir(v.PrixEffectif)
+}
+
+// localiserProduits réécrit PrixEffectif des produits et de leurs variantes
+// dans la devise demandée ; sans devise demandée, rien ne change.
+//
+// Priorité des prix localisés, du plus fort au plus faible :
+//  1. prix du groupe de clients (règle appliquée, GroupeClient renseigné),
+//     converti au taux de la boutique : un prix négocié pour ce client
+//     l'emporte sur le prix public du marché ;
+//  2. prix explicite de la liste du marché (SourcePrixListe) ;
+//  3. prix effectif converti au taux (SourcePrixConversion).
+func (s *TarifService) localiserProduits(ctx context.Context, boutiqueID string, loc ContexteTarif, produits ...*dto.ProduitResponse) error {
+	if loc.Devise == "" || len(produits) == 0 {
+		return nil
+	}
+	ids := make([]string, len(produits))
+	for i, p := range produits {
+		ids[i] = p.ID
+	}
+	conv, err := s.convertisseur(ctx, boutiqueID, loc, ids)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range produits {
+		base := p.PrixDefaut
+		if p.PrixEffectif != nil {
+			base = *p.PrixEffectif
+		}
+		prix, source, err := conv.produit(p.ID, base)
+		if p.GroupeClient != "" {
+			// le prix du groupe prime sur la liste du marché (voir plus haut)
+			prix, source, err = conv.convertir(base)
+		}
+		if err != nil {
+			return err
+		}
+		origine, err := conv.origine(source, p.PrixOrigine)
+		if err != nil {
+			return err
+		}
+		p.PrixEffectif, p.SourcePrix, p.PrixOrigine = &prix, source, origine
+		if source == SourcePrixListe {
+			p.PromoActive, p.RemisePourcentage = false, nil
+		}
+		p.Paliers = conv.paliers(p.Paliers)
+		if err := conv.localiserVariantes(p.ID, p.Variantes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// localiserVariantes : variantes d'un même produit
+func (s *TarifService) localiserVariantes(ctx context.Context, boutiqueID string, loc ContexteTarif, produitID string, variantes []dto.VarianteResponse) error {
+	if loc.Devise == "" || len(variantes) == 0 {
+		return nil
+	}
+	conv, err := s.convertisseur(ctx, boutiqueID, loc, []string{produitID})
+	if err != nil {
+		return err
+	}
+	return conv.localiserVariantes(produitID, variantes)
+}
+
+func (c *convertisseur) localiserVariantes(produitID string, variantes []dto.VarianteResponse) error {
+	for i := range variantes {
+		prix, source, err := c.variante(produitID, variantes[i])
+		if variantes[i].GroupeClient != "" {
+			// même priorité que localiserProduits : le groupe avant la liste
+			prix, source, err = c.convertir(variantes[i].PrixEffectif)
+		}
+		if err != nil {
+			return err
+		}
+		origine, err := c.origine(source, variantes[i].PrixOrigine)
+		if err != nil {
+			return err
+		}
+		variantes[i].PrixEffectif, variantes[i].SourcePrix, variantes[i].PrixOrigine = prix, source, origine
+		if source == SourcePrixListe {
+			variantes[i].PromoActive, variantes[i].RemisePourcentage = false, nil
+		}
+		variantes[i].Paliers = c.paliers(variantes[i].Paliers)
+	}
+	return nil
+}
@@ -0,0 +1,365 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"projet/internal/dto"
+	"projet/internal/models"
+	"projet/internal/monnaie"
+	"projet/internal/repository"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrClasseTaxeIntrouvable : classe absente ou d'une autre boutique
+	ErrClasseTaxeIntrouvable = errors.New("classe de taxe non trouvée")
+	// ErrClasseTaxe : classe_taxe d'un produit ou classe par défaut inconnue
+	ErrClasseTaxe = errors.New("classe de taxe inconnue")
+	// ErrClasseTaxeUtilisee : des produits portent encore la classe
+	ErrClasseTaxeUtilisee = repository.ErrClasseTaxeUtilisee
+	// ErrClasseTaxeDefaut : la classe est la classe par défaut des paramètres fiscaux
+	ErrClasseTaxeDefaut = repository.ErrClasseTaxeDefaut
+	// ErrTauxTaxe : zone en double dans les taux d'une classe
+	ErrTauxTaxe = errors.New("taux de taxe invalides")
+)
+
+// verifierClasseTaxe : classe_taxe doit désigner une classe de la boutique
+// (nil ou "" = aucune classe)
+func verifierClasseTaxe(ctx context.Context, repo *repository.ProduitRepo, boutiqueID string, code *string) error {
+	if code == nil || *code == "" {
+		return nil
+	}
+	existe, err := repo.ClasseTaxeExiste(ctx, boutiqueID, *code)
+	if err != nil {
+		return err
+	}
+	if !existe {
+		return fmt.Errorf("%w: %s", ErrClasseTaxe, *code)
+	}
+	return nil
+}
+
+// classeTaxeModifiee extrait classe_taxe d'un jeu de modifications
+func classeTaxeModifiee(modifications map[string]interface{}) (*string, bool) {
+	switch v := modifications["classe_taxe"].(type) {
+	case string:
+		return &v, true
+	case *string:
+		return v, true
+	}
+	return nil, false
+}
+
+// memeChaine : chaînes optionnelles égales, nil valant ""
+func memeChaine(a, b *string) bool {
+	valeur := func(s *string) string {
+		if s == nil {
+			return ""
+		}
+		return *s
+	}
+	return valeur(a) == valeur(b)
+}
+
+// ------------------------------------------------------------
+// Convertisseurs
+// ------------------------------------------------------------
+func classeTaxeVersResponse(c models.ClasseTaxe) dto.ClasseTaxeResponse {
+	resp := dto.ClasseTaxeResponse{
+		ID:         c.ID,
+		Code:       c.Code,
+		Nom:        c.Nom,
+		CreeLe:     c.CreeLe,
+		MisAJourLe: c.MisAJourLe,
+		Taux:       make([]dto.TauxTaxeResponse, len(c.Taux)),
+	}
+	for i, t := range c.Taux {
+		resp.Taux[i] = dto.TauxTaxeResponse{Pays: t.Pays, Region: t.Region, Taux: t.Taux}
+	}
+	return resp
+}
+
+func parametresTaxeVersResponse(p *models.ParametresTaxe) *dto.ParametresTaxeResponse {
+	resp := &dto.ParametresTaxeResponse{PrixTTC: p.PrixTTC, PaysDefaut: p.PaysDefaut, ClasseDefaut: p.ClasseDefaut}
+	if !p.MisAJourLe.IsZero() {
+		resp.MisAJourLe = &p.MisAJourLe
+	}
+	return resp
+}
+
+// ------------------------------------------------------------
+// Classes de taxe
+// ------------------------------------------------------------
+func (s *TarifService) CreateClasseTaxe(ctx context.Context, boutiqueID string, req dto.RequeteCreationClasseTaxe) (*dto.ClasseTaxeResponse, error) {
+	if boutiqueID == "" {
+		return nil, errors.New("boutique ID is required")
+	}
+	classe, err := s.repo.CreateClasseTaxe(ctx, &models.ClasseTaxe{
+		BoutiqueID: boutiqueID,
+		Code:       req.Code,
+		Nom:        req.Nom,
+	})
+	if err != nil {
+		return nil, err
+	}
+	resp := classeTaxeVersResponse(*classe)
+	return &resp, nil
+}
+
+func (s *TarifService) ListClassesTaxe(ctx context.Context, boutiqueID string) ([]dto.ClasseTaxeResponse, error) {
+	if boutiqueID == "" {
+		return nil, errors.New("boutique ID is required")
+	}
+	classes, err := s.repo.ListClassesTaxe(ctx, boutiqueID)
+	if err != nil {
+		return nil, err
+	}
+	resp := make([]dto.ClasseTaxeResponse, len(classes))
+	for i, c := range classes {
+		resp[i] = classeTaxeVersResponse(c)
+	}
+	return resp, nil
+}
+
+func (s *TarifService) GetClasseTaxe(ctx context.Context, id, boutiqueID string) (*dto.ClasseTaxeResponse, error) {
+	classe, err := s.classeTaxe(ctx, id, boutiqueID)
+	if err != nil {
+		return nil, err
+	}
+	resp := classeTaxeVersResponse(*classe)
+	return &resp, nil
+}
+
+// UpdateClasseTaxe : seul le nom change, le code est référencé par les produits
+func (s *TarifService) UpdateClasseTaxe(ctx context.Context, id, boutiqueID string, req dto.RequeteUpdateClasseTaxe) (*dto.ClasseTaxeResponse, error) {
+	if boutiqueID == "" {
+		return nil, errors.New("boutique ID is required")
+	}
+	updates := map[string]interface{}{"mis_a_jour_le": time.Now()}
+	if req.Nom != nil {
+		updates["nom"] = *req.Nom
+	}
+	classe, err := s.repo.UpdateClasseTaxe(ctx, id, boutiqueID, updates)
+	if err != nil {
+		return nil, err
+	}
+	if classe == nil {
+		return nil, ErrClasseTaxeIntrouvable
+	}
+	resp := classeTaxeVersResponse(*classe)
+	return &resp, nil
+}
+
+// DeleteClasseTaxe refuse de supprimer une classe encore portée par des
+// produits, corbeille comprise, ou désignée comme classe par défaut (à
+// changer d'abord dans les paramètres fiscaux)
+func (s *TarifService) DeleteClasseTaxe(ctx context.Context, id, boutiqueID string) error {
+	classe, err := s.classeTaxe(ctx, id, boutiqueID)
+	if err != nil {
+		return err
+	}
+	return s.repo.DeleteClasseTaxe(ctx, classe)
+}
+
+// DefinirTauxTaxe remplace les taux de la classe ; une zone (pays, région)
+// n'apparaît qu'une fois
+func (s *TarifService) DefinirTauxTaxe(ctx context.Context, id, boutiqueID string, req dto.RequeteTauxClasse) (*dto.ClasseTaxeResponse, error) {
+	classe, err := s.classeTaxe(ctx, id, boutiqueID)
+	if err != nil {
+		return nil, err
+	}
+
+	vues := map[string]bool{}
+	taux := make([]models.TauxTaxe, len(req.Taux))
+	for i, t := range req.Taux {
+		taux[i] = models.TauxTaxe{Pays: strings.ToUpper(t.Pays), Region: strings.ToUpper(t.Region), Taux: t.Taux}
+		zone := taux[i].Pays + "/" + taux[i].Region
+		if vues[zone] {
+			return nil, fmt.Errorf("%w: zone %s en double", ErrTauxTaxe, strings.TrimSuffix(zone, "/"))
+		}
+		vues[zone] = true
+	}
+
+	if err := s.repo.RemplacerTauxTaxe(ctx, classe.ID, taux); err != nil {
+		return nil, err
+	}
+	return s.GetClasseTaxe(ctx, id, boutiqueID)
+}
+
+func (s *TarifService) classeTaxe(ctx context.Context, id, boutiqueID string) (*models.ClasseTaxe, error) {
+	if boutiqueID == "" {
+		return nil, errors.New("boutique ID is required")
+	}
+	classe, err := s.repo.GetClasseTaxe(ctx, id, boutiqueID)
+	if err != nil {
+		return nil, err
+	}
+	if classe == nil {
+		return nil, ErrClasseTaxeIntrouvable
+	}
+	return classe, nil
+}
+
+// ------------------------------------------------------------
+// Paramètres fiscaux
+// ------------------------------------------------------------
+func (s *TarifService) ParametresTaxe(ctx context.Context, boutiqueID string) (*dto.ParametresTaxeResponse, error) {
+	if boutiqueID == "" {
+		return nil, errors.New("boutique ID is required")
+	}
+	parametres, err := s.parametresTaxe(ctx, boutiqueID)
+	if err != nil {
+		return nil, err
+	}
+	return parametresTaxeVersResponse(parametres), nil
+}
+
+func (s *TarifService) ModifierParametresTaxe(ctx context.Context, boutiqueID string, req dto.RequeteParametresTaxe) (*dto.ParametresTaxeResponse, error) {
+	if boutiqueID == "" {
+		return nil, errors.New("boutique ID is required")
+	}
+	parametres, err := s.parametresTaxe(ctx, boutiqueID)
+	if err != nil {
+		return nil, err
+	}
+	if req.PrixTTC != nil {
+		parametres.PrixTTC = *req.PrixTTC
+	}
+	if req.PaysDefaut != nil {
+		parametres.PaysDefaut = paysNormalise(req.PaysDefaut)
+	}
+	if req.ClasseDefaut != nil {
+		parametres.ClasseDefaut = nil
+		if *req.ClasseDefaut != "" {
+			classes, err := s.repo.ListClassesTaxe(ctx, boutiqueID)
+			if err != nil {
+				return nil, err
+			}
+			for _, c := range classes {
+				if c.Code == *req.ClasseDefaut {
+					parametres.ClasseDefaut = &c.Code
+				}
+			}
+			if parametres.ClasseDefaut == nil {
+				return nil, fmt.Errorf("%w: %s", ErrClasseTaxe, *req.ClasseDefaut)
+			}
+		}
+	}
+
+	enregistres, err := s.repo.EnregistrerParametresTaxe(ctx, parametres)
+	if err != nil {
+		return nil, err
+	}
+	return parametresTaxeVersResponse(enregistres), nil
+}
+
+// parametresTaxe : ceux de la boutique, sinon prix hors taxes sans pays ni
+// classe par défaut
+func (s *TarifService) parametresTaxe(ctx context.Context, boutiqueID string) (*models.ParametresTaxe, error) {
+	parametres, err := s.repo.ParametresTaxe(ctx, boutiqueID)
+	if err != nil {
+		return nil, err
+	}
+	if parametres == nil {
+		parametres = &models.ParametresTaxe{BoutiqueID: boutiqueID}
+	}
+	return parametres, nil
+}
+
+// ------------------------------------------------------------
+// Moteur de taxes
+// ------------------------------------------------------------
+
+// moteurTaxe : taux applicables à une zone (pays, région) par code de classe
+type moteurTaxe struct {
+	prixTTC      bool
+	classeDefaut string
+	taux         map[string]float64 // code classe -> taux de la zone
+}
+
+// moteurTaxe : nil quand aucun pays n'est connu (ni ?pays= ni pays par
+// défaut de la boutique) ; les prix restent alors sans ventilation
+func (s *TarifService) moteurTaxe(ctx context.Context, boutiqueID string, contexte ContexteTarif) (*moteurTaxe, error) {
+	parametres, err := s.parametresTaxe(ctx, boutiqueID)
+	if err != nil {
+		return nil, err
+	}
+	pays, region := contexte.Pays, contexte.Region
+	if pays == "" {
+		if parametres.PaysDefaut == nil {
+			return nil, nil
+		}
+		pays, region = *parametres.PaysDefaut, ""
+	}
+
+	classes, err := s.repo.ListClassesTaxe(ctx, boutiqueID)
+	if err != nil {
+		return nil, err
+	}
+	m := &moteurTaxe{prixTTC: parametres.PrixTTC, taux: map[string]float64{}}
+	if parametres.ClasseDefaut != nil {
+		m.classeDefaut = *parametres.ClasseDefaut
+	}
+	for _, c := range classes {
+		m.taux[c.Code] = tauxZone(c.Taux, pays, region)
+	}
+	return m, nil
+}
+
+// tauxZone : taux de la région, sinon du pays ; sans taux pour le pays, la
+// classe est exonérée dans cette zone
+func tauxZone(taux []models.TauxTaxe, pays, region string) float64 {
+	applicable := 0.0
+	for _, t := range taux {
+		if t.Pays != pays {
+			continue
+		}
+		if region != "" && t.Region == region {
+			return t.Taux
+		}
+		if t.Region == "" {
+			applicable = t.Taux
+		}
+	}
+	return applicable
+}
+
+// ventiler : prix hors taxes et taxes comprises du prix effectif selon la
+// classe du produit (ou celle par défaut) ; false sans classe connue
+func (m *moteurTaxe) ventiler(classe *string, prix monnaie.Prix) (ht, ttc monnaie.Prix, taux float64, ok bool) {
+	code := m.classeDefaut
+	if classe != nil && *classe != "" {
+		code = *classe
+	}
+	taux, ok = m.taux[code]
+	if !ok {
+		return ht, ttc, 0, false
+	}
+	if m.prixTTC {
+		ht, ttc = monnaie.NouveauPrix(prix.Montant.RetirerTaxe(taux, prix.Devise), prix.Devise), prix
+	} else {
+		ht, ttc = prix, monnaie.NouveauPrix(prix.Montant.AjouterTaxe(taux, prix.Devise), prix.Devise)
+	}
+	return ht, ttc, taux, true
+}
+
+// taxerProduit renseigne prix_ht / prix_ttc du produit et de ses variantes
+func (m *moteurTaxe) taxerProduit(p *dto.ProduitResponse) {
+	if p.PrixEffectif != nil {
+		if ht, ttc, taux, ok := m.ventiler(p.ClasseTaxe, *p.PrixEffectif); ok {
+			p.PrixHT, p.PrixTTC, p.TauxTaxe = &ht, &ttc, &taux
+		}
+	}
+	m.taxerVariantes(p.ClasseTaxe, p.Variantes)
+}
+
+// taxerVariantes : les variantes suivent la classe de leur produit
+func (m *moteurTaxe) taxerVariantes(classe *string, variantes []dto.VarianteResponse) {
+	for i := range variantes {
+		if ht, ttc, taux, ok := m.ventiler(classe, variantes[i].PrixEffectif); ok {
+			variantes[i].PrixHT, variantes[i].PrixTTC, variantes[i].TauxTaxe = &ht, &ttc, &taux
+		}
+	}
+}
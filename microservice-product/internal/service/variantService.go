@@ -4,114 +4,238 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log"
 	"projet/internal/dto"
+	"projet/internal/gtin"
 	"projet/internal/models"
+	"projet/internal/monnaie"
 	"projet/internal/repository"
 	"time"
 )
 
 type VarianteService struct {
-	repo *repository.VarianteRepo
+	repo       *repository.VarianteRepo
+	evenements PublieurEvenements
+	audit      *AuditService
+	revisions  *RevisionService
 }
 
-func NewVarianteService(repo *repository.VarianteRepo) *VarianteService {
-	return &VarianteService{repo: repo}
+func NewVarianteService(repo *repository.VarianteRepo, evenements PublieurEvenements, audit *AuditService, revisions *RevisionService) *VarianteService {
+	return &VarianteService{repo: repo, evenements: evenements, audit: audit, revisions: revisions}
+}
+
+// ecrire exécute fn dans une transaction où l'audit est écrit avec la
+// modification, encadrée par les révisions du produit parent (voir
+// OptionProduitService.ecrire)
+func (s *VarianteService) ecrire(ctx context.Context, produitID string, fn func(variantes *repository.VarianteRepo, audit *AuditService) error) error {
+	if err := s.revisions.CapturerInitialeProduit(ctx, produitID); err != nil {
+		return err
+	}
+	err := s.repo.Transaction(ctx, func(variantes *repository.VarianteRepo, journal *repository.AuditRepo) error {
+		return fn(variantes, s.audit.Dans(journal))
+	})
+	if err != nil {
+		return err
+	}
+	if err := s.revisions.CapturerProduit(ctx, produitID); err != nil {
+		log.Printf("révision produit %s: %v", produitID, err)
+	}
+	return nil
 }
 
 // ------------------------------------------------------------
 // Convertisseur
 // ------------------------------------------------------------
-func (s *VarianteService) toResponse(v models.Variante, prixDefautProduit float64) dto.VarianteResponse {
+func (s *VarianteService) toResponse(v models.Variante, tarif TarifProduit) dto.VarianteResponse {
+	return varianteVersResponse(v, tarif)
+}
+
+// varianteVersResponse est partagé avec ProduitService qui renvoie les
+// variantes préchargées avec le produit
+func varianteVersResponse(v models.Variante, tarif TarifProduit) dto.VarianteResponse {
 	valeursOpts := make([]dto.ValeurOptionResponse, len(v.ValeurOptions))
 	for i, vo := range v.ValeurOptions {
-		valeursOpts[i] = dto.ValeurOptionResponse{
-			ID:       vo.ID,
-			OptionID: vo.OptionID,
-			Valeur:   vo.Valeur,
-			Position: vo.Position,
-		}
+		valeursOpts[i] = valeurOptionVersResponse(vo)
 	}
 
-	prixEffectif := prixDefautProduit
+	// sans prix propre, la variante hérite du prix du produit et, à défaut
+	// de promotion à elle, de celle du produit
+	devise := tarif.Prix.Devise
+	var prix *monnaie.Prix
+	regulier, promotion := tarif.Prix, v.Promotion
 	if v.Prix != nil {
-		prixEffectif = *v.Prix
+		propre := monnaie.NouveauPrix(*v.Prix, devise)
+		prix, regulier = &propre, propre
+	} else if promotion.Vide() {
+		promotion = tarif.Promotion
 	}
+	affichage := calculerAffichage(regulier, promotion, time.Now())
 
 	return dto.VarianteResponse{
-		ID:            v.ID,
-		ProduitID:     v.ProduitID,
-		SKU:           v.SKU,
-		Prix:          v.Prix,
-		QuantiteStock: v.QuantiteStock,
-		CodeBarres:    v.CodeBarres,
-		Poids:         v.Poids,
-		Images:        v.Images,
-		CreeLe:        v.CreeLe,
-		MisAJourLe:    v.MisAJourLe,
-		ValeurOptions: valeursOpts,
-		PrixEffectif:  prixEffectif,
+		ID:                v.ID,
+		ProduitID:         v.ProduitID,
+		SKU:               v.SKU,
+		Prix:              prix,
+		QuantiteStock:     v.QuantiteStock,
+		CodeBarres:        v.CodeBarres,
+		Poids:             v.Poids,
+		Images:            v.Images,
+		Version:           v.Version,
+		CreeLe:            v.CreeLe,
+		MisAJourLe:        v.MisAJourLe,
+		ValeurOptions:     valeursOpts,
+		PrixBarre:         prixOptionnel(v.PrixBarre, devise),
+		PrixPromo:         prixOptionnel(v.PrixPromo, devise),
+		DebutPromo:        v.DebutPromo,
+		FinPromo:          v.FinPromo,
+		PromoActive:       affichage.active,
+		PrixEffectif:      affichage.effectif,
+		PrixOrigine:       affichage.origine,
+		RemisePourcentage: affichage.remise,
+	}
+}
+
+// ErrCombinaisonInvalide : valeur étrangère au produit, en double ou deux valeurs d'une même option
+var ErrCombinaisonInvalide = repository.ErrCombinaisonInvalide
+
+// ErrCombinaisonExistante : une autre variante porte déjà exactement cette combinaison
+var ErrCombinaisonExistante = repository.ErrCombinaisonExistante
+
+// ErrCodeBarresInvalide : format ou clé de contrôle GTIN incorrects
+var ErrCodeBarresInvalide = errors.New("code-barres invalide")
+
+// ErrCodeBarresPris : le code-barres est déjà porté par une variante de la boutique
+var ErrCodeBarresPris = repository.ErrCodeBarresPris
+
+// verifierCodeBarres valide la clé GTIN puis l'unicité dans la boutique
+// (UPC-A et EAN-13 équivalents comparés sur 14 chiffres) ; "" = pas de code
+func verifierCodeBarres(ctx context.Context, repo *repository.VarianteRepo, boutiqueID, code, exclureID string) error {
+	if code == "" {
+		return nil
+	}
+	if err := gtin.Valider(code); err != nil {
+		return fmt.Errorf("%w: %v", ErrCodeBarresInvalide, err)
+	}
+	pris, err := repo.CodeBarresPris(ctx, boutiqueID, gtin.Normaliser(code), exclureID)
+	if err != nil {
+		return err
+	}
+	if pris {
+		return fmt.Errorf("%w: %s", ErrCodeBarresPris, code)
+	}
+	return nil
+}
+
+// codeBarresOuNul : un code-barres vide est stocké NULL, pas ""
+func codeBarresOuNul(code *string) *string {
+	if code == nil || *code == "" {
+		return nil
+	}
+	return code
+}
+
+// viderCodeBarres remplace un code-barres effacé ("") par NULL
+func viderCodeBarres(modifications map[string]interface{}) {
+	switch code := modifications["code_barres"].(type) {
+	case string:
+		if code == "" {
+			modifications["code_barres"] = nil
+		}
+	case *string:
+		if codeBarresOuNul(code) == nil {
+			modifications["code_barres"] = nil
+		}
 	}
 }
 
+// codeBarresModifie extrait le code-barres d'un jeu de modifications
+func codeBarresModifie(modifications map[string]interface{}) (string, bool) {
+	switch code := modifications["code_barres"].(type) {
+	case string:
+		return code, true
+	case *string:
+		if code != nil {
+			return *code, true
+		}
+	}
+	return "", false
+}
+
 // ------------------------------------------------------------
 // Créer une variante
 // ------------------------------------------------------------
 func (s *VarianteService) Create(
 	ctx context.Context,
-	produitID string,
+	produitID, boutiqueID string,
 	req dto.RequeteCreationVariante,
-	prixDefautProduit float64,
+	tarif TarifProduit,
 ) (*dto.VarianteResponse, error) {
 
-	// Vérifier les doublons
-	if len(req.ValeurOptionIDs) > 0 {
-		existe, err := s.repo.CheckDuplicateCombination(ctx, produitID, req.ValeurOptionIDs)
-		if err != nil {
-			return nil, fmt.Errorf("erreur vérification doublon: %v", err)
-		}
-		if existe {
-			return nil, errors.New("cette combinaison existe déjà")
+	if req.CodeBarres != nil {
+		if err := verifierCodeBarres(ctx, s.repo, boutiqueID, *req.CodeBarres, ""); err != nil {
+			return nil, err
 		}
 	}
+	if err := verifierSKUVariante(ctx, s.repo, boutiqueID, req.SKU, ""); err != nil {
+		return nil, err
+	}
+
+	promotion, err := arrondirPromotion(models.Promotion{
+		PrixBarre:  req.PrixBarre,
+		PrixPromo:  req.PrixPromo,
+		DebutPromo: req.DebutPromo,
+		FinPromo:   req.FinPromo,
+	}, tarif.Prix.Devise, prixRegulierVariante(req.Prix, tarif))
+	if err != nil {
+		return nil, err
+	}
 
 	// Créer la variante
 	variante := &models.Variante{
 		ProduitID:     produitID,
+		BoutiqueID:    boutiqueID,
 		SKU:           req.SKU,
-		Prix:          req.Prix,
+		Prix:          arrondirMontant(req.Prix, tarif.Prix.Devise),
 		QuantiteStock: req.QuantiteStock,
-		CodeBarres:    req.CodeBarres,
+		CodeBarres:    codeBarresOuNul(req.CodeBarres),
 		Poids:         req.Poids,
 		Images:        req.Images,
+		Promotion:     promotion,
 		CreeLe:        time.Now(),
 		MisAJourLe:    time.Now(),
 	}
 
-	creee, err := s.repo.CreationVariant(ctx, variante)
-	if err != nil {
-		return nil, fmt.Errorf("échec création: %v", err)
-	}
+	// boutiqueID est celle du produit, chargé par l'appelant (Tarif)
+	var finale *models.Variante
+	err = s.ecrire(ctx, produitID, func(variantes *repository.VarianteRepo, audit *AuditService) error {
+		creee, err := variantes.CreationVariant(ctx, variante)
+		if err != nil {
+			return fmt.Errorf("échec création: %w", err)
+		}
 
-	// Ajouter les relations (à implémenter dans le repo)
-	if len(req.ValeurOptionIDs) > 0 {
-		// Il faudra ajouter une méthode dans le repo pour attacher les valeurs
-		// Pour l'instant, on suppose que c'est géré ailleurs
-	}
+		// combinaison vérifiée et liée sous le verrou du produit
+		if err := variantes.AttacherValeurs(ctx, produitID, creee.ID, req.ValeurOptionIDs); err != nil {
+			return err
+		}
 
-	// Récupérer la variante complète
-	finale, err := s.repo.GetByID(ctx, creee.ID)
+		// Récupérer la variante complète
+		if finale, err = variantes.GetAvecValeurs(ctx, creee.ID); err != nil {
+			return err
+		}
+		return audit.Journaliser(ctx, boutiqueID, produitID, models.EntiteVariante, finale.ID, models.ActionCreation, nil, finale)
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	reponse := s.toResponse(*finale, prixDefautProduit)
+	reponse := s.toResponse(*finale, tarif)
 	return &reponse, nil
 }
 
 // ------------------------------------------------------------
 // Lister les variantes d'un produit
 // ------------------------------------------------------------
-func (s *VarianteService) ListByProduit(ctx context.Context, produitID string, prixDefautProduit float64) ([]dto.VarianteResponse, error) {
+func (s *VarianteService) ListByProduit(ctx context.Context, produitID string, tarif TarifProduit) ([]dto.VarianteResponse, error) {
 	if produitID == "" {
 		return nil, errors.New("ID produit requis")
 	}
@@ -123,7 +247,7 @@ func (s *VarianteService) ListByProduit(ctx context.Context, produitID string, p
 
 	resultats := make([]dto.VarianteResponse, len(variantes))
 	for i, v := range variantes {
-		resultats[i] = s.toResponse(v, prixDefautProduit)
+		resultats[i] = s.toResponse(v, tarif)
 	}
 	return resultats, nil
 }
@@ -131,7 +255,7 @@ func (s *VarianteService) ListByProduit(ctx context.Context, produitID string, p
 // ------------------------------------------------------------
 // Récupérer une variante par ID
 // ------------------------------------------------------------
-func (s *VarianteService) GetByID(ctx context.Context, id string, prixDefautProduit float64) (*dto.VarianteResponse, error) {
+func (s *VarianteService) GetByID(ctx context.Context, id string, tarif TarifProduit) (*dto.VarianteResponse, error) {
 	variante, err := s.repo.GetByID(ctx, id)
 	if err != nil {
 		return nil, err
@@ -140,21 +264,43 @@ func (s *VarianteService) GetByID(ctx context.Context, id string, prixDefautProd
 		return nil, errors.New("variante non trouvée")
 	}
 
-	reponse := s.toResponse(*variante, prixDefautProduit)
+	reponse := s.toResponse(*variante, tarif)
 	return &reponse, nil
 }
 
 // ------------------------------------------------------------
 // Mettre à jour une variante
 // ------------------------------------------------------------
-func (s *VarianteService) Update(ctx context.Context, id string, req dto.RequeteUpdateVariante, prixDefautProduit float64) (*dto.VarianteResponse, error) {
+func (s *VarianteService) Update(ctx context.Context, id, boutiqueID string, req dto.RequeteUpdateVariante, tarif TarifProduit, version *int) (*dto.VarianteResponse, error) {
 	// Vérifier que la variante existe
-	_, err := s.repo.GetByID(ctx, id)
+	avant, err := s.repo.GetByID(ctx, id)
 	if err != nil {
 		return nil, err
 	}
+	if avant == nil {
+		return nil, errors.New("variante non trouvée")
+	}
+	if version != nil && *version != avant.Version {
+		return nil, ErrVersionObsolete
+	}
 
 	// Préparer les modifs
+	modifications := champsVariante(req)
+	modifications["mis_a_jour_le"] = time.Now()
+
+	return s.enregistrer(ctx, avant, boutiqueID, modifications, tarif, version)
+}
+
+// prixRegulierVariante : prix propre de la variante, sinon celui du produit
+func prixRegulierVariante(prix *monnaie.Montant, tarif TarifProduit) monnaie.Montant {
+	if prix != nil {
+		return *prix
+	}
+	return tarif.Prix.Montant
+}
+
+// champsVariante traduit une mise à jour partielle en colonnes : nil = inchangé
+func champsVariante(req dto.RequeteUpdateVariante) map[string]interface{} {
 	modifications := make(map[string]interface{})
 	if req.SKU != nil {
 		modifications["sku"] = *req.SKU
@@ -174,31 +320,132 @@ func (s *VarianteService) Update(ctx context.Context, id string, req dto.Requete
 	if req.Images != nil {
 		modifications["images"] = req.Images
 	}
-	modifications["mis_a_jour_le"] = time.Now()
+	champsPromotion(modifications, req.PrixBarre, req.PrixPromo, req.DebutPromo, req.FinPromo)
+	return modifications
+}
 
-	// Mettre à jour
-	modifiee, err := s.repo.Update(ctx, id, modifications)
+// ------------------------------------------------------------
+// Document modifiable d'une variante (base d'un PATCH)
+// ------------------------------------------------------------
+func (s *VarianteService) Document(ctx context.Context, id string) (*dto.DocumentVariante, int, error) {
+	variante, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, 0, err
+	}
+	if variante == nil {
+		return nil, 0, errors.New("variante non trouvée")
+	}
+	return &dto.DocumentVariante{
+		SKU:           variante.SKU,
+		Prix:          variante.Prix,
+		QuantiteStock: variante.QuantiteStock,
+		CodeBarres:    variante.CodeBarres,
+		Poids:         variante.Poids,
+		Images:        variante.Images,
+		PrixBarre:     variante.PrixBarre,
+		PrixPromo:     variante.PrixPromo,
+		DebutPromo:    variante.DebutPromo,
+		FinPromo:      variante.FinPromo,
+	}, variante.Version, nil
+}
+
+// ------------------------------------------------------------
+// Remplacer : écrit le document patché, nil efface la colonne
+// ------------------------------------------------------------
+func (s *VarianteService) Remplacer(ctx context.Context, id, boutiqueID string, doc dto.DocumentVariante, tarif TarifProduit, version int) (*dto.VarianteResponse, error) {
+	avant, err := s.repo.GetByID(ctx, id)
 	if err != nil {
 		return nil, err
 	}
-	if modifiee == nil {
-		return nil, errors.New("variante non trouvée après update")
+	if avant == nil {
+		return nil, errors.New("variante non trouvée")
+	}
+	if version != avant.Version {
+		return nil, ErrVersionObsolete
+	}
+
+	modifications := map[string]interface{}{
+		"sku":            doc.SKU,
+		"prix":           doc.Prix,
+		"quantite_stock": doc.QuantiteStock,
+		"code_barres":    doc.CodeBarres,
+		"poids":          doc.Poids,
+		"images":         doc.Images,
+		"prix_barre":     doc.PrixBarre,
+		"prix_promo":     doc.PrixPromo,
+		"debut_promo":    doc.DebutPromo,
+		"fin_promo":      doc.FinPromo,
+		"mis_a_jour_le":  time.Now(),
+	}
+	return s.enregistrer(ctx, avant, boutiqueID, modifications, tarif, &version)
+}
+
+// enregistrer applique les modifications, journalise et détecte la rupture de stock
+func (s *VarianteService) enregistrer(ctx context.Context, avant *models.Variante, boutiqueID string, modifications map[string]interface{}, tarif TarifProduit, version *int) (*dto.VarianteResponse, error) {
+	id := avant.ID
+	arrondirPrix(modifications, "prix", tarif.Prix.Devise)
+	regulier := prixRegulierVariante(prixModifie(modifications, "prix", avant.Prix), tarif)
+	if err := preparerPromotion(avant.Promotion, modifications, tarif.Prix.Devise, regulier, "prix"); err != nil {
+		return nil, err
 	}
 
-	reponse := s.toResponse(*modifiee, prixDefautProduit)
+	viderCodeBarres(modifications)
+	if code, ok := codeBarresModifie(modifications); ok {
+		if err := verifierCodeBarres(ctx, s.repo, boutiqueID, code, id); err != nil {
+			return nil, err
+		}
+	}
+	if sku, ok := skuModifie(modifications); ok {
+		if err := verifierSKUVariante(ctx, s.repo, boutiqueID, sku, id); err != nil {
+			return nil, err
+		}
+	}
+
+	// Mettre à jour
+	var modifiee *models.Variante
+	err := s.ecrire(ctx, avant.ProduitID, func(variantes *repository.VarianteRepo, audit *AuditService) error {
+		var err error
+		if modifiee, err = variantes.Update(ctx, id, modifications, version); err != nil {
+			return err
+		}
+		if modifiee == nil {
+			return errors.New("variante non trouvée après update")
+		}
+		return audit.Journaliser(ctx, avant.BoutiqueID, modifiee.ProduitID, models.EntiteVariante, id, models.ActionModification, avant, modifiee)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	reponse := s.toResponse(*modifiee, tarif)
+
+	// Passage en rupture : le stock vient de tomber à zéro
+	if avant.QuantiteStock > 0 && modifiee.QuantiteStock <= 0 && s.evenements != nil {
+		s.evenements.Publier(ctx, boutiqueID, models.EvenementVarianteRupture, reponse)
+	}
 	return &reponse, nil
 }
 
 // ------------------------------------------------------------
 // Supprimer une variante
 // ------------------------------------------------------------
-func (s *VarianteService) Delete(ctx context.Context, id string) error {
-	supprimee, err := s.repo.SupprimereById(ctx, id)
+// Delete : une variante d'une autre boutique est traitée comme absente
+func (s *VarianteService) Delete(ctx context.Context, id, boutiqueID string, version *int) error {
+	avant, err := s.repo.GetByID(ctx, id)
 	if err != nil {
 		return err
 	}
-	if !supprimee {
+	if avant == nil || avant.BoutiqueID != boutiqueID {
 		return errors.New("variante non trouvée")
 	}
-	return nil
+	return s.ecrire(ctx, avant.ProduitID, func(variantes *repository.VarianteRepo, audit *AuditService) error {
+		supprimee, err := variantes.SupprimereById(ctx, id, version)
+		if err != nil {
+			return err
+		}
+		if !supprimee {
+			return errors.New("variante non trouvée")
+		}
+		return audit.Journaliser(ctx, avant.BoutiqueID, avant.ProduitID, models.EntiteVariante, id, models.ActionSuppression, avant, nil)
+	})
 }
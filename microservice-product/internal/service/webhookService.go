@@ -0,0 +1,610 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"projet/internal/dto"
+	"projet/internal/models"
+	"projet/internal/repository"
+	"slices"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// En-têtes envoyés avec chaque livraison. La signature est un HMAC-SHA256
+// (clé = secret de l'abonnement) calculé sur "<horodatage>.<corps>".
+const (
+	EnteteSignature  = "X-Webhook-Signature"
+	EnteteHorodatage = "X-Webhook-Horodatage"
+	EnteteEvenement  = "X-Webhook-Evenement"
+	EnteteLivraison  = "X-Webhook-Livraison"
+)
+
+// PublieurEvenements est ce dont les autres services ont besoin pour notifier
+// un événement catalogue, sans dépendre du transport (webhooks, bus, ...).
+type PublieurEvenements interface {
+	Publier(ctx context.Context, boutiqueID, evenement string, donnees interface{})
+}
+
+// Backoff donne le délai avant l'essai suivant d'une série (essai = 1 après
+// le premier échec)
+type Backoff func(essai int) time.Duration
+
+// BackoffExponentiel : initial, x2, x4, ...
+func BackoffExponentiel(initial time.Duration) Backoff {
+	return func(essai int) time.Duration {
+		return initial << (essai - 1)
+	}
+}
+
+const (
+	// bailLivraison : durée pendant laquelle une livraison en cours d'envoi
+	// n'est pas reprise par le worker (au-delà, l'envoi est considéré perdu)
+	bailLivraison = time.Minute
+	// livraisons reprises par passage du worker
+	lotReprise = 50
+)
+
+// ErrURLWebhook : URL non http(s) ou qui désigne une adresse interne
+var ErrURLWebhook = errors.New("URL de webhook non autorisée")
+
+// ErrWebhookInactif : l'abonnement est désactivé, rien ne lui est envoyé
+var ErrWebhookInactif = errors.New("webhook désactivé")
+
+// depotLivraisons : ce dont la livraison a besoin du repo, isolé pour les tests
+type depotLivraisons interface {
+	CreateLivraison(ctx context.Context, livraison *models.LivraisonWebhook) (*models.LivraisonWebhook, error)
+	UpdateLivraison(ctx context.Context, id string, updates map[string]interface{}) error
+	ReserverLivraisonsDues(ctx context.Context, maintenant, bail time.Time, limite int) ([]models.LivraisonWebhook, error)
+	GetAbonnementParID(ctx context.Context, id string) (*models.AbonnementWebhook, error)
+}
+
+type WebhookService struct {
+	repo       *repository.WebhookRepo
+	livraisons depotLivraisons
+	client     *http.Client
+
+	maxTentatives int
+	backoff       Backoff
+}
+
+// NewWebhookService : client nil = client qui refuse de joindre une adresse
+// interne ; backoff nil = BackoffExponentiel(2s)
+func NewWebhookService(repo *repository.WebhookRepo, client *http.Client, backoff Backoff) *WebhookService {
+	if client == nil {
+		client = clientExterne(10 * time.Second)
+	}
+	if backoff == nil {
+		backoff = BackoffExponentiel(2 * time.Second)
+	}
+	return &WebhookService{
+		repo:          repo,
+		livraisons:    repo,
+		client:        client,
+		maxTentatives: 5,
+		backoff:       backoff,
+	}
+}
+
+// ------------------------------------------------------------
+// Convertisseurs
+// ------------------------------------------------------------
+func (s *WebhookService) toResponse(a models.AbonnementWebhook) dto.WebhookResponse {
+	return dto.WebhookResponse{
+		ID:         a.ID,
+		BoutiqueID: a.BoutiqueID,
+		URL:        a.URL,
+		Evenements: a.Evenements,
+		Actif:      a.Actif,
+		CreeLe:     a.CreeLe,
+		MisAJourLe: a.MisAJourLe,
+	}
+}
+
+func (s *WebhookService) toResponseLivraison(l models.LivraisonWebhook) dto.LivraisonWebhookResponse {
+	return dto.LivraisonWebhookResponse{
+		ID:           l.ID,
+		AbonnementID: l.AbonnementID,
+		Evenement:    l.Evenement,
+		Payload:      l.Payload,
+		Statut:       l.Statut,
+		Tentatives:   l.Tentatives,
+		CodeReponse:  l.CodeReponse,
+		Erreur:       l.Erreur,
+		CreeLe:       l.CreeLe,
+		MisAJourLe:   l.MisAJourLe,
+	}
+}
+
+// ------------------------------------------------------------
+// CRUD des abonnements
+// ------------------------------------------------------------
+func (s *WebhookService) Create(ctx context.Context, boutiqueID string, req dto.RequeteCreationWebhook) (*dto.WebhookResponse, error) {
+	if boutiqueID == "" {
+		return nil, errors.New("boutique ID is required")
+	}
+	if err := verifierURLWebhook(ctx, req.URL); err != nil {
+		return nil, err
+	}
+
+	secret := ""
+	if req.Secret != nil {
+		secret = *req.Secret
+	} else {
+		genere, err := genererSecret()
+		if err != nil {
+			return nil, err
+		}
+		secret = genere
+	}
+
+	actif := true
+	if req.Actif != nil {
+		actif = *req.Actif
+	}
+
+	abonnement := &models.AbonnementWebhook{
+		BoutiqueID: boutiqueID,
+		URL:        req.URL,
+		Secret:     secret,
+		Evenements: req.Evenements,
+		Actif:      actif,
+	}
+
+	cree, err := s.repo.CreateAbonnement(ctx, abonnement)
+	if err != nil {
+		return nil, err
+	}
+
+	// le secret n'est visible qu'une seule fois
+	resp := s.toResponse(*cree)
+	resp.Secret = cree.Secret
+	return &resp, nil
+}
+
+func (s *WebhookService) List(ctx context.Context, boutiqueID string) ([]dto.WebhookResponse, error) {
+	if boutiqueID == "" {
+		return nil, errors.New("boutique ID is required")
+	}
+
+	abonnements, err := s.repo.ListAbonnements(ctx, boutiqueID)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := make([]dto.WebhookResponse, len(abonnements))
+	for i, a := range abonnements {
+		resp[i] = s.toResponse(a)
+	}
+	return resp, nil
+}
+
+func (s *WebhookService) GetByID(ctx context.Context, id, boutiqueID string) (*dto.WebhookResponse, error) {
+	abonnement, err := s.repo.GetAbonnement(ctx, id, boutiqueID)
+	if err != nil {
+		return nil, err
+	}
+	if abonnement == nil {
+		return nil, errors.New("webhook not found")
+	}
+	resp := s.toResponse(*abonnement)
+	return &resp, nil
+}
+
+func (s *WebhookService) Update(ctx context.Context, id, boutiqueID string, req dto.RequeteUpdateWebhook) (*dto.WebhookResponse, error) {
+	if _, err := s.GetByID(ctx, id, boutiqueID); err != nil {
+		return nil, err
+	}
+
+	updates := make(map[string]interface{})
+	if req.URL != nil {
+		if err := verifierURLWebhook(ctx, *req.URL); err != nil {
+			return nil, err
+		}
+		updates["url"] = *req.URL
+	}
+	if req.Secret != nil {
+		updates["secret"] = *req.Secret
+	}
+	if req.Evenements != nil {
+		// serializer:json n'est pas appliqué aux maps, on encode nous-mêmes
+		evenements, err := json.Marshal(req.Evenements)
+		if err != nil {
+			return nil, err
+		}
+		updates["evenements"] = string(evenements)
+	}
+	if req.Actif != nil {
+		updates["actif"] = *req.Actif
+	}
+	updates["mis_a_jour_le"] = time.Now()
+
+	updated, err := s.repo.UpdateAbonnement(ctx, id, boutiqueID, updates)
+	if err != nil {
+		return nil, err
+	}
+	if updated == nil {
+		return nil, errors.New("webhook not found")
+	}
+	resp := s.toResponse(*updated)
+	return &resp, nil
+}
+
+func (s *WebhookService) Delete(ctx context.Context, id, boutiqueID string) error {
+	deleted, err := s.repo.DeleteAbonnement(ctx, id, boutiqueID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return errors.New("webhook not found")
+	}
+	return nil
+}
+
+// ------------------------------------------------------------
+// Journal des livraisons et relivraison manuelle
+// ------------------------------------------------------------
+func (s *WebhookService) ListLivraisons(ctx context.Context, abonnementID, boutiqueID string, page, limite int) ([]dto.LivraisonWebhookResponse, error) {
+	if _, err := s.GetByID(ctx, abonnementID, boutiqueID); err != nil {
+		return nil, err
+	}
+	if page <= 0 {
+		page = 1
+	}
+	if limite <= 0 {
+		limite = 20
+	}
+
+	livraisons, err := s.repo.ListLivraisons(ctx, abonnementID, page, limite)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := make([]dto.LivraisonWebhookResponse, len(livraisons))
+	for i, l := range livraisons {
+		resp[i] = s.toResponseLivraison(l)
+	}
+	return resp, nil
+}
+
+// Relivrer renvoie une livraison existante (même payload, même identifiant),
+// quel que soit son statut, avec une nouvelle série d'essais, tant que
+// l'abonnement est actif. Le premier envoi
+// part en arrière-plan, les réessais sont repris par le worker.
+func (s *WebhookService) Relivrer(ctx context.Context, livraisonID, abonnementID, boutiqueID string) (*dto.LivraisonWebhookResponse, error) {
+	abonnement, err := s.repo.GetAbonnement(ctx, abonnementID, boutiqueID)
+	if err != nil {
+		return nil, err
+	}
+	if abonnement == nil {
+		return nil, errors.New("webhook not found")
+	}
+	// un point de terminaison désactivé ne reçoit plus de payloads signés
+	if !abonnement.Actif {
+		return nil, ErrWebhookInactif
+	}
+
+	livraison, err := s.repo.GetLivraison(ctx, livraisonID, abonnementID)
+	if err != nil {
+		return nil, err
+	}
+	if livraison == nil {
+		return nil, errors.New("delivery not found")
+	}
+
+	// nouvelle série d'essais ; le bail écarte le worker pendant l'envoi
+	bail := time.Now().Add(bailLivraison)
+	if err := s.repo.UpdateLivraison(ctx, livraison.ID, map[string]interface{}{
+		"statut":              models.LivraisonEnAttente,
+		"essais_restants":     s.maxTentatives,
+		"prochaine_tentative": bail,
+		"mis_a_jour_le":       time.Now(),
+	}); err != nil {
+		return nil, err
+	}
+	livraison.Statut = models.LivraisonEnAttente
+	livraison.EssaisRestants = s.maxTentatives
+	livraison.ProchaineTentative = &bail
+
+	go s.tenter(context.Background(), *abonnement, *livraison)
+
+	resp := s.toResponseLivraison(*livraison)
+	return &resp, nil
+}
+
+// ------------------------------------------------------------
+// Publication d'un événement
+// ------------------------------------------------------------
+
+// Publier crée une livraison par abonnement concerné puis les envoie en
+// arrière-plan. Les erreurs sont journalisées : un webhook en panne ne doit
+// jamais faire échouer la requête qui a déclenché l'événement.
+func (s *WebhookService) Publier(_ context.Context, boutiqueID, evenement string, donnees interface{}) {
+	// le contexte de la requête fiber est recyclé dès la réponse envoyée
+	go func() {
+		ctx := context.Background()
+		if err := s.publier(ctx, boutiqueID, evenement, donnees); err != nil {
+			log.Printf("webhook %s (boutique %s): %v", evenement, boutiqueID, err)
+		}
+	}()
+}
+
+func (s *WebhookService) publier(ctx context.Context, boutiqueID, evenement string, donnees interface{}) error {
+	abonnements, err := s.repo.ListAbonnementsActifs(ctx, boutiqueID)
+	if err != nil {
+		return err
+	}
+
+	for _, abonnement := range abonnements {
+		if !slices.Contains(abonnement.Evenements, evenement) {
+			continue
+		}
+
+		id, err := nouvelIdentifiant()
+		if err != nil {
+			return err
+		}
+		payload, err := json.Marshal(dto.EvenementWebhook{
+			ID:         id,
+			Evenement:  evenement,
+			BoutiqueID: boutiqueID,
+			CreeLe:     time.Now().UTC(),
+			Donnees:    donnees,
+		})
+		if err != nil {
+			return fmt.Errorf("encodage du payload: %w", err)
+		}
+
+		bail := time.Now().Add(bailLivraison)
+		livraison, err := s.livraisons.CreateLivraison(ctx, &models.LivraisonWebhook{
+			AbonnementID:       abonnement.ID,
+			Evenement:          evenement,
+			Payload:            string(payload),
+			Statut:             models.LivraisonEnAttente,
+			EssaisRestants:     s.maxTentatives,
+			ProchaineTentative: &bail,
+		})
+		if err != nil {
+			return err
+		}
+
+		go s.tenter(ctx, abonnement, *livraison)
+	}
+	return nil
+}
+
+// ------------------------------------------------------------
+// Essais et reprise
+// ------------------------------------------------------------
+
+// Demarrer reprend les livraisons en attente dont la date de tentative est
+// échue : réessais programmés et envois interrompus par un arrêt. Bloque
+// jusqu'à l'annulation de ctx : à lancer dans une goroutine.
+func (s *WebhookService) Demarrer(ctx context.Context, intervalle time.Duration) {
+	s.reprendre(ctx)
+
+	ticker := time.NewTicker(intervalle)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.reprendre(ctx)
+		}
+	}
+}
+
+func (s *WebhookService) reprendre(ctx context.Context) {
+	maintenant := time.Now()
+	livraisons, err := s.livraisons.ReserverLivraisonsDues(ctx, maintenant, maintenant.Add(bailLivraison), lotReprise)
+	if err != nil {
+		log.Printf("webhook reprise: %v", err)
+		return
+	}
+
+	abonnements := make(map[string]*models.AbonnementWebhook)
+	var attente sync.WaitGroup
+	for _, livraison := range livraisons {
+		abonnement, ok := abonnements[livraison.AbonnementID]
+		if !ok {
+			if abonnement, err = s.livraisons.GetAbonnementParID(ctx, livraison.AbonnementID); err != nil {
+				log.Printf("webhook reprise %s: %v", livraison.ID, err)
+				continue
+			}
+			abonnements[livraison.AbonnementID] = abonnement
+		}
+		if abonnement == nil || !abonnement.Actif {
+			s.abandonner(ctx, livraison, "abonnement inactif")
+			continue
+		}
+
+		attente.Add(1)
+		go func(livraison models.LivraisonWebhook) {
+			defer attente.Done()
+			s.tenter(ctx, *abonnement, livraison)
+		}(livraison)
+	}
+	attente.Wait()
+}
+
+func (s *WebhookService) abandonner(ctx context.Context, livraison models.LivraisonWebhook, raison string) {
+	if err := s.livraisons.UpdateLivraison(ctx, livraison.ID, map[string]interface{}{
+		"statut":              models.LivraisonEchouee,
+		"prochaine_tentative": nil,
+		"erreur":              raison,
+		"mis_a_jour_le":       time.Now(),
+	}); err != nil {
+		log.Printf("webhook livraison %s: %v", livraison.ID, err)
+	}
+}
+
+// tenter fait un envoi et l'inscrit au journal ; en cas d'échec, le prochain
+// essai est programmé selon le backoff et sera repris par le worker
+func (s *WebhookService) tenter(ctx context.Context, abonnement models.AbonnementWebhook, livraison models.LivraisonWebhook) {
+	code, err := s.envoyer(ctx, abonnement, livraison)
+	modifications := s.bilanTentative(livraison, code, err, time.Now())
+	if errMaj := s.livraisons.UpdateLivraison(ctx, livraison.ID, modifications); errMaj != nil {
+		log.Printf("webhook livraison %s: %v", livraison.ID, errMaj)
+	}
+}
+
+// bilanTentative : colonnes à écrire après un envoi. Un échec laisse la
+// livraison en_attente tant qu'il reste des essais dans la série.
+func (s *WebhookService) bilanTentative(livraison models.LivraisonWebhook, code int, err error, maintenant time.Time) map[string]interface{} {
+	restants := livraison.EssaisRestants
+	if restants <= 0 {
+		// livraison antérieure au compteur d'essais
+		restants = s.maxTentatives
+	}
+	restants--
+
+	modifications := map[string]interface{}{
+		"tentatives":      livraison.Tentatives + 1,
+		"essais_restants": restants,
+		"mis_a_jour_le":   maintenant,
+	}
+	if code != 0 {
+		modifications["code_reponse"] = code
+	}
+	switch {
+	case err == nil:
+		modifications["statut"] = models.LivraisonReussie
+		modifications["erreur"] = nil
+		modifications["prochaine_tentative"] = nil
+	case restants <= 0:
+		modifications["statut"] = models.LivraisonEchouee
+		modifications["erreur"] = err.Error()
+		modifications["prochaine_tentative"] = nil
+	default:
+		modifications["erreur"] = err.Error()
+		modifications["prochaine_tentative"] = maintenant.Add(s.backoff(s.maxTentatives - restants))
+	}
+	return modifications
+}
+
+// envoyer fait un seul POST signé ; seul un code 2xx est considéré comme un succès.
+func (s *WebhookService) envoyer(ctx context.Context, abonnement models.AbonnementWebhook, livraison models.LivraisonWebhook) (int, error) {
+	horodatage := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, abonnement.URL, bytes.NewBufferString(livraison.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EnteteEvenement, livraison.Evenement)
+	req.Header.Set(EnteteLivraison, livraison.ID)
+	req.Header.Set(EnteteHorodatage, horodatage)
+	req.Header.Set(EnteteSignature, "sha256="+SignerPayload(abonnement.Secret, horodatage, []byte(livraison.Payload)))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("réponse HTTP %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// ------------------------------------------------------------
+// Adresses autorisées (SSRF)
+// ------------------------------------------------------------
+
+// verifierURLWebhook refuse les schémas autres que http(s) et les hôtes qui
+// résolvent vers une adresse interne. La résolution peut changer ensuite :
+// le client par défaut refait la vérification à chaque connexion.
+func verifierURLWebhook(ctx context.Context, brute string) error {
+	u, err := url.Parse(brute)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return fmt.Errorf("%w: %s", ErrURLWebhook, brute)
+	}
+	adresses, err := net.DefaultResolver.LookupIPAddr(ctx, u.Hostname())
+	if err != nil {
+		return fmt.Errorf("%w: hôte %s introuvable", ErrURLWebhook, u.Hostname())
+	}
+	for _, a := range adresses {
+		if adresseInterne(a.IP) {
+			return fmt.Errorf("%w: %s désigne une adresse interne", ErrURLWebhook, u.Hostname())
+		}
+	}
+	return nil
+}
+
+// plage partagée des opérateurs (CGNAT), absente de net.IP.IsPrivate
+var plageCGNAT = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+func adresseInterne(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsMulticast() ||
+		plageCGNAT.Contains(ip)
+}
+
+// clientExterne : client HTTP dont chaque connexion (redirections comprises)
+// est refusée si l'adresse résolue est interne
+func clientExterne(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 5 * time.Second,
+		Control: func(_, adresse string, _ syscall.RawConn) error {
+			hote, _, err := net.SplitHostPort(adresse)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(hote); ip == nil || adresseInterne(ip) {
+				return fmt.Errorf("%w: %s", ErrURLWebhook, hote)
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
+
+// SignerPayload calcule la signature hexadécimale attendue dans X-Webhook-Signature
+// (sans le préfixe "sha256="). Exposé pour que les marchands puissent vérifier.
+func SignerPayload(secret, horodatage string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(horodatage))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func genererSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("génération du secret: %w", err)
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+// identifiant au format UUID v4, sans dépendance supplémentaire
+func nouvelIdentifiant() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
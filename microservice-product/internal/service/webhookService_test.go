@@ -0,0 +1,195 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"projet/internal/models"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// depotMemoire : livraisons en mémoire, journal des mises à jour
+type depotMemoire struct {
+	mu         sync.Mutex
+	abonnement *models.AbonnementWebhook
+	dues       []models.LivraisonWebhook
+	misesAJour []map[string]interface{}
+}
+
+func (d *depotMemoire) CreateLivraison(_ context.Context, l *models.LivraisonWebhook) (*models.LivraisonWebhook, error) {
+	l.ID = "00000000-0000-4000-8000-000000000001"
+	return l, nil
+}
+
+func (d *depotMemoire) UpdateLivraison(_ context.Context, _ string, updates map[string]interface{}) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.misesAJour = append(d.misesAJour, updates)
+	return nil
+}
+
+func (d *depotMemoire) ReserverLivraisonsDues(_ context.Context, _, _ time.Time, _ int) ([]models.LivraisonWebhook, error) {
+	dues := d.dues
+	d.dues = nil
+	return dues, nil
+}
+
+func (d *depotMemoire) GetAbonnementParID(context.Context, string) (*models.AbonnementWebhook, error) {
+	return d.abonnement, nil
+}
+
+func (d *depotMemoire) derniere(t *testing.T) map[string]interface{} {
+	t.Helper()
+	if len(d.misesAJour) == 0 {
+		t.Fatal("aucune mise à jour de la livraison")
+	}
+	return d.misesAJour[len(d.misesAJour)-1]
+}
+
+func serviceTest(srv *httptest.Server, depot *depotMemoire, backoff Backoff) *WebhookService {
+	return &WebhookService{livraisons: depot, client: srv.Client(), maxTentatives: 3, backoff: backoff}
+}
+
+func TestLivraisonSignee(t *testing.T) {
+	const secret = "whsec_test_0123456789"
+	payload := `{"id":"e1","evenement":"produit.publie"}`
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		corps, _ := io.ReadAll(r.Body)
+		attendu := "sha256=" + SignerPayload(secret, r.Header.Get(EnteteHorodatage), corps)
+		if r.Header.Get(EnteteSignature) != attendu {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Header.Get(EnteteEvenement) != "produit.publie" || r.Header.Get(EnteteLivraison) != "l1" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	depot := &depotMemoire{}
+	s := serviceTest(srv, depot, BackoffExponentiel(time.Second))
+	abonnement := models.AbonnementWebhook{ID: "a1", URL: srv.URL, Secret: secret, Actif: true}
+	livraison := models.LivraisonWebhook{ID: "l1", Evenement: "produit.publie", Payload: payload, EssaisRestants: 3}
+
+	s.tenter(context.Background(), abonnement, livraison)
+
+	maj := depot.derniere(t)
+	if maj["statut"] != models.LivraisonReussie {
+		t.Fatalf("statut = %v, attendu reussie (%v)", maj["statut"], maj["erreur"])
+	}
+	if maj["code_reponse"] != http.StatusNoContent || maj["tentatives"] != 1 || maj["prochaine_tentative"] != nil {
+		t.Fatalf("mise à jour inattendue: %v", maj)
+	}
+}
+
+func TestReessaiRepris(t *testing.T) {
+	var appels int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		appels++
+		if appels == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	abonnement := &models.AbonnementWebhook{ID: "a1", URL: srv.URL, Secret: "s", Actif: true}
+	depot := &depotMemoire{abonnement: abonnement}
+	backoff := func(essai int) time.Duration { return time.Duration(essai) * time.Hour }
+	s := serviceTest(srv, depot, backoff)
+	livraison := models.LivraisonWebhook{ID: "l1", AbonnementID: "a1", Payload: "{}", EssaisRestants: 3}
+
+	avant := time.Now()
+	s.tenter(context.Background(), *abonnement, livraison)
+
+	maj := depot.derniere(t)
+	if _, ok := maj["statut"]; ok {
+		t.Fatalf("la livraison doit rester en attente après un premier échec: %v", maj)
+	}
+	prochaine, ok := maj["prochaine_tentative"].(time.Time)
+	if !ok || prochaine.Before(avant.Add(time.Hour)) || prochaine.After(time.Now().Add(time.Hour)) {
+		t.Fatalf("prochaine_tentative = %v, attendu maintenant + backoff(1)", maj["prochaine_tentative"])
+	}
+	if maj["essais_restants"] != 2 || maj["code_reponse"] != http.StatusServiceUnavailable {
+		t.Fatalf("mise à jour inattendue: %v", maj)
+	}
+
+	// le worker reprend la livraison échue
+	livraison.Tentatives, livraison.EssaisRestants = 1, 2
+	depot.dues = []models.LivraisonWebhook{livraison}
+	s.reprendre(context.Background())
+
+	maj = depot.derniere(t)
+	if maj["statut"] != models.LivraisonReussie || maj["tentatives"] != 2 || appels != 2 {
+		t.Fatalf("reprise: %v (%d appels)", maj, appels)
+	}
+}
+
+func TestDernierEssaiEchoue(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	depot := &depotMemoire{}
+	s := serviceTest(srv, depot, BackoffExponentiel(time.Second))
+	abonnement := models.AbonnementWebhook{ID: "a1", URL: srv.URL, Secret: "s", Actif: true}
+	livraison := models.LivraisonWebhook{ID: "l1", Payload: "{}", Tentatives: 2, EssaisRestants: 1}
+
+	s.tenter(context.Background(), abonnement, livraison)
+
+	maj := depot.derniere(t)
+	if maj["statut"] != models.LivraisonEchouee || maj["prochaine_tentative"] != nil || maj["tentatives"] != 3 {
+		t.Fatalf("mise à jour inattendue: %v", maj)
+	}
+}
+
+func TestBackoffExponentiel(t *testing.T) {
+	b := BackoffExponentiel(2 * time.Second)
+	for essai, attendu := range []time.Duration{2 * time.Second, 4 * time.Second, 8 * time.Second} {
+		if got := b(essai + 1); got != attendu {
+			t.Errorf("essai %d: %v, attendu %v", essai+1, got, attendu)
+		}
+	}
+}
+
+func TestVerifierURLWebhook(t *testing.T) {
+	refusees := []string{
+		"ftp://93.184.216.34/hook",
+		"http://127.0.0.1:8080/hook",
+		"http://localhost/hook",
+		"http://10.0.0.5/hook",
+		"http://192.168.1.1/hook",
+		"http://169.254.169.254/latest/meta-data",
+		"http://[::1]/hook",
+		"http://100.64.0.1/hook",
+		"http://0.0.0.0/hook",
+	}
+	for _, u := range refusees {
+		if err := verifierURLWebhook(context.Background(), u); !errors.Is(err, ErrURLWebhook) {
+			t.Errorf("%s: erreur %v, attendu ErrURLWebhook", u, err)
+		}
+	}
+	if err := verifierURLWebhook(context.Background(), "https://93.184.216.34/hook"); err != nil {
+		t.Errorf("adresse publique refusée: %v", err)
+	}
+}
+
+func TestClientExterneRefuseBoucleLocale(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	_, err := clientExterne(time.Second).Get(srv.URL)
+	if err == nil || !strings.Contains(err.Error(), ErrURLWebhook.Error()) {
+		t.Fatalf("connexion à %s: %v, attendu un refus", srv.URL, err)
+	}
+}
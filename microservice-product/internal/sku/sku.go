@@ -0,0 +1,139 @@
+// Package sku fabrique des SKU à partir d'un modèle à jetons, par exemple
+// "{PREFIXE}-{PRODUIT}-{OPTIONS}" ou "TS{COMPTEUR:5}".
+package sku
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// LongueurMax : taille de la colonne sku
+const LongueurMax = 100
+
+var ErrModele = errors.New("modèle de SKU invalide")
+
+var jeton = regexp.MustCompile(`\{([A-Z]+)(?::([0-9]+))?\}`)
+
+// Donnees : ce que les jetons remplacent
+type Donnees struct {
+	Prefixe  string
+	Produit  string   // SKU du produit, à défaut son slug
+	Options  []string // code (à défaut libellé) de chaque valeur, dans l'ordre des options
+	Compteur int64
+}
+
+// Valider refuse les jetons inconnus, les accolades orphelines et les
+// caractères qui n'ont rien à faire dans un SKU
+func Valider(modele string) error {
+	if strings.TrimSpace(modele) == "" {
+		return fmt.Errorf("%w: modèle vide", ErrModele)
+	}
+	for _, m := range jeton.FindAllStringSubmatch(modele, -1) {
+		switch m[1] {
+		case "PREFIXE", "PRODUIT", "OPTIONS":
+			if m[2] != "" {
+				return fmt.Errorf("%w: {%s} ne prend pas de largeur", ErrModele, m[1])
+			}
+		case "COMPTEUR":
+			if m[2] != "" {
+				if n, _ := strconv.Atoi(m[2]); n < 1 || n > 12 {
+					return fmt.Errorf("%w: largeur du compteur entre 1 et 12", ErrModele)
+				}
+			}
+		default:
+			return fmt.Errorf("%w: jeton {%s} inconnu", ErrModele, m[1])
+		}
+	}
+	for _, r := range jeton.ReplaceAllString(modele, "") {
+		if r == '{' || r == '}' {
+			return fmt.Errorf("%w: accolade orpheline", ErrModele)
+		}
+		if r <= ' ' || r > '~' {
+			return fmt.Errorf("%w: caractère %q interdit", ErrModele, r)
+		}
+	}
+	return nil
+}
+
+// UtiliseCompteur : le modèle consomme un numéro par SKU généré
+func UtiliseCompteur(modele string) bool {
+	for _, m := range jeton.FindAllStringSubmatch(modele, -1) {
+		if m[1] == "COMPTEUR" {
+			return true
+		}
+	}
+	return false
+}
+
+// Generer remplace les jetons d'un modèle déjà validé. Les segments vides
+// ne laissent pas de tirets doublés ou en bordure.
+func Generer(modele string, d Donnees) string {
+	options := make([]string, 0, len(d.Options))
+	for _, o := range d.Options {
+		if seg := Segment(o); seg != "" {
+			options = append(options, seg)
+		}
+	}
+	resultat := jeton.ReplaceAllStringFunc(modele, func(j string) string {
+		m := jeton.FindStringSubmatch(j)
+		switch m[1] {
+		case "PREFIXE":
+			return Segment(d.Prefixe)
+		case "PRODUIT":
+			return Segment(d.Produit)
+		case "OPTIONS":
+			return strings.Join(options, "-")
+		case "COMPTEUR":
+			largeur, _ := strconv.Atoi(m[2])
+			return fmt.Sprintf("%0*d", largeur, d.Compteur)
+		}
+		return j
+	})
+
+	for strings.Contains(resultat, "--") {
+		resultat = strings.ReplaceAll(resultat, "--", "-")
+	}
+	resultat = strings.Trim(resultat, "-")
+	if len(resultat) > LongueurMax {
+		resultat = strings.TrimRight(resultat[:LongueurMax], "-")
+	}
+	return resultat
+}
+
+// accents ramenés à la lettre de base (majuscule)
+var sansAccent = map[rune]rune{
+	'À': 'A', 'Â': 'A', 'Ä': 'A', 'Á': 'A', 'Ã': 'A', 'Å': 'A',
+	'Ç': 'C', 'È': 'E', 'É': 'E', 'Ê': 'E', 'Ë': 'E',
+	'Ì': 'I', 'Í': 'I', 'Î': 'I', 'Ï': 'I', 'Ñ': 'N',
+	'Ò': 'O', 'Ó': 'O', 'Ô': 'O', 'Ö': 'O', 'Õ': 'O',
+	'Ù': 'U', 'Ú': 'U', 'Û': 'U', 'Ü': 'U', 'Ý': 'Y', 'Ÿ': 'Y',
+}
+
+// Segment normalise un texte libre : majuscules ASCII sans accents, tout
+// autre caractère devient un tiret ("Bleu marine" -> "BLEU-MARINE")
+func Segment(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(s) {
+		if base, ok := sansAccent[r]; ok {
+			r = base
+		}
+		switch {
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r == 'Œ':
+			b.WriteString("OE")
+		case r == 'Æ':
+			b.WriteString("AE")
+		default:
+			b.WriteByte('-')
+		}
+	}
+	resultat := b.String()
+	for strings.Contains(resultat, "--") {
+		resultat = strings.ReplaceAll(resultat, "--", "-")
+	}
+	return strings.Trim(resultat, "-")
+}
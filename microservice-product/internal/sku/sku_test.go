@@ -0,0 +1,84 @@
+package sku
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValider(t *testing.T) {
+	valides := []string{
+		"{PREFIXE}-{PRODUIT}-{OPTIONS}",
+		"TS{COMPTEUR:5}",
+		"{PRODUIT}/{COMPTEUR}",
+	}
+	for _, m := range valides {
+		if err := Valider(m); err != nil {
+			t.Errorf("Valider(%q) = %v, attendu nil", m, err)
+		}
+	}
+
+	invalides := []string{
+		"",
+		"   ",
+		"{INCONNU}",
+		"{PRODUIT:3}",
+		"{COMPTEUR:0}",
+		"{COMPTEUR:13}",
+		"{PRODUIT",
+		"PRODUIT}",
+		"{PRODUIT} {OPTIONS}",
+		"{PRODUIT}-é",
+	}
+	for _, m := range invalides {
+		if err := Valider(m); !errors.Is(err, ErrModele) {
+			t.Errorf("Valider(%q) = %v, attendu ErrModele", m, err)
+		}
+	}
+}
+
+func TestGenerer(t *testing.T) {
+	cas := []struct {
+		modele  string
+		donnees Donnees
+		attendu string
+	}{
+		{
+			"{PREFIXE}-{PRODUIT}-{OPTIONS}",
+			Donnees{Prefixe: "ts", Produit: "t-shirt-col-v", Options: []string{"Bleu marine", "XL"}},
+			"TS-T-SHIRT-COL-V-BLEU-MARINE-XL",
+		},
+		// segments vides : ni tiret doublé ni tiret en bordure
+		{
+			"{PREFIXE}-{PRODUIT}-{OPTIONS}",
+			Donnees{Produit: "polo", Options: []string{"", "Été"}},
+			"POLO-ETE",
+		},
+		{"TS{COMPTEUR:5}", Donnees{Compteur: 42}, "TS00042"},
+		{"{PRODUIT}-{COMPTEUR}", Donnees{Produit: "Cœur", Compteur: 7}, "COEUR-7"},
+	}
+	for _, c := range cas {
+		if obtenu := Generer(c.modele, c.donnees); obtenu != c.attendu {
+			t.Errorf("Generer(%q) = %q, attendu %q", c.modele, obtenu, c.attendu)
+		}
+	}
+}
+
+func TestGenererTronque(t *testing.T) {
+	obtenu := Generer("{PRODUIT}", Donnees{Produit: strings.Repeat("ABC-", 40)})
+	if len(obtenu) > LongueurMax {
+		t.Fatalf("longueur %d, max %d", len(obtenu), LongueurMax)
+	}
+	if strings.HasSuffix(obtenu, "-") {
+		t.Errorf("SKU tronqué terminé par un tiret : %q", obtenu)
+	}
+}
+
+func TestUtiliseCompteur(t *testing.T) {
+	if !UtiliseCompteur("TS{COMPTEUR:5}") {
+		t.Error("TS{COMPTEUR:5} consomme un numéro")
+	}
+	if UtiliseCompteur("{PREFIXE}-{PRODUIT}") {
+		t.Error("{PREFIXE}-{PRODUIT} ne consomme pas de numéro")
+	}
+}
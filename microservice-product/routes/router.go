@@ -1,13 +1,15 @@
 package routes
 
 import (
+	"projet/internal/handler"
 	"projet/internal/routes"
+	"projet/internal/service"
 
 	"github.com/gofiber/fiber/v2"
 	"gorm.io/gorm"
 )
 
-func NewRouter(db *gorm.DB) *fiber.App {
+func NewRouter(db *gorm.DB, webhookService *service.WebhookService, exigerIfMatch bool, secretJWT string) *fiber.App {
 	app := fiber.New()
 
 	app.Get("/health", func(c *fiber.Ctx) error {
@@ -17,8 +19,19 @@ func NewRouter(db *gorm.DB) *fiber.App {
 		})
 	})
 
-	routes.RegisterProduitRoutes(app, db)
+	// groupe de clients (jeton ou X-Groupe-Client) pour l'affichage des prix
+	app.Use(handler.GroupeClient(secretJWT))
+
+	// verrouillage optimiste sur les ressources versionnées du catalogue
+	app.Use([]string{"/produits", "/options", "/valeurs", "/variantes", "/modeles-options"}, handler.ExigerIfMatch(exigerIfMatch))
+
+	routes.RegisterProduitRoutes(app, db, webhookService)
 	routes.RegisterOptionRoutes(app, db)
-	routes.RegisterVarianteRoutes(app, db)
+	routes.RegisterModeleOptionRoutes(app, db)
+	routes.RegisterVarianteRoutes(app, db, webhookService)
+	routes.RegisterCatalogueRoutes(app, db, webhookService)
+	routes.RegisterSKURoutes(app, db, webhookService)
+	routes.RegisterTarifRoutes(app, db)
+	routes.RegisterWebhookRoutes(app, webhookService)
 	return app
 }